@@ -24,9 +24,12 @@ package main
 
 import (
 	"context"
+	"io/ioutil"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
 )
 
 var (
@@ -57,6 +60,46 @@ var (
 		Long: `Activate JWT Token. Token needs to be installed on all instances, at least in passive mode.`,
 	}
 
+	cmdTLS = &cobra.Command{
+		Use:  "tls",
+		Long: `Cluster TLS Management. Requires 3.7+`,
+	}
+
+	cmdTLSRefresh = &cobra.Command{
+		Use:  "refresh",
+		Run:  tlsRefresh,
+		Long: `Replace the TLS keyfile on all starters (if --keyfile is given) and let all members reload it.`,
+	}
+
+	cmdEncryption = &cobra.Command{
+		Use:  "encryption",
+		Long: `Cluster RocksDB encryption key Management. Requires Enterprise 3.7.1+`,
+	}
+
+	cmdEncryptionAdd = &cobra.Command{
+		Use:  "add",
+		Run:  encryptionAdd,
+		Long: `Add the encryption key from --keyfile to the encryption key folder of all starters and let all members reload their keys.`,
+	}
+
+	cmdEncryptionActivate = &cobra.Command{
+		Use:  "activate",
+		Run:  encryptionActivate,
+		Long: `Activate encryption key. Key needs to be installed on all members.`,
+	}
+
+	cmdEncryptionRemove = &cobra.Command{
+		Use:  "remove",
+		Run:  encryptionRemove,
+		Long: `Remove encryption key from all starters. The active key cannot be removed.`,
+	}
+
+	cmdEncryptionRefresh = &cobra.Command{
+		Use:  "refresh",
+		Run:  encryptionRefresh,
+		Long: `Synchronize the encryption key folder of all starters with their members and let all members reload their keys.`,
+	}
+
 	cmdInventoryLocal = &cobra.Command{
 		Use:  "local",
 		Run:  localInventory,
@@ -74,11 +117,17 @@ var (
 	}
 
 	jwtToken string
+
+	tlsKeyFile string
+
+	encryptionKeyFile string
+	encryptionKey     string
 )
 
 func init() {
 	f := cmdAdmin.PersistentFlags()
 	f.StringVar(&adminOptions.starterEndpoint, "starter.endpoint", "http://localhost:8528", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
 
 	cmdMain.AddCommand(cmdAdmin)
 
@@ -92,6 +141,26 @@ func init() {
 
 	cmdJWT.AddCommand(cmdJWTRefresh)
 
+	cmdAdmin.AddCommand(cmdTLS)
+
+	cmdTLSRefresh.Flags().StringVar(&tlsKeyFile, "keyfile", "", "Path of a PEM encoded file containing the new server certificate + private key")
+
+	cmdTLS.AddCommand(cmdTLSRefresh)
+
+	cmdAdmin.AddCommand(cmdEncryption)
+
+	cmdEncryptionAdd.Flags().StringVar(&encryptionKeyFile, "keyfile", "", "Path of a file containing the new encryption key (32 bytes)")
+	cmdEncryptionActivate.Flags().StringVar(&encryptionKey, "key", "", "SHA256 of the encryption key to be activated")
+	cmdEncryptionRemove.Flags().StringVar(&encryptionKey, "key", "", "SHA256 of the encryption key to be removed")
+
+	cmdEncryption.AddCommand(cmdEncryptionAdd)
+
+	cmdEncryption.AddCommand(cmdEncryptionActivate)
+
+	cmdEncryption.AddCommand(cmdEncryptionRemove)
+
+	cmdEncryption.AddCommand(cmdEncryptionRefresh)
+
 	cmdInventory.AddCommand(cmdInventoryLocal)
 
 	cmdInventory.AddCommand(cmdInventoryCluster)
@@ -125,6 +194,16 @@ func localInventory(cmd *cobra.Command, args []string) {
 					log.Info().Msgf("\t\t- %s", j.GetSHA())
 				}
 			}
+			if t := m.Hashes.TLS; t != nil {
+				log.Info().Msgf("\tTLS:")
+				log.Info().Msgf("\t\tKeyfile: %s", t.KeyFile.GetSHA())
+			}
+			if e := m.Hashes.Encryption; e != nil {
+				log.Info().Msgf("\tEncryption:")
+				for _, k := range e.Keys {
+					log.Info().Msgf("\t\t- %s", k.GetSHA())
+				}
+			}
 		}
 	}
 }
@@ -160,6 +239,16 @@ func clusterInventory(cmd *cobra.Command, args []string) {
 						log.Info().Msgf("\t\t\t- %s", j.GetSHA())
 					}
 				}
+				if t := m.Hashes.TLS; t != nil {
+					log.Info().Msgf("\t\tTLS:")
+					log.Info().Msgf("\t\t\tKeyfile: %s", t.KeyFile.GetSHA())
+				}
+				if e := m.Hashes.Encryption; e != nil {
+					log.Info().Msgf("\t\tEncryption:")
+					for _, k := range e.Keys {
+						log.Info().Msgf("\t\t\t- %s", k.GetSHA())
+					}
+				}
 			}
 		}
 	}
@@ -198,3 +287,139 @@ func jwtActivate(cmd *cobra.Command, args []string) {
 
 	log.Info().Msgf("JWT Token %s activated", jwtToken)
 }
+
+func tlsRefresh(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	c := mustCreateStarterClient(adminOptions.starterEndpoint)
+
+	var keyFile []byte
+	if tlsKeyFile != "" {
+		var err error
+		keyFile, err = ioutil.ReadFile(mustExpand(tlsKeyFile))
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Unable to read keyfile %s", tlsKeyFile)
+		}
+		log.Info().Msgf("Replacing TLS keyfile")
+	}
+
+	log.Info().Msgf("Refreshing TLS keyfile")
+
+	if _, err := c.AdminTLSRefresh(ctx, keyFile); err != nil {
+		log.Fatal().Msgf("Error while refreshing TLS keyfile: %s", err.Error())
+	}
+
+	log.Info().Msgf("TLS keyfile refreshed")
+
+	i, err := c.ClusterInventory(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Unable to load inventory")
+	}
+
+	for pn, p := range i.Peers {
+		for n, m := range p.Members {
+			if m.Hashes != nil && m.Hashes.TLS != nil {
+				log.Info().Msgf("Peer %s, Member %s, Keyfile: %s", pn, n.String(), m.Hashes.TLS.KeyFile.GetSHA())
+			}
+		}
+	}
+}
+
+func encryptionAdd(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	c := mustCreateStarterClient(adminOptions.starterEndpoint)
+
+	if encryptionKeyFile == "" {
+		log.Fatal().Msgf("Encryption keyfile not provided")
+	}
+
+	key, err := ioutil.ReadFile(mustExpand(encryptionKeyFile))
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Unable to read encryption keyfile %s", encryptionKeyFile)
+	}
+
+	log.Info().Msgf("Adding encryption key")
+
+	if _, err := c.AdminEncryptionAdd(ctx, key); err != nil {
+		log.Fatal().Msgf("Error while adding encryption key: %s", err.Error())
+	}
+
+	log.Info().Msgf("Encryption key added")
+
+	showEncryptionKeys(ctx, c)
+}
+
+func encryptionActivate(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	c := mustCreateStarterClient(adminOptions.starterEndpoint)
+
+	if encryptionKey == "" {
+		log.Fatal().Msgf("Encryption key not provided")
+	}
+
+	log.Info().Msgf("Activating encryption key")
+
+	if _, err := c.AdminEncryptionActivate(ctx, encryptionKey); err != nil {
+		log.Fatal().Msgf("Error while activating encryption key: %s", err.Error())
+	}
+
+	log.Info().Msgf("Encryption key %s activated", encryptionKey)
+
+	showEncryptionKeys(ctx, c)
+}
+
+func encryptionRemove(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	c := mustCreateStarterClient(adminOptions.starterEndpoint)
+
+	if encryptionKey == "" {
+		log.Fatal().Msgf("Encryption key not provided")
+	}
+
+	log.Info().Msgf("Removing encryption key")
+
+	if _, err := c.AdminEncryptionRemove(ctx, encryptionKey); err != nil {
+		log.Fatal().Msgf("Error while removing encryption key: %s", err.Error())
+	}
+
+	log.Info().Msgf("Encryption key %s removed", encryptionKey)
+
+	showEncryptionKeys(ctx, c)
+}
+
+func encryptionRefresh(cmd *cobra.Command, args []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	c := mustCreateStarterClient(adminOptions.starterEndpoint)
+
+	log.Info().Msgf("Refreshing encryption keys")
+
+	if _, err := c.AdminEncryptionRefresh(ctx); err != nil {
+		log.Fatal().Msgf("Error while refreshing encryption keys: %s", err.Error())
+	}
+
+	log.Info().Msgf("Encryption keys refreshed")
+
+	showEncryptionKeys(ctx, c)
+}
+
+// showEncryptionKeys logs the SHAs of the encryption keys loaded by all members in the cluster.
+func showEncryptionKeys(ctx context.Context, c client.API) {
+	i, err := c.ClusterInventory(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Unable to load inventory")
+	}
+
+	for pn, p := range i.Peers {
+		for n, m := range p.Members {
+			if m.Hashes != nil && m.Hashes.Encryption != nil {
+				for _, k := range m.Hashes.Encryption.Keys {
+					log.Info().Msgf("Peer %s, Member %s, Encryption key: %s", pn, n.String(), k.GetSHA())
+				}
+			}
+		}
+	}
+}
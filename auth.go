@@ -60,6 +60,9 @@ var (
 
 		fieldsOverride    []string
 		fieldsOverrideMap map[string]interface{}
+
+		starterRole       string
+		parsedStarterRole service.StarterRole
 	}
 )
 
@@ -74,6 +77,7 @@ func init() {
 	pf.StringSliceVar(&authOptions.paths, "auth.paths", nil, "a list of allowed pathes. The path must not include the '_db/DBNAME' prefix.")
 	pf.StringVar(&authOptions.exp, "auth.exp", "", "a time in which token should expire - based on current time in UTC. Supported units: h, m, s (default)")
 	pf.StringSliceVar(&authOptions.fieldsOverride, "auth.fields", nil, "a list of additional fields set in the token. This flags override one auto-generated in token")
+	pf.StringVar(&authOptions.starterRole, "auth.starter-role", "", "role of the token for the starter API (read-only|admin), requires --auth.exp. See --auth.starter-api")
 }
 
 // mustAuthCreateJWTToken creates a the JWT token based on authentication options.
@@ -89,6 +93,13 @@ func mustAuthCreateJWTToken() string {
 		log.Fatal().Err(err).Msgf("Failed to read JWT secret file '%s'", authOptions.jwtSecretFile)
 	}
 	jwtSecret := strings.TrimSpace(string(content))
+	if authOptions.parsedStarterRole != "" {
+		token, err := service.CreateStarterToken(jwtSecret, authOptions.user, authOptions.parsedStarterRole, authOptions.expDuration)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create starter API token")
+		}
+		return token
+	}
 	token, err := service.CreateJwtToken(jwtSecret, authOptions.user, "", authOptions.paths, authOptions.expDuration, authOptions.fieldsOverrideMap)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create JWT token")
@@ -150,6 +161,20 @@ func persistentAuthPreFunE(cmd *cobra.Command, args []string) error {
 		authOptions.fieldsOverrideMap[key] = calculatedValue
 	}
 
+	if authOptions.starterRole != "" {
+		role, err := service.ParseStarterRole(authOptions.starterRole)
+		if err != nil {
+			return err
+		}
+		if authOptions.expDuration <= 0 {
+			return fmt.Errorf("--auth.exp is required with --auth.starter-role")
+		}
+		if len(authOptions.paths) > 0 || len(authOptions.fieldsOverride) > 0 {
+			return fmt.Errorf("--auth.paths and --auth.fields are not allowed with --auth.starter-role")
+		}
+		authOptions.parsedStarterRole = role
+	}
+
 	return nil
 }
 
@@ -0,0 +1,195 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/service"
+)
+
+var (
+	cmdCheck = &cobra.Command{
+		Use:   "check",
+		Short: "Check the configuration of the starter without starting any server",
+		Long: "Check the configuration of the starter without starting any server.\n" +
+			"It accepts the same options as running the starter and exits with code 1 when a check fails.",
+		Run: cmdCheckRun,
+		// Forbidden options are not known as flags, they are reported by the check
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	}
+	// forbiddenArgs holds the forbidden options given to `arangodb check`
+	forbiddenArgs []string
+)
+
+func init() {
+	cmdMain.AddCommand(cmdCheck)
+}
+
+// isCheckCommand returns true if the given command line runs `arangodb check`.
+func isCheckCommand(args []string) bool {
+	return len(args) > 1 && args[1] == cmdCheck.Use
+}
+
+// withoutArgs returns the given arguments, leaving out the given options (with their value).
+func withoutArgs(args, options []string) []string {
+	var result []string
+	for _, arg := range args {
+		if !containsString(options, strings.SplitN(arg, "=", 2)[0]) {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// withoutConfigFileOptions returns the given configuration file options, leaving out the given options.
+func withoutConfigFileOptions(list []configFileOption, options []string) []configFileOption {
+	var result []configFileOption
+	for _, o := range list {
+		if !containsString(options, "--"+o.Name) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// containsString returns true if the given list contains the given value.
+func containsString(list []string, value string) bool {
+	for _, x := range list {
+		if x == value {
+			return true
+		}
+	}
+	return false
+}
+
+func cmdCheckRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	if len(args) > 0 {
+		log.Fatal().Msgf("Expected no arguments, got %q", args)
+	}
+
+	// Run the checks that would otherwise stop the preparation of the service
+	results := preflightChecks()
+	passed := true
+	for _, r := range results {
+		passed = passed && r.Passed
+	}
+
+	if passed {
+		// Create service (without launching or creating anything)
+		svc, bsCfg := mustPrepareService(true)
+
+		// Read setup.json (if exists)
+		bsCfg, peers, relaunch, _ := service.ReadSetupConfig(log, dataDir, bsCfg)
+
+		results = append(results, svc.Check(context.Background(), bsCfg, peers, relaunch)...)
+	} else {
+		results = append(results, service.FailedCheck("service", "Skipped, fix the failures above first"))
+	}
+
+	// Show report
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		state := "PASS"
+		if !r.Passed {
+			state = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", state, r.Name, r.Message)
+	}
+	w.Flush()
+	fmt.Printf("%d checks passed, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// preflightChecks checks the options & files that are needed to prepare the service.
+func preflightChecks() []service.CheckResult {
+	var results []service.CheckResult
+
+	// Check options
+	if len(forbiddenArgs) > 0 {
+		results = append(results, service.FailedCheck("options", "Options %s are essential to the starters behavior and cannot be overwritten", strings.Join(forbiddenArgs, ", ")))
+	} else {
+		results = append(results, service.PassedCheck("options", "No forbidden options"))
+	}
+
+	// Check JWT secret
+	if jwtSecretFile != "" {
+		path := mustExpand(jwtSecretFile)
+		if content, err := ioutil.ReadFile(path); err != nil {
+			results = append(results, service.FailedCheck("jwt-secret", "Cannot read JWT secret file '%s': %s", path, err))
+		} else if strings.TrimSpace(string(content)) == "" {
+			results = append(results, service.FailedCheck("jwt-secret", "JWT secret file '%s' is empty", path))
+		} else {
+			results = append(results, service.PassedCheck("jwt-secret", "JWT secret file '%s' can be read", path))
+		}
+	}
+
+	// Check consistency of the options
+	jwtSecret, _ := readJWTSecret()
+	if errs := validateConfiguration(jwtSecret); len(errs) > 0 {
+		for _, err := range errs {
+			results = append(results, service.FailedCheck("configuration", "%s", err))
+		}
+	} else {
+		results = append(results, service.PassedCheck("configuration", "Options are consistent"))
+	}
+
+	// Check executables
+	if isRunningInDocker() {
+		if dockerContainerName == "" {
+			if _, err := findDockerContainerInfo(dockerEndpoint); err != nil {
+				results = append(results, service.FailedCheck("docker-container", "Cannot find docker container name, use --docker.container: %s", err))
+			}
+		}
+	} else {
+		path := mustExpand(arangodPath)
+		if _, err := os.Stat(path); err != nil {
+			results = append(results, service.FailedCheck("arangod-executable", "Cannot find `arangod` at '%s': %s", path, err))
+		} else {
+			results = append(results, service.PassedCheck("arangod-executable", "Found `arangod` at '%s'", path))
+		}
+		if enableSync {
+			path := mustExpand(arangoSyncPath)
+			if _, err := os.Stat(path); err != nil {
+				results = append(results, service.FailedCheck("arangosync-executable", "Cannot find `arangosync` at '%s': %s", path, err))
+			} else {
+				results = append(results, service.PassedCheck("arangosync-executable", "Found `arangosync` at '%s'", path))
+			}
+		}
+	}
+
+	return results
+}
@@ -25,6 +25,9 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/url"
+	"time"
 
 	"github.com/arangodb-helper/arangodb/pkg/api"
 
@@ -45,6 +48,11 @@ type API interface {
 	// used by this starter.
 	DatabaseVersion(ctx context.Context) (driver.Version, error)
 
+	// CheckArangodBinaryLocal lets the starter check the given arangod executable (or docker image)
+	// it would switch to for an upgrade.
+	// It returns the database version of the target.
+	CheckArangodBinaryLocal(ctx context.Context, target UpgradeTarget) (SwitchArangodBinaryResult, error)
+
 	// Processes loads information of all the database server processes launched by the starter.
 	Processes(ctx context.Context) (ProcessList, error)
 
@@ -61,9 +69,38 @@ type API interface {
 	// unless force is set to true.
 	RemovePeer(ctx context.Context, id string, force bool) error
 
+	// RemovePeerAsync starts the removal of the peer with given ID from the starter cluster
+	// and returns as soon as it has been started.
+	// Use PeerRemovalStatus to follow its progress.
+	RemovePeerAsync(ctx context.Context, id string, force bool) error
+
+	// PeerRemovalStatus returns the status of the last removal of the peer with given ID.
+	// If that peer has not been removed, a NotFoundError will be returned.
+	PeerRemovalStatus(ctx context.Context, id string) (PeerRemovalStatus, error)
+
+	// SetPeerRoles changes the servers started by the peer with given ID.
+	// Roles that are not set in the given roles are left unchanged.
+	// A dbserver that is no longer wanted is cleaned out first.
+	// Servers that are no longer wanted are removed from the cluster and stopped.
+	SetPeerRoles(ctx context.Context, id string, roles PeerRoles) error
+
+	// ApplyPeerRolesLocal lets the starter take the given roles, starting the servers
+	// of the roles it gains and removing the servers of the roles it loses from the cluster.
+	ApplyPeerRolesLocal(ctx context.Context, roles PeerRoles) error
+
 	// StartDatabaseUpgrade is called to start the upgrade process
 	StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool) error
 
+	// StartDatabaseUpgradeTo is called to start the upgrade process after all starters
+	// have switched to the given arangod executable (or docker image).
+	// TriggeredBy describes who starts the upgrade, it is kept in the upgrade history
+	// unless the starter API requires a token (then the token is described instead).
+	StartDatabaseUpgradeTo(ctx context.Context, forceMinorUpgrade bool, target UpgradeTarget, triggeredBy string) error
+
+	// DryRunDatabaseUpgrade runs all checks of StartDatabaseUpgradeTo and returns the
+	// plan it would create, without changing anything.
+	DryRunDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, target UpgradeTarget) (UpgradeDryRun, error)
+
 	// RetryDatabaseUpgrade resets a failure mark in the existing upgrade plan
 	// such that the starters will retry the upgrade once more.
 	RetryDatabaseUpgrade(ctx context.Context) error
@@ -74,16 +111,103 @@ type API interface {
 	// If there is no plan, a NotFoundError will be returned.
 	AbortDatabaseUpgrade(ctx context.Context) error
 
+	// RollbackDatabaseUpgrade restarts all servers that have already been upgraded
+	// with the binary (or docker image) they used before the upgrade.
+	// If there is no plan, a NotFoundError will be returned.
+	RollbackDatabaseUpgrade(ctx context.Context) error
+
+	// PauseDatabaseUpgrade pauses the existing upgrade plan.
+	// Note that Starters working on an entry of the upgrade
+	// will finish that entry.
+	// If there is no plan, a NotFoundError will be returned.
+	PauseDatabaseUpgrade(ctx context.Context) error
+
+	// ResumeDatabaseUpgrade resumes the existing paused upgrade plan.
+	// If there is no plan, a NotFoundError will be returned.
+	ResumeDatabaseUpgrade(ctx context.Context) error
+
 	// Status returns the status of any upgrade plan
 	UpgradeStatus(context.Context) (UpgradeStatus, error)
 
+	// UpgradeHistory returns the finished, aborted & rolled back upgrade plans, oldest first.
+	UpgradeHistory(context.Context) (UpgradeHistory, error)
+
+	// StartDatabaseRestart is called to start a rolling restart of all servers of the given type
+	// (or all arangod servers if the type is RestartTypeAll).
+	StartDatabaseRestart(ctx context.Context, serverType ServerType) error
+
+	// RetryDatabaseRestart resets a failure mark in the existing restart plan
+	// such that the starters will retry the restart once more.
+	RetryDatabaseRestart(ctx context.Context) error
+
+	// AbortDatabaseRestart removes the existing restart plan.
+	// Note that Starters working on an entry of the restart
+	// will finish that entry.
+	// If there is no plan, a NotFoundError will be returned.
+	AbortDatabaseRestart(ctx context.Context) error
+
+	// RestartStatus returns the status of any restart plan
+	RestartStatus(context.Context) (RestartStatus, error)
+
 	Inventory(ctx context.Context) (api.Inventory, error)
 
 	ClusterInventory(ctx context.Context) (api.ClusterInventory, error)
 
+	// ClusterStatus returns all starters of the cluster with the servers they start
+	// and the health of those servers as known to the agency.
+	ClusterStatus(ctx context.Context) (ClusterStatus, error)
+
 	AdminJWTRefresh(ctx context.Context) (api.Empty, error)
 
 	AdminJWTActivate(ctx context.Context, token string) (api.Empty, error)
+
+	// AdminTLSRefresh replaces the TLS keyfile on all peers with the given keyfile (if not empty)
+	// and lets all servers reload it.
+	AdminTLSRefresh(ctx context.Context, keyFile []byte) (api.Empty, error)
+
+	// AdminTLSRefreshLocal replaces the TLS keyfile of the starter with the given keyfile (if not empty)
+	// and lets all servers of that starter reload it.
+	AdminTLSRefreshLocal(ctx context.Context, keyFile []byte) (api.Empty, error)
+
+	// AdminEncryptionAdd adds the given RocksDB encryption key on all peers.
+	AdminEncryptionAdd(ctx context.Context, key []byte) (api.Empty, error)
+
+	// AdminEncryptionActivate makes the RocksDB encryption key with given SHA the active key on all peers.
+	AdminEncryptionActivate(ctx context.Context, sha string) (api.Empty, error)
+
+	// AdminEncryptionRemove removes the (inactive) RocksDB encryption key with given SHA on all peers.
+	AdminEncryptionRemove(ctx context.Context, sha string) (api.Empty, error)
+
+	// AdminEncryptionRefresh lets all servers of all peers reload their RocksDB encryption keys.
+	AdminEncryptionRefresh(ctx context.Context) (api.Empty, error)
+
+	// AdminEncryptionLocal performs the given action (add|activate|remove|refresh) on the RocksDB
+	// encryption keys of the starter only.
+	AdminEncryptionLocal(ctx context.Context, action string, key []byte, sha string) (api.Empty, error)
+
+	// EncryptionKeysLocal returns the SHAs of the RocksDB encryption keys of the starter only.
+	EncryptionKeysLocal(ctx context.Context) (api.EncryptionKeys, error)
+
+	// SyncHealthLocal checks the health of the arangosync servers started by the starter only.
+	// It returns an error when one of them is unhealthy or synchronization is broken.
+	SyncHealthLocal(ctx context.Context) (api.SyncHealth, error)
+
+	// Logs returns a reader for the log of the server of given type.
+	// The caller must close the returned reader.
+	Logs(ctx context.Context, serverType ServerType, opts LogsOptions) (io.ReadCloser, error)
+
+	// ClusterLogs returns the logs of the servers of all starters in the cluster, merged by timestamp.
+	// If serverType is empty, the logs of servers of all types are returned.
+	// Following is not supported for cluster logs.
+	ClusterLogs(ctx context.Context, serverType ServerType, opts LogsOptions) (api.ClusterLogs, error)
+}
+
+// LogsOptions holds the options of a logs request.
+type LogsOptions struct {
+	Tail   int       // If > 0, only the last Tail lines are returned
+	Since  time.Time // If set, only lines logged at or after this time are returned
+	Level  string    // If set, only lines with this level or more severe are returned (fatal|error|warning|info|debug|trace)
+	Follow bool      // If set, the returned reader keeps returning new lines until the context is canceled
 }
 
 // IDInfo contains the ID of the starter
@@ -102,6 +226,148 @@ type DatabaseVersionResponse struct {
 	Version driver.Version `json:"version"`
 }
 
+// ClusterStatus is the JSON response of a `/cluster/status` request.
+type ClusterStatus struct {
+	// Mode of the cluster (cluster|activefailover|single)
+	Mode string `json:"mode"`
+	// MasterID is the ID of the starter that is the current master
+	MasterID string `json:"master_id,omitempty"`
+	// Peers contains all starters of the cluster
+	Peers []PeerStatus `json:"peers"`
+	// HealthError is set when the health of the servers cannot be read from the agency
+	HealthError string `json:"health_error,omitempty"`
+}
+
+// PeerStatus is the status of a single starter of the cluster.
+type PeerStatus struct {
+	// ID of the starter
+	ID string `json:"id"`
+	// Address of the starter (IP or hostname)
+	Address string `json:"address"`
+	// Port of the starter (including its port offset)
+	Port int `json:"port"`
+	// Endpoint is the URL of the starter
+	Endpoint string `json:"endpoint"`
+	// IsMaster is set for the starter that is the current master
+	IsMaster bool `json:"is_master,omitempty"`
+	// Servers contains all servers started by the starter
+	Servers []ServerStatus `json:"servers"`
+	// Error is set when the starter cannot be reached (set by `arangodb status`)
+	Error string `json:"error,omitempty"`
+}
+
+// ServerStatus is the status of a single server of the cluster.
+type ServerStatus struct {
+	// Type of the server
+	Type ServerType `json:"type"`
+	// Address of the server (IP or hostname)
+	Address string `json:"address"`
+	// Port the server is listening on
+	Port int `json:"port"`
+	// ID of the server in the agency (if known)
+	ID string `json:"id,omitempty"`
+	// ShortName of the server in the agency (if known)
+	ShortName string `json:"short_name,omitempty"`
+	// Health of the server according to the agency (GOOD|BAD|FAILED), or UNKNOWN
+	Health string `json:"health,omitempty"`
+	// Leader is set for the leader of the agency and the leader of an active failover deployment
+	Leader bool `json:"leader,omitempty"`
+
+	// The following fields are set by `arangodb status` from the starter of the server.
+
+	// Up is set when the process of the server is running and the server is up with the correct role
+	Up bool `json:"up"`
+	// Version of the server
+	Version driver.Version `json:"version,omitempty"`
+	// ProcessID of the server (0 when running in docker)
+	ProcessID int `json:"pid,omitempty"`
+	// ContainerID of the docker container running the server
+	ContainerID string `json:"container_id,omitempty"`
+	// Restarts is the number of times the server has been restarted
+	Restarts int `json:"restarts,omitempty"`
+	// Failed is set when the starter has given up restarting the server
+	Failed bool `json:"failed,omitempty"`
+	// Upgrade is the state of the server in the current upgrade plan (if any)
+	Upgrade string `json:"upgrade,omitempty"`
+}
+
+// PeerRemovalState is the state of the removal of a starter from the cluster.
+type PeerRemovalState string
+
+const (
+	// PeerRemovalStateCleaningOut indicates that all shards are being moved away from the dbserver of the starter
+	PeerRemovalStateCleaningOut PeerRemovalState = "cleaning-out"
+	// PeerRemovalStateShuttingDown indicates that the servers of the starter are being removed from the cluster
+	PeerRemovalStateShuttingDown PeerRemovalState = "shutting-down"
+	// PeerRemovalStateFinished indicates that the starter has been removed
+	PeerRemovalStateFinished PeerRemovalState = "finished"
+	// PeerRemovalStateFailed indicates that the removal of the starter has failed
+	PeerRemovalStateFailed PeerRemovalState = "failed"
+)
+
+// PeerRemovalStatus is the JSON response of a `/peer/removal` request.
+type PeerRemovalStatus struct {
+	// ID of the starter that is removed
+	ID string `json:"id"`
+	// State of the removal
+	State PeerRemovalState `json:"state"`
+	// JobID is the ID of the agency job that cleans out the dbserver of the starter
+	JobID string `json:"job_id,omitempty"`
+	// JobState is the state of that job (ToDo|Pending|Finished|Failed)
+	JobState string `json:"job_state,omitempty"`
+	// ShardsTotal is the number of shards on the dbserver when its cleanout started
+	ShardsTotal int `json:"shards_total,omitempty"`
+	// ShardsRemaining is the number of shards that have not yet been moved away from the dbserver
+	ShardsRemaining int `json:"shards_remaining,omitempty"`
+	// Progress contains the last progress message
+	Progress string `json:"progress,omitempty"`
+	// Error contains the reason of a failed removal
+	Error string `json:"error,omitempty"`
+	// StartedAt is the time the removal was started
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is the time the removal has finished (or failed)
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// IsDone returns true when the removal has finished or failed.
+func (s PeerRemovalStatus) IsDone() bool {
+	return s.State == PeerRemovalStateFinished || s.State == PeerRemovalStateFailed
+}
+
+// PeerRoles holds the roles of a starter in the cluster.
+// When changing roles, roles that are nil are left unchanged.
+type PeerRoles struct {
+	Agent       *bool `json:"agent,omitempty"`
+	DBServer    *bool `json:"dbserver,omitempty"`
+	Coordinator *bool `json:"coordinator,omitempty"`
+}
+
+// SetPeerRolesRequest is the JSON structure send in the request to /peer/roles.
+type SetPeerRolesRequest struct {
+	ID string `json:"id"` // Unique ID of the peer whose roles are changed
+	PeerRoles
+}
+
+// HealthResponse is the JSON response of a `/health/live` or `/health/ready` request.
+type HealthResponse struct {
+	// OK is set when all checks succeeded
+	OK bool `json:"ok"`
+	// State contains the state of the starter (e.g. bootstrap-master, running-slave)
+	State string `json:"state"`
+	// Checks contains the outcome of every check
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the outcome of a single check of a `/health/live` or `/health/ready` request.
+type HealthCheck struct {
+	// Name of the check
+	Name string `json:"name"`
+	// OK is set when the check succeeded
+	OK bool `json:"ok"`
+	// Message describes why the check failed (or additional information)
+	Message string `json:"message,omitempty"`
+}
+
 // EndpointList is the JSON response of a `/endpoints` request.
 // It contains URL's of all starters, agents & coordinators in the cluster.
 type EndpointList struct {
@@ -137,6 +403,11 @@ type ServerProcess struct {
 	ContainerID string     `json:"container-id,omitempty"` // ID of docker container running the server
 	ContainerIP string     `json:"container-ip,omitempty"` // IP address of docker container running the server
 	IsSecure    bool       `json:"is-secure,omitempty"`    // If set, this server is using an SSL connection
+	Restarts    int        `json:"restarts,omitempty"`     // Number of times the server has been restarted
+	Failures    int        `json:"failures,omitempty"`     // Number of recent failures of the server (within the failure window of the restart policy)
+	Failed      bool       `json:"failed,omitempty"`       // If set, the starter has given up restarting the server
+	Running     bool       `json:"running,omitempty"`      // If set, the process of the server is running
+	Up          bool       `json:"up,omitempty"`           // If set, the server has been detected to be up with the correct role
 }
 
 // ServerByType returns the server of given type.
@@ -166,6 +437,153 @@ type UpgradeStatus struct {
 	// ServersUpgraded contains the servers that have been upgraded
 	ServersUpgraded []UpgradeStatusServer `json:"servers_upgraded"`
 	// ServersRemaining contains the servers that have not yet been upgraded
+	// (or rolled back when Rollback is set)
+	ServersRemaining []UpgradeStatusServer `json:"servers_remaining"`
+	// Paused is set to true when the upgrade has been paused.
+	// No further servers are upgraded until it is resumed.
+	Paused bool `json:"paused,omitempty"`
+	// Rollback is set to true when the upgrade is being rolled back.
+	Rollback bool `json:"rollback,omitempty"`
+	// ServersRolledBack contains the servers that have been rolled back to their previous version
+	ServersRolledBack []UpgradeStatusServer `json:"servers_rolled_back,omitempty"`
+}
+
+// UpgradeOutcome describes how an upgrade plan in the upgrade history has ended.
+type UpgradeOutcome string
+
+const (
+	UpgradeOutcomeFinished   UpgradeOutcome = "finished"    // All servers have been upgraded
+	UpgradeOutcomeFailed     UpgradeOutcome = "failed"      // The upgrade failed (single server only)
+	UpgradeOutcomeAborted    UpgradeOutcome = "aborted"     // The upgrade plan was removed before it finished
+	UpgradeOutcomeRolledBack UpgradeOutcome = "rolled-back" // The upgraded servers have been rolled back
+)
+
+// UpgradeHistory is the JSON structure returns from a `GET /database-auto-upgrade/history`
+// request.
+type UpgradeHistory struct {
+	// Plans contains the archived upgrade plans, oldest first.
+	Plans []UpgradeHistoryPlan `json:"plans"`
+}
+
+// UpgradeHistoryPlan is the nested JSON structure returns from a `GET /database-auto-upgrade/history`
+// request.
+type UpgradeHistoryPlan struct {
+	// ID of the plan (derived from its creation time)
+	ID string `json:"id"`
+	// Outcome describes how the plan has ended
+	Outcome UpgradeOutcome `json:"outcome"`
+	// TriggeredBy describes who started the upgrade
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	// CreatedAt contains the time the upgrade was started
+	CreatedAt time.Time `json:"created_at"`
+	// ArchivedAt contains the time the plan has ended
+	ArchivedAt time.Time `json:"archived_at"`
+	// FromVersions contains all database versions found that were upgraded.
+	FromVersions []driver.Version `json:"from_versions"`
+	// ToVersion contains the database version that was upgraded to.
+	ToVersion driver.Version `json:"to_version"`
+	// Entries contains all servers of the plan, in upgrade order
+	Entries []UpgradeHistoryEntry `json:"entries"`
+}
+
+// UpgradeHistoryEntry is the nested JSON structure returns from a `GET /database-auto-upgrade/history`
+// request.
+type UpgradeHistoryEntry struct {
+	UpgradeStatusServer
+	// PeerID contains the ID of the starter that upgraded the server
+	PeerID string `json:"peer_id"`
+	// Upgraded is set to true when the server has been upgraded
+	Upgraded bool `json:"upgraded"`
+	// RolledBack is set to true when the server has been rolled back to its previous version
+	RolledBack bool `json:"rolled_back,omitempty"`
+	// Failures contains the reasons of all failed upgrade attempts of the server
+	Failures []string `json:"failures,omitempty"`
+}
+
+// UpgradeTarget describes the arangod executable (or docker image) that all starters
+// switch to before the upgrade is started.
+type UpgradeTarget struct {
+	// ArangodPath contains the path of the arangod executable (process runner only)
+	ArangodPath string `json:"arangod_path,omitempty"`
+	// Image contains the docker image containing arangod (docker runner only)
+	Image string `json:"image,omitempty"`
+}
+
+// IsEmpty returns true when no executable & no image is set.
+func (t UpgradeTarget) IsEmpty() bool {
+	return t.ArangodPath == "" && t.Image == ""
+}
+
+// addQuery adds the target to the given query parameters.
+func (t UpgradeTarget) addQuery(q url.Values) {
+	if t.ArangodPath != "" {
+		q.Set("arangod", t.ArangodPath)
+	}
+	if t.Image != "" {
+		q.Set("image", t.Image)
+	}
+}
+
+// UpgradeTargetFromQuery returns the target described by the given query parameters.
+func UpgradeTargetFromQuery(q url.Values) UpgradeTarget {
+	return UpgradeTarget{
+		ArangodPath: q.Get("arangod"),
+		Image:       q.Get("image"),
+	}
+}
+
+// SwitchArangodBinaryResult is the JSON structure returns from a `POST /local/upgrade/binary`
+// request.
+type SwitchArangodBinaryResult struct {
+	// Version contains the database version of the target
+	Version driver.Version `json:"version"`
+	// Previous contains the target used before the switch (empty when the configured executable was used)
+	Previous UpgradeTarget `json:"previous"`
+}
+
+// UpgradeDryRun is the JSON structure returns from a `GET /database-auto-upgrade/dry-run`
+// request.
+type UpgradeDryRun struct {
+	// FromVersions contains all database versions found that will be upgraded.
+	FromVersions []driver.Version `json:"from_versions"`
+	// ToVersion contains the database version that will be upgraded to.
+	ToVersion driver.Version `json:"to_version"`
+	// SupervisionMaintenance is set to true when the agency supervision will be put
+	// into maintenance mode while upgrading dbservers & single servers.
+	SupervisionMaintenance bool `json:"supervision_maintenance"`
+	// Entries contains the servers in the order they will be upgraded
+	Entries []UpgradeDryRunEntry `json:"entries"`
+	// Restarts contains the estimated number of server restarts of the entire upgrade
+	Restarts int `json:"restarts"`
+}
+
+// UpgradeDryRunEntry is the nested JSON structure returns from a `GET /database-auto-upgrade/dry-run`
+// request.
+type UpgradeDryRunEntry struct {
+	UpgradeStatusServer
+	// PeerID contains the ID of the starter that will upgrade the server
+	PeerID string `json:"peer_id"`
+	// Restarts contains the estimated number of restarts of the server
+	Restarts int `json:"restarts"`
+}
+
+// RestartTypeAll is used to restart all arangod servers with StartDatabaseRestart.
+const RestartTypeAll = ServerType("all")
+
+// RestartStatus is the JSON structure returns from a `GET /database-restart`
+// request.
+type RestartStatus struct {
+	// Ready is set to true when the entire restart has been finished succesfully.
+	Ready bool `json:"ready"`
+	// Failed is set to true when the restart process has yielded an error
+	Failed bool `json:"failed"`
+	// Reasons contains a human readable description of the state
+	Reason string `json:"reason,omitempty"`
+	// ServerType contains the type of servers that are restarted (or RestartTypeAll)
+	ServerType ServerType `json:"server_type"`
+	// ServersRestarted contains the servers that have been restarted
+	ServersRestarted []UpgradeStatusServer `json:"servers_restarted"`
+	// ServersRemaining contains the servers that have not yet been restarted
 	ServersRemaining []UpgradeStatusServer `json:"servers_remaining"`
 }
 
@@ -178,8 +596,27 @@ type UpgradeStatusServer struct {
 	Port int `json:"port"`
 	// Address of the server (IP or hostname)
 	Address string `json:"address"`
+	// StartedAt contains the time the upgrade (or rollback) of the server started
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// FinishedAt contains the time the upgrade (or rollback) of the server finished
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Phase contains the step the upgrade (or rollback) of the server is in.
+	// It is only set for the server that is being upgraded.
+	Phase UpgradePhase `json:"phase,omitempty"`
+	// Progress contains the last progress message of the upgrade (or rollback) of the server
+	Progress string `json:"progress,omitempty"`
 }
 
+// UpgradePhase describes the step an upgrade plan entry is in.
+type UpgradePhase string
+
+const (
+	UpgradePhaseStopping         UpgradePhase = "stopping"           // The server is being stopped
+	UpgradePhaseAutoUpgrade      UpgradePhase = "auto-upgrade"       // The server runs with --database.auto-upgrade=true
+	UpgradePhaseRestarting       UpgradePhase = "restarting"         // The server is being started with its normal arguments
+	UpgradePhaseWaitingForHealth UpgradePhase = "waiting-for-health" // Waiting until the deployment is healthy again
+)
+
 // GetEndpoint return address endpoint to the server.
 func (s *ServerProcess) GetEndpoint() string {
 	if s.IsSecure {
@@ -0,0 +1,150 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// PeerTokenIssuer is the issuer of bearer tokens for the starter-to-starter API.
+	PeerTokenIssuer = "arangodb-starter"
+
+	peerTokenValidFor = time.Minute
+)
+
+// ClientOption configures how a client connects to a starter.
+type ClientOption func(o *clientOptions)
+
+// TokenSource returns the bearer token used to authenticate a request.
+type TokenSource func() (string, error)
+
+type clientOptions struct {
+	httpClient  *http.Client
+	tlsConfig   *tls.Config
+	tokenSource TokenSource
+}
+
+// WithHTTPClient lets the client use the given HTTP client (e.g. created using NewHTTPClient).
+// All other options are ignored.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(o *clientOptions) {
+		o.httpClient = c
+	}
+}
+
+// WithTLSConfig lets the client use the given TLS configuration,
+// e.g. to present a client certificate and/or verify the certificate of the starter.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithPeerKey lets the client authenticate every request with a short-lived
+// bearer token signed with the given key (see PeerKey).
+func WithPeerKey(key []byte) ClientOption {
+	return WithTokenSource(func() (string, error) {
+		return CreatePeerToken(key)
+	})
+}
+
+// WithBearerToken lets the client authenticate every request with the given bearer token.
+func WithBearerToken(token string) ClientOption {
+	return WithTokenSource(func() (string, error) {
+		return token, nil
+	})
+}
+
+// WithTokenSource lets the client authenticate every request with a bearer token
+// returned by the given source.
+func WithTokenSource(source TokenSource) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenSource = source
+	}
+}
+
+// PeerKey derives the key used to sign bearer tokens for the starter-to-starter API
+// from the given cluster secret, so these tokens cannot be used to authenticate with the servers.
+func PeerKey(secret []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(PeerTokenIssuer))
+	return h.Sum(nil)
+}
+
+// CreatePeerToken creates a short-lived bearer token for the starter-to-starter API,
+// signed with the given key.
+func CreatePeerToken(key []byte) (string, error) {
+	t := time.Now().UTC()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": PeerTokenIssuer,
+		"iat": t.Unix(),
+		"exp": t.Add(peerTokenValidFor).Unix(),
+	})
+	signedToken, err := token.SignedString(key)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return signedToken, nil
+}
+
+// NewHTTPClient creates a new HTTP client configured for accessing a starter with the given options.
+func NewHTTPClient(opts ...ClientOption) *http.Client {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := DefaultHTTPClient()
+	if o.tlsConfig != nil {
+		c.Transport.(*http.Transport).TLSClientConfig = o.tlsConfig
+	}
+	if o.tokenSource != nil {
+		c.Transport = &tokenTransport{
+			base:   c.Transport,
+			source: o.tokenSource,
+		}
+	}
+	return c
+}
+
+// tokenTransport adds a bearer token to every request.
+type tokenTransport struct {
+	base   http.RoundTripper
+	source TokenSource
+}
+
+// RoundTrip executes a single HTTP transaction with an added Authorization header.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "bearer "+token)
+	return t.base.RoundTrip(req)
+}
@@ -26,9 +26,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/arangodb-helper/arangodb/pkg/api"
@@ -38,11 +40,24 @@ import (
 )
 
 // NewArangoStarterClient creates a new client implementation.
-func NewArangoStarterClient(endpoint url.URL) (API, error) {
+// Use options to authenticate with the starter-to-starter API.
+func NewArangoStarterClient(endpoint url.URL, opts ...ClientOption) (API, error) {
 	endpoint.Path = ""
+	httpClient := shardHTTPClient
+	if len(opts) > 0 {
+		var o clientOptions
+		for _, opt := range opts {
+			opt(&o)
+		}
+		if o.httpClient != nil {
+			httpClient = o.httpClient
+		} else {
+			httpClient = NewHTTPClient(opts...)
+		}
+	}
 	return &client{
 		endpoint: endpoint,
-		client:   shardHTTPClient,
+		client:   httpClient,
 	}, nil
 }
 
@@ -103,6 +118,136 @@ func (c *client) AdminJWTRefresh(ctx context.Context) (api.Empty, error) {
 	return result, nil
 }
 
+func (c *client) AdminTLSRefresh(ctx context.Context, keyFile []byte) (api.Empty, error) {
+	return c.adminTLSRefresh(ctx, "/admin/tls/refresh", keyFile)
+}
+
+func (c *client) AdminTLSRefreshLocal(ctx context.Context, keyFile []byte) (api.Empty, error) {
+	return c.adminTLSRefresh(ctx, "/local/tls/refresh", keyFile)
+}
+
+func (c *client) adminTLSRefresh(ctx context.Context, path string, keyFile []byte) (api.Empty, error) {
+	url := c.createURL(path, nil)
+
+	var result api.Empty
+	req, err := http.NewRequest("POST", url, bytes.NewReader(keyFile))
+	if err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	// Refreshing all servers of all peers can take longer than the default timeout.
+	refreshClient := *c.client
+	refreshClient.Timeout = 0
+	resp, err := refreshClient.Do(req)
+	if err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, &result); err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+func (c *client) AdminEncryptionAdd(ctx context.Context, key []byte) (api.Empty, error) {
+	return c.adminEncryption(ctx, "/admin/encryption/add", key, "")
+}
+
+func (c *client) AdminEncryptionActivate(ctx context.Context, sha string) (api.Empty, error) {
+	return c.adminEncryption(ctx, "/admin/encryption/activate", nil, sha)
+}
+
+func (c *client) AdminEncryptionRemove(ctx context.Context, sha string) (api.Empty, error) {
+	return c.adminEncryption(ctx, "/admin/encryption/remove", nil, sha)
+}
+
+func (c *client) AdminEncryptionRefresh(ctx context.Context) (api.Empty, error) {
+	return c.adminEncryption(ctx, "/admin/encryption/refresh", nil, "")
+}
+
+func (c *client) AdminEncryptionLocal(ctx context.Context, action string, key []byte, sha string) (api.Empty, error) {
+	return c.adminEncryption(ctx, "/local/encryption/"+action, key, sha)
+}
+
+// EncryptionKeysLocal returns the SHAs of the RocksDB encryption keys of the starter only.
+func (c *client) EncryptionKeysLocal(ctx context.Context) (api.EncryptionKeys, error) {
+	url := c.createURL("/local/encryption/keys", nil)
+
+	var result api.EncryptionKeys
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return api.EncryptionKeys{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return api.EncryptionKeys{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return api.EncryptionKeys{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// SyncHealthLocal checks the health of the arangosync servers started by the starter only.
+// It returns an error when one of them is unhealthy or synchronization is broken.
+func (c *client) SyncHealthLocal(ctx context.Context) (api.SyncHealth, error) {
+	url := c.createURL("/local/sync/health", nil)
+
+	var result api.SyncHealth
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return api.SyncHealth{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return api.SyncHealth{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return api.SyncHealth{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+func (c *client) adminEncryption(ctx context.Context, path string, key []byte, sha string) (api.Empty, error) {
+	var q url.Values
+	if sha != "" {
+		q = url.Values{}
+		q.Set("key", sha)
+	}
+	url := c.createURL(path, q)
+
+	var result api.Empty
+	req, err := http.NewRequest("POST", url, bytes.NewReader(key))
+	if err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	// Refreshing all servers of all peers can take longer than the default timeout.
+	refreshClient := *c.client
+	refreshClient.Timeout = 0
+	resp, err := refreshClient.Do(req)
+	if err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, &result); err != nil {
+		return api.Empty{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 func (c *client) ClusterInventory(ctx context.Context) (api.ClusterInventory, error) {
 	url := c.createURL("/cluster/inventory", nil)
 
@@ -125,6 +270,30 @@ func (c *client) ClusterInventory(ctx context.Context) (api.ClusterInventory, er
 	return result, nil
 }
 
+// ClusterStatus returns all starters of the cluster with the servers they start
+// and the health of those servers as known to the agency.
+func (c *client) ClusterStatus(ctx context.Context) (ClusterStatus, error) {
+	url := c.createURL("/cluster/status", nil)
+
+	var result ClusterStatus
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ClusterStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ClusterStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return ClusterStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 func (c *client) Inventory(ctx context.Context) (api.Inventory, error) {
 	url := c.createURL("/local/inventory", nil)
 
@@ -221,6 +390,39 @@ func (c *client) DatabaseVersion(ctx context.Context) (driver.Version, error) {
 	return result.Version, nil
 }
 
+// CheckArangodBinaryLocal lets the starter check the given arangod executable (or docker image)
+// it would switch to for an upgrade.
+// It returns the database version of the target.
+func (c *client) CheckArangodBinaryLocal(ctx context.Context, target UpgradeTarget) (SwitchArangodBinaryResult, error) {
+	q := url.Values{}
+	q.Set("validateOnly", "true")
+	url := c.createURL("/local/upgrade/binary", q)
+
+	inputJSON, err := json.Marshal(target)
+	if err != nil {
+		return SwitchArangodBinaryResult{}, maskAny(err)
+	}
+	var result SwitchArangodBinaryResult
+	// Checking the target may involve pulling a docker image
+	c.client.Timeout = time.Minute * 5
+	req, err := http.NewRequest("POST", url, bytes.NewReader(inputJSON))
+	if err != nil {
+		return SwitchArangodBinaryResult{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return SwitchArangodBinaryResult{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, &result); err != nil {
+		return SwitchArangodBinaryResult{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 // Processes loads information of all the server processes launched by a specific arangodb.
 func (c *client) Processes(ctx context.Context) (ProcessList, error) {
 	url := c.createURL("/process", nil)
@@ -304,10 +506,25 @@ type GoodbyeRequest struct {
 // If that does not succeed, the operation returns an error,
 // unless force is set to true.
 func (c *client) RemovePeer(ctx context.Context, id string, force bool) error {
+	return c.removePeer(ctx, id, force, false)
+}
+
+// RemovePeerAsync starts the removal of the peer with given ID from the starter cluster
+// and returns as soon as it has been started.
+// Use PeerRemovalStatus to follow its progress.
+func (c *client) RemovePeerAsync(ctx context.Context, id string, force bool) error {
+	return c.removePeer(ctx, id, force, true)
+}
+
+// removePeer sends a goodbye request for the peer with given ID.
+func (c *client) removePeer(ctx context.Context, id string, force, async bool) error {
 	q := url.Values{}
 	if force {
 		q.Set("force", "true")
 	}
+	if async {
+		q.Set("async", "true")
+	}
 	url := c.createURL("/goodbye", q)
 
 	input := GoodbyeRequest{
@@ -336,12 +553,117 @@ func (c *client) RemovePeer(ctx context.Context, id string, force bool) error {
 	return nil
 }
 
+// PeerRemovalStatus returns the status of the last removal of the peer with given ID.
+// If that peer has not been removed, a NotFoundError will be returned.
+func (c *client) PeerRemovalStatus(ctx context.Context, id string) (PeerRemovalStatus, error) {
+	q := url.Values{}
+	q.Set("id", id)
+	url := c.createURL("/peer/removal", q)
+
+	var result PeerRemovalStatus
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return PeerRemovalStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return PeerRemovalStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return PeerRemovalStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// SetPeerRoles changes the servers started by the peer with given ID.
+// Roles that are not set in the given roles are left unchanged.
+// A dbserver that is no longer wanted is cleaned out first.
+// Servers that are no longer wanted are removed from the cluster and stopped.
+func (c *client) SetPeerRoles(ctx context.Context, id string, roles PeerRoles) error {
+	url := c.createURL("/peer/roles", nil)
+
+	input := SetPeerRolesRequest{
+		ID:        id,
+		PeerRoles: roles,
+	}
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(inputJSON))
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	// Cleaning out a dbserver can take longer than the default timeout.
+	rolesClient := *c.client
+	rolesClient.Timeout = 0
+	resp, err := rolesClient.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// ApplyPeerRolesLocal lets the starter take the given roles, starting the servers
+// of the roles it gains and removing the servers of the roles it loses from the cluster.
+func (c *client) ApplyPeerRolesLocal(ctx context.Context, roles PeerRoles) error {
+	url := c.createURL("/local/peer/roles", nil)
+
+	inputJSON, err := json.Marshal(roles)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Stopping servers may take a while
+	c.client.Timeout = time.Minute * 5
+	req, err := http.NewRequest("POST", url, bytes.NewReader(inputJSON))
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
 // StartDatabaseUpgrade is called to start the upgrade process
 func (c *client) StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool) error {
+	return c.StartDatabaseUpgradeTo(ctx, forceMinorUpgrade, UpgradeTarget{}, "")
+}
+
+// StartDatabaseUpgradeTo is called to start the upgrade process after all starters
+// have switched to the given arangod executable (or docker image).
+// TriggeredBy describes who starts the upgrade, it is kept in the upgrade history
+// unless the starter API requires a token (then the token is described instead).
+func (c *client) StartDatabaseUpgradeTo(ctx context.Context, forceMinorUpgrade bool, target UpgradeTarget, triggeredBy string) error {
 	q := url.Values{}
 	if forceMinorUpgrade {
 		q.Set("forceMinorUpgrade", "true")
 	}
+	target.addQuery(q)
+	if triggeredBy != "" {
+		q.Set("triggeredBy", triggeredBy)
+	}
 	url := c.createURL("/database-auto-upgrade", q)
 
 	c.client.Timeout = time.Minute * 5
@@ -363,6 +685,36 @@ func (c *client) StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade boo
 	return nil
 }
 
+// DryRunDatabaseUpgrade runs all checks of StartDatabaseUpgradeTo and returns the
+// plan it would create, without changing anything.
+func (c *client) DryRunDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, target UpgradeTarget) (UpgradeDryRun, error) {
+	q := url.Values{}
+	if forceMinorUpgrade {
+		q.Set("forceMinorUpgrade", "true")
+	}
+	target.addQuery(q)
+	url := c.createURL("/database-auto-upgrade/dry-run", q)
+
+	var result UpgradeDryRun
+	c.client.Timeout = time.Minute * 5
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return UpgradeDryRun{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return UpgradeDryRun{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return UpgradeDryRun{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
 // RetryDatabaseUpgrade resets a failure mark in the existing upgrade plan
 // such that the starters will retry the upgrade once more.
 func (c *client) RetryDatabaseUpgrade(ctx context.Context) error {
@@ -411,6 +763,78 @@ func (c *client) AbortDatabaseUpgrade(ctx context.Context) error {
 	return nil
 }
 
+// RollbackDatabaseUpgrade restarts all servers that have already been upgraded
+// with the binary (or docker image) they used before the upgrade.
+// If there is no plan, a NotFoundError will be returned.
+func (c *client) RollbackDatabaseUpgrade(ctx context.Context) error {
+	url := c.createURL("/database-auto-upgrade/rollback", nil)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// PauseDatabaseUpgrade pauses the existing upgrade plan.
+// Note that Starters working on an entry of the upgrade
+// will finish that entry.
+// If there is no plan, a NotFoundError will be returned.
+func (c *client) PauseDatabaseUpgrade(ctx context.Context) error {
+	url := c.createURL("/database-auto-upgrade/pause", nil)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// ResumeDatabaseUpgrade resumes the existing paused upgrade plan.
+// If there is no plan, a NotFoundError will be returned.
+func (c *client) ResumeDatabaseUpgrade(ctx context.Context) error {
+	url := c.createURL("/database-auto-upgrade/resume", nil)
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
 // Status returns the status of any upgrade plan
 func (c *client) UpgradeStatus(ctx context.Context) (UpgradeStatus, error) {
 	url := c.createURL("/database-auto-upgrade", nil)
@@ -434,6 +858,201 @@ func (c *client) UpgradeStatus(ctx context.Context) (UpgradeStatus, error) {
 	return result, nil
 }
 
+// UpgradeHistory returns the finished, aborted & rolled back upgrade plans, oldest first.
+func (c *client) UpgradeHistory(ctx context.Context) (UpgradeHistory, error) {
+	url := c.createURL("/database-auto-upgrade/history", nil)
+
+	var result UpgradeHistory
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return UpgradeHistory{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return UpgradeHistory{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return UpgradeHistory{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// StartDatabaseRestart is called to start a rolling restart of all servers of the given type
+// (or all arangod servers if the type is RestartTypeAll).
+func (c *client) StartDatabaseRestart(ctx context.Context, serverType ServerType) error {
+	q := url.Values{}
+	q.Set("type", string(serverType))
+	url := c.createURL("/database-restart", q)
+
+	c.client.Timeout = time.Minute * 5
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "POST", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// RetryDatabaseRestart resets a failure mark in the existing restart plan
+// such that the starters will retry the restart once more.
+func (c *client) RetryDatabaseRestart(ctx context.Context) error {
+	url := c.createURL("/database-restart", nil)
+
+	req, err := http.NewRequest("PUT", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "PUT", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// AbortDatabaseRestart removes the existing restart plan.
+// Note that Starters working on an entry of the restart
+// will finish that entry.
+// If there is no plan, a NotFoundError will be returned.
+func (c *client) AbortDatabaseRestart(ctx context.Context) error {
+	url := c.createURL("/database-restart", nil)
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := c.handleResponse(resp, "DELETE", url, nil); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// RestartStatus returns the status of any restart plan
+func (c *client) RestartStatus(ctx context.Context) (RestartStatus, error) {
+	url := c.createURL("/database-restart", nil)
+
+	var result RestartStatus
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return RestartStatus{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return RestartStatus{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return RestartStatus{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// Logs returns a reader for the log of the server of given type.
+// The caller must close the returned reader.
+func (c *client) Logs(ctx context.Context, serverType ServerType, opts LogsOptions) (io.ReadCloser, error) {
+	url := c.createURL("/logs/"+string(serverType), opts.queryValues())
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	// Logs can be large & followed for a long time, so do not use a timeout.
+	streamClient := *c.client
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// handleResponse always returns an error for non-OK status codes
+		return nil, maskAny(c.handleResponse(resp, "GET", url, nil))
+	}
+
+	return resp.Body, nil
+}
+
+// ClusterLogs returns the logs of the servers of all starters in the cluster, merged by timestamp.
+func (c *client) ClusterLogs(ctx context.Context, serverType ServerType, opts LogsOptions) (api.ClusterLogs, error) {
+	q := opts.queryValues()
+	if serverType != "" {
+		q.Set("type", string(serverType))
+	}
+	url := c.createURL("/cluster/logs", q)
+
+	var result api.ClusterLogs
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return api.ClusterLogs{}, maskAny(err)
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	// Fetching the logs of all peers can take longer than the default timeout.
+	logsClient := *c.client
+	logsClient.Timeout = 0
+	resp, err := logsClient.Do(req)
+	if err != nil {
+		return api.ClusterLogs{}, maskAny(err)
+	}
+	if err := c.handleResponse(resp, "GET", url, &result); err != nil {
+		return api.ClusterLogs{}, maskAny(err)
+	}
+
+	return result, nil
+}
+
+// queryValues returns the query parameters of a logs request for these options.
+func (opts LogsOptions) queryValues() url.Values {
+	q := url.Values{}
+	if opts.Tail > 0 {
+		q.Set("tail", strconv.Itoa(opts.Tail))
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.Format(time.RFC3339Nano))
+	}
+	if opts.Level != "" {
+		q.Set("level", opts.Level)
+	}
+	if opts.Follow {
+		q.Set("follow", "true")
+	}
+	return q
+}
+
 // handleResponse checks the given response status and decodes any JSON result.
 func (c *client) handleResponse(resp *http.Response, method, url string, result interface{}) error {
 	// Read response body into memory
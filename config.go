@@ -0,0 +1,317 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	configFileOptionName = "starter.config"
+)
+
+var (
+	cmdConfig = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration of the starter",
+		Run:   cmdShowUsage,
+	}
+	cmdConfigDump = &cobra.Command{
+		Use:   "dump",
+		Short: "Show the effective configuration, merged from configuration file, environment & command line",
+		Run:   cmdConfigDumpRun,
+	}
+	configDumpOptions struct {
+		format string
+	}
+	configFilePath    string
+	configFileOptions []configFileOption
+)
+
+func init() {
+	f := cmdConfigDump.Flags()
+	f.StringVar(&configDumpOptions.format, "format", "yaml", "Format of the configuration (yaml|json)")
+
+	cmdMain.AddCommand(cmdConfig)
+	cmdConfig.AddCommand(cmdConfigDump)
+}
+
+// configFileOption is a single option read from a configuration file.
+type configFileOption struct {
+	Name   string   // Name of the command line option
+	Values []string // Values of the option, more than one for list options
+}
+
+// findConfigFilePath returns the path of the configuration file given in the given
+// command line arguments, or from the environment if not found there.
+// This is needed before the command line is parsed, since the configuration file
+// can contain `--args.*` & `--envs.*` options, which are added to the command line parser on the fly.
+func findConfigFilePath(args []string) string {
+	option := "--" + configFileOptionName
+	for i, arg := range args {
+		if arg == option && i+1 < len(args) {
+			return args[i+1]
+		} else if strings.HasPrefix(arg, option+"=") {
+			return strings.TrimPrefix(arg, option+"=")
+		}
+	}
+	return os.Getenv("ARANGODB_STARTER_CONFIG")
+}
+
+// mustLoadConfigFile loads the configuration file given in the given command line arguments (if any).
+func mustLoadConfigFile(args []string) []configFileOption {
+	path := findConfigFilePath(args)
+	if path == "" {
+		return nil
+	}
+	path = mustExpand(path)
+	options, err := loadConfigFile(path)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed to load configuration file '%s'", path)
+	}
+	return options
+}
+
+// loadConfigFile reads a YAML or JSON configuration file and returns the options found in it.
+// The keys of the document are the names of the command line options, where every `.`
+// in an option name can be replaced by a nested object.
+// E.g. `starter: { mode: single }` is the same as `starter.mode: single`
+// and `args: { dbservers: { log.level: debug } }` is the same as `--args.dbservers.log.level=debug`.
+func loadConfigFile(path string) ([]configFileOption, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var doc interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return nil, maskAny(err)
+		}
+	} else if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, maskAny(err)
+	}
+	var result []configFileOption
+	if err := flattenConfigFile("", doc, &result); err != nil {
+		return nil, maskAny(err)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// flattenConfigFile adds all options found in the given (part of a) configuration document to the given list.
+func flattenConfigFile(prefix string, v interface{}, result *[]configFileOption) error {
+	join := func(key interface{}) string {
+		if prefix == "" {
+			return fmt.Sprint(key)
+		}
+		return prefix + "." + fmt.Sprint(key)
+	}
+	switch v := v.(type) {
+	case nil:
+		// No value, ignore
+		return nil
+	case map[string]interface{}:
+		for key, value := range v {
+			if err := flattenConfigFile(join(key), value, result); err != nil {
+				return maskAny(err)
+			}
+		}
+		return nil
+	case map[interface{}]interface{}:
+		for key, value := range v {
+			if err := flattenConfigFile(join(key), value, result); err != nil {
+				return maskAny(err)
+			}
+		}
+		return nil
+	}
+	if prefix == "" {
+		return maskAny(fmt.Errorf("Configuration file must contain an object"))
+	}
+	option := configFileOption{Name: prefix}
+	if list, ok := v.([]interface{}); ok {
+		for _, elem := range list {
+			s, err := configFileScalar(prefix, elem)
+			if err != nil {
+				return maskAny(err)
+			}
+			option.Values = append(option.Values, s)
+		}
+	} else {
+		s, err := configFileScalar(prefix, v)
+		if err != nil {
+			return maskAny(err)
+		}
+		option.Values = []string{s}
+	}
+	*result = append(*result, option)
+	return nil
+}
+
+// configFileScalar converts a single value of a configuration file into a command line value.
+func configFileScalar(name string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", maskAny(fmt.Errorf("Option '%s' in configuration file has an unsupported value", name))
+	}
+}
+
+// configFileArgs returns the given options formatted as command line arguments (without values).
+func configFileArgs(options []configFileOption) []string {
+	result := make([]string, 0, len(options))
+	for _, o := range options {
+		result = append(result, "--"+o.Name)
+	}
+	return result
+}
+
+// applyConfigFile sets all flags in the given set that have not been set on the
+// command line (or from the environment) to the value found in the configuration file.
+func applyConfigFile(fs *pflag.FlagSet, options []configFileOption) error {
+	if fs.Lookup(configFileOptionName) == nil {
+		// Command does not use the starter configuration
+		return nil
+	}
+	for _, o := range options {
+		f := fs.Lookup(o.Name)
+		if f == nil {
+			return maskAny(fmt.Errorf("Unknown option '%s' in configuration file", o.Name))
+		} else if f.Name == configFileOptionName {
+			return maskAny(fmt.Errorf("Option '%s' cannot be set in a configuration file", o.Name))
+		}
+		if f.Changed {
+			// Command line takes precedence
+			continue
+		}
+		if _, isSlice := f.Value.(pflag.SliceValue); !isSlice && len(o.Values) > 1 {
+			return maskAny(fmt.Errorf("Option '%s' in configuration file expects a single value", o.Name))
+		}
+		for _, v := range o.Values {
+			if err := fs.Set(f.Name, v); err != nil {
+				return maskAny(fmt.Errorf("Invalid value '%s' for option '%s' in configuration file: %s", v, o.Name, err))
+			}
+		}
+	}
+	return nil
+}
+
+// configDocument builds a configuration document holding the values of all
+// starter options in the given flag set.
+// Option sections (the part of the name before a `.`) are turned into nested objects,
+// such that the document can be used as configuration file again.
+func configDocument(fs *pflag.FlagSet) map[string]interface{} {
+	doc := make(map[string]interface{})
+	fs.VisitAll(func(f *pflag.Flag) {
+		if !strings.Contains(f.Name, ".") || f.Name == configFileOptionName {
+			// Not a starter option (e.g. --help or --version)
+			return
+		}
+		if f.Deprecated != "" && !f.Changed {
+			return
+		}
+		setConfigDocumentValue(doc, strings.Split(f.Name, "."), configDocumentValue(f))
+	})
+	return doc
+}
+
+// configDocumentValue returns the value of the given flag as it should appear in a configuration document.
+func configDocumentValue(f *pflag.Flag) interface{} {
+	switch f.Value.Type() {
+	case "bool":
+		if b, err := strconv.ParseBool(f.Value.String()); err == nil {
+			return b
+		}
+	case "int":
+		if i, err := strconv.Atoi(f.Value.String()); err == nil {
+			return i
+		}
+	case "boolSlice":
+		values := f.Value.(pflag.SliceValue).GetSlice()
+		result := make([]bool, 0, len(values))
+		for _, v := range values {
+			b, _ := strconv.ParseBool(v)
+			result = append(result, b)
+		}
+		return result
+	}
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		return append([]string{}, sv.GetSlice()...)
+	}
+	return f.Value.String()
+}
+
+// setConfigDocumentValue stores the given value in the given document under the given path.
+// When the path conflicts with an option that is already stored, the remainder of the path
+// is used as (dotted) key.
+func setConfigDocumentValue(doc map[string]interface{}, path []string, value interface{}) {
+	for len(path) > 1 {
+		child, found := doc[path[0]]
+		if !found {
+			child = make(map[string]interface{})
+			doc[path[0]] = child
+		}
+		childDoc, ok := child.(map[string]interface{})
+		if !ok {
+			break
+		}
+		doc = childDoc
+		path = path[1:]
+	}
+	doc[strings.Join(path, ".")] = value
+}
+
+func cmdConfigDumpRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	doc := configDocument(cmd.Flags())
+	var content []byte
+	var err error
+	switch configDumpOptions.format {
+	case "yaml":
+		content, err = yaml.Marshal(doc)
+	case "json":
+		content, err = json.MarshalIndent(doc, "", "  ")
+		content = append(content, '\n')
+	default:
+		log.Fatal().Msgf("Unknown format '%s'", configDumpOptions.format)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to format configuration")
+	}
+	os.Stdout.Write(content)
+}
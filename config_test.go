@@ -0,0 +1,160 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "starter-config")
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func Test_LoadConfigFile(t *testing.T) {
+	expected := []configFileOption{
+		{Name: "args.dbservers.log.level", Values: []string{"startup=trace", "requests=debug"}},
+		{Name: "cluster.agency-size", Values: []string{"5"}},
+		{Name: "starter.join", Values: []string{"a", "b"}},
+		{Name: "starter.local", Values: []string{"true"}},
+		{Name: "starter.mode", Values: []string{"cluster"}},
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		path := writeConfigFile(t, "starter.yaml", `
+starter:
+  mode: cluster
+  local: true
+  join: [a, b]
+cluster.agency-size: 5
+args:
+  dbservers:
+    log.level:
+    - startup=trace
+    - requests=debug
+`)
+		defer os.RemoveAll(filepath.Dir(path))
+		options, err := loadConfigFile(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, options)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		path := writeConfigFile(t, "starter.json", `{
+	"starter": {"mode": "cluster", "local": true, "join": ["a", "b"]},
+	"cluster.agency-size": 5,
+	"args": {"dbservers": {"log": {"level": ["startup=trace", "requests=debug"]}}}
+}`)
+		defer os.RemoveAll(filepath.Dir(path))
+		options, err := loadConfigFile(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, options)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		path := writeConfigFile(t, "starter.yaml", "starter:\n  join: [{a: b}]\n")
+		defer os.RemoveAll(filepath.Dir(path))
+		_, err := loadConfigFile(path)
+		require.Error(t, err)
+	})
+}
+
+func Test_ApplyConfigFile(t *testing.T) {
+	createFlagSet := func() (*pflag.FlagSet, *string, *int, *[]string) {
+		fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		fs.String(configFileOptionName, "", "")
+		mode := fs.String("starter.mode", "cluster", "")
+		port := fs.Int("starter.port", 8528, "")
+		join := fs.StringSlice("starter.join", nil, "")
+		return fs, mode, port, join
+	}
+	options := []configFileOption{
+		{Name: "starter.join", Values: []string{"a", "b"}},
+		{Name: "starter.mode", Values: []string{"single"}},
+		{Name: "starter.port", Values: []string{"9528"}},
+	}
+
+	t.Run("File values", func(t *testing.T) {
+		fs, mode, port, join := createFlagSet()
+		require.NoError(t, fs.Parse(nil))
+		require.NoError(t, applyConfigFile(fs, options))
+		require.Equal(t, "single", *mode)
+		require.Equal(t, 9528, *port)
+		require.Equal(t, []string{"a", "b"}, *join)
+	})
+
+	t.Run("Command line takes precedence", func(t *testing.T) {
+		fs, mode, port, join := createFlagSet()
+		require.NoError(t, fs.Parse([]string{"--starter.port=7000", "--starter.join=c"}))
+		require.NoError(t, applyConfigFile(fs, options))
+		require.Equal(t, "single", *mode)
+		require.Equal(t, 7000, *port)
+		require.Equal(t, []string{"c"}, *join)
+	})
+
+	t.Run("Unknown option", func(t *testing.T) {
+		fs, _, _, _ := createFlagSet()
+		require.NoError(t, fs.Parse(nil))
+		require.Error(t, applyConfigFile(fs, []configFileOption{{Name: "starter.unknown", Values: []string{"x"}}}))
+	})
+
+	t.Run("Multiple values for single option", func(t *testing.T) {
+		fs, _, _, _ := createFlagSet()
+		require.NoError(t, fs.Parse(nil))
+		require.Error(t, applyConfigFile(fs, []configFileOption{{Name: "starter.mode", Values: []string{"single", "cluster"}}}))
+	})
+}
+
+func Test_ConfigDocument(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Bool("version", false, "")
+	fs.String("starter.mode", "cluster", "")
+	fs.Int("starter.port", 8528, "")
+	fs.BoolSlice("cluster.start-agent", nil, "")
+	fs.StringSlice("args.all.log.level", nil, "")
+	require.NoError(t, fs.Parse([]string{"--cluster.start-agent=false", "--args.all.log.level=debug"}))
+
+	require.Equal(t, map[string]interface{}{
+		"starter": map[string]interface{}{
+			"mode": "cluster",
+			"port": 8528,
+		},
+		"cluster": map[string]interface{}{
+			"start-agent": []bool{false},
+		},
+		"args": map[string]interface{}{
+			"all": map[string]interface{}{
+				"log": map[string]interface{}{
+					"level": []string{"debug"},
+				},
+			},
+		},
+	}, configDocument(fs))
+}
@@ -44,8 +44,8 @@ func showDockerContainerNameMissingHelp() {
 }
 
 // --cluster.agency-size invalid.
-func showClusterAgencySizeInvalidHelp() {
-	showFatalHelp(
+func clusterAgencySizeInvalidError() error {
+	return newHelpError(
 		"Cluster agency size is invalid cluster.agency-size needs to be a positive, odd number.",
 		"",
 		"How to solve this:",
@@ -57,8 +57,8 @@ func showClusterAgencySizeInvalidHelp() {
 }
 
 // --cluster.agency-size=1 without specifying --starter.address.
-func showClusterAgencySize1WithoutAddressHelp() {
-	showFatalHelp(
+func clusterAgencySize1WithoutAddressError() error {
+	return newHelpError(
 		"With a cluster agency size of 1, a starter address is required.",
 		"",
 		"How to solve this:",
@@ -70,8 +70,8 @@ func showClusterAgencySize1WithoutAddressHelp() {
 }
 
 // setting both --docker.image and --server.rr is not possible.
-func showDockerImageWithRRIsNotAllowedHelp() {
-	showFatalHelp(
+func dockerImageWithRRIsNotAllowedError() error {
+	return newHelpError(
 		"Using RR is not possible with docker.",
 		"",
 		"How to solve this:",
@@ -81,8 +81,8 @@ func showDockerImageWithRRIsNotAllowedHelp() {
 }
 
 // setting both --docker.net-host and --docker.net-mode is not possible
-func showDockerNetHostAndNotModeNotBothAllowedHelp() {
-	showFatalHelp(
+func dockerNetHostAndNotModeNotBothAllowedError() error {
+	return newHelpError(
 		"It is not allowed to set `--docker.net-host` and `--docker.net-mode` at the same time.",
 		"",
 		"How to solve this:",
@@ -103,8 +103,8 @@ func showArangodExecutableNotFoundHelp(arangodPath string) {
 }
 
 // cannnot specify both `--ssl.auto-key` and `--ssl.keyfile`
-func showSslAutoKeyAndKeyFileNotBothAllowedHelp() {
-	showFatalHelp(
+func sslAutoKeyAndKeyFileNotBothAllowedError() error {
+	return newHelpError(
 		"Specifying both `--ssl.auto-key` and `--ssl.keyfile` is not allowed.",
 		"",
 		"How to solve this:",
@@ -113,9 +113,88 @@ func showSslAutoKeyAndKeyFileNotBothAllowedHelp() {
 	)
 }
 
+// cannnot specify both `--ssl.auto-ca` and `--ssl.keyfile` or `--ssl.auto-key`
+func sslAutoCAAndKeyFileNotBothAllowedError() error {
+	return newHelpError(
+		"Specifying `--ssl.auto-ca` together with `--ssl.keyfile` or `--ssl.auto-key` is not allowed.",
+		"",
+		"How to solve this:",
+		"1 - Remove `--ssl.keyfile` and `--ssl.auto-key` from the commandline arguments.",
+		"",
+	)
+}
+
+// `--auth.starter-api` requires `--auth.jwt-secret`
+func authStarterAPIJWTSecretMissingError() error {
+	return newHelpError(
+		"Specifying `--auth.starter-api` requires a JWT secret.",
+		"",
+		"How to solve this:",
+		"1 - Add a commandline argument:",
+		"    `--auth.jwt-secret=<path of a file containing the JWT secret>`",
+		"",
+	)
+}
+
+// `--starter.peer-auth=jwt` requires `--auth.jwt-secret`
+func peerAuthJWTSecretMissingError() error {
+	return newHelpError(
+		"Specifying `--starter.peer-auth=jwt` requires a JWT secret.",
+		"",
+		"How to solve this:",
+		"1 - Add a commandline argument:",
+		"    `--auth.jwt-secret=<path of a file containing the JWT secret>`",
+		"    Use the same secret for all starters.",
+		"",
+	)
+}
+
+// `--ssl.auto-ca` with multiple starters requires `--starter.peer-auth=jwt|mtls`
+func sslAutoCAPeerAuthMissingError() error {
+	return newHelpError(
+		"Specifying `--ssl.auto-ca` for a cluster of multiple starters requires `--starter.peer-auth=jwt` or `--starter.peer-auth=mtls`.",
+		"The certificate authority is only handed out to starters that authenticate.",
+		"",
+		"How to solve this:",
+		"1 - Add commandline arguments (on all starters):",
+		"    `--starter.peer-auth=jwt --auth.jwt-secret=<secret-file>`",
+		"",
+	)
+}
+
+// `--starter.peer-auth=mtls` requires `--ssl.auto-ca`
+func peerAuthAutoCAMissingError() error {
+	return newHelpError(
+		"Specifying `--starter.peer-auth=mtls` requires `--ssl.auto-ca`.",
+		"",
+		"How to solve this:",
+		"1 - Add a commandline argument:",
+		"    `--ssl.auto-ca`",
+		"2 - Copy the `tls/ca.crt` and `tls/ca.key` files from the data directory",
+		"    of the master starter into the data directory of all joining starters.",
+		"",
+	)
+}
+
+// `--starter.peer-auth=mtls` requires the CA of the master when joining
+func peerAuthMTLSAutoCANotFoundError() error {
+	return newHelpError(
+		"Joining a cluster with `--starter.peer-auth=mtls` requires the certificate authority of the master.",
+		"Starters authenticate with a client certificate issued by that certificate authority,",
+		"so a joining starter cannot fetch it from the master.",
+		"",
+		"How to solve this:",
+		"1 - Copy the `tls/ca.crt` and `tls/ca.key` files from the data directory",
+		"    of the master starter into the data directory of this starter.",
+		"2 - Use `--starter.peer-auth=jwt --auth.jwt-secret=<secret-file>` (on all starters)",
+		"    to let joining starters fetch the certificate authority from the master.",
+		"",
+	)
+}
+
 // arangosync is not allowed with given starter mode.
-func showArangoSyncNotAllowedWithModeHelp(mode string) {
-	showFatalHelp(
+func arangoSyncNotAllowedWithModeError(mode string) error {
+	return newHelpError(
 		fmt.Sprintf("ArangoSync is not supported in combination with mode '%s'\n", mode),
 		"",
 		"How to solve this:",
@@ -139,8 +218,8 @@ func showArangoSyncExecutableNotFoundHelp(arangosyncPath string) {
 }
 
 // --sync.server.keyfile is missing
-func showSyncMasterServerKeyfileMissingHelp() {
-	showFatalHelp(
+func syncMasterServerKeyfileMissingError() error {
+	return newHelpError(
 		"A TLS certificate used for the HTTPS connection of the arangosync syncmaster is missing.",
 		"",
 		"How to solve this:",
@@ -165,8 +244,8 @@ func showSyncMasterServerKeyfileMissingHelp() {
 }
 
 // --sync.server.client-cafile is missing
-func showSyncMasterClientCAFileMissingHelp() {
-	showFatalHelp(
+func syncMasterClientCAFileMissingError() error {
+	return newHelpError(
 		"A CA certificate used for client authentication of the arangosync syncmaster is missing.",
 		"",
 		"How to solve this:",
@@ -183,8 +262,8 @@ func showSyncMasterClientCAFileMissingHelp() {
 }
 
 // --sync.master.jwt-secret is missing
-func showSyncMasterJWTSecretMissingHelp() {
-	showFatalHelp(
+func syncMasterJWTSecretMissingError() error {
+	return newHelpError(
 		"A JWT secret used for authentication of the arangosync syncworkers at the syncmaster is missing.",
 		"",
 		"How to solve this:",
@@ -200,6 +279,31 @@ func showSyncMasterJWTSecretMissingHelp() {
 	)
 }
 
+// helpError is an error that comes with instructions on how to solve it.
+type helpError struct {
+	title string
+	lines []string
+}
+
+// newHelpError returns an error with given title and instructions.
+func newHelpError(title string, lines ...string) error {
+	return helpError{title: title, lines: lines}
+}
+
+// Error returns the title of the error.
+func (e helpError) Error() string {
+	return strings.TrimSpace(e.title)
+}
+
+// showFatalErrorHelp logs the given error with its instructions (if any)
+// and exits with code 1.
+func showFatalErrorHelp(err error) {
+	if e, ok := err.(helpError); ok {
+		showFatalHelp(e.title, e.lines...)
+	}
+	log.Fatal().Err(err).Msg("Invalid configuration")
+}
+
 // showFatalHelp logs a title and prints additional usages
 // underneeth and the exit with code 1.
 // Backticks in the lines are colored yellow.
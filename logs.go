@@ -0,0 +1,136 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+var (
+	cmdLogs = &cobra.Command{
+		Use:   "logs",
+		Short: "Show the log of a server started by an ArangoDB starter",
+		Run:   cmdLogsRun,
+	}
+	logsOptions struct {
+		starterEndpoint string
+		serverType      string
+		tail            int
+		since           string
+		level           string
+		follow          bool
+		cluster         bool
+	}
+)
+
+func init() {
+	f := cmdLogs.Flags()
+	f.StringVar(&logsOptions.starterEndpoint, "starter.endpoint", "http://localhost:8528", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
+	f.StringVar(&logsOptions.serverType, "type", "", "Type of server to show the log of (agent|dbserver|coordinator|single|syncmaster|syncworker)")
+	f.IntVar(&logsOptions.tail, "tail", 0, "If set, only show the last N lines")
+	f.StringVar(&logsOptions.since, "since", "", "If set, only show lines logged since the given time (RFC3339) or duration (e.g. 10m)")
+	f.StringVar(&logsOptions.level, "level", "", "If set, only show lines with the given level or more severe (fatal|error|warning|info|debug|trace)")
+	f.BoolVarP(&logsOptions.follow, "follow", "f", false, "If set, keep showing new lines")
+	f.BoolVar(&logsOptions.cluster, "cluster", false, "If set, show the merged logs of the servers of all starters in the cluster")
+
+	cmdMain.AddCommand(cmdLogs)
+}
+
+func cmdLogsRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	if logsOptions.cluster {
+		if logsOptions.follow {
+			log.Fatal().Msg("--follow cannot be combined with --cluster")
+		}
+	} else if logsOptions.serverType == "" {
+		log.Fatal().Msg("--type must be set")
+	}
+	opts := client.LogsOptions{
+		Tail:   logsOptions.tail,
+		Level:  logsOptions.level,
+		Follow: logsOptions.follow,
+	}
+	if logsOptions.since != "" {
+		if t, err := time.Parse(time.RFC3339Nano, logsOptions.since); err == nil {
+			opts.Since = t
+		} else if d, err := time.ParseDuration(logsOptions.since); err == nil {
+			opts.Since = time.Now().Add(-d)
+		} else {
+			log.Fatal().Msgf("--since '%s' is neither a timestamp nor a duration", logsOptions.since)
+		}
+	}
+
+	// Stop following on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChannel := make(chan os.Signal, 1)
+	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChannel
+		cancel()
+	}()
+
+	// Create starter client
+	c := mustCreateStarterClient(logsOptions.starterEndpoint)
+	if logsOptions.cluster {
+		showClusterLogs(ctx, c, opts)
+		return
+	}
+	rd, err := c.Logs(ctx, client.ServerType(logsOptions.serverType), opts)
+	if client.IsNotFound(err) {
+		log.Fatal().Msgf("Starter has not launched a %s", logsOptions.serverType)
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Failed to fetch logs")
+	}
+	defer rd.Close()
+	if _, err := io.Copy(os.Stdout, rd); err != nil && ctx.Err() == nil {
+		log.Fatal().Err(err).Msg("Failed to read logs")
+	}
+}
+
+// showClusterLogs writes the merged logs of all starters in the cluster to stdout,
+// prefixing every line with the ID of the peer & the type of the server.
+func showClusterLogs(ctx context.Context, c client.API, opts client.LogsOptions) {
+	logs, err := c.ClusterLogs(ctx, client.ServerType(logsOptions.serverType), opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to fetch cluster logs")
+	}
+	for _, e := range logs.Errors {
+		log.Warn().Str("peer", e.Peer).Str("type", string(e.Type)).Msgf("Failed to fetch log: %s", e.Error.String())
+	}
+	for _, l := range logs.Lines {
+		fmt.Printf("%s %s | %s\n", l.Peer, l.Type, l.Line)
+	}
+}
@@ -82,6 +82,10 @@ var (
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			// Load commandline options from envvars
 			setFlagValuesFromEnv(cmd.Flags())
+			// Load remaining options from configuration file
+			if err := applyConfigFile(cmd.Flags(), configFileOptions); err != nil {
+				log.Fatal().Err(err).Msg("Invalid configuration file")
+			}
 			// Show version if requested
 			cmdShowVersionRun(cmd, args)
 		},
@@ -126,8 +130,10 @@ var (
 	serverStorageEngine      string
 	allPortOffsetsUnique     bool
 	jwtSecretFile            string
+	authStarterAPI           bool
 	sslKeyFile               string
 	sslAutoKeyFile           bool
+	sslAutoCA                bool
 	sslAutoServerName        string
 	sslAutoOrganization      string
 	sslCAFile                string
@@ -150,6 +156,11 @@ var (
 	debugCluster             bool
 	enableSync               bool
 	instanceUpTimeout        time.Duration
+	peerAuthMode             string
+	restartMaxFailures       []string
+	restartFailureWindow     []string
+	restartInitialBackoff    []string
+	restartMaxBackoff        []string
 	syncMonitoringToken      string
 	syncMasterKeyFile        string // TLS keyfile of local sync master
 	syncMasterClientCAFile   string // CA Certificate used for client certificate verification
@@ -189,6 +200,7 @@ func init() {
 
 	pf.BoolVar(&showVersion, "version", false, "If set, show version and exit")
 
+	f.StringVar(&configFilePath, configFileOptionName, "", "YAML or JSON file containing options. Options given on the command line take precedence")
 	f.StringSliceVar(&masterAddresses, "starter.join", nil, "join a cluster with master at given address")
 	f.StringVar(&mode, "starter.mode", "cluster", "Set the mode of operation to use (cluster|single|activefailover)")
 	f.BoolVar(&startLocalSlaves, "starter.local", false, "If set, local slaves will be started to create a machine local (test) cluster")
@@ -202,6 +214,7 @@ func init() {
 	f.BoolVar(&disableIPv6, "starter.disable-ipv6", !net.IsIPv6Supported(), "If set, no IPv6 notation will be used. Use this only when IPv6 address family is disabled")
 	f.BoolVar(&enableSync, "starter.sync", false, "If set, the starter will also start arangosync instances")
 	f.DurationVar(&instanceUpTimeout, "starter.instance-up-timeout", defaultInstanceUpTimeout, "Timeout to wait for an instance start")
+	f.StringVar(&peerAuthMode, "starter.peer-auth", string(service.PeerAuthModeNone), "Authentication required for the starter-to-starter API (none|jwt|mtls). jwt requires --auth.jwt-secret, mtls requires --ssl.auto-ca")
 	if err := features.JWTRotation().Register(f); err != nil {
 		panic(err)
 	}
@@ -227,6 +240,14 @@ func init() {
 	f.StringVar(&arangodJSPath, "server.js-dir", "/usr/share/arangodb3/js", "Path of arango JS folder")
 	f.StringVar(&rrPath, "server.rr", "", "Path of rr")
 	f.IntVar(&serverThreads, "server.threads", 0, "Adjust server.threads of each server")
+	f.StringSliceVar(&restartMaxFailures, "server.restart-max-failures", nil,
+		fmt.Sprintf("Maximum number of failures of a server within the failure window before it is marked failed, 0 means unlimited (default %d). Use <server-type>=<value> to set it for a single server type", service.DefaultRestartMaxFailures))
+	f.StringSliceVar(&restartFailureWindow, "server.restart-failure-window", nil,
+		fmt.Sprintf("Time window in which failures of a server are counted (default %s). Use <server-type>=<value> to set it for a single server type", service.DefaultRestartFailureWindow))
+	f.StringSliceVar(&restartInitialBackoff, "server.restart-initial-backoff", nil,
+		fmt.Sprintf("Delay before restarting a server that has failed (default %s). Use <server-type>=<value> to set it for a single server type", service.DefaultRestartInitialBackoff))
+	f.StringSliceVar(&restartMaxBackoff, "server.restart-max-backoff", nil,
+		fmt.Sprintf("Maximum delay before restarting a server that keeps failing (default %s). Use <server-type>=<value> to set it for a single server type", service.DefaultRestartMaxBackoff))
 	f.StringVar(&serverStorageEngine, "server.storage-engine", "", "Type of storage engine to use (mmfiles|rocksdb) (3.2 and up)")
 	f.StringVar(&rocksDBEncryptionKeyFile, "rocksdb.encryption-keyfile", "", "Key file used for RocksDB encryption. (Enterprise Edition 3.2 and up)")
 
@@ -244,12 +265,14 @@ func init() {
 	f.BoolVar(&dockerTTY, "docker.tty", true, "Run containers with TTY enabled")
 
 	f.StringVar(&jwtSecretFile, "auth.jwt-secret", "", "name of a plain text file containing a JWT secret used for server authentication")
+	f.BoolVar(&authStarterAPI, "auth.starter-api", false, "If set, the starter API requires a JWT token signed with the JWT secret. Tokens with the read-only role can only read, tokens with the admin role (default) can also make changes")
 
 	f.StringVar(&sslKeyFile, "ssl.keyfile", "", "path of a PEM encoded file containing a server certificate + private key")
 	f.StringVar(&sslCAFile, "ssl.cafile", "", "path of a PEM encoded file containing a CA certificate used for client authentication")
 	f.BoolVar(&sslAutoKeyFile, "ssl.auto-key", false, "If set, a self-signed certificate will be created and used as --ssl.keyfile")
 	f.StringVar(&sslAutoServerName, "ssl.auto-server-name", "", "Server name put into self-signed certificate. See --ssl.auto-key")
 	f.StringVar(&sslAutoOrganization, "ssl.auto-organization", "ArangoDB", "Organization name put into self-signed certificate. See --ssl.auto-key")
+	f.BoolVar(&sslAutoCA, "ssl.auto-ca", false, "If set, a certificate authority shared by all starters will be created and used to issue a certificate for the servers of every starter")
 
 	f.BoolSliceVar(&startSyncMaster, "sync.start-master", nil, "should an ArangoSync master instance be started (only relevant when starter.sync is enabled)")
 	f.BoolSliceVar(&startSyncWorker, "sync.start-worker", nil, "should an ArangoSync worker instance be started (only relevant when starter.sync is enabled)")
@@ -442,7 +465,17 @@ func init() {
 		},
 	}
 
-	config, flags, err := passthroughtPrefixesNew.Parse(os.Args...)
+	// Options from the configuration file are parsed along with the command line,
+	// such that passthrough options in that file are known.
+	configFileOptions = mustLoadConfigFile(os.Args)
+	args := append(configFileArgs(configFileOptions), os.Args...)
+	if isCheckCommand(os.Args) {
+		// Forbidden options are reported by `arangodb check` instead of refusing to run
+		forbiddenArgs = passthroughtPrefixesNew.ForbiddenArgs(args...)
+		args = withoutArgs(args, forbiddenArgs)
+		configFileOptions = withoutConfigFileOptions(configFileOptions, forbiddenArgs)
+	}
+	config, flags, err := passthroughtPrefixesNew.Parse(args...)
 	if err != nil {
 		log.Fatal().Err(err).Msgf("Unable to parse arguments")
 	}
@@ -463,6 +496,8 @@ func init() {
 
 	cmdStart.Flags().AddFlagSet(f)
 	cmdStop.Flags().AddFlagSet(f)
+	cmdCheck.Flags().AddFlagSet(f)
+	cmdConfigDump.Flags().AddFlagSet(f)
 }
 
 // setFlagValuesFromEnv sets defaults from environment variables
@@ -651,10 +686,10 @@ func cmdMainRun(cmd *cobra.Command, args []string) {
 	}
 
 	// Create service
-	svc, bsCfg := mustPrepareService(true)
+	svc, bsCfg := mustPrepareService(false)
 
 	// Interrupt signal:
-	sigChannel := make(chan os.Signal)
+	sigChannel := make(chan os.Signal, 1)
 	rootCtx, cancel := context.WithCancel(context.Background())
 	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go handleSignal(sigChannel, cancel, svc.RotateLogFiles)
@@ -709,9 +744,107 @@ func configureLogging(consoleOnly bool) {
 	log = logService.MustGetLogger(projectName)
 }
 
+// validateConfiguration checks the configured arguments for consistency,
+// without changing them. It returns all inconsistencies found.
+func validateConfiguration(jwtSecret string) []error {
+	var errs []error
+	if agencySize%2 == 0 || agencySize <= 0 {
+		errs = append(errs, clusterAgencySizeInvalidError())
+	}
+	if agencySize == 1 && ownAddress == "" {
+		errs = append(errs, clusterAgencySize1WithoutAddressError())
+	}
+	if dockerArangodImage != "" && rrPath != "" {
+		errs = append(errs, dockerImageWithRRIsNotAllowedError())
+	}
+	if dockerNetHost && dockerNetworkMode != "" && dockerNetworkMode != "host" {
+		errs = append(errs, dockerNetHostAndNotModeNotBothAllowedError())
+	}
+	if _, err := service.ParseImagePullPolicy(dockerImagePullPolicy, dockerArangodImage); err != nil {
+		errs = append(errs, errors.Wrapf(err, "Unsupport image pull policy '%s'", dockerImagePullPolicy))
+	}
+	if _, err := service.ParseRestartPolicies(restartMaxFailures, restartFailureWindow, restartInitialBackoff, restartMaxBackoff); err != nil {
+		errs = append(errs, errors.Wrap(err, "Invalid server restart policy"))
+	}
+	if _, err := url.Parse(advertisedEndpoint); err != nil {
+		errs = append(errs, errors.Wrapf(err, "Advertised cluster endpoint %s does not meet URL standards", advertisedEndpoint))
+	}
+
+	// Check starter API authentication settings
+	if authStarterAPI && jwtSecret == "" {
+		errs = append(errs, authStarterAPIJWTSecretMissingError())
+	}
+
+	// Check peer authentication settings
+	if parsedPeerAuthMode, err := service.ParsePeerAuthMode(peerAuthMode); err != nil {
+		errs = append(errs, errors.Wrap(err, "Invalid starter peer authentication"))
+	} else {
+		switch parsedPeerAuthMode {
+		case service.PeerAuthModeJWT:
+			if jwtSecret == "" {
+				errs = append(errs, peerAuthJWTSecretMissingError())
+			}
+		case service.PeerAuthModeMTLS:
+			if !sslAutoCA {
+				errs = append(errs, peerAuthAutoCAMissingError())
+			} else if len(masterAddresses) == 1 {
+				// A joining starter cannot fetch the CA from the master without authenticating with it
+				if _, err := os.Stat(service.AutoCACertFile(mustExpand(dataDir))); os.IsNotExist(err) {
+					errs = append(errs, peerAuthMTLSAutoCANotFoundError())
+				}
+			}
+		case service.PeerAuthModeNone:
+			if sslAutoCA && (len(masterAddresses) > 0 || startLocalSlaves) {
+				errs = append(errs, sslAutoCAPeerAuthMissingError())
+			}
+		}
+	}
+
+	// Check certificate settings
+	if sslAutoCA && (sslKeyFile != "" || sslAutoKeyFile) {
+		errs = append(errs, sslAutoCAAndKeyFileNotBothAllowedError())
+	} else if sslAutoKeyFile && sslKeyFile != "" {
+		errs = append(errs, sslAutoKeyAndKeyFileNotBothAllowedError())
+	}
+
+	// Check sync settings
+	if enableSync {
+		if !service.ServiceMode(mode).SupportsArangoSync() {
+			errs = append(errs, arangoSyncNotAllowedWithModeError(mode))
+		}
+		if startMaster := optionalBool(startSyncMaster, true); startMaster {
+			// With the auto CA, its certificate is used by default
+			if syncMasterKeyFile == "" && !sslAutoCA {
+				errs = append(errs, syncMasterServerKeyfileMissingError())
+			}
+			if syncMasterClientCAFile == "" {
+				errs = append(errs, syncMasterClientCAFileMissingError())
+			}
+		}
+		if syncMasterJWTSecretFile == "" && jwtSecretFile == "" {
+			errs = append(errs, syncMasterJWTSecretMissingError())
+		}
+	}
+	return errs
+}
+
+// readJWTSecret reads the JWT secret from the configured file (if any).
+func readJWTSecret() (string, error) {
+	if jwtSecretFile == "" {
+		return "", nil
+	}
+	path := mustExpand(jwtSecretFile)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to read JWT secret file '%s'", path)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
 // mustPrepareService creates a new Service for the configured arguments,
 // creating & checking settings where needed.
-func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.BootstrapConfig) {
+// When checkOnly is set, no directories or files are created.
+func mustPrepareService(checkOnly bool) (*service.Service, service.BootstrapConfig) {
 	// Auto detect docker container ID (if needed)
 	runningInDocker := false
 	if isRunningInDocker() {
@@ -732,30 +865,27 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 	}
 
 	// Some plausibility checks:
-	if agencySize%2 == 0 || agencySize <= 0 {
-		showClusterAgencySizeInvalidHelp()
-	}
-	if agencySize == 1 && ownAddress == "" {
-		showClusterAgencySize1WithoutAddressHelp()
+	jwtSecret, err := readJWTSecret()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot read JWT secret")
 	}
-	if dockerArangodImage != "" && rrPath != "" {
-		showDockerImageWithRRIsNotAllowedHelp()
+	if errs := validateConfiguration(jwtSecret); len(errs) > 0 {
+		showFatalErrorHelp(errs[0])
 	}
 	if dockerNetHost {
-		if dockerNetworkMode == "" {
-			dockerNetworkMode = "host"
-		} else if dockerNetworkMode != "host" {
-			showDockerNetHostAndNotModeNotBothAllowedHelp()
-		}
+		dockerNetworkMode = "host"
 	}
 	imagePullPolicy, err := service.ParseImagePullPolicy(dockerImagePullPolicy, dockerArangodImage)
 	if err != nil {
 		log.Fatal().Err(err).Msgf("Unsupport image pull policy '%s'", dockerImagePullPolicy)
 	}
-
-	// Sanity checking URL scheme on advertised endpoints
-	if _, err := url.Parse(advertisedEndpoint); err != nil {
-		log.Fatal().Err(err).Msgf("Advertised cluster endpoint %s does not meet URL standards", advertisedEndpoint)
+	restartPolicies, err := service.ParseRestartPolicies(restartMaxFailures, restartFailureWindow, restartInitialBackoff, restartMaxBackoff)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid server restart policy")
+	}
+	parsedPeerAuthMode, err := service.ParsePeerAuthMode(peerAuthMode)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid starter peer authentication")
 	}
 
 	// Expand home-dis (~) in paths
@@ -783,32 +913,33 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 		dataDir = "."
 	}
 	dataDir, _ = filepath.Abs(dataDir)
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatal().Err(err).Msgf("Cannot create data directory %s, giving up.", dataDir)
+	if !checkOnly {
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			log.Fatal().Err(err).Msgf("Cannot create data directory %s, giving up.", dataDir)
+		}
 	}
 
 	// Make custom log directory absolute
 	if logDir != "" {
 		logDir, _ = filepath.Abs(logDir)
-		if err := os.MkdirAll(logDir, 0755); err != nil {
-			log.Fatal().Err(err).Msgf("Cannot create custom log directory %s, giving up.", logDir)
+		if !checkOnly {
+			if err := os.MkdirAll(logDir, 0755); err != nil {
+				log.Fatal().Err(err).Msgf("Cannot create custom log directory %s, giving up.", logDir)
+			}
 		}
 	}
 
-	var jwtSecret string
-	if jwtSecretFile != "" {
-		content, err := ioutil.ReadFile(jwtSecretFile)
-		if err != nil {
-			log.Fatal().Err(err).Msgf("Failed to read JWT secret file '%s'", jwtSecretFile)
+	// Use certificate issued by the auto CA (if needed)
+	if sslAutoCA {
+		sslKeyFile = service.AutoCAKeyFile(dataDir)
+		if syncMasterKeyFile == "" {
+			syncMasterKeyFile = sslKeyFile
 		}
-		jwtSecret = strings.TrimSpace(string(content))
+		log.Info().Msgf("Using certificate issued by the starter certificate authority: %s", sslKeyFile)
 	}
 
 	// Auto create key file (if needed)
-	if sslAutoKeyFile && generateAutoKeyFile {
-		if sslKeyFile != "" {
-			showSslAutoKeyAndKeyFileNotBothAllowedHelp()
-		}
+	if sslAutoKeyFile && !checkOnly {
 		hosts := []string{"arangod.server"}
 		if sslAutoServerName != "" {
 			hosts = []string{sslAutoServerName}
@@ -827,12 +958,8 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 		log.Info().Msgf("Using self-signed certificate: %s", sslKeyFile)
 	}
 
-	// Check sync settings
+	// Complete sync settings
 	if enableSync {
-		// Check mode
-		if !service.ServiceMode(mode).SupportsArangoSync() {
-			showArangoSyncNotAllowedWithModeHelp(mode)
-		}
 		if !runningInDocker {
 			// Check arangosync executable
 			if _, err := os.Stat(arangoSyncPath); os.IsNotExist(err) {
@@ -846,23 +973,9 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 				dockerArangoSyncImage = dockerArangodImage
 			}
 		}
-		if startMaster := optionalBool(startSyncMaster, true); startMaster {
-			if syncMasterKeyFile == "" {
-				showSyncMasterServerKeyfileMissingHelp()
-			}
-			if syncMasterClientCAFile == "" {
-				showSyncMasterClientCAFileMissingHelp()
-			}
-		}
-		/*		if startWorker := optionalBool(startSyncWorker, true); startWorker {
-				}*/
 		if syncMasterJWTSecretFile == "" {
-			if jwtSecretFile != "" {
-				// Use cluster JWT secret
-				syncMasterJWTSecretFile = jwtSecretFile
-			} else {
-				showSyncMasterJWTSecretMissingHelp()
-			}
+			// Use cluster JWT secret
+			syncMasterJWTSecretFile = jwtSecretFile
 		}
 		if syncMonitoringToken == "" {
 			syncMonitoringToken = uniuri.New()
@@ -889,6 +1002,8 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 		JwtSecret:                jwtSecret,
 		SslKeyFile:               sslKeyFile,
 		SslCAFile:                sslCAFile,
+		SslAutoCA:                sslAutoCA,
+		SslAutoOrganization:      sslAutoOrganization,
 		RocksDBEncryptionKeyFile: rocksDBEncryptionKeyFile,
 		DisableIPv6:              disableIPv6,
 	}
@@ -911,6 +1026,9 @@ func mustPrepareService(generateAutoKeyFile bool) (*service.Service, service.Boo
 		LogRotateFilesToKeep:    logRotateFilesToKeep,
 		LogRotateInterval:       logRotateInterval,
 		InstanceUpTimeout:       instanceUpTimeout,
+		RestartPolicies:         restartPolicies,
+		PeerAuthMode:            parsedPeerAuthMode,
+		APIAuthentication:       authStarterAPI,
 		RunningInDocker:         isRunningInDocker(),
 		DockerContainerName:     dockerContainerName,
 		DockerEndpoint:          dockerEndpoint,
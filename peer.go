@@ -0,0 +1,107 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+var (
+	cmdPeer = &cobra.Command{
+		Use:   "peer",
+		Short: "Manage peers (starters) of the cluster",
+		Run:   cmdShowUsage,
+	}
+	cmdPeerSetRoles = &cobra.Command{
+		Use:   "set-roles",
+		Short: "Change the servers started by a starter of a running cluster",
+		Long: "Change the servers started by a starter of a running cluster.\n" +
+			"Only the dbserver & coordinator roles can be changed. The agent role (and the size of the agency)\n" +
+			"of a running cluster cannot be changed, use --cluster.agency-size when creating the cluster instead.",
+		Run: cmdPeerSetRolesRun,
+	}
+	peerSetRolesOptions struct {
+		starterEndpoint string
+		starterID       string
+		agent           bool
+		dbserver        bool
+		coordinator     bool
+	}
+)
+
+func init() {
+	f := cmdPeerSetRoles.Flags()
+	f.StringVar(&peerSetRolesOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
+	f.StringVar(&peerSetRolesOptions.starterID, "id", "", "The ID of the starter whose roles are changed")
+	f.BoolVar(&peerSetRolesOptions.agent, "agent", false, "Not supported: the agent role of a running cluster cannot be changed")
+	f.BoolVar(&peerSetRolesOptions.dbserver, "dbserver", true, "If set, the starter runs a dbserver")
+	f.BoolVar(&peerSetRolesOptions.coordinator, "coordinator", true, "If set, the starter runs a coordinator")
+
+	cmdMain.AddCommand(cmdPeer)
+	cmdPeer.AddCommand(cmdPeerSetRoles)
+}
+
+func cmdPeerSetRolesRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Only the roles given on the command line are changed
+	f := cmd.Flags()
+	var roles client.PeerRoles
+	if f.Changed("agent") {
+		log.Fatal().Msg("Changing the agent role of a running cluster is not supported, use --cluster.agency-size when creating the cluster")
+	}
+	if f.Changed("dbserver") {
+		roles.DBServer = &peerSetRolesOptions.dbserver
+	}
+	if f.Changed("coordinator") {
+		roles.Coordinator = &peerSetRolesOptions.coordinator
+	}
+	if roles.DBServer == nil && roles.Coordinator == nil {
+		log.Fatal().Msg("Specify at least one of --dbserver or --coordinator")
+	}
+
+	// Create starter client
+	c := mustCreateStarterClient(peerSetRolesOptions.starterEndpoint)
+
+	// Use the ID of the starter for which the endpoint is given (if needed)
+	ctx := context.Background()
+	id := peerSetRolesOptions.starterID
+	if id == "" {
+		info, err := c.ID(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to fetch ID from starter")
+		}
+		id = info.ID
+	}
+
+	log.Info().Msgf("Changing roles of starter %s, this can take a while when a dbserver must be cleaned out...", id)
+	if err := c.SetPeerRoles(ctx, id, roles); err != nil {
+		log.Fatal().Err(err).Msg("Changing roles of starter failed")
+	}
+	log.Info().Msgf("Roles of starter %s have been changed", id)
+}
@@ -45,9 +45,21 @@ type MemberInventory struct {
 
 	Hashes *MemberHashes `json:"hashes,omitempty"`
 
+	Process *MemberProcess `json:"process,omitempty"`
+
 	*Error `json:",inline"`
 }
 
+type MemberProcess struct {
+	Restarts int  `json:"restarts"`
+	Failures int  `json:"failures"`
+	Failed   bool `json:"failed,omitempty"`
+}
+
 type MemberHashes struct {
 	JWT client.JWTDetailsResult `json:"jwt"`
+
+	TLS *client.TLSDetailsResult `json:"tls,omitempty"`
+
+	Encryption *client.EncryptionDetailsResult `json:"encryption,omitempty"`
 }
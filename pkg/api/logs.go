@@ -0,0 +1,54 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package api
+
+import (
+	"time"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+// ClusterLogs holds the log lines of the servers of all starters in the cluster,
+// ordered by their timestamp.
+type ClusterLogs struct {
+	Lines []ClusterLogLine `json:"lines"`
+
+	// Errors holds the servers of which the log could not be fetched.
+	Errors []ClusterLogsError `json:"errors,omitempty"`
+}
+
+// ClusterLogLine is a single line of the log of a server in the cluster.
+type ClusterLogLine struct {
+	// Time of the line. Lines that are a continuation of a multi line
+	// message get the time of the line that started the message.
+	Time time.Time              `json:"time,omitempty"`
+	Peer string                 `json:"peer"`
+	Type definitions.ServerType `json:"type"`
+	Line string                 `json:"line"`
+}
+
+// ClusterLogsError describes the failure to fetch the log of a server in the cluster.
+type ClusterLogsError struct {
+	Peer string                 `json:"peer"`
+	Type definitions.ServerType `json:"type"`
+
+	*Error `json:",inline"`
+}
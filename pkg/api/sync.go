@@ -0,0 +1,28 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package api
+
+// SyncHealth is the result of the health check of the arangosync servers started by a starter.
+type SyncHealth struct {
+	// StateReported is set when the sync master of the starter is the leading one,
+	// which reported a synchronization state that is not broken.
+	StateReported bool `json:"state-reported,omitempty"`
+}
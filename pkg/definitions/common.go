@@ -34,8 +34,7 @@ const (
 )
 
 const (
-	MinRecentFailuresForLog = 2   // Number of recent failures needed before a log file is shown.
-	MaxRecentFailures       = 100 // Maximum number of recent failures before the starter gives up.
+	MinRecentFailuresForLog = 2 // Number of recent failures needed before a log file is shown.
 )
 
 const (
@@ -43,4 +42,13 @@ const (
 	ArangodJWTSecretFileName   = "arangod.jwtsecret"
 	ArangodJWTSecretFolderName = "jwt"
 	ArangodJWTSecretActive     = "-"
+
+	ArangodEncryptionKeyFolderName = "rocksdb-encryption-keys"
+	ArangodEncryptionKeyActive     = "-"
+	ArangodEncryptionKeyLength     = 32
+
+	TLSFolderName     = "tls"
+	TLSCACertFileName = "ca.crt"
+	TLSCAKeyFileName  = "ca.key"
+	TLSKeyFileName    = "tls.keyfile"
 )
@@ -0,0 +1,31 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package features
+
+var (
+	encryptionRotation = NewFeature("encryption.rotation", "Enable RocksDB encryption key rotation and folder support", true, func(v Version) bool {
+		return v.Enterprise && v.Version.CompareTo("3.7.1") >= 0
+	})
+)
+
+func EncryptionRotation() Feature {
+	return encryptionRotation
+}
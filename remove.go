@@ -24,8 +24,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
 )
 
 var (
@@ -43,14 +48,19 @@ var (
 		starterEndpoint string
 		starterID       string
 		force           bool
+		async           bool
+		status          bool
 	}
 )
 
 func init() {
 	f := cmdRemoveStarter.Flags()
 	f.StringVar(&removeStarterOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
 	f.StringVar(&removeStarterOptions.starterID, "starter.id", "", "The ID of the starter to remove")
 	f.BoolVar(&removeStarterOptions.force, "force", false, "If set to true, the starter will be removed even if the servers cannot be properly shutdown")
+	f.BoolVar(&removeStarterOptions.async, "async", false, "If set to true, return as soon as the removal of the starter has been started")
+	f.BoolVar(&removeStarterOptions.status, "status", false, "If set to true, show the status of the last removal of the starter instead of removing it")
 
 	cmdMain.AddCommand(cmdRemove)
 	cmdRemove.AddCommand(cmdRemoveStarter)
@@ -71,8 +81,33 @@ func cmdRemoveStarterRun(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err).Msg("Failed to fetch ID from starter")
 	}
 
+	if removeStarterOptions.status {
+		// Show the status of the last removal
+		id := removeStarterOptions.starterID
+		if id == "" {
+			id = info.ID
+		}
+		status, err := c.PeerRemovalStatus(ctx, id)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to fetch removal status")
+		}
+		b, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to encode removal status")
+		}
+		fmt.Println(string(b))
+		return
+	}
+
 	// Compare ID with requested.
 	if removeStarterOptions.starterID == "" || removeStarterOptions.starterID == info.ID {
+		if removeStarterOptions.async {
+			log.Fatal().Msg("--async is not supported when removing the starter at --starter.endpoint")
+		}
+		// Follow the removal while the starter is shutting down
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go waitForPeerRemoval(watchCtx, c, info.ID)
 		// Shutdown (with goodbye) the starter at given endpoint
 		goodbye := true
 		if err := c.Shutdown(ctx, goodbye); err != nil {
@@ -82,10 +117,56 @@ func cmdRemoveStarterRun(cmd *cobra.Command, args []string) {
 		}
 	} else {
 		// Remove another starter from the cluster
-		if err := c.RemovePeer(ctx, removeStarterOptions.starterID, removeStarterOptions.force); err != nil {
+		id := removeStarterOptions.starterID
+		if err := c.RemovePeerAsync(ctx, id, removeStarterOptions.force); err != nil {
 			log.Fatal().Err(err).Msg("Removing starter from cluster failed")
-		} else {
+		}
+		if removeStarterOptions.async {
+			log.Info().Msgf("Removal of starter has been started, use `arangodb remove starter --status --starter.id=%s` to follow its progress", id)
+			return
+		}
+		if _, err := c.PeerRemovalStatus(ctx, id); client.IsNotFound(err) {
+			// The removal is not tracked by the master, so it has already been done
 			log.Info().Msg("Starter has been removed from cluster")
+			return
+		}
+		status := waitForPeerRemoval(ctx, c, id)
+		if status.State == client.PeerRemovalStateFailed {
+			log.Fatal().Msgf("Removing starter from cluster failed: %s", status.Error)
+		}
+		log.Info().Msg("Starter has been removed from cluster")
+	}
+}
+
+// waitForPeerRemoval shows the progress of the removal of the starter with given ID,
+// until that removal is done or the given context is canceled.
+func waitForPeerRemoval(ctx context.Context, c client.API, id string) client.PeerRemovalStatus {
+	var last client.PeerRemovalStatus
+	for {
+		if status, err := c.PeerRemovalStatus(ctx, id); err == nil {
+			if status.State != last.State || status.JobState != last.JobState || status.ShardsRemaining != last.ShardsRemaining {
+				l := log.Info().Str("state", string(status.State))
+				if status.JobID != "" {
+					l = l.Str("job", status.JobID).Str("job-state", status.JobState)
+				}
+				if status.ShardsTotal > 0 {
+					l.Msgf("Removing starter: %d of %d shards remaining", status.ShardsRemaining, status.ShardsTotal)
+				} else {
+					l.Msg("Removing starter")
+				}
+			}
+			last = status
+			if status.IsDone() {
+				return status
+			}
+		} else if ctx.Err() == nil {
+			log.Debug().Err(err).Msg("Failed to fetch removal status")
+		}
+		select {
+		case <-ctx.Done():
+			return last
+		case <-time.After(time.Second):
+			// Continue
 		}
 	}
 }
@@ -0,0 +1,156 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+var (
+	cmdRestart = &cobra.Command{
+		Use:   "restart",
+		Short: "Restart the servers of an ArangoDB deployment one at a time",
+		Run:   cmdRestartRun,
+	}
+	cmdRetryRestart = &cobra.Command{
+		Use:   "restart",
+		Short: "Retry a failed rolling restart of an ArangoDB deployment",
+		Run:   cmdRetryRestartRun,
+	}
+	cmdAbortRestart = &cobra.Command{
+		Use:   "restart",
+		Short: "Abort (or remove) a rolling restart of an ArangoDB deployment",
+		Run:   cmdAbortRestartRun,
+	}
+	restartOptions struct {
+		starterEndpoint string
+		serverType      string
+	}
+	retryRestartOptions struct {
+		starterEndpoint string
+	}
+	abortRestartOptions struct {
+		starterEndpoint string
+	}
+)
+
+func init() {
+	f := cmdRestart.Flags()
+	f.StringVar(&restartOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	f.StringVar(&restartOptions.serverType, "type", string(client.RestartTypeAll), "Type of servers to restart (agent|dbserver|coordinator|single|all)")
+	addStarterAuthFlags(f)
+
+	f = cmdRetryRestart.Flags()
+	f.StringVar(&retryRestartOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
+
+	f = cmdAbortRestart.Flags()
+	f.StringVar(&abortRestartOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	addStarterAuthFlags(f)
+
+	cmdMain.AddCommand(cmdRestart)
+	cmdRetry.AddCommand(cmdRetryRestart)
+	cmdAbort.AddCommand(cmdAbortRestart)
+}
+
+func cmdRestartRun(cmd *cobra.Command, args []string) {
+	runRestart(restartOptions.starterEndpoint, client.ServerType(restartOptions.serverType), false)
+}
+
+func cmdRetryRestartRun(cmd *cobra.Command, args []string) {
+	runRestart(retryRestartOptions.starterEndpoint, "", true)
+}
+
+func cmdAbortRestartRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(abortRestartOptions.starterEndpoint)
+	ctx := context.Background()
+	if err := c.AbortDatabaseRestart(ctx); client.IsNotFound(err) {
+		log.Fatal().Msg("Restart plan does not exist")
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Failed to abort restart")
+	} else {
+		log.Info().Msg("Restart plan has been removed")
+	}
+}
+
+func runRestart(starterEndpoint string, serverType client.ServerType, retry bool) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(starterEndpoint)
+	ctx := context.Background()
+	if retry {
+		if err := c.RetryDatabaseRestart(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to retry restart")
+		}
+		log.Info().Msg("Restart has been retried")
+	} else {
+		if err := c.StartDatabaseRestart(ctx, serverType); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start restart")
+		}
+		log.Info().Msgf("Restart of %s servers has been started", serverType)
+	}
+
+	// Wait for the restart to finish
+	remaining := ""
+	finished := ""
+	for {
+		status, err := c.RestartStatus(ctx)
+		if client.IsNotFound(err) {
+			// Restart plan is gone
+			log.Error().Msg("Restart plan is gone.")
+			return
+		} else if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch restart status")
+		} else {
+			if status.Failed {
+				log.Error().Str("reason", status.Reason).Msg("Restart has failed, use `arangodb retry restart` to retry")
+				return
+			}
+			if status.Ready {
+				log.Info().Msg("Restart has finished")
+				// Let's remove the plan now
+				if err := c.AbortDatabaseRestart(ctx); err != nil {
+					log.Warn().Err(err).Msg("Failed to remove restart plan")
+				}
+				return
+			}
+			r, f := formatServerStatusList(status.ServersRemaining), formatServerStatusList(status.ServersRestarted)
+			if remaining != r || finished != f {
+				remaining, finished = r, f
+				log.Info().Msgf("Servers restarted: %s, remaining servers: %s", finished, remaining)
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
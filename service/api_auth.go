@@ -0,0 +1,339 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+// StarterRole specifies what a token is allowed to do with the starter API.
+type StarterRole string
+
+const (
+	// StarterRoleClaim is the token claim that holds the starter role.
+	StarterRoleClaim = "starter_role"
+	// StarterTokenIssuer is the issuer of tokens for the starter API.
+	StarterTokenIssuer = "arangodb-starter-api"
+
+	StarterRoleReadOnly StarterRole = "read-only" // Allowed to read only (GET requests)
+	StarterRoleAdmin    StarterRole = "admin"     // Allowed to read & change
+)
+
+// ParseStarterRole parses the given string into a starter role.
+func ParseStarterRole(v string) (StarterRole, error) {
+	switch r := StarterRole(strings.ToLower(v)); r {
+	case StarterRoleReadOnly, StarterRoleAdmin:
+		return r, nil
+	default:
+		return "", maskAny(fmt.Errorf("Unknown starter role '%s'", v))
+	}
+}
+
+// Allows returns true if a token with this role is allowed to do what requires the given role.
+func (r StarterRole) Allows(required StarterRole) bool {
+	return r == StarterRoleAdmin || r == required
+}
+
+// starterKey derives the key used to sign tokens for the starter API from the given cluster secret,
+// so these tokens cannot be used to authenticate with the servers (and server tokens are no starter tokens).
+func starterKey(jwtSecret string) []byte {
+	h := hmac.New(sha256.New, []byte(jwtSecret))
+	h.Write([]byte(StarterTokenIssuer))
+	return h.Sum(nil)
+}
+
+// CreateStarterToken creates a JWT token for the starter API with the given role that expires
+// after the given duration, signed with a key derived from the given secret.
+// If user is not empty, it is the subject of the token.
+func CreateStarterToken(jwtSecret, user string, role StarterRole, exp time.Duration) (string, error) {
+	if exp <= 0 {
+		return "", maskAny(errors.Errorf("Tokens for the starter API must expire"))
+	}
+	t := time.Now().UTC()
+	claims := jwt.MapClaims{
+		"iss":            StarterTokenIssuer,
+		"iat":            t.Unix(),
+		"exp":            t.Add(exp).Unix(),
+		StarterRoleClaim: string(role),
+	}
+	if user != "" {
+		claims["preferred_username"] = user
+	}
+	signedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(starterKey(jwtSecret))
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return signedToken, nil
+}
+
+// jwtSecretSource provides the JWT secrets of the cluster.
+// When the secrets of the servers are kept in a folder, the secrets in that folder are used,
+// so secrets rotated through /admin/jwt are taken into account.
+type jwtSecretSource struct {
+	secret string // Secret given with --auth.jwt-secret
+	folder string // Folder of the JWT secrets of the servers
+}
+
+// secrets returns all JWT secrets, the active one first.
+func (j jwtSecretSource) secrets() ([]string, error) {
+	if j.folder == "" {
+		return []string{j.secret}, nil
+	}
+	tokens, err := newJWTManager(j.folder).tokens()
+	if os.IsNotExist(err) {
+		return []string{j.secret}, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	active, found := tokens[definitions.ArangodJWTSecretActive]
+	if !found {
+		return []string{j.secret}, nil
+	}
+	result := []string{string(active)}
+	for name, data := range tokens {
+		if name != definitions.ArangodJWTSecretActive {
+			result = append(result, string(data))
+		}
+	}
+	return result, nil
+}
+
+// active returns the active JWT secret.
+func (j jwtSecretSource) active() (string, error) {
+	secrets, err := j.secrets()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return secrets[0], nil
+}
+
+// apiAuthenticator authorizes requests to the public starter API.
+type apiAuthenticator struct {
+	enabled  bool
+	secrets  jwtSecretSource
+	peerAuth *peerAuthenticator // Authentication of requests made by other starters
+}
+
+// newAPIAuthenticator creates the authorization used by the public starter API.
+func newAPIAuthenticator(enabled bool, secrets jwtSecretSource, peerAuth *peerAuthenticator) (*apiAuthenticator, error) {
+	if enabled && secrets.secret == "" {
+		return nil, maskAny(fmt.Errorf("Starter API authentication requires a JWT secret"))
+	}
+	return &apiAuthenticator{
+		enabled:  enabled,
+		secrets:  secrets,
+		peerAuth: peerAuth,
+	}, nil
+}
+
+// authorize returns the HTTP status code & error when the given request is not allowed to do
+// what requires the given role. Requests of other starters (see peerAuthenticator) are always allowed.
+func (a *apiAuthenticator) authorize(r *http.Request, required StarterRole) (int, error) {
+	if !a.enabled {
+		return 0, nil
+	}
+	if a.isPeerRequest(r) {
+		return 0, nil
+	}
+	return a.authorizeToken(r, required)
+}
+
+// isPeerRequest returns true when the given request is made by another starter (see peerAuthenticator).
+func (a *apiAuthenticator) isPeerRequest(r *http.Request) bool {
+	return a.peerAuth.mode != PeerAuthModeNone && a.peerAuth.authenticate(r) == nil
+}
+
+// authorizeToken returns the HTTP status code & error when the bearer token of the given request
+// does not have the given role.
+func (a *apiAuthenticator) authorizeToken(r *http.Request, required StarterRole) (int, error) {
+	header := r.Header.Get(AuthorizationHeader)
+	if !strings.HasPrefix(strings.ToLower(header), BearerPrefix) {
+		return http.StatusUnauthorized, errors.Errorf("Missing bearer token")
+	}
+	role, _, err := a.parseToken(header[len(BearerPrefix):])
+	if err != nil {
+		return http.StatusUnauthorized, maskAny(err)
+	}
+	if !role.Allows(required) {
+		return http.StatusForbidden, errors.Errorf("Role '%s' is not allowed to do this, '%s' is required", role, required)
+	}
+	return 0, nil
+}
+
+// parseToken verifies the given token, which must be signed with a key derived from one of the
+// JWT secrets (see CreateStarterToken), and returns its role & subject.
+// The subject is taken from the preferred_username (or sub) claim, it is empty when the token has neither.
+func (a *apiAuthenticator) parseToken(token string) (StarterRole, string, error) {
+	secrets, err := a.secrets.secrets()
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	var t *jwt.Token
+	for _, secret := range secrets {
+		key := starterKey(secret)
+		if t, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.Errorf("Unexpected signing method %v", t.Header["alg"])
+			}
+			return key, nil
+		}); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(StarterTokenIssuer, true) {
+		return "", "", maskAny(errors.Errorf("Invalid token issuer"))
+	}
+	if _, found := claims["exp"]; !found {
+		return "", "", maskAny(errors.Errorf("Token without expiration"))
+	}
+	subject, _ := claims["preferred_username"].(string)
+	if subject == "" {
+		subject, _ = claims["sub"].(string)
+	}
+	raw, found := claims[StarterRoleClaim]
+	if !found {
+		return "", "", maskAny(errors.Errorf("Token without %s claim", StarterRoleClaim))
+	}
+	v, ok := raw.(string)
+	if !ok {
+		return "", "", maskAny(errors.Errorf("Invalid %s claim", StarterRoleClaim))
+	}
+	role, err := ParseStarterRole(v)
+	if err != nil {
+		return "", "", maskAny(err)
+	}
+	return role, subject, nil
+}
+
+// requester returns a description of who made the given request, e.g. for the upgrade history.
+// When the starter API requires a token, that is the subject of the token, or else its role
+// and the remote address. Requests forwarded by other starters (see peerAuthenticator) and
+// requests to a starter API without authentication are described by the given description
+// sent by the client (if any) or else the remote address.
+func (a *apiAuthenticator) requester(r *http.Request, description string) string {
+	if a.enabled && !a.isPeerRequest(r) {
+		header := r.Header.Get(AuthorizationHeader)
+		if strings.HasPrefix(strings.ToLower(header), BearerPrefix) {
+			if role, subject, err := a.parseToken(header[len(BearerPrefix):]); err == nil {
+				if subject != "" {
+					return subject
+				}
+				return fmt.Sprintf("%s token from %s", role, r.RemoteAddr)
+			}
+		}
+		return r.RemoteAddr
+	}
+	if description != "" {
+		return description
+	}
+	return r.RemoteAddr
+}
+
+// requesterClientOptions returns the options of a client that forwards a request made by the given
+// requester (see requester) to another starter.
+// Without peer authentication, the other starter only sees a token, so that token carries the requester as subject.
+func (a *apiAuthenticator) requesterClientOptions(requester string) []client.ClientOption {
+	if a.peerAuth.mode != PeerAuthModeNone || !a.enabled {
+		return a.peerAuth.clientOptions
+	}
+	secrets := a.secrets
+	return []client.ClientOption{client.WithTokenSource(func() (string, error) {
+		jwtSecret, err := secrets.active()
+		if err != nil {
+			return "", maskAny(err)
+		}
+		return CreateStarterToken(jwtSecret, requester, StarterRoleAdmin, time.Minute)
+	})}
+}
+
+// requestsAuthenticated returns true when requests to the starter API are
+// authenticated, by peer authentication (see peerAuthenticator) and/or by tokens.
+func (a *apiAuthenticator) requestsAuthenticated() bool {
+	return a.peerAuth.mode != PeerAuthModeNone || a.enabled
+}
+
+// requireRole wraps the given handler such that it can only be used with a token that has the given role.
+func (a *apiAuthenticator) requireRole(role StarterRole, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, err := a.authorize(r, role); err != nil {
+			writeError(w, code, fmt.Sprintf("Authorization failed: %v", err))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// authorizePeerOrRole returns the HTTP status code & error when the given request is neither made by
+// another starter (see peerAuthenticator) nor made with a token that has the given role.
+// Without peer authentication, this is the same as requiring the given role.
+func (a *apiAuthenticator) authorizePeerOrRole(r *http.Request, required StarterRole) (int, error) {
+	if a.peerAuth.mode == PeerAuthModeNone {
+		return a.authorize(r, required)
+	}
+	peerErr := a.peerAuth.authenticate(r)
+	if peerErr == nil {
+		return 0, nil
+	}
+	if a.secrets.secret == "" {
+		return http.StatusUnauthorized, errors.Wrap(peerErr, "Peer authentication failed")
+	}
+	return a.authorizeToken(r, required)
+}
+
+// requirePeerOrRole wraps the given handler such that it can only be used by other starters
+// or with a token that has the given role.
+func (a *apiAuthenticator) requirePeerOrRole(role StarterRole, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code, err := a.authorizePeerOrRole(r, role); err != nil {
+			writeError(w, code, fmt.Sprintf("Authorization failed: %v", err))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireRoleByMethod wraps the given handler such that reading (GET) requires the read-only role
+// and all other requests require the admin role.
+func (a *apiAuthenticator) requireRoleByMethod(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := StarterRoleAdmin
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			role = StarterRoleReadOnly
+		}
+		a.requireRole(role, h)(w, r)
+	}
+}
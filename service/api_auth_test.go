@@ -0,0 +1,263 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_APIAuthenticator(t *testing.T) {
+	peerAuth := &peerAuthenticator{mode: PeerAuthModeNone}
+
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/shutdown", nil)
+		if token != "" {
+			r.Header.Set(AuthorizationHeader, BearerPrefix+token)
+		}
+		return r
+	}
+	readOnly, err := CreateStarterToken("secret", "", StarterRoleReadOnly, time.Minute)
+	require.NoError(t, err)
+	admin, err := CreateStarterToken("secret", "", StarterRoleAdmin, time.Minute)
+	require.NoError(t, err)
+	// Server tokens & tokens without role or expiration are no starter tokens
+	superUser, err := CreateJwtToken("secret", "", "", nil, time.Minute, nil)
+	require.NoError(t, err)
+	withRole, err := CreateJwtToken("secret", "", "", nil, time.Minute, jwt.MapClaims{StarterRoleClaim: string(StarterRoleAdmin)})
+	require.NoError(t, err)
+	withoutRole, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": StarterTokenIssuer,
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(starterKey("secret"))
+	require.NoError(t, err)
+	withoutExp, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":            StarterTokenIssuer,
+		StarterRoleClaim: string(StarterRoleAdmin),
+	}).SignedString(starterKey("secret"))
+	require.NoError(t, err)
+	_, err = CreateStarterToken("secret", "", StarterRoleAdmin, 0)
+	require.Error(t, err)
+	other, err := CreateStarterToken("other", "", StarterRoleAdmin, time.Minute)
+	require.NoError(t, err)
+
+	// Everything is allowed when disabled
+	apiAuth, err := newAPIAuthenticator(false, jwtSecretSource{secret: ""}, peerAuth)
+	require.NoError(t, err)
+	_, err = apiAuth.authorize(newRequest(""), StarterRoleAdmin)
+	require.NoError(t, err)
+
+	_, err = newAPIAuthenticator(true, jwtSecretSource{secret: ""}, peerAuth)
+	require.Error(t, err)
+	apiAuth, err = newAPIAuthenticator(true, jwtSecretSource{secret: "secret"}, peerAuth)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		token    string
+		required StarterRole
+		code     int
+	}{
+		{"", StarterRoleReadOnly, http.StatusUnauthorized},
+		{other, StarterRoleReadOnly, http.StatusUnauthorized},
+		{readOnly, StarterRoleReadOnly, 0},
+		{readOnly, StarterRoleAdmin, http.StatusForbidden},
+		{admin, StarterRoleAdmin, 0},
+		{superUser, StarterRoleReadOnly, http.StatusUnauthorized},
+		{withRole, StarterRoleReadOnly, http.StatusUnauthorized},
+		{withoutRole, StarterRoleReadOnly, http.StatusUnauthorized},
+		{withoutExp, StarterRoleReadOnly, http.StatusUnauthorized},
+	} {
+		code, err := apiAuth.authorize(newRequest(c.token), c.required)
+		require.Equal(t, c.code, code, "%s %s", c.token, c.required)
+		if c.code == 0 {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	// Requests of authenticated starters are allowed
+	apiAuth.peerAuth = &peerAuthenticator{mode: PeerAuthModeJWT, jwtSecret: "secret"}
+	r := newRequest("")
+	peerToken, err := client.CreatePeerToken(client.PeerKey([]byte("secret")))
+	require.NoError(t, err)
+	r.Header.Set(AuthorizationHeader, BearerPrefix+peerToken)
+	_, err = apiAuth.authorize(r, StarterRoleAdmin)
+	require.NoError(t, err)
+}
+
+func Test_APIAuthenticatorRotatedSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starter-jwt")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	apiAuth, err := newAPIAuthenticator(true, jwtSecretSource{secret: "secret", folder: dir}, &peerAuthenticator{mode: PeerAuthModeNone})
+	require.NoError(t, err)
+	authorize := func(jwtSecret string) error {
+		token, err := CreateStarterToken(jwtSecret, "", StarterRoleAdmin, time.Minute)
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPost, "/shutdown", nil)
+		r.Header.Set(AuthorizationHeader, BearerPrefix+token)
+		_, err = apiAuth.authorize(r, StarterRoleAdmin)
+		return err
+	}
+
+	// Without active secret in the folder, the configured secret is used
+	require.NoError(t, authorize("secret"))
+	require.Error(t, authorize("rotated"))
+
+	// All secrets in the folder are accepted
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, definitions.ArangodJWTSecretActive), []byte("rotated"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, Sha256sum([]byte("secret"))), []byte("secret"), 0600))
+	require.NoError(t, authorize("secret"))
+	require.NoError(t, authorize("rotated"))
+	active, err := apiAuth.secrets.active()
+	require.NoError(t, err)
+	require.Equal(t, "rotated", active)
+
+	// Removed secrets are refused
+	require.NoError(t, os.Remove(filepath.Join(dir, Sha256sum([]byte("secret")))))
+	require.Error(t, authorize("secret"))
+	require.NoError(t, authorize("rotated"))
+}
+
+func Test_RequireRoleByMethod(t *testing.T) {
+	apiAuth, err := newAPIAuthenticator(true, jwtSecretSource{secret: "secret"}, &peerAuthenticator{mode: PeerAuthModeNone})
+	require.NoError(t, err)
+	readOnly, err := CreateStarterToken("secret", "", StarterRoleReadOnly, time.Minute)
+	require.NoError(t, err)
+
+	h := apiAuth.requireRoleByMethod(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for method, code := range map[string]int{
+		http.MethodGet:    http.StatusOK,
+		http.MethodPost:   http.StatusForbidden,
+		http.MethodDelete: http.StatusForbidden,
+	} {
+		r := httptest.NewRequest(method, "/database-auto-upgrade", nil)
+		r.Header.Set(AuthorizationHeader, BearerPrefix+readOnly)
+		w := httptest.NewRecorder()
+		h(w, r)
+		require.Equal(t, code, w.Code, method)
+	}
+}
+
+func Test_AuthorizePeerOrRole(t *testing.T) {
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/goodbye", nil)
+		if token != "" {
+			r.Header.Set(AuthorizationHeader, BearerPrefix+token)
+		}
+		return r
+	}
+	readOnly, err := CreateStarterToken("secret", "", StarterRoleReadOnly, time.Minute)
+	require.NoError(t, err)
+	admin, err := CreateStarterToken("secret", "", StarterRoleAdmin, time.Minute)
+	require.NoError(t, err)
+	peerToken, err := client.CreatePeerToken(client.PeerKey([]byte("secret")))
+	require.NoError(t, err)
+
+	// Without any authentication, everything is allowed
+	peerAuth := &peerAuthenticator{mode: PeerAuthModeNone}
+	apiAuth, err := newAPIAuthenticator(false, jwtSecretSource{secret: "secret"}, peerAuth)
+	require.NoError(t, err)
+	_, err = apiAuth.authorizePeerOrRole(newRequest(""), StarterRoleAdmin)
+	require.NoError(t, err)
+
+	// Without peer authentication, the role is required
+	apiAuth, err = newAPIAuthenticator(true, jwtSecretSource{secret: "secret"}, peerAuth)
+	require.NoError(t, err)
+	code, err := apiAuth.authorizePeerOrRole(newRequest(""), StarterRoleAdmin)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, code)
+	_, err = apiAuth.authorizePeerOrRole(newRequest(admin), StarterRoleAdmin)
+	require.NoError(t, err)
+
+	// With peer authentication, starters & tokens with the role are allowed,
+	// even when the starter API does not require authentication
+	peerAuth = &peerAuthenticator{mode: PeerAuthModeJWT, jwtSecret: "secret"}
+	for _, enabled := range []bool{false, true} {
+		apiAuth, err := newAPIAuthenticator(enabled, jwtSecretSource{secret: "secret"}, peerAuth)
+		require.NoError(t, err)
+		for _, c := range []struct {
+			token string
+			code  int
+		}{
+			{"", http.StatusUnauthorized},
+			{peerToken, 0},
+			{admin, 0},
+			{readOnly, http.StatusForbidden},
+		} {
+			code, err := apiAuth.authorizePeerOrRole(newRequest(c.token), StarterRoleAdmin)
+			require.Equal(t, c.code, code, "%v %s", enabled, c.token)
+			if c.code == 0 {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		}
+	}
+}
+
+func Test_Requester(t *testing.T) {
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/database-auto-upgrade", nil)
+		r.RemoteAddr = "10.0.0.1:4321"
+		if token != "" {
+			r.Header.Set(AuthorizationHeader, BearerPrefix+token)
+		}
+		return r
+	}
+	admin, err := CreateStarterToken("secret", "", StarterRoleAdmin, time.Minute)
+	require.NoError(t, err)
+	alice, err := CreateStarterToken("secret", "alice", StarterRoleAdmin, time.Minute)
+	require.NoError(t, err)
+	peerToken, err := client.CreatePeerToken(client.PeerKey([]byte("secret")))
+	require.NoError(t, err)
+
+	// Without authentication, the description of the client is used
+	apiAuth, err := newAPIAuthenticator(false, jwtSecretSource{secret: "secret"}, &peerAuthenticator{mode: PeerAuthModeNone})
+	require.NoError(t, err)
+	require.Equal(t, "bob@host", apiAuth.requester(newRequest(""), "bob@host"))
+	require.Equal(t, "10.0.0.1:4321", apiAuth.requester(newRequest(""), ""))
+
+	// With authentication, the token is described
+	apiAuth, err = newAPIAuthenticator(true, jwtSecretSource{secret: "secret"}, &peerAuthenticator{mode: PeerAuthModeNone})
+	require.NoError(t, err)
+	require.Equal(t, "admin token from 10.0.0.1:4321", apiAuth.requester(newRequest(admin), "bob@host"))
+	require.Equal(t, "alice", apiAuth.requester(newRequest(alice), "bob@host"))
+
+	// Requests forwarded by other starters keep the description
+	apiAuth.peerAuth = &peerAuthenticator{mode: PeerAuthModeJWT, jwtSecret: "secret"}
+	require.Equal(t, "alice", apiAuth.requester(newRequest(peerToken), "alice"))
+}
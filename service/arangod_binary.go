@@ -0,0 +1,176 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+// configuredArangodBinary returns the arangod executable (or docker image) given on the command line.
+func (c Config) configuredArangodBinary() string {
+	if c.UseDockerRunner() {
+		return c.DockerArangodImage
+	}
+	return c.ArangodPath
+}
+
+// configuredArangodBinaryOr returns the given arangod executable (or docker image),
+// or the configured one when the given one is empty.
+func (c Config) configuredArangodBinaryOr(binary string) string {
+	if binary == "" {
+		return c.configuredArangodBinary()
+	}
+	return binary
+}
+
+// arangodBinaryOf returns the arangod executable (or docker image) described by the given target.
+// An empty target results in an empty string.
+func (c Config) arangodBinaryOf(target client.UpgradeTarget) (string, error) {
+	switch {
+	case target.Image != "" && target.ArangodPath != "":
+		return "", maskAny(client.NewBadRequestError("Specify either an image or an arangod executable, not both"))
+	case target.Image != "":
+		if !c.UseDockerRunner() {
+			return "", maskAny(client.NewBadRequestError("An image can only be used with the docker runner"))
+		}
+		return target.Image, nil
+	case target.ArangodPath != "":
+		if c.UseDockerRunner() {
+			return "", maskAny(client.NewBadRequestError("An arangod executable cannot be used with the docker runner, specify an image instead"))
+		}
+		return target.ArangodPath, nil
+	}
+	return "", nil
+}
+
+// upgradeTargetOf returns the target describing the given arangod executable (or docker image).
+func (c Config) upgradeTargetOf(binary string) client.UpgradeTarget {
+	if binary == "" {
+		return client.UpgradeTarget{}
+	}
+	if c.UseDockerRunner() {
+		return client.UpgradeTarget{Image: binary}
+	}
+	return client.UpgradeTarget{ArangodPath: binary}
+}
+
+// withArangodBinary returns the runner & configuration used to start arangod servers
+// with the given executable (or docker image).
+// An empty binary returns the given runner & configuration unchanged.
+func withArangodBinary(runner Runner, config Config, binary string) (Runner, Config) {
+	if binary == "" {
+		return runner, config
+	}
+	if config.UseDockerRunner() {
+		return runner.WithArangodImage(binary), config
+	}
+	config.ArangodPath = binary
+	return runner, config
+}
+
+// ArangodBinary returns the arangod executable (or docker image) this starter switched to
+// for an upgrade, or an empty string when the configured one is used.
+func (s *Service) ArangodBinary() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.arangodBinary
+}
+
+// ArangodTarget returns the arangod executable (or docker image) this starter uses
+// to start arangod servers: the one it switched to for an upgrade, or else the configured one.
+func (s *Service) ArangodTarget() client.UpgradeTarget {
+	return s.cfg.upgradeTargetOf(s.cfg.configuredArangodBinaryOr(s.ArangodBinary()))
+}
+
+// SwitchArangodBinary lets this starter use the given arangod executable (or docker image)
+// for all arangod servers it starts from now on. The choice is kept in the setup file.
+// An empty target switches back to the configured executable (or image).
+// When validateOnly is set, the target is only checked.
+func (s *Service) SwitchArangodBinary(ctx context.Context, target client.UpgradeTarget, validateOnly bool) (client.SwitchArangodBinaryResult, error) {
+	binary, err := s.cfg.arangodBinaryOf(target)
+	if err != nil {
+		return client.SwitchArangodBinaryResult{}, maskAny(err)
+	}
+	if binary == s.cfg.configuredArangodBinary() {
+		binary = ""
+	}
+	previous := s.ArangodBinary()
+	result := client.SwitchArangodBinaryResult{
+		Previous: s.cfg.upgradeTargetOf(previous),
+	}
+	if binary == previous && !validateOnly {
+		// Nothing changes
+		result.Version = s.DatabaseFeatures().Version
+		return result, nil
+	}
+
+	// Check the target
+	runner, config := withArangodBinary(s.runner, s.cfg, binary)
+	if !config.UseDockerRunner() {
+		if _, err := os.Stat(config.ArangodPath); err != nil {
+			return client.SwitchArangodBinaryResult{}, maskAny(client.NewBadRequestError(fmt.Sprintf("Cannot find arangod executable '%s'", config.ArangodPath)))
+		}
+	}
+	version, enterprise, err := databaseVersionOf(ctx, runner, config.ArangodPath)
+	if err != nil {
+		return client.SwitchArangodBinaryResult{}, errors.Wrapf(err, "Failed to get the version of '%s'", config.configuredArangodBinaryOr(binary))
+	}
+	result.Version = version
+	if validateOnly {
+		return result, nil
+	}
+
+	// Switch & save the setup, so we keep using the target after a restart
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.arangodBinary = binary
+	s.databaseFeatures = NewDatabaseFeatures(version, enterprise)
+	s.log.Info().Msgf("Switched to '%s' (version %s) for starting arangod servers", config.configuredArangodBinaryOr(binary), version)
+	if err := s.saveSetup(); err != nil {
+		return client.SwitchArangodBinaryResult{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// restoreArangodBinary lets this starter use the arangod executable (or docker image)
+// it switched to for an upgrade before it was restarted.
+// The switch is dropped when the configured executable (or image) has changed since then.
+func (s *Service) restoreArangodBinary(bsCfg BootstrapConfig) {
+	if bsCfg.ArangodBinary == "" {
+		return
+	}
+	if configured := s.cfg.configuredArangodBinary(); configured != bsCfg.ConfiguredArangodBinary {
+		s.log.Warn().Msgf("Configured arangod '%s' differs from '%s' used when switching to '%s', using '%s'",
+			configured, bsCfg.ConfiguredArangodBinary, bsCfg.ArangodBinary, configured)
+		return
+	}
+	s.log.Info().Msgf("Using '%s' for starting arangod servers", bsCfg.ArangodBinary)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.arangodBinary = bsCfg.ArangodBinary
+}
@@ -0,0 +1,71 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_ArangodBinaryOf(t *testing.T) {
+	process := Config{ArangodPath: "/usr/sbin/arangod"}
+	docker := Config{DockerEndpoint: "unix:///var/run/docker.sock", DockerArangodImage: "arangodb/arangodb:3.7.10"}
+
+	binary, err := process.arangodBinaryOf(client.UpgradeTarget{ArangodPath: "/opt/new/bin/arangod"})
+	require.NoError(t, err)
+	require.Equal(t, "/opt/new/bin/arangod", binary)
+	binary, err = docker.arangodBinaryOf(client.UpgradeTarget{Image: "arangodb/arangodb:3.7.11"})
+	require.NoError(t, err)
+	require.Equal(t, "arangodb/arangodb:3.7.11", binary)
+	binary, err = docker.arangodBinaryOf(client.UpgradeTarget{})
+	require.NoError(t, err)
+	require.Equal(t, "", binary)
+
+	// Target does not match the runner
+	_, err = process.arangodBinaryOf(client.UpgradeTarget{Image: "arangodb/arangodb:3.7.11"})
+	require.True(t, client.IsBadRequest(err))
+	_, err = docker.arangodBinaryOf(client.UpgradeTarget{ArangodPath: "/opt/new/bin/arangod"})
+	require.True(t, client.IsBadRequest(err))
+	_, err = docker.arangodBinaryOf(client.UpgradeTarget{ArangodPath: "/opt/new/bin/arangod", Image: "arangodb/arangodb:3.7.11"})
+	require.True(t, client.IsBadRequest(err))
+
+	require.Equal(t, client.UpgradeTarget{ArangodPath: "/opt/new/bin/arangod"}, process.upgradeTargetOf("/opt/new/bin/arangod"))
+	require.Equal(t, client.UpgradeTarget{Image: "arangodb/arangodb:3.7.11"}, docker.upgradeTargetOf("arangodb/arangodb:3.7.11"))
+	require.True(t, docker.upgradeTargetOf("").IsEmpty())
+}
+
+func Test_WithArangodBinary(t *testing.T) {
+	process := Config{ArangodPath: "/usr/sbin/arangod"}
+	runner := &processRunner{}
+
+	r, c := withArangodBinary(runner, process, "")
+	require.Equal(t, runner, r)
+	require.Equal(t, "/usr/sbin/arangod", c.ArangodPath)
+
+	r, c = withArangodBinary(runner, process, "/opt/new/bin/arangod")
+	require.Equal(t, runner, r)
+	require.Equal(t, "/opt/new/bin/arangod", c.ArangodPath)
+	require.Equal(t, "/usr/sbin/arangod", process.ArangodPath)
+	require.Equal(t, "/usr/sbin/arangod", process.configuredArangodBinary())
+}
@@ -23,9 +23,11 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"sort"
 	"strings"
 )
 
@@ -37,12 +39,18 @@ var confHeader = `# ArangoDB configuration file
 
 `
 
+const (
+	// confContentHashPrefix is the prefix of the line that holds the content hash of a generated config file.
+	confContentHashPrefix = "# CONTENT-HASH: "
+)
+
 type configFile []*configSection
 
 // WriteTo writes the configuration sections to the given writer.
+// The content hash of the configuration is written as part of the header.
 func (cf configFile) WriteTo(w io.Writer) (int64, error) {
 	x := int64(0)
-	n, err := w.Write([]byte(confHeader))
+	n, err := w.Write([]byte(confHeader + confContentHashPrefix + cf.ContentHash() + "\n\n"))
 	if err != nil {
 		return x, maskAny(err)
 	}
@@ -57,6 +65,19 @@ func (cf configFile) WriteTo(w io.Writer) (int64, error) {
 	return x, nil
 }
 
+// ContentHash returns a hex encoded hash of the content of the configuration,
+// ignoring comments, blank lines, spacing & the order of settings within a section.
+func (cf configFile) ContentHash() string {
+	h := sha256.New()
+	for _, section := range cf {
+		fmt.Fprintf(h, "[%s]\n", section.Name)
+		for _, k := range section.sortedKeys() {
+			fmt.Fprintf(h, "%s=%s\n", k, section.Settings[k])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // FindSection searches for a section with given name and returns it.
 // If not found, nil is returned.
 func (cf configFile) FindSection(sectionName string) *configSection {
@@ -76,24 +97,36 @@ type configSection struct {
 // WriteTo writes the configuration section to the given writer.
 func (s *configSection) WriteTo(w io.Writer) (int64, error) {
 	lines := []string{"[" + s.Name + "]"}
-	for k, v := range s.Settings {
-		lines = append(lines, fmt.Sprintf("%s = %s", k, v))
+	for _, k := range s.sortedKeys() {
+		lines = append(lines, fmt.Sprintf("%s = %s", k, s.Settings[k]))
 	}
 	lines = append(lines, "")
 	n, err := w.Write([]byte(strings.Join(lines, "\n")))
 	return int64(n), maskAny(err)
 }
 
-// readConfigFile loads the content of a config file.
-func readConfigFile(path string) (configFile, error) {
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, maskAny(err)
+// sortedKeys returns the keys of all settings in this section in sorted order.
+func (s *configSection) sortedKeys() []string {
+	keys := make([]string, 0, len(s.Settings))
+	for k := range s.Settings {
+		keys = append(keys, k)
 	}
-	lines := strings.Split(string(content), "\n")
+	sort.Strings(keys)
+	return keys
+}
+
+// parseConfigFile parses the content of a config file.
+// Returns the config & the content hash stored in the content (empty if there is no such hash).
+func parseConfigFile(content string) (configFile, string, error) {
+	lines := strings.Split(content, "\n")
 	config := configFile{}
+	storedHash := ""
 	var section *configSection
 	for _, line := range lines {
+		if strings.HasPrefix(line, confContentHashPrefix) {
+			storedHash = strings.TrimSpace(strings.TrimPrefix(line, confContentHashPrefix))
+			continue
+		}
 		idx := strings.Index(line, "#")
 		if idx >= 0 {
 			line = line[:idx]
@@ -103,7 +136,7 @@ func readConfigFile(path string) (configFile, error) {
 			continue
 		}
 		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			name := strings.TrimSpace(line[1 : len(line)-2])
+			name := strings.TrimSpace(line[1 : len(line)-1])
 			section = &configSection{
 				Name:     name,
 				Settings: make(map[string]string),
@@ -119,5 +152,5 @@ func readConfigFile(path string) (configFile, error) {
 			section.Settings[key] = value
 		}
 	}
-	return config, nil
+	return config, storedHash, nil
 }
@@ -25,8 +25,10 @@ package service
 //
 // Arangod options are configured in the following places:
 // - arangod.conf:
-//     This holds all settings that are considered static for the lifetime of the cluster.
-//     Using new/different settings on the Starter will not change these settings.
+//     This holds all settings that are considered static for the lifetime of the server.
+//     Using new/different settings on the Starter will update these settings, unless
+//     the file has been modified manually (detected by its content hash) or the
+//     setting is immutable (e.g. storage engine).
 // - arangod commandline:
 //     This holds all settings that can change over the lifetime of the cluster.
 //     These settings mostly involve the cluster layout.
@@ -36,6 +38,7 @@ package service
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
@@ -62,24 +65,89 @@ func fixupEndpointURLSchemeForArangod(u string) string {
 	return urlFixer.Replace(u)
 }
 
+// immutableArangodConfSettings holds the settings of arangod.conf that cannot be changed
+// once the config file has been created. Keys are formatted as "<section>.<key>".
+var immutableArangodConfSettings = map[string]bool{
+	"server.storage-engine": true,
+}
+
+// legacyArangodConfSettings holds the settings of arangod.conf that were generated by
+// starters that did not yet store a content hash. Keys are formatted as "<section>.<key>".
+var legacyArangodConfSettings = map[string]bool{
+	"server.endpoint":            true,
+	"server.authentication":      true,
+	"server.jwt-secret":          true,
+	"server.storage-engine":      true,
+	"log.level":                  true,
+	"ssl.keyfile":                true,
+	"ssl.cafile":                 true,
+	"rocksdb.encryption-keyfile": true,
+}
+
+// secretArangodConfSettings holds the settings of arangod.conf whose values must never be logged.
+var secretArangodConfSettings = map[string]bool{
+	"server.jwt-secret": true,
+}
+
 // createArangodConf creates an arangod.conf file in the given host directory if it does not yet exists.
-// The arangod.conf file contains all settings that are considered static for the lifetime of the server.
+// If the file already exists and it has not been modified by the user (detected using a content hash),
+// it is updated to reflect the current starter options (except for immutable settings).
+// Files without a content hash are considered unmodified when they are exactly what an older starter generated.
+// If the file has been modified by the user, it is left untouched and all conflicting settings are logged.
 func createArangodConf(log zerolog.Logger, bsCfg BootstrapConfig, myHostDir, myContainerDir, myPort string, serverType definitions.ServerType, features DatabaseFeatures) ([]Volume, configFile, error) {
 	hostConfFileName := filepath.Join(myHostDir, definitions.ArangodConfFileName)
 	containerConfFileName := filepath.Join(myContainerDir, definitions.ArangodConfFileName)
 	volumes := addVolume(nil, hostConfFileName, containerConfFileName, true)
 
+	config := buildArangodConf(bsCfg, myPort, features)
+
 	if _, err := os.Stat(hostConfFileName); err == nil {
 		// Arangod.conf already exists
 		// Read config file
-		if cfg, err := readConfigFile(hostConfFileName); err != nil {
+		content, err := ioutil.ReadFile(hostConfFileName)
+		if err != nil {
 			return nil, nil, maskAny(err)
-		} else {
-			return volumes, cfg, nil
 		}
+		existing, storedHash, err := parseConfigFile(string(content))
+		if err != nil {
+			return nil, nil, maskAny(err)
+		}
+		unmodified := storedHash != "" && storedHash == existing.ContentHash()
+		if storedHash == "" && isLegacyArangodConf(string(content), existing, myPort) {
+			// Created by a starter that did not yet store a content hash
+			unmodified = true
+		}
+		if !unmodified {
+			// Config file has been modified by the user, do not touch it.
+			log.Info().Msgf("%s has been modified manually, it will not be updated", hostConfFileName)
+			warnArangodConfConflicts(log, hostConfFileName, existing, config)
+			return volumes, existing, nil
+		}
+		// Config file has not been modified, keep immutable settings
+		keepImmutableArangodConfSettings(log, hostConfFileName, existing, config)
+		if existing.ContentHash() == config.ContentHash() {
+			// Nothing changed
+			return volumes, existing, nil
+		}
+		log.Info().Msgf("Updating %s", hostConfFileName)
 	}
 
-	// Arangod.conf does not exist. Create it.
+	out, err := os.Create(hostConfFileName)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Could not create configuration file %s", hostConfFileName)
+		return nil, nil, maskAny(err)
+	}
+	defer out.Close()
+	if _, err := config.WriteTo(out); err != nil {
+		log.Fatal().Err(err).Msg("Cannot create config file")
+		return nil, nil, maskAny(err)
+	}
+
+	return volumes, config, nil
+}
+
+// buildArangodConf creates the content of an arangod.conf file based on the given options.
+func buildArangodConf(bsCfg BootstrapConfig, myPort string, features DatabaseFeatures) configFile {
 	logLevel := "INFO"
 	listenAddr := "[::]"
 	if bsCfg.DisableIPv6 {
@@ -124,7 +192,8 @@ func createArangodConf(log zerolog.Logger, bsCfg BootstrapConfig, myHostDir, myC
 		}
 		config = append(config, sslSection)
 	}
-	if bsCfg.RocksDBEncryptionKeyFile != "" {
+	if bsCfg.RocksDBEncryptionKeyFile != "" && !features.GetEncryptionFolderOption() {
+		// When supported, the keyfile is copied into an encryption key folder instead
 		rocksdbSection := &configSection{
 			Name: "rocksdb",
 			Settings: map[string]string{
@@ -133,25 +202,115 @@ func createArangodConf(log zerolog.Logger, bsCfg BootstrapConfig, myHostDir, myC
 		}
 		config = append(config, rocksdbSection)
 	}
+	return config
+}
 
-	out, err := os.Create(hostConfFileName)
-	if err != nil {
-		log.Fatal().Err(err).Msgf("Could not create configuration file %s", hostConfFileName)
-		return nil, nil, maskAny(err)
+// isLegacyArangodConf returns true if the given content of an arangod.conf file (without a content hash)
+// is exactly what a starter that did not yet store a content hash would have generated:
+// the header followed by starter settings only, formatted by the starter, with values it could have chosen.
+func isLegacyArangodConf(content string, config configFile, myPort string) bool {
+	if !strings.HasPrefix(content, confHeader) {
+		return false
 	}
-	defer out.Close()
-	if _, err := config.WriteTo(out); err != nil {
-		log.Fatal().Err(err).Msg("Cannot create config file")
-		return nil, nil, maskAny(err)
+	lines := strings.Split(strings.TrimPrefix(content, confHeader), "\n")
+	if lines[len(lines)-1] != "" {
+		return false
+	}
+	for _, line := range lines[:len(lines)-1] {
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			continue
+		}
+		parts := strings.SplitN(line, " = ", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.ContainsAny(parts[0], " #") || strings.Contains(parts[1], "#") {
+			return false
+		}
 	}
 
-	return volumes, config, nil
+	// Check settings
+	for _, section := range config {
+		for key := range section.Settings {
+			if !legacyArangodConfSettings[section.Name+"."+key] {
+				return false
+			}
+		}
+	}
+	setting := func(sectionName, key string) (string, bool) {
+		if section := config.FindSection(sectionName); section != nil {
+			value, found := section.Settings[key]
+			return value, found
+		}
+		return "", false
+	}
+	if level, _ := setting("log", "level"); level != "INFO" {
+		return false
+	}
+	authentication, _ := setting("server", "authentication")
+	if _, found := setting("server", "jwt-secret"); found && authentication != "true" {
+		return false
+	} else if authentication != "true" && authentication != "false" {
+		return false
+	}
+	_, isSecure := setting("ssl", "keyfile")
+	if _, found := setting("ssl", "cafile"); found && !isSecure {
+		return false
+	}
+	scheme := NewURLSchemes(isSecure).Arangod
+	endpoint, _ := setting("server", "endpoint")
+	return endpoint == fmt.Sprintf("%s://[::]:%s", scheme, myPort) || endpoint == fmt.Sprintf("%s://0.0.0.0:%s", scheme, myPort)
+}
+
+// keepImmutableArangodConfSettings copies all immutable settings from the existing config into the
+// given new config. A warning is logged for every immutable setting that the user tries to change.
+func keepImmutableArangodConfSettings(log zerolog.Logger, confFileName string, existing, config configFile) {
+	for _, existingSection := range existing {
+		for key, existingValue := range existingSection.Settings {
+			if !immutableArangodConfSettings[existingSection.Name+"."+key] {
+				continue
+			}
+			section := config.FindSection(existingSection.Name)
+			if section == nil {
+				continue
+			}
+			if value, found := section.Settings[key]; found && value != existingValue {
+				log.Warn().Msgf("Setting %s.%s in %s cannot be changed from '%s' to '%s', keeping '%s'",
+					existingSection.Name, key, confFileName, existingValue, value, existingValue)
+			}
+			section.Settings[key] = existingValue
+		}
+	}
+}
+
+// warnArangodConfConflicts logs a warning for every setting in the existing config that conflicts
+// with the given expected config.
+func warnArangodConfConflicts(log zerolog.Logger, confFileName string, existing, expected configFile) {
+	for _, expectedSection := range expected {
+		existingSection := existing.FindSection(expectedSection.Name)
+		for _, key := range expectedSection.sortedKeys() {
+			value := expectedSection.Settings[key]
+			var existingValue string
+			found := false
+			if existingSection != nil {
+				existingValue, found = existingSection.Settings[key]
+			}
+			if found && existingValue == value {
+				continue
+			}
+			name := expectedSection.Name + "." + key
+			if secretArangodConfSettings[name] {
+				log.Warn().Msgf("Setting %s in %s conflicts with the starter options", name, confFileName)
+			} else if found {
+				log.Warn().Msgf("Setting %s in %s is '%s', but the starter options require '%s'", name, confFileName, existingValue, value)
+			} else {
+				log.Warn().Msgf("Setting %s is missing in %s, but the starter options require '%s'", name, confFileName, value)
+			}
+		}
+	}
 }
 
 // createArangodArgs returns the command line arguments needed to run an arangod server of given type.
 func createArangodArgs(log zerolog.Logger, config Config, clusterConfig ClusterConfig, myContainerDir, myContainerLogFile string,
 	myPeerID, myAddress, myPort string, serverType definitions.ServerType, arangodConfig configFile, agentRecoveryID string, databaseAutoUpgrade bool, clusterJWTSecretFile string,
-	features DatabaseFeatures) []string {
+	encryptionKeyFolder string, features DatabaseFeatures) []string {
 	containerConfFileName := filepath.Join(myContainerDir, definitions.ArangodConfFileName)
 
 	args := make([]string, 0, 40)
@@ -184,6 +343,11 @@ func createArangodArgs(log zerolog.Logger, config Config, clusterConfig ClusterC
 			)
 		}
 	}
+	if encryptionKeyFolder != "" {
+		opts = append(opts,
+			optionPair{"--rocksdb.encryption-keyfolder", slasher(encryptionKeyFolder)},
+		)
+	}
 	if !config.RunningInDocker && features.HasCopyInstallationFiles() {
 		opts = append(opts, optionPair{"--javascript.copy-installation", "true"})
 	}
@@ -0,0 +1,111 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConfigFile_ContentHash(t *testing.T) {
+	cfg := configFile{
+		&configSection{
+			Name: "server",
+			Settings: map[string]string{
+				"endpoint":       "tcp://[::]:8529",
+				"authentication": "false",
+			},
+		},
+		&configSection{
+			Name: "log",
+			Settings: map[string]string{
+				"level": "INFO",
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	_, err := cfg.WriteTo(&buf)
+	require.NoError(t, err)
+
+	t.Run("Unmodified", func(t *testing.T) {
+		parsed, storedHash, err := parseConfigFile(buf.String())
+		require.NoError(t, err)
+		require.Len(t, parsed, 2)
+		require.Equal(t, "server", parsed[0].Name)
+		require.Equal(t, "log", parsed[1].Name)
+		require.Equal(t, cfg.ContentHash(), storedHash)
+		require.Equal(t, storedHash, parsed.ContentHash())
+	})
+
+	t.Run("Spacing and comments", func(t *testing.T) {
+		content := strings.Replace(buf.String(), "level = INFO", "\n  level=INFO   # comment\n", 1)
+		parsed, storedHash, err := parseConfigFile(content)
+		require.NoError(t, err)
+		require.Equal(t, storedHash, parsed.ContentHash())
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		content := strings.Replace(buf.String(), "level = INFO", "level = DEBUG", 1)
+		parsed, storedHash, err := parseConfigFile(content)
+		require.NoError(t, err)
+		require.NotEqual(t, storedHash, parsed.ContentHash())
+	})
+
+	t.Run("Without hash", func(t *testing.T) {
+		_, storedHash, err := parseConfigFile("[server]\nendpoint = tcp://[::]:8529\n")
+		require.NoError(t, err)
+		require.Empty(t, storedHash)
+	})
+}
+
+func Test_IsLegacyArangodConf(t *testing.T) {
+	generated := confHeader +
+		"[server]\nauthentication = true\nendpoint = tcp://[::]:8530\njwt-secret = foo\n" +
+		"[log]\nlevel = INFO\n"
+
+	for _, c := range []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"Generated", generated, true},
+		{"Generated with IPv4 & TLS", confHeader +
+			"[server]\nendpoint = ssl://0.0.0.0:8530\nauthentication = false\n" +
+			"[log]\nlevel = INFO\n[ssl]\nkeyfile = /data/key.pem\n", true},
+		{"Without header", strings.TrimPrefix(generated, confHeader), false},
+		{"Comment", generated + "# my setting\n", false},
+		{"Other spacing", strings.Replace(generated, "level = INFO", "level=INFO", 1), false},
+		{"Other value", strings.Replace(generated, "level = INFO", "level = DEBUG", 1), false},
+		{"Other port", strings.Replace(generated, ":8530", ":9999", 1), false},
+		{"Other scheme", strings.Replace(generated, "tcp://", "ssl://", 1), false},
+		{"Unknown setting", generated + "[query]\ncache-mode = on\n", false},
+		{"Secret without authentication", strings.Replace(generated, "authentication = true", "authentication = false", 1), false},
+	} {
+		config, storedHash, err := parseConfigFile(c.content)
+		require.NoError(t, err, c.name)
+		require.Empty(t, storedHash, c.name)
+		require.Equal(t, c.expected, isLegacyArangodConf(c.content, config, "8530"), c.name)
+	}
+}
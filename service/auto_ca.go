@@ -0,0 +1,448 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	certificates "github.com/arangodb-helper/go-certificates"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	autoCAValidFor          = time.Hour * 24 * 365 * 10 // 10 years
+	autoCertRenewBefore     = time.Hour * 24 * 30       // Renew server certificates 30 days before they expire
+	autoCertRenewalInterval = time.Hour
+)
+
+// AutoCA is the certificate authority used to issue the server certificates of all peers
+// when `--ssl.auto-ca` is set.
+// It is created by the bootstrap master & handed to other peers when they join the cluster,
+// which is only done when peers authenticate (see --starter.peer-auth).
+type AutoCA struct {
+	Certificate string `json:"certificate"` // PEM encoded certificate of the CA
+	PrivateKey  string `json:"private-key"` // PEM encoded private key of the CA
+}
+
+// AutoCAKeyFile returns the path of the server keyfile issued by the auto CA
+// for a starter using the given data directory.
+func AutoCAKeyFile(dataDir string) string {
+	return filepath.Join(dataDir, definitions.TLSFolderName, definitions.TLSKeyFileName)
+}
+
+// AutoCACertFile returns the path of the certificate of the auto CA
+// for a starter using the given data directory.
+func AutoCACertFile(dataDir string) string {
+	return filepath.Join(dataDir, definitions.TLSFolderName, definitions.TLSCACertFileName)
+}
+
+// autoCAManager persists the auto CA in the data directory and uses it to issue
+// the server certificate (used by the starter, arangod & arangosync) of this peer.
+type autoCAManager struct {
+	log          zerolog.Logger
+	dir          string
+	organization string
+
+	mutex sync.Mutex
+	cert  *tls.Certificate // Certificate currently used by the starter API
+}
+
+// newAutoCAManager creates a manager for the auto CA stored in the given data directory.
+func newAutoCAManager(log zerolog.Logger, dataDir, organization string) *autoCAManager {
+	return &autoCAManager{
+		log:          log,
+		dir:          filepath.Join(dataDir, definitions.TLSFolderName),
+		organization: organization,
+	}
+}
+
+// KeyFile returns the path of the server keyfile.
+func (m *autoCAManager) KeyFile() string {
+	return filepath.Join(m.dir, definitions.TLSKeyFileName)
+}
+
+// CACertFile returns the path of the CA certificate, which can be used by clients to verify server certificates.
+func (m *autoCAManager) CACertFile() string {
+	return filepath.Join(m.dir, definitions.TLSCACertFileName)
+}
+
+func (m *autoCAManager) caKeyFile() string {
+	return filepath.Join(m.dir, definitions.TLSCAKeyFileName)
+}
+
+// Load returns the CA stored in the data directory or nil if there is none yet.
+func (m *autoCAManager) Load() (*AutoCA, error) {
+	cert, err := ioutil.ReadFile(m.CACertFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	key, err := ioutil.ReadFile(m.caKeyFile())
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &AutoCA{
+		Certificate: string(cert),
+		PrivateKey:  string(key),
+	}, nil
+}
+
+// Create creates a new CA in the data directory, unless there is one already.
+func (m *autoCAManager) Create() error {
+	if ca, err := m.Load(); err != nil {
+		return maskAny(err)
+	} else if ca != nil {
+		return nil
+	}
+
+	cert, priv, err := certificates.CreateCertificate(certificates.CreateCertificateOptions{
+		Subject: &pkix.Name{
+			CommonName:   "ArangoDB Starter CA",
+			Organization: []string{m.organization},
+		},
+		ValidFrom:  time.Now(),
+		ValidFor:   autoCAValidFor,
+		IsCA:       true,
+		ECDSACurve: defaultCurve,
+	}, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	m.log.Info().Msgf("Created certificate authority %s", m.CACertFile())
+	return maskAny(m.Install(AutoCA{Certificate: cert, PrivateKey: priv}))
+}
+
+// Install stores the given CA in the data directory.
+func (m *autoCAManager) Install(ca AutoCA) error {
+	if _, err := certificates.LoadCAFromPEM(ca.Certificate, ca.PrivateKey); err != nil {
+		return maskAny(errors.Wrap(err, "Invalid certificate authority"))
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(m.caKeyFile(), []byte(ca.PrivateKey), 0600); err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(m.CACertFile(), []byte(ca.Certificate), 0644); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Prepare ensures that there is a server keyfile for the given hosts.
+// If the CA is available, the keyfile is (re)issued when it is missing, not issued by the CA,
+// does not cover all hosts or is about to expire.
+// If there is no CA yet (a peer that still has to join the cluster), a temporary self-signed
+// keyfile is created when it is missing.
+func (m *autoCAManager) Prepare(hosts []string) error {
+	ca, err := m.Load()
+	if err != nil {
+		return maskAny(err)
+	}
+	if ca == nil {
+		if _, err := os.Stat(m.KeyFile()); os.IsNotExist(err) {
+			if err := m.writeKeyFile(hosts, nil); err != nil {
+				return maskAny(err)
+			}
+		} else if err != nil {
+			return maskAny(err)
+		}
+		return maskAny(m.reload())
+	}
+
+	if renew, err := m.NeedsRenewal(time.Now(), hosts); err != nil {
+		return maskAny(err)
+	} else if renew {
+		return maskAny(m.Issue(hosts))
+	}
+	return maskAny(m.reload())
+}
+
+// Issue creates a new server keyfile for the given hosts, signed by the CA.
+func (m *autoCAManager) Issue(hosts []string) error {
+	ca, err := m.Load()
+	if err != nil {
+		return maskAny(err)
+	} else if ca == nil {
+		return maskAny(errors.Errorf("No certificate authority found in %s", m.dir))
+	}
+	certCA, err := certificates.LoadCAFromPEM(ca.Certificate, ca.PrivateKey)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := m.writeKeyFile(hosts, &certCA); err != nil {
+		return maskAny(err)
+	}
+	m.log.Info().Strs("hosts", hosts).Msgf("Issued server certificate %s", m.KeyFile())
+	return maskAny(m.reload())
+}
+
+// NeedsRenewal returns true if the server keyfile is missing, not issued by the CA,
+// does not cover all given hosts or expires within the renewal period.
+// It returns false if there is no CA yet.
+func (m *autoCAManager) NeedsRenewal(now time.Time, hosts []string) (bool, error) {
+	ca, err := m.Load()
+	if err != nil || ca == nil {
+		return false, maskAny(err)
+	}
+	caCerts, _, err := certificates.LoadFromPEM(ca.Certificate, ca.PrivateKey)
+	if err != nil {
+		return false, maskAny(err)
+	}
+
+	if _, err := os.Stat(m.KeyFile()); os.IsNotExist(err) {
+		return true, nil
+	}
+	cert, err := LoadKeyFile(m.KeyFile())
+	if err != nil {
+		return false, maskAny(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, maskAny(err)
+	}
+
+	if leaf.CheckSignatureFrom(caCerts[0]) != nil {
+		return true, nil
+	}
+	if leaf.NotAfter.Sub(now) < autoCertRenewBefore {
+		return true, nil
+	}
+	for _, h := range hosts {
+		if leaf.VerifyHostname(h) != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TLSConfig returns a TLS config for the starter API that always uses the current server certificate.
+// If requestClientCert is set, clients are asked for a certificate (see VerifyCertificate).
+func (m *autoCAManager) TLSConfig(requestClientCert bool) *tls.Config {
+	config := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return m.currentCertificate()
+		},
+	}
+	if requestClientCert {
+		config.ClientAuth = tls.RequestClientCert
+	}
+	return config
+}
+
+// ClientTLSConfig returns a TLS config for connections to other starters that presents the current
+// server certificate as client certificate and only accepts server certificates issued by the CA.
+func (m *autoCAManager) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return m.currentCertificate()
+		},
+		// The certificate chain is verified by VerifyPeerCertificate, since the CA may not be known yet
+		// when the config is created.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				c, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return maskAny(err)
+				}
+				certs = append(certs, c)
+			}
+			return maskAny(m.VerifyCertificate(certs, x509.ExtKeyUsageServerAuth))
+		},
+	}
+}
+
+// VerifyCertificate returns nil if the given certificate chain (leaf first) is issued by the CA
+// and can be used for the given purpose.
+func (m *autoCAManager) VerifyCertificate(certs []*x509.Certificate, usage x509.ExtKeyUsage) error {
+	if len(certs) == 0 {
+		return maskAny(errors.Errorf("No certificate"))
+	}
+	ca, err := m.Load()
+	if err != nil {
+		return maskAny(err)
+	} else if ca == nil {
+		return maskAny(errors.Errorf("No certificate authority found in %s", m.dir))
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(ca.Certificate)) {
+		return maskAny(errors.Errorf("Invalid certificate authority"))
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// currentCertificate returns the server certificate currently used by the starter.
+func (m *autoCAManager) currentCertificate() (*tls.Certificate, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.cert == nil {
+		return nil, errors.Errorf("No server certificate loaded")
+	}
+	return m.cert, nil
+}
+
+// writeKeyFile creates a certificate for the given hosts (signed by the given CA, or self-signed
+// if nil) and writes it, including its private key, into the server keyfile.
+func (m *autoCAManager) writeKeyFile(hosts []string, ca *certificates.CA) error {
+	cert, priv, err := certificates.CreateCertificate(certificates.CreateCertificateOptions{
+		Hosts: hosts,
+		Subject: &pkix.Name{
+			CommonName:   hosts[0],
+			Organization: []string{m.organization},
+		},
+		ValidFrom:  time.Now(),
+		ValidFor:   defaultValidFor,
+		ECDSACurve: defaultCurve,
+	}, ca)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return maskAny(err)
+	}
+	content := []byte(strings.TrimSpace(cert) + "\n" + priv)
+	if _, err := os.Stat(m.KeyFile()); err == nil {
+		// Replace the keyfile atomically, so servers never read a half-written keyfile.
+		return maskAny(replaceKeyFile(m.KeyFile(), content))
+	}
+	return maskAny(ioutil.WriteFile(m.KeyFile(), content, 0600))
+}
+
+// reload loads the server keyfile for use by the starter API.
+func (m *autoCAManager) reload() error {
+	cert, err := LoadKeyFile(m.KeyFile())
+	if err != nil {
+		return maskAny(err)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.cert = &cert
+	return nil
+}
+
+// autoCAHosts returns the host names & IP addresses put into the server certificate of this peer.
+func (s *Service) autoCAHosts() []string {
+	var hosts []string
+	found := map[string]bool{}
+	add := func(h string) {
+		h = strings.Trim(strings.TrimSpace(h), "[]")
+		if h != "" && !found[strings.ToLower(h)] {
+			found[strings.ToLower(h)] = true
+			hosts = append(hosts, h)
+		}
+	}
+
+	add(s.cfg.OwnAddress)
+	if s.cfg.AdvertisedEndpoint != "" {
+		if u, err := url.Parse(s.cfg.AdvertisedEndpoint); err == nil {
+			add(u.Hostname())
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		add(hostname)
+	}
+	// Include all addresses of this host (or container)
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				add(ipNet.IP.String())
+			}
+		}
+	}
+	add("localhost")
+	add("127.0.0.1")
+	add("::1")
+
+	return hosts
+}
+
+// runAutoCARenewal renews the server certificate of this peer before it expires
+// and lets the servers reload it.
+func (s *Service) runAutoCARenewal(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(autoCertRenewalInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		hosts := s.autoCAHosts()
+		if renew, err := s.autoCA.NeedsRenewal(time.Now(), hosts); err != nil {
+			s.log.Warn().Err(err).Msg("Failed to check server certificate")
+			continue
+		} else if !renew {
+			continue
+		}
+
+		if err := s.autoCA.Issue(hosts); err != nil {
+			s.log.Error().Err(err).Msg("Failed to renew server certificate")
+			continue
+		}
+
+		if _, isRunning, _ := s.IsRunningMaster(); !isRunning {
+			continue
+		}
+		_, p, _ := s.ClusterConfig()
+		if p == nil {
+			continue
+		}
+		c, err := createPeerClient(*p, s.peerAuth.clientOptions...)
+		if err != nil {
+			s.log.Warn().Err(err).Msg("Failed to create starter client")
+			continue
+		}
+		refreshCtx, cancel := context.WithTimeout(ctx, tlsRefreshTimeout)
+		if _, err := c.AdminTLSRefreshLocal(refreshCtx, nil); err != nil {
+			s.log.Warn().Err(err).Msg("Servers could not reload the renewed certificate, they will use it after their next restart")
+		}
+		cancel()
+	}
+}
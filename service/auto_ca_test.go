@@ -0,0 +1,110 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AutoCAManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starter-auto-ca")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	hosts := []string{"starter1.example.com", "10.0.0.1", "localhost"}
+	m := newAutoCAManager(zerolog.Nop(), dir, "ArangoDB")
+
+	// Without CA a temporary self-signed keyfile is created
+	require.NoError(t, m.Prepare(hosts))
+	_, err = LoadKeyFile(m.KeyFile())
+	require.NoError(t, err)
+	renew, err := m.NeedsRenewal(time.Now(), hosts)
+	require.NoError(t, err)
+	require.False(t, renew, "no renewal without CA")
+
+	// Once the CA exists, the keyfile is reissued by the CA
+	require.NoError(t, m.Create())
+	renew, err = m.NeedsRenewal(time.Now(), hosts)
+	require.NoError(t, err)
+	require.True(t, renew, "self-signed keyfile must be replaced")
+	require.NoError(t, m.Prepare(hosts))
+	renew, err = m.NeedsRenewal(time.Now(), hosts)
+	require.NoError(t, err)
+	require.False(t, renew)
+
+	// Certificate must be verifiable using the CA for all hosts
+	ca, err := m.Load()
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM([]byte(ca.Certificate)))
+	cert, err := LoadKeyFile(m.KeyFile())
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	for _, h := range hosts {
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: h, Roots: roots})
+		require.NoError(t, err, h)
+	}
+
+	// Renewal is needed for new hosts & before expiry
+	renew, err = m.NeedsRenewal(time.Now(), append(hosts, "10.0.0.2"))
+	require.NoError(t, err)
+	require.True(t, renew)
+	renew, err = m.NeedsRenewal(leaf.NotAfter.Add(-time.Hour), hosts)
+	require.NoError(t, err)
+	require.True(t, renew)
+
+	// Another peer using the same CA accepts the installed CA
+	dir2, err := ioutil.TempDir("", "starter-auto-ca")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir2)
+	m2 := newAutoCAManager(zerolog.Nop(), dir2, "ArangoDB")
+	require.NoError(t, m2.Install(*ca))
+	require.NoError(t, m2.Prepare([]string{"starter2.example.com"}))
+	cert2, err := LoadKeyFile(m2.KeyFile())
+	require.NoError(t, err)
+	leaf2, err := x509.ParseCertificate(cert2.Certificate[0])
+	require.NoError(t, err)
+	_, err = leaf2.Verify(x509.VerifyOptions{DNSName: "starter2.example.com", Roots: roots})
+	require.NoError(t, err)
+
+	// Certificates of peers are accepted as client certificates, self-signed ones are not
+	require.NoError(t, m.VerifyCertificate([]*x509.Certificate{leaf2}, x509.ExtKeyUsageClientAuth))
+	dir3, err := ioutil.TempDir("", "starter-auto-ca")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir3)
+	m3 := newAutoCAManager(zerolog.Nop(), dir3, "ArangoDB")
+	require.NoError(t, m3.Prepare(hosts))
+	cert3, err := LoadKeyFile(m3.KeyFile())
+	require.NoError(t, err)
+	leaf3, err := x509.ParseCertificate(cert3.Certificate[0])
+	require.NoError(t, err)
+	require.Error(t, m.VerifyCertificate([]*x509.Certificate{leaf3}, x509.ExtKeyUsageClientAuth))
+
+	require.Error(t, m2.Install(AutoCA{Certificate: "invalid", PrivateKey: "invalid"}))
+}
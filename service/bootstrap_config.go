@@ -48,9 +48,13 @@ type BootstrapConfig struct {
 	ArangosyncMonitoringToken string // Bearer token used for arangosync authentication
 	SslKeyFile                string // Path containing an x509 certificate + private key to be used by the servers.
 	SslCAFile                 string // Path containing an x509 CA certificate used to authenticate clients.
+	SslAutoCA                 bool   // If set, the server certificates are issued by a CA that is shared by all peers.
+	SslAutoOrganization       string // Organization name put into certificates created by the starter.
 	RocksDBEncryptionKeyFile  string // Path containing encryption key for RocksDB encryption.
 	DisableIPv6               bool   // If set, no IPv6 notation will be used
 	RecoveryAgentID           string `json:"-"` // ID of the agent. Only set during recovery
+	ArangodBinary             string // arangod executable (or docker image) switched to for an upgrade (if any)
+	ConfiguredArangodBinary   string // arangod executable (or docker image) configured when switching to ArangodBinary
 }
 
 func (bsCfg BootstrapConfig) JWTFolderDir() string {
@@ -61,6 +65,14 @@ func (bsCfg BootstrapConfig) JWTFolderDirFile(f string) string {
 	return path.Join(bsCfg.DataDir, definitions.ArangodJWTSecretFolderName, f)
 }
 
+func (bsCfg BootstrapConfig) EncryptionKeyFolderDir() string {
+	return path.Join(bsCfg.DataDir, definitions.ArangodEncryptionKeyFolderName)
+}
+
+func (bsCfg BootstrapConfig) EncryptionKeyFolderDirFile(f string) string {
+	return path.Join(bsCfg.DataDir, definitions.ArangodEncryptionKeyFolderName, f)
+}
+
 // Initialize auto-configures some optional values
 func (bsCfg *BootstrapConfig) Initialize() error {
 	// Create unique ID
@@ -66,6 +66,16 @@ func (s *Service) bootstrapMaster(ctx context.Context, runner Runner, config Con
 		bsCfg.AgencySize, storageEngine)
 	s.learnOwnAddress = config.OwnAddress == ""
 
+	// Create the certificate authority shared by all peers (if needed)
+	if s.autoCA != nil {
+		if err := s.autoCA.Create(); err != nil {
+			s.log.Fatal().Err(err).Msg("Failed to create certificate authority")
+		}
+		if err := s.autoCA.Prepare(s.autoCAHosts()); err != nil {
+			s.log.Fatal().Err(err).Msg("Failed to issue server certificate")
+		}
+	}
+
 	// Start HTTP listener
 	s.startHTTPServer(config)
 
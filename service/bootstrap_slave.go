@@ -35,6 +35,14 @@ import (
 // bootstrapSlave starts the Service as slave and begins bootstrapping the cluster from nothing.
 func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Config, bsCfg BootstrapConfig) {
 	masterURL := s.createBootstrapMasterURL(peerAddress, config)
+	if config.PeerAuthMode == PeerAuthModeMTLS {
+		// The CA must be known before we can authenticate with the master
+		if ca, err := s.autoCA.Load(); err != nil {
+			s.log.Fatal().Err(err).Msg("Failed to load certificate authority")
+		} else if ca == nil {
+			s.log.Fatal().Msgf("Peer authentication mode '%s' requires the certificate authority of the master in %s, copy it from the data directory of the master", config.PeerAuthMode, s.autoCA.dir)
+		}
+	}
 	for {
 		s.log.Info().Msgf("Contacting master %s...", masterURL)
 		_, hostPort, err := s.getHTTPServerPort()
@@ -53,6 +61,7 @@ func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Confi
 			ResilientSingle: copyBoolRef(bsCfg.StartResilientSingle),
 			SyncMaster:      copyBoolRef(bsCfg.StartSyncMaster),
 			SyncWorker:      copyBoolRef(bsCfg.StartSyncWorker),
+			AutoCA:          s.autoCA != nil,
 		})
 		if err != nil {
 			s.log.Fatal().Err(err).Msg("Failed to encode Hello request")
@@ -61,7 +70,7 @@ func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Confi
 		if err != nil {
 			s.log.Fatal().Err(err).Msg("Failed to create Hello URL")
 		}
-		r, err := httpClient.Post(helloURL, contentTypeJSON, bytes.NewReader(encoded))
+		r, err := s.peerAuth.httpClient.Post(helloURL, contentTypeJSON, bytes.NewReader(encoded))
 		if err != nil {
 			s.log.Info().Err(err).Msg("Initial handshake with master failed")
 			time.Sleep(time.Second)
@@ -93,11 +102,12 @@ func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Confi
 			s.log.Fatal().Msgf("Cannot start because of HTTP error from master: code=%d, message=%s\n", r.StatusCode, err.Error())
 			return
 		}
-		var result ClusterConfig
-		if err := json.Unmarshal(body, &result); err != nil {
+		var response HelloResponse
+		if err := json.Unmarshal(body, &response); err != nil {
 			s.log.Warn().Err(err).Msg("Cannot parse body from master")
 			return
 		}
+		result := response.ClusterConfig
 		// Check result
 		if _, found := result.PeerByID(s.id); !found {
 			s.log.Fatal().Msg("Master responsed with cluster config that does not contain my ID, please check master")
@@ -107,6 +117,21 @@ func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Confi
 			s.log.Fatal().Msg("Master responsed with cluster config that does not contain a ServerStorageEngine, please update master first")
 			return
 		}
+		// Install certificate authority of the cluster
+		if s.autoCA != nil {
+			if response.AutoCA == nil {
+				s.log.Fatal().Msg("Master responded without a certificate authority, please use --ssl.auto-ca on all starters")
+				return
+			}
+			if err := s.autoCA.Install(*response.AutoCA); err != nil {
+				s.log.Fatal().Err(err).Msg("Failed to install certificate authority")
+				return
+			}
+			if err := s.autoCA.Prepare(s.autoCAHosts()); err != nil {
+				s.log.Fatal().Err(err).Msg("Failed to issue server certificate")
+				return
+			}
+		}
 		// Save cluster config
 		s.myPeers = result
 		bsCfg.ServerStorageEngine = result.ServerStorageEngine
@@ -137,7 +162,7 @@ func (s *Service) bootstrapSlave(peerAddress string, runner Runner, config Confi
 			// Wait a bit until we have enough peers for a valid agency
 			time.Sleep(time.Second)
 			master := s.myPeers.AllPeers[0] // TODO replace with bootstrap master
-			r, err := httpClient.Get(master.CreateStarterURL("/hello"))
+			r, err := s.peerAuth.httpClient.Get(master.CreateStarterURL("/hello"))
 			if err != nil {
 				s.log.Error().Err(err).Msg("Failed to connect to master")
 				time.Sleep(time.Second * 2)
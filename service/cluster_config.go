@@ -47,7 +47,7 @@ type ClusterConfig struct {
 	AgencySize          int        // Number of agents
 	LastModified        *time.Time `json:"LastModified,omitempty"`        // Time of last modification
 	PortOffsetIncrement int        `json:"PortOffsetIncrement,omitempty"` // Increment of port offsets for peers on same address
-	ServerStorageEngine string     `json:ServerStorageEngine,omitempty"`  // Storage engine being used
+	ServerStorageEngine string     `json:"ServerStorageEngine,omitempty"` // Storage engine being used
 }
 
 // PeerByID returns a peer with given id & true, or false if not found.
@@ -295,6 +295,20 @@ func (p ClusterConfig) GetSyncMasterEndpoints() ([]string, error) {
 	return endpoints, nil
 }
 
+// GetSyncWorkerEndpoints creates a list of URL's for all sync workers.
+func (p ClusterConfig) GetSyncWorkerEndpoints() ([]string, error) {
+	// Build endpoint list
+	var endpoints []string
+	for _, p := range p.AllPeers {
+		if p.HasSyncWorker() {
+			port := p.Port + p.PortOffset + definitions.ServerType(definitions.ServerTypeSyncWorker).PortOffset()
+			ep := fmt.Sprintf("https://%s", net.JoinHostPort(p.Address, strconv.Itoa(port)))
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return endpoints, nil
+}
+
 // CreateAgencyAPI creates a client for the agency
 func (p ClusterConfig) CreateAgencyAPI(clientBuilder ClientBuilder) (agency.Agency, error) {
 	// Build endpoint list
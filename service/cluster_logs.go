@@ -0,0 +1,226 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/api"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	clusterLogsPeerTimeout = time.Minute
+)
+
+// clusterLogsSource holds the (filtered) log content of a single server in the cluster.
+type clusterLogsSource struct {
+	Peer    Peer
+	Type    definitions.ServerType
+	Content string
+	Err     error
+}
+
+// clusterLogsHandler returns the logs of the servers of all starters in the cluster,
+// merged by timestamp.
+// The query parameters `type`, `tail`, `since` & `level` can be used to limit
+// the returned lines.
+func (s *httpServer) clusterLogsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseLogsQuery(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if q.Follow {
+		handleError(w, maskAny(client.NewBadRequestError("Following the cluster logs is not supported")))
+		return
+	}
+	q.SSE = false
+	serverType := definitions.ServerType(r.URL.Query().Get("type"))
+	if serverType != "" && !isKnownServerType(serverType, definitions.AllServerTypes()) {
+		handleError(w, maskAny(client.NewBadRequestError(fmt.Sprintf("Unknown server type '%s'", serverType))))
+		return
+	}
+
+	result := s.clusterLogsObject(r.Context(), serverType, q)
+	b, err := json.Marshal(result)
+	if err != nil {
+		handleError(w, maskAny(err))
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// clusterLogsObject fetches the logs of all servers (of the given type, if set) of all
+// peers in the cluster and merges them.
+func (s *httpServer) clusterLogsObject(ctx context.Context, serverType definitions.ServerType, q logsQuery) api.ClusterLogs {
+	peers, _, mode := s.context.ClusterConfig()
+
+	var sources []*clusterLogsSource
+	for _, p := range peers.AllPeers {
+		p := p
+		for _, t := range s.clusterLogsServerTypes(mode, &p) {
+			if serverType == "" || serverType == t || (serverType == definitions.ServerTypeSingle && t == definitions.ServerTypeResilientSingle) {
+				sources = append(sources, &clusterLogsSource{Peer: p, Type: t})
+			}
+		}
+	}
+
+	wg := sync.WaitGroup{}
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src *clusterLogsSource) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, clusterLogsPeerTimeout)
+			defer cancel()
+			if src.Peer.ID == s.idInfo.ID {
+				src.Content, src.Err = s.localLogContent(ctx, src.Type, q)
+			} else {
+				src.Content, src.Err = s.remoteLogContent(ctx, src.Peer, src.Type, q)
+			}
+		}(src)
+	}
+	wg.Wait()
+
+	result := api.ClusterLogs{}
+	for _, src := range sources {
+		if src.Err != nil {
+			s.log.Debug().Err(src.Err).Str("peer", src.Peer.ID).Str("type", string(src.Type)).Msg("Failed to fetch log")
+			result.Errors = append(result.Errors, api.ClusterLogsError{
+				Peer:  src.Peer.ID,
+				Type:  src.Type,
+				Error: api.NewError(src.Err),
+			})
+		}
+	}
+	result.Lines = mergeClusterLogs(sources, q.Tail)
+	return result
+}
+
+// clusterLogsServerTypes returns the types of all servers started by the given peer.
+func (s *httpServer) clusterLogsServerTypes(mode ServiceMode, p *Peer) []definitions.ServerType {
+	var result []definitions.ServerType
+	s.forEachServerType(mode, p, func(_ ServiceMode, _ *Peer, t definitions.ServerType) error {
+		result = append(result, t)
+		return nil
+	})
+	if p.HasSyncMaster() {
+		result = append(result, definitions.ServerTypeSyncMaster)
+	}
+	if p.HasSyncWorker() {
+		result = append(result, definitions.ServerTypeSyncWorker)
+	}
+	return result
+}
+
+// localLogContent returns the content of the log of the server of given type started by this starter.
+func (s *httpServer) localLogContent(ctx context.Context, serverType definitions.ServerType, q logsQuery) (string, error) {
+	logPath, err := s.context.serverHostLogFile(serverType)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	f, err := openLogFile(ctx, logPath, false)
+	if err != nil {
+		return "", maskAny(err)
+	} else if f == nil {
+		// Log file not there (yet)
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := copyLogFile(ctx, f, logPath, &logsStreamer{w: &buf, q: q}); err != nil {
+		return "", maskAny(err)
+	}
+	return buf.String(), nil
+}
+
+// remoteLogContent fetches the content of the log of the server of given type from the starter of the given peer.
+func (s *httpServer) remoteLogContent(ctx context.Context, p Peer, serverType definitions.ServerType, q logsQuery) (string, error) {
+	c, err := createPeerClient(p, s.peerAuth.clientOptions...)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	clientServerType := client.ServerType(serverType)
+	if serverType == definitions.ServerTypeResilientSingle {
+		clientServerType = client.ServerTypeSingle
+	}
+	rd, err := c.Logs(ctx, clientServerType, client.LogsOptions{
+		Tail:  q.Tail,
+		Since: q.Since,
+		Level: q.Level.String(),
+	})
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer rd.Close()
+	content, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(content), nil
+}
+
+// mergeClusterLogs merges the lines of all given sources, ordered by their timestamp.
+// Lines without a timestamp (continuations of multi line messages) keep their position
+// after the line that started the message.
+// If tail > 0, only the last tail lines are returned.
+func mergeClusterLogs(sources []*clusterLogsSource, tail int) []api.ClusterLogLine {
+	lines := make([]api.ClusterLogLine, 0)
+	for _, src := range sources {
+		var lastTime time.Time
+		for _, line := range strings.Split(strings.TrimSuffix(src.Content, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			if h, ok := parseLogLineHeader(line); ok {
+				lastTime = h.Time
+			}
+			lines = append(lines, api.ClusterLogLine{
+				Time: lastTime,
+				Peer: src.Peer.ID,
+				Type: src.Type,
+				Line: line,
+			})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	return lines
+}
@@ -0,0 +1,76 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/pkg/api"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_MergeClusterLogs(t *testing.T) {
+	sources := []*clusterLogsSource{
+		{
+			Peer: Peer{ID: "a"},
+			Type: definitions.ServerTypeDBServer,
+			Content: "2021-03-01T10:00:00Z [1] INFO [aaaaa] {general} a1\n" +
+				"2021-03-01T10:02:00Z [1] ERROR [bbbbb] {general} a2\n" +
+				"\tcontinuation of a2\n",
+		},
+		{
+			Peer: Peer{ID: "b"},
+			Type: definitions.ServerTypeAgent,
+			Content: "2021-03-01T10:01:00Z [1] INFO [ccccc] {general} b1\n" +
+				"2021-03-01T10:03:00Z [1] INFO [ddddd] {general} b2\n",
+		},
+	}
+	lineOf := func(lines []api.ClusterLogLine) []string {
+		var result []string
+		for _, l := range lines {
+			result = append(result, l.Peer+" "+string(l.Type)+" "+l.Line)
+		}
+		return result
+	}
+
+	t.Run("All", func(t *testing.T) {
+		require.Equal(t, []string{
+			"a dbserver 2021-03-01T10:00:00Z [1] INFO [aaaaa] {general} a1",
+			"b agent 2021-03-01T10:01:00Z [1] INFO [ccccc] {general} b1",
+			"a dbserver 2021-03-01T10:02:00Z [1] ERROR [bbbbb] {general} a2",
+			"a dbserver \tcontinuation of a2",
+			"b agent 2021-03-01T10:03:00Z [1] INFO [ddddd] {general} b2",
+		}, lineOf(mergeClusterLogs(sources, 0)))
+	})
+
+	t.Run("Tail", func(t *testing.T) {
+		require.Equal(t, []string{
+			"a dbserver \tcontinuation of a2",
+			"b agent 2021-03-01T10:03:00Z [1] INFO [ddddd] {general} b2",
+		}, lineOf(mergeClusterLogs(sources, 2)))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		require.Empty(t, mergeClusterLogs([]*clusterLogsSource{{Peer: Peer{ID: "a"}}}, 0))
+	})
+}
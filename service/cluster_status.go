@@ -0,0 +1,211 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/arangodb/go-driver/agency"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	// serverHealthUnknown is reported for servers of which the agency has no health record.
+	serverHealthUnknown = "UNKNOWN"
+	// serverHealthGood is reported for agents that respond to agency requests.
+	serverHealthGood = "GOOD"
+	// serverHealthBad is reported for agents that do not respond to agency requests.
+	serverHealthBad = "BAD"
+
+	// maxAgentStatusResponseTime is the maximum time to wait for a single agent
+	// to respond when building the cluster status.
+	maxAgentStatusResponseTime = time.Second * 5
+)
+
+var (
+	supervisionHealthKey      = []string{"arango", "Supervision", "Health"}
+	asyncReplicationLeaderKey = []string{"arango", "Plan", "AsyncReplication", "Leader"}
+)
+
+// agencyServerHealth is the health record of a single server in the agency
+// (under arango/Supervision/Health).
+type agencyServerHealth struct {
+	Endpoint  string `json:"Endpoint"`
+	ShortName string `json:"ShortName"`
+	Status    string `json:"Status"`
+}
+
+// ClusterStatus returns all starters of the cluster with the servers they start
+// and the health of those servers as known to the agency.
+func (s *Service) ClusterStatus(ctx context.Context) client.ClusterStatus {
+	config, _, mode := s.ClusterConfig()
+	isRunningMaster, _, masterURL := s.IsRunningMaster()
+
+	result := client.ClusterStatus{
+		Mode: string(mode),
+	}
+	for _, p := range config.AllPeers {
+		ps := client.PeerStatus{
+			ID:       p.ID,
+			Address:  p.Address,
+			Port:     p.Port + p.PortOffset,
+			Endpoint: p.CreateStarterURL("/"),
+		}
+		if (isRunningMaster && p.ID == s.id) || (!isRunningMaster && p.CreateStarterURL("/") == masterURL) {
+			ps.IsMaster = true
+			result.MasterID = p.ID
+		}
+		for _, t := range expectedServerTypes(mode, p) {
+			ps.Servers = append(ps.Servers, client.ServerStatus{
+				Type:    client.ServerType(t),
+				Address: p.Address,
+				Port:    p.Port + p.PortOffset + t.PortOffset(),
+			})
+		}
+		result.Peers = append(result.Peers, ps)
+	}
+
+	if !mode.HasAgency() {
+		// Without an agency there is no health information
+		return result
+	}
+
+	// Fetch health of agents
+	for i, p := range config.AllPeers {
+		for j, srv := range result.Peers[i].Servers {
+			if srv.Type == client.ServerTypeAgent {
+				result.Peers[i].Servers[j].Health, result.Peers[i].Servers[j].Leader = s.agentStatus(ctx, p)
+			}
+		}
+	}
+
+	// Fetch health of all other servers
+	health, leaderID, err := s.readServerHealth(ctx, mode)
+	if err != nil {
+		s.log.Debug().Err(err).Msg("Failed to read server health from agency")
+		result.HealthError = err.Error()
+	}
+	for i := range result.Peers {
+		for j, srv := range result.Peers[i].Servers {
+			if srv.Type == client.ServerTypeAgent {
+				continue
+			}
+			srvStatus := &result.Peers[i].Servers[j]
+			if id, h, found := findServerHealth(health, srv.Address, srv.Port); found {
+				srvStatus.ID = id
+				srvStatus.ShortName = h.ShortName
+				srvStatus.Health = h.Status
+				srvStatus.Leader = leaderID != "" && id == leaderID
+			} else {
+				srvStatus.Health = serverHealthUnknown
+			}
+		}
+	}
+	return result
+}
+
+// readServerHealth reads the health records of all servers from the agency.
+// In active failover mode, the ID of the current leader is returned as well.
+func (s *Service) readServerHealth(ctx context.Context, mode ServiceMode) (map[string]agencyServerHealth, string, error) {
+	config, _, _ := s.ClusterConfig()
+	agencyClient, err := config.CreateAgencyAPI(s)
+	if err != nil {
+		return nil, "", maskAny(err)
+	}
+	var health map[string]agencyServerHealth
+	if err := agencyClient.ReadKey(ctx, supervisionHealthKey, &health); err != nil && !agency.IsKeyNotFound(err) {
+		return nil, "", maskAny(err)
+	}
+	var leaderID string
+	if mode.IsActiveFailoverMode() {
+		if err := agencyClient.ReadKey(ctx, asyncReplicationLeaderKey, &leaderID); err != nil && !agency.IsKeyNotFound(err) {
+			return health, "", maskAny(err)
+		}
+	}
+	return health, leaderID, nil
+}
+
+// agencyConfig is the part of the response of /_api/agency/config
+// that is used to find the leader of the agency.
+type agencyConfig struct {
+	LeaderID      string `json:"leaderId"`
+	Configuration struct {
+		ID string `json:"id"`
+	} `json:"configuration"`
+}
+
+// IsLeader returns true if the agent that returned this configuration
+// is the leader of the agency.
+func (c agencyConfig) IsLeader() bool {
+	return c.LeaderID != "" && c.LeaderID == c.Configuration.ID
+}
+
+// agentStatus returns the health of the agent of the given peer and whether
+// it is the leader of the agency.
+// Every agent answers /_api/agency/config itself, with its own ID and the ID of the leader.
+func (s *Service) agentStatus(ctx context.Context, p Peer) (health string, isLeader bool) {
+	port := p.Port + p.PortOffset + definitions.ServerType(definitions.ServerTypeAgent).PortOffset()
+	scheme := NewURLSchemes(p.IsSecure).Browser
+	ep := scheme + "://" + net.JoinHostPort(p.Address, strconv.Itoa(port))
+	client, err := s.CreateClient([]string{ep}, ConnectionTypeAgency, definitions.ServerTypeUnknown)
+	if err != nil {
+		return serverHealthBad, false
+	}
+	c := client.Connection()
+	req, err := c.NewRequest("GET", "/_api/agency/config")
+	if err != nil {
+		return serverHealthBad, false
+	}
+	lctx, cancel := context.WithTimeout(ctx, maxAgentStatusResponseTime)
+	defer cancel()
+	resp, err := c.Do(lctx, req)
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		return serverHealthBad, false
+	}
+	var config agencyConfig
+	if err := resp.ParseBody("", &config); err != nil {
+		return serverHealthBad, false
+	}
+	return serverHealthGood, config.IsLeader()
+}
+
+// findServerHealth returns the ID and health record of the server listening
+// on the given address and port.
+func findServerHealth(health map[string]agencyServerHealth, address string, port int) (string, agencyServerHealth, bool) {
+	hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+	for id, h := range health {
+		u, err := url.Parse(h.Endpoint)
+		if err != nil {
+			continue
+		}
+		if u.Host == hostPort {
+			return id, h, true
+		}
+	}
+	return "", agencyServerHealth{}, false
+}
@@ -0,0 +1,68 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FindServerHealth(t *testing.T) {
+	health := map[string]agencyServerHealth{
+		"PRMR-1": {Endpoint: "tcp://10.0.0.1:8530", ShortName: "DBServer0001", Status: "GOOD"},
+		"CRDN-1": {Endpoint: "ssl://10.0.0.1:8529", ShortName: "Coordinator0001", Status: "BAD"},
+		"PRMR-2": {Endpoint: "tcp://[::1]:8530", ShortName: "DBServer0002", Status: "FAILED"},
+	}
+
+	id, h, found := findServerHealth(health, "10.0.0.1", 8530)
+	require.True(t, found)
+	require.Equal(t, "PRMR-1", id)
+	require.Equal(t, "DBServer0001", h.ShortName)
+
+	id, h, found = findServerHealth(health, "10.0.0.1", 8529)
+	require.True(t, found)
+	require.Equal(t, "CRDN-1", id)
+	require.Equal(t, "BAD", h.Status)
+
+	id, _, found = findServerHealth(health, "::1", 8530)
+	require.True(t, found)
+	require.Equal(t, "PRMR-2", id)
+
+	_, _, found = findServerHealth(health, "10.0.0.2", 8530)
+	require.False(t, found)
+	_, _, found = findServerHealth(nil, "10.0.0.1", 8530)
+	require.False(t, found)
+}
+
+func Test_AgencyConfigIsLeader(t *testing.T) {
+	parse := func(body string) agencyConfig {
+		var c agencyConfig
+		require.NoError(t, json.Unmarshal([]byte(body), &c))
+		return c
+	}
+
+	require.True(t, parse(`{"term":3,"leaderId":"AGNT-1","configuration":{"id":"AGNT-1","active":["AGNT-1","AGNT-2","AGNT-3"]}}`).IsLeader())
+	require.False(t, parse(`{"term":3,"leaderId":"AGNT-1","configuration":{"id":"AGNT-2","active":["AGNT-1","AGNT-2","AGNT-3"]}}`).IsLeader())
+	// No leader elected yet
+	require.False(t, parse(`{"term":0,"leaderId":"","configuration":{"id":""}}`).IsLeader())
+}
@@ -87,6 +87,15 @@ func (v DatabaseFeatures) HasJWTSecretFileOption() bool {
 	return false
 }
 
+// GetEncryptionFolderOption returns true when the server supports
+// an encryption key folder (`--rocksdb.encryption-keyfolder`) & key rotation.
+func (v DatabaseFeatures) GetEncryptionFolderOption() bool {
+	return features.EncryptionRotation().Enabled(features.Version{
+		Version:    v.Version,
+		Enterprise: v.Enterprise,
+	})
+}
+
 func (v DatabaseFeatures) GetJWTFolderOption() bool {
 	return features.JWTRotation().Enabled(features.Version{
 		Version:    v.Version,
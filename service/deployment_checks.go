@@ -0,0 +1,301 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/arangodb/go-driver/agency"
+	"github.com/rs/zerolog"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+	"github.com/arangodb-helper/arangodb/service/actions"
+)
+
+const (
+	// maxServerStartTime is the maximum time to wait for a server that is restarted
+	// by an upgrade, rollback or restart plan to be started again.
+	maxServerStartTime = time.Minute * 30
+)
+
+// deploymentChecks provides agency access & the health checks of a deployment.
+// It is embedded by the upgrade manager and the restart manager.
+type deploymentChecks struct {
+	log                   zerolog.Logger
+	upgradeManagerContext UpgradeManagerContext
+}
+
+// Errorf is a wrapper for log.Error()... used by the agency lock
+func (m *deploymentChecks) Errorf(msg string, args ...interface{}) {
+	m.log.Error().Msgf(msg, args...)
+}
+
+// Create a client for the agency
+func (m *deploymentChecks) createAgencyAPI() (agency.Agency, error) {
+	// Get cluster config
+	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
+	// Create client
+	a, err := clusterConfig.CreateAgencyAPI(m.upgradeManagerContext)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return a, nil
+}
+
+// readUpgradePlan reads the current upgrade plan from the agency.
+func (m *deploymentChecks) readUpgradePlan(ctx context.Context) (UpgradePlan, error) {
+	var plan UpgradePlan
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return UpgradePlan{}, maskAny(err)
+	}
+	if err := api.ReadKey(ctx, upgradePlanKey, &plan); err != nil {
+		return UpgradePlan{}, maskAny(err)
+	}
+	return plan, nil
+}
+
+// waitUntil loops until the the given predicate returns nil or the given context is
+// canceled.
+// Failures of the predicate are reported to the given progress (if any).
+// Returns nil when the predicate succeeded, an error otherwise.
+func (m *deploymentChecks) waitUntil(ctx context.Context, predicate func(ctx context.Context) error, errorLogTemplate string, progress actions.Progressor) error {
+	for {
+		err := predicate(ctx)
+		if err == nil {
+			return nil
+		}
+		m.log.Info().Msgf(errorLogTemplate, err)
+		if progress != nil {
+			progress.Progress(fmt.Sprintf(errorLogTemplate, err))
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-time.After(time.Second * 5):
+			// Try again
+		}
+	}
+}
+
+// waitUntilServerStarted loops until the given started function returns true or the given context is
+// canceled.
+// Returns an error when the starter has given up restarting the server of given type
+// or when it has not been started within maxServerStartTime.
+func (m *deploymentChecks) waitUntilServerStarted(ctx context.Context, serverType definitions.ServerType, started func() bool) error {
+	deadline := time.Now().Add(maxServerStartTime)
+	for {
+		if started() {
+			return nil
+		}
+		if status, found := m.upgradeManagerContext.ServerStatus(serverType); found && status.Failed {
+			return maskAny(fmt.Errorf("Starter has given up restarting the %s", serverType))
+		}
+		if time.Now().After(deadline) {
+			return maskAny(fmt.Errorf("The %s has not been started within %s", serverType, maxServerStartTime))
+		}
+		select {
+		case <-ctx.Done():
+			return maskAny(ctx.Err())
+		case <-time.After(time.Millisecond * 100):
+			// Try again
+		}
+	}
+}
+
+// isAgencyHealth performs a check if the agency is healthy.
+// Returns nil when agency is completely healthy, an error
+// when the agency is not healthy or its health state could not
+// be determined.
+func (m *deploymentChecks) isAgencyHealth(ctx context.Context) error {
+	// Get cluster config
+	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
+	// Build endpoint list
+	endpoints, err := clusterConfig.GetAgentEndpoints()
+	if err != nil {
+		return maskAny(err)
+	}
+	// Build agency clients
+	clients := make([]driver.Connection, 0, len(endpoints))
+	for _, ep := range endpoints {
+		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeAgency, definitions.ServerTypeUnknown)
+		if err != nil {
+			return maskAny(err)
+		}
+		clients = append(clients, c.Connection())
+	}
+	// Check health
+	if err := agency.AreAgentsHealthy(ctx, clients); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// isClusterHealthy performs a check on the cluster health status.
+// If any of the servers is reported as not GOOD, an error is returned.
+func (m *deploymentChecks) isClusterHealthy(ctx context.Context) error {
+	// Get cluster config
+	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
+	// Build endpoint list
+	endpoints, err := clusterConfig.GetCoordinatorEndpoints()
+	if err != nil {
+		return maskAny(err)
+	}
+	// Build client
+	c, err := m.upgradeManagerContext.CreateClient(endpoints, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
+	if err != nil {
+		return maskAny(err)
+	}
+	// Check health
+	cl, err := c.Cluster(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	h, err := cl.Health(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	for id, sh := range h.Health {
+		if sh.Role == driver.ServerRoleAgent && sh.Status == "" {
+			continue
+		}
+		if sh.Status != driver.ServerStatusGood {
+			return maskAny(fmt.Errorf("Server '%s' has a '%s' status", id, sh.Status))
+		}
+	}
+	return nil
+}
+
+// areDBServersResponding performs a check if all dbservers are responding.
+func (m *deploymentChecks) areDBServersResponding(ctx context.Context) error {
+	// Get cluster config
+	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
+	// Build endpoint list
+	endpoints, err := clusterConfig.GetDBServerEndpoints()
+	if err != nil {
+		return maskAny(err)
+	}
+	// Check all
+	for _, ep := range endpoints {
+		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
+		if err != nil {
+			return maskAny(err)
+		}
+		if _, err := c.ServerID(ctx); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// areCoordinatorsResponding performs a check if all coordinators are responding.
+func (m *deploymentChecks) areCoordinatorsResponding(ctx context.Context) error {
+	// Get cluster config
+	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
+	// Build endpoint list
+	endpoints, err := clusterConfig.GetCoordinatorEndpoints()
+	if err != nil {
+		return maskAny(err)
+	}
+	// Check all
+	for _, ep := range endpoints {
+		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
+		if err != nil {
+			return maskAny(err)
+		}
+		if _, err := c.ServerID(ctx); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// areSingleServersResponding performs a check if all single servers are responding.
+func (m *deploymentChecks) areSingleServersResponding(ctx context.Context) error {
+	// Get cluster config
+	clusterConfig, _, mode := m.upgradeManagerContext.ClusterConfig()
+	// Build endpoint list
+	endpoints, err := clusterConfig.GetSingleEndpoints(mode.IsSingleMode())
+	if err != nil {
+		return maskAny(err)
+	}
+	// Check all
+	for _, ep := range endpoints {
+		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
+		if err != nil {
+			return maskAny(err)
+		}
+		if _, err := c.Version(ctx); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// restartCheck is a predicate that must succeed after restarting a server
+// before the restart plan continues.
+type restartCheck struct {
+	predicate        func(ctx context.Context) error
+	errorLogTemplate string
+	failure          string
+}
+
+// restartChecks returns the type of server involved in entries of the given type,
+// together with the checks that must succeed after restarting that server.
+func (m *deploymentChecks) restartChecks(entryType UpgradeEntryType, mode ServiceMode) (definitions.ServerType, []restartCheck, error) {
+	clusterHealthy := restartCheck{m.isClusterHealthy, "Cluster is not yet healthy: %v", "Cluster is not healthy in time"}
+	agencyHealthy := restartCheck{m.isAgencyHealth, "Agency is not yet healthy: %v", "Agency is not healthy in time"}
+	var serverType definitions.ServerType
+	var checks []restartCheck
+	switch entryType {
+	case UpgradeEntryTypeAgent:
+		serverType = definitions.ServerTypeAgent
+		checks = []restartCheck{agencyHealthy}
+		if mode.IsClusterMode() {
+			checks = append(checks, clusterHealthy)
+		}
+	case UpgradeEntryTypeDBServer:
+		// The dbserver resigns its leadership before it is stopped (see ActionResignLeadership)
+		serverType = definitions.ServerTypeDBServer
+		checks = []restartCheck{
+			{m.areDBServersResponding, "DBServers are not yet all responding: %v", "Not all DBServers are responding in time"},
+			clusterHealthy,
+		}
+	case UpgradeEntryTypeCoordinator:
+		serverType = definitions.ServerTypeCoordinator
+		checks = []restartCheck{
+			{m.areCoordinatorsResponding, "Coordinator are not yet all responding: %v", "Not all Coordinators are responding in time"},
+			clusterHealthy,
+		}
+	case UpgradeEntryTypeSingle:
+		serverType = definitions.ServerTypeResilientSingle
+		checks = []restartCheck{
+			{m.areSingleServersResponding, "Active failover single server is not yet responding: %v", "Not all single servers are responding in time"},
+			agencyHealthy,
+		}
+	default:
+		return "", nil, maskAny(fmt.Errorf("Cannot restart servers of entry type '%s'", entryType))
+	}
+	return serverType, checks, nil
+}
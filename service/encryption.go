@@ -0,0 +1,529 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/arangodb-helper/arangodb/pkg/api"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+	rotateClient "github.com/arangodb-helper/arangodb/service/clients"
+)
+
+const (
+	encryptionActionAdd      = "add"
+	encryptionActionActivate = "activate"
+	encryptionActionRemove   = "remove"
+	encryptionActionRefresh  = "refresh"
+
+	encryptionRefreshTimeout = time.Minute
+)
+
+// errEncryptionKeyNotFound is returned when a key with a given SHA is not found in a key folder.
+var errEncryptionKeyNotFound = errors.New("Encryption key not found")
+
+func newEncryptionKeyManager(d string) encryptionKeyManager {
+	return encryptionKeyManager{d}
+}
+
+// encryptionKeyManager manages a folder of RocksDB encryption keys.
+// Every key is stored in a file named by the SHA256 of the key.
+// The active key is (also) stored in a file named `-`.
+type encryptionKeyManager struct {
+	dir string
+}
+
+// keys returns the content of all files in the folder, by file name.
+func (e encryptionKeyManager) keys() (tokens, error) {
+	files, err := ioutil.ReadDir(e.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files = FilterFiles(files, FilterOnlyFiles)
+
+	m := map[string][]byte{}
+
+	for _, f := range files {
+		d, err := ioutil.ReadFile(filepath.Join(e.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m[f.Name()] = d
+	}
+
+	return m, nil
+}
+
+// shas returns the sorted SHAs of all keys in the folder.
+func (e encryptionKeyManager) shas() ([]string, error) {
+	keys, err := e.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, d := range keys {
+		found[Sha256sumRaw(d)] = true
+	}
+
+	result := make([]string, 0, len(found))
+	for sha := range found {
+		result = append(result, sha)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// active returns the SHA of the active key.
+func (e encryptionKeyManager) active() (string, error) {
+	d, err := ioutil.ReadFile(filepath.Join(e.dir, definitions.ArangodEncryptionKeyActive))
+	if err != nil {
+		return "", err
+	}
+
+	return Sha256sumRaw(d), nil
+}
+
+// add stores the given key in the folder.
+func (e encryptionKeyManager) add(d []byte) error {
+	if len(d) != definitions.ArangodEncryptionKeyLength {
+		return errors.Errorf("Encryption key must be %d bytes long, got %d", definitions.ArangodEncryptionKeyLength, len(d))
+	}
+
+	return ioutil.WriteFile(filepath.Join(e.dir, Sha256sumRaw(d)), d, 0600)
+}
+
+// setActive makes the key with given SHA the active key.
+func (e encryptionKeyManager) setActive(sha string) error {
+	d, err := ioutil.ReadFile(filepath.Join(e.dir, sha))
+	if os.IsNotExist(err) {
+		return errEncryptionKeyNotFound
+	} else if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(e.dir, definitions.ArangodEncryptionKeyActive), d, 0600)
+}
+
+// remove removes the key with given SHA from the folder.
+// The active key cannot be removed.
+func (e encryptionKeyManager) remove(sha string) error {
+	if active, err := e.active(); err == nil && active == sha {
+		return errors.Errorf("Encryption key %s is active", sha)
+	}
+
+	if err := os.Remove(filepath.Join(e.dir, sha)); os.IsNotExist(err) {
+		return errEncryptionKeyNotFound
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// mirror makes the content of the folder of the given manager equal to the content of this folder.
+func (e encryptionKeyManager) mirror(target encryptionKeyManager) error {
+	keys, err := e.keys()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := keys[definitions.ArangodEncryptionKeyActive]; !ok {
+		return errors.Errorf("Unable to find active encryption key in %s", e.dir)
+	}
+
+	tKeys, err := target.keys()
+	if err != nil {
+		return err
+	}
+
+	// Add missing keys & update the active key
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if tD, ok := tKeys[name]; ok && bytes.Equal(tD, keys[name]) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(target.dir, name), keys[name], 0600); err != nil {
+			return err
+		}
+	}
+
+	// Remove keys that no longer exist
+	for name := range tKeys {
+		if _, ok := keys[name]; !ok {
+			if err := os.Remove(filepath.Join(target.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// initEncryptionKeyFolder creates the encryption key folder of the starter
+// and stores the given keyfile as active key if the folder has no active key yet.
+func initEncryptionKeyFolder(dir, keyFile string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	e := newEncryptionKeyManager(dir)
+	if _, err := e.active(); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	d, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := e.add(d); err != nil {
+		return err
+	}
+
+	return e.setActive(Sha256sumRaw(d))
+}
+
+// createRocksDBEncryptionKeyFolder synchronizes the encryption key folder in the given host directory
+// with the encryption key folder of the starter.
+// Returns the volumes & the name of the folder in the container, or an empty name when no
+// key folder is used.
+func createRocksDBEncryptionKeyFolder(log zerolog.Logger, bsCfg BootstrapConfig, myHostDir, myContainerDir string, arangodConfig configFile, features DatabaseFeatures) ([]Volume, string, error) {
+	if bsCfg.RocksDBEncryptionKeyFile == "" || !features.GetEncryptionFolderOption() {
+		return nil, "", nil
+	}
+
+	if section := arangodConfig.FindSection("rocksdb"); section != nil {
+		if _, found := section.Settings["encryption-keyfile"]; found {
+			// Config file has been modified by the user & still uses a keyfile
+			log.Warn().Msg("arangod.conf contains rocksdb.encryption-keyfile, not using an encryption key folder")
+			return nil, "", nil
+		}
+	}
+
+	hostFolderName := filepath.Join(myHostDir, definitions.ArangodEncryptionKeyFolderName)
+	containerFolderName := filepath.Join(myContainerDir, definitions.ArangodEncryptionKeyFolderName)
+	volumes := addVolume(nil, hostFolderName, containerFolderName, true)
+
+	if err := os.MkdirAll(hostFolderName, 0700); err != nil {
+		return nil, "", err
+	}
+
+	if err := newEncryptionKeyManager(bsCfg.EncryptionKeyFolderDir()).mirror(newEncryptionKeyManager(hostFolderName)); err != nil {
+		return nil, "", err
+	}
+
+	return volumes, containerFolderName, nil
+}
+
+func (s *httpServer) registerEncryptionFunctions(m *http.ServeMux) {
+	for _, action := range []string{encryptionActionAdd, encryptionActionActivate, encryptionActionRemove, encryptionActionRefresh} {
+		m.HandleFunc("/admin/encryption/"+action, s.apiAuth.requireRoleByMethod(s.encryptionHandler(action, false)))
+		m.HandleFunc("/local/encryption/"+action, s.apiAuth.requirePeerOrRole(StarterRoleAdmin, s.encryptionHandler(action, true)))
+	}
+	m.HandleFunc("/local/encryption/keys", s.apiAuth.requirePeerOrRole(StarterRoleReadOnly, s.encryptionKeysHandler))
+}
+
+// encryptionKeysHandler returns the SHAs of the encryption keys of this peer.
+func (s *httpServer) encryptionKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.context.EncryptionKeyFolder() == "" {
+		writeError(w, http.StatusNotImplemented, "Only available in folder mode")
+		return
+	}
+	keys, err := readEncryptionKeys(s.context.EncryptionKeyFolder())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	b, err := json.Marshal(keys)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// readEncryptionKeys returns the SHAs of the keys in the given encryption key folder.
+func readEncryptionKeys(dir string) (api.EncryptionKeys, error) {
+	e := newEncryptionKeyManager(dir)
+	active, err := e.active()
+	if err != nil {
+		return api.EncryptionKeys{}, maskAny(err)
+	}
+	shas, err := e.shas()
+	if err != nil {
+		return api.EncryptionKeys{}, maskAny(err)
+	}
+	return api.EncryptionKeys{Active: active, Keys: shas}, nil
+}
+
+// checkEncryptionKeysOfPeers compares the active encryption key of this starter with the active
+// key of the first other starter that responds.
+// A starter that joins (or rejoins) after the keys have been rotated only has the key of
+// --rocksdb.encryption-keyfile, so its servers would not be able to work with the rotated keys.
+// Returns an error when the active keys differ.
+func (s *Service) checkEncryptionKeysOfPeers(ctx context.Context) error {
+	dir := s.EncryptionKeyFolder()
+	if dir == "" {
+		return nil
+	}
+	own, err := readEncryptionKeys(dir)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, p := range s.myPeers.AllPeers {
+		if p.ID == s.id {
+			continue
+		}
+		c, err := createPeerClient(p, s.peerAuth.clientOptions...)
+		if err != nil {
+			return maskAny(err)
+		}
+		lctx, cancel := context.WithTimeout(ctx, time.Second*10)
+		keys, err := c.EncryptionKeysLocal(lctx)
+		cancel()
+		if err != nil {
+			s.log.Debug().Err(err).Msgf("Cannot get encryption keys of peer %s", p.ID)
+			continue
+		}
+		if keys.Active != own.Active {
+			return maskAny(errors.Errorf("Active encryption key %s differs from active key %s of peer %s, "+
+				"copy the contents of the encryption key folder of that peer into %s before starting this starter", own.Active, keys.Active, p.ID, dir))
+		}
+		return nil
+	}
+	s.log.Info().Msg("No other starter responded, cannot check encryption keys")
+	return nil
+}
+
+// encryptionHandler returns a handler that performs the given action on the encryption keys
+// of this peer (local) or all peers.
+func (s *httpServer) encryptionHandler(action string, local bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, err := s.encryptionE(r, action, local)
+		writeEmptyResponse(w, code, err)
+	}
+}
+
+func (s *httpServer) encryptionE(r *http.Request, action string, local bool) (int, error) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, errors.Errorf("Method not allowed")
+	}
+
+	if s.context.EncryptionKeyFolder() == "" {
+		return http.StatusNotImplemented, errors.Errorf("Only available in folder mode")
+	}
+
+	var key []byte
+	sha := r.URL.Query().Get("key")
+	switch action {
+	case encryptionActionAdd:
+		var err error
+		if key, err = ioutil.ReadAll(r.Body); err != nil {
+			return http.StatusBadRequest, err
+		}
+		if len(key) != definitions.ArangodEncryptionKeyLength {
+			return http.StatusBadRequest, errors.Errorf("Encryption key must be %d bytes long, got %d", definitions.ArangodEncryptionKeyLength, len(key))
+		}
+	case encryptionActionActivate, encryptionActionRemove:
+		if sha == "" {
+			return http.StatusBadRequest, errors.Errorf("Key query param needs to be set")
+		}
+	}
+
+	s.log.Info().Bool("local", local).Msgf("Received encryption %s call", action)
+	var code int
+	var err error
+	if local {
+		code, err = s.encryptionOnMembers(action, key, sha)
+	} else {
+		code, err = s.encryptionOnPeers(action, key, sha)
+	}
+	if err != nil {
+		s.log.Warn().Err(err).Msgf("Encryption %s call failed", action)
+		return code, err
+	}
+	s.log.Info().Msgf("Encryption %s call done", action)
+
+	return 0, nil
+}
+
+// encryptionOnPeers performs the given action on all peers, one peer at a time.
+func (s *httpServer) encryptionOnPeers(action string, key []byte, sha string) (int, error) {
+	switch action {
+	case encryptionActionActivate:
+		// Ensure that the key is known by all members before activating it
+		i, err := s.clusterInventoryObject()
+		if err != nil {
+			return 0, err
+		}
+		if err := encryptionKeyInstalled(i, sha); err != nil {
+			return http.StatusPreconditionFailed, err
+		}
+	case encryptionActionRemove:
+		if active, err := newEncryptionKeyManager(s.context.EncryptionKeyFolder()).active(); err != nil {
+			return 0, err
+		} else if active == sha {
+			return http.StatusPreconditionFailed, errors.Errorf("Encryption key %s is active", sha)
+		}
+	}
+
+	peers, _, _ := s.context.ClusterConfig()
+
+	for _, p := range peers.AllPeers {
+		if p.ID == s.idInfo.ID {
+			if code, err := s.encryptionOnMembers(action, key, sha); err != nil {
+				return code, errors.Wrapf(err, "Failed to %s encryption key on peer %s", action, p.ID)
+			}
+			continue
+		}
+
+		c, err := createPeerClient(p, s.peerAuth.clientOptions...)
+		if err != nil {
+			return 0, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), encryptionRefreshTimeout)
+		_, err = c.AdminEncryptionLocal(ctx, action, key, sha)
+		cancel()
+		if err != nil {
+			return 0, errors.Wrapf(err, "Failed to %s encryption key on peer %s", action, p.ID)
+		}
+	}
+
+	return 0, nil
+}
+
+// encryptionKeyInstalled returns an error if the key with given SHA is not loaded by all members.
+func encryptionKeyInstalled(i *api.ClusterInventory, sha string) error {
+	if i.Error != nil {
+		return errors.Errorf("Unable to set active key if member is failed: %s", i.Error.Error)
+	}
+
+	for pname, p := range i.Peers {
+		for mname, n := range p.Members {
+			if n.Hashes == nil || n.Hashes.Encryption == nil {
+				return errors.Errorf("Unable to get encryption hashes - probably not supported by server")
+			}
+
+			if !n.Hashes.Encryption.Keys.ContainsSha(sha) {
+				return errors.Errorf("Encryption key %s is not installed on peer %s and member %s", sha, pname, mname)
+			}
+		}
+	}
+
+	return nil
+}
+
+// encryptionOnMembers performs the given action on the encryption key folder of this peer
+// and lets all members of this peer reload their keys.
+func (s *httpServer) encryptionOnMembers(action string, key []byte, sha string) (int, error) {
+	s.encryptionLock.Lock()
+	defer s.encryptionLock.Unlock()
+
+	e := newEncryptionKeyManager(s.context.EncryptionKeyFolder())
+
+	var err error
+	switch action {
+	case encryptionActionAdd:
+		err = e.add(key)
+	case encryptionActionActivate:
+		err = e.setActive(sha)
+	case encryptionActionRemove:
+		err = e.remove(sha)
+	}
+	if err == errEncryptionKeyNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		return http.StatusPreconditionFailed, err
+	}
+
+	return 0, s.synchronizeEncryptionOnMembers(e)
+}
+
+// synchronizeEncryptionOnMembers copies the given key folder into the key folder of all members
+// of this peer and lets them reload their keys.
+func (s *httpServer) synchronizeEncryptionOnMembers(e encryptionKeyManager) error {
+	_, p, mode := s.context.ClusterConfig()
+
+	shas, err := e.shas()
+	if err != nil {
+		return err
+	}
+
+	return s.forEachServerType(mode, p, func(m ServiceMode, p *Peer, t definitions.ServerType) error {
+		d, err := s.context.serverHostDir(t)
+		if err != nil {
+			return err
+		}
+
+		if err := e.mirror(newEncryptionKeyManager(filepath.Join(d, definitions.ArangodEncryptionKeyFolderName))); err != nil {
+			return err
+		}
+
+		client, err := p.CreateClient(s.context, t)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), encryptionRefreshTimeout)
+		defer cancel()
+
+		details, err := rotateClient.NewClient(client).RefreshEncryption(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, sha := range shas {
+			if !details.Result.Keys.ContainsSha(sha) {
+				return errors.Errorf("Encryption key %s not found on %s", sha, t)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_EncryptionKeyManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starter-encryption")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "keyfile")
+	key1 := bytes.Repeat([]byte{1}, definitions.ArangodEncryptionKeyLength)
+	key2 := bytes.Repeat([]byte{2}, definitions.ArangodEncryptionKeyLength)
+	require.NoError(t, ioutil.WriteFile(keyFile, key1, 0600))
+
+	global := filepath.Join(dir, "global")
+	require.NoError(t, initEncryptionKeyFolder(global, keyFile))
+	e := newEncryptionKeyManager(global)
+
+	active, err := e.active()
+	require.NoError(t, err)
+	require.Equal(t, Sha256sumRaw(key1), active)
+
+	require.Error(t, e.add([]byte("too short")))
+	require.NoError(t, e.add(key2))
+
+	shas, err := e.shas()
+	require.NoError(t, err)
+	expected := []string{Sha256sumRaw(key1), Sha256sumRaw(key2)}
+	sort.Strings(expected)
+	require.Equal(t, expected, shas)
+
+	require.Equal(t, errEncryptionKeyNotFound, e.setActive("unknown"))
+	require.NoError(t, e.setActive(Sha256sumRaw(key2)))
+	require.Error(t, e.remove(Sha256sumRaw(key2)), "active key must not be removed")
+
+	// Mirror into a server folder containing an obsolete key
+	server := filepath.Join(dir, "server")
+	require.NoError(t, os.MkdirAll(server, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(server, "obsolete"), key1, 0600))
+	require.NoError(t, e.remove(Sha256sumRaw(key1)))
+	require.NoError(t, e.mirror(newEncryptionKeyManager(server)))
+
+	keys, err := newEncryptionKeyManager(server).keys()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, key2, keys[definitions.ArangodEncryptionKeyActive])
+	require.Equal(t, key2, keys[Sha256sumRaw(key2)])
+
+	// Initializing again must keep the active key
+	require.NoError(t, initEncryptionKeyFolder(global, keyFile))
+	active, err = e.active()
+	require.NoError(t, err)
+	require.Equal(t, Sha256sumRaw(key2), active)
+}
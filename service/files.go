@@ -51,3 +51,8 @@ func Sha256sum(d []byte) string {
 	cleanData := []byte(strings.TrimSpace(string(d)))
 	return fmt.Sprintf("%0x", sha256.Sum256(cleanData))
 }
+
+// Sha256sumRaw returns the checksum of the given data without trimming it (for binary data).
+func Sha256sumRaw(d []byte) string {
+	return fmt.Sprintf("%0x", sha256.Sum256(d))
+}
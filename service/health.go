@@ -0,0 +1,174 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	// maxClusterManagerIdle is the longest time the runtime cluster manager may go without
+	// starting a new iteration before the starter is considered stuck.
+	maxClusterManagerIdle = time.Minute * 2
+)
+
+// healthLiveHandler reports if this starter is alive, that is, its HTTP server
+// responds and its state machine is not stuck.
+func (s *httpServer) healthLiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeHealthResponse(w, s.healthLive(time.Now()))
+}
+
+// healthReadyHandler reports if this starter and all of its servers are ready.
+func (s *httpServer) healthReadyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeHealthResponse(w, s.healthReady(r.Context()))
+}
+
+// healthLive runs all liveness checks.
+func (s *httpServer) healthLive(now time.Time) client.HealthResponse {
+	state := s.context.State()
+	checks := []client.HealthCheck{{Name: "http-server", OK: true}}
+
+	check := client.HealthCheck{Name: "state-machine", OK: true}
+	if lastIteration := s.context.LastClusterManagerIteration(); state.IsRunning() && !lastIteration.IsZero() {
+		if idle := now.Sub(lastIteration); idle > maxClusterManagerIdle {
+			check.OK = false
+			check.Message = fmt.Sprintf("Runtime cluster manager has not made progress for %s", idle.Round(time.Second))
+		}
+	}
+	checks = append(checks, check)
+
+	return newHealthResponse(state, checks)
+}
+
+// healthReady runs all readiness checks.
+func (s *httpServer) healthReady(ctx context.Context) client.HealthResponse {
+	state := s.context.State()
+	var checks []client.HealthCheck
+
+	check := client.HealthCheck{Name: "bootstrap", OK: state.IsRunning()}
+	if !check.OK {
+		check.Message = "Bootstrap has not completed yet"
+	}
+	checks = append(checks, check)
+
+	wrappers := s.runtimeServerManager.processWrappers()
+	if state.IsRunning() && len(wrappers) == 0 {
+		checks = append(checks, client.HealthCheck{Name: "servers", Message: "No servers have been started yet"})
+	}
+	serverTypes := make([]string, 0, len(wrappers))
+	for t := range wrappers {
+		serverTypes = append(serverTypes, string(t))
+	}
+	sort.Strings(serverTypes)
+	upgrading, err := s.upgradingServerTypes(ctx)
+	if err != nil {
+		checks = append(checks, client.HealthCheck{Name: "upgrade", Message: fmt.Sprintf("Upgrade plan cannot be read: %v", err)})
+	}
+	for _, t := range serverTypes {
+		serverType := definitions.ServerType(t)
+		status := wrappers[serverType].Status()
+		check := client.HealthCheck{Name: t, OK: true}
+		switch {
+		case status.Failed:
+			check.OK, check.Message = false, "Server has failed too often and is no longer restarted"
+		case !status.Up:
+			check.OK, check.Message = false, "Server is not up and running with the correct role"
+		case upgrading[serverType]:
+			check.OK, check.Message = false, "Server is being upgraded"
+		}
+		checks = append(checks, check)
+	}
+
+	return newHealthResponse(state, checks)
+}
+
+// upgradingServerTypes returns the types of the servers of this starter that are being upgraded
+// (or rolled back) according to the upgrade plan.
+func (s *httpServer) upgradingServerTypes(ctx context.Context) (map[definitions.ServerType]bool, error) {
+	_, myPeer, mode := s.context.ClusterConfig()
+	if !mode.HasAgency() || myPeer == nil {
+		return nil, nil
+	}
+	status, hasPlan, err := s.cachedUpgradeStatus(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if !hasPlan || status.Ready || status.Failed {
+		return nil, nil
+	}
+	result := make(map[definitions.ServerType]bool)
+	for _, srv := range status.ServersRemaining {
+		serverType := definitions.ServerType(srv.Type)
+		port := myPeer.Port + myPeer.PortOffset + serverType.PortOffset()
+		if srv.StartedAt != nil && srv.FinishedAt == nil && srv.Address == myPeer.Address && srv.Port == port {
+			result[serverType] = true
+		}
+	}
+	return result, nil
+}
+
+// newHealthResponse creates a health response for the given state & checks.
+func newHealthResponse(state State, checks []client.HealthCheck) client.HealthResponse {
+	result := client.HealthResponse{
+		OK:     true,
+		State:  state.String(),
+		Checks: checks,
+	}
+	for _, c := range checks {
+		if !c.OK {
+			result.OK = false
+		}
+	}
+	return result
+}
+
+// writeHealthResponse writes the given health response as JSON with
+// status 200 when all checks succeeded or 503 otherwise.
+func writeHealthResponse(w http.ResponseWriter, resp client.HealthResponse) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	code := http.StatusOK
+	if !resp.OK {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(code)
+	w.Write(b)
+}
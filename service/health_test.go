@@ -0,0 +1,147 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+// healthTestContext implements the parts of httpServerContext used by the health checks.
+type healthTestContext struct {
+	httpServerContext
+	state          State
+	lastIteration  time.Time
+	peer           Peer
+	upgradeManager UpgradeManager
+}
+
+func (c healthTestContext) State() State                           { return c.state }
+func (c healthTestContext) LastClusterManagerIteration() time.Time { return c.lastIteration }
+func (c healthTestContext) UpgradeManager() UpgradeManager         { return c.upgradeManager }
+func (c healthTestContext) ClusterConfig() (ClusterConfig, *Peer, ServiceMode) {
+	return ClusterConfig{AllPeers: []Peer{c.peer}}, &c.peer, ServiceModeCluster
+}
+
+// healthTestUpgradeManager is an UpgradeManager with a fixed upgrade status.
+type healthTestUpgradeManager struct {
+	UpgradeManager
+	status client.UpgradeStatus
+	err    error
+}
+
+func (m *healthTestUpgradeManager) Status(context.Context) (client.UpgradeStatus, error) {
+	return m.status, m.err
+}
+
+// healthTestProcessWrapper is a ProcessWrapper with a fixed status.
+type healthTestProcessWrapper struct {
+	ProcessWrapper
+	status ProcessWrapperStatus
+}
+
+func (p healthTestProcessWrapper) Status() ProcessWrapperStatus { return p.status }
+
+func Test_HealthLive(t *testing.T) {
+	now := time.Now()
+	ctx := &healthTestContext{state: stateBootstrapMaster}
+	s := &httpServer{context: ctx, runtimeServerManager: &runtimeServerManager{}}
+
+	// Bootstrapping starters are alive
+	require.True(t, s.healthLive(now).OK)
+
+	// Running starters must make progress
+	ctx.state = stateRunningSlave
+	ctx.lastIteration = now.Add(-time.Second * 15)
+	require.True(t, s.healthLive(now).OK)
+	ctx.lastIteration = now.Add(-maxClusterManagerIdle - time.Second)
+	resp := s.healthLive(now)
+	require.False(t, resp.OK)
+	require.Equal(t, "running-slave", resp.State)
+	require.False(t, resp.Checks[1].OK)
+}
+
+func Test_HealthReady(t *testing.T) {
+	m := &healthTestUpgradeManager{err: client.NewNotFoundError("There is no upgrade plan")}
+	peer := Peer{Address: "10.0.0.1", Port: 8528}
+	ctx := &healthTestContext{state: stateBootstrapSlave, peer: peer, upgradeManager: m}
+	rsm := &runtimeServerManager{}
+	newServer := func() *httpServer {
+		// Without cached upgrade status
+		return &httpServer{context: ctx, runtimeServerManager: rsm}
+	}
+	s := newServer()
+
+	// Not ready during bootstrap
+	require.False(t, s.healthReady(context.Background()).OK)
+
+	// Not ready without servers
+	ctx.state = stateRunningMaster
+	require.False(t, s.healthReady(context.Background()).OK)
+
+	// Ready when all servers are up
+	agent := &healthTestProcessWrapper{status: ProcessWrapperStatus{Running: true, Up: true}}
+	dbserver := &healthTestProcessWrapper{status: ProcessWrapperStatus{Running: true, Up: true}}
+	rsm.agentProc, rsm.dbserverProc = agent, dbserver
+	resp := s.healthReady(context.Background())
+	require.True(t, resp.OK)
+	require.Len(t, resp.Checks, 3)
+
+	// Not ready when a server is down or failed
+	dbserver.status.Up = false
+	require.False(t, s.healthReady(context.Background()).OK)
+	dbserver.status.Up = true
+	agent.status.Failed = true
+	require.False(t, s.healthReady(context.Background()).OK)
+	agent.status.Failed = false
+
+	// Not ready when a server is being upgraded according to the upgrade plan
+	startedAt := time.Now()
+	m.err = nil
+	m.status.ServersRemaining = []client.UpgradeStatusServer{
+		{Type: client.ServerTypeAgent, Address: peer.Address, Port: peer.Port + definitions.ServerType(definitions.ServerTypeAgent).PortOffset(), StartedAt: &startedAt},
+		{Type: client.ServerTypeDBServer, Address: peer.Address, Port: peer.Port + definitions.ServerType(definitions.ServerTypeDBServer).PortOffset()},
+	}
+	s = newServer()
+	resp = s.healthReady(context.Background())
+	require.False(t, resp.OK)
+	require.Equal(t, "Server is being upgraded", resp.Checks[1].Message)
+	require.True(t, resp.Checks[2].OK)
+
+	// Servers of other starters do not matter
+	m.status.ServersRemaining[0].Address = "10.0.0.2"
+	s = newServer()
+	require.True(t, s.healthReady(context.Background()).OK)
+
+	// Not ready when the upgrade plan cannot be read
+	m.err = errors.New("agency unavailable")
+	s = newServer()
+	resp = s.healthReady(context.Background())
+	require.False(t, resp.OK)
+	require.Equal(t, "upgrade", resp.Checks[1].Name)
+}
@@ -150,16 +150,34 @@ func (s *httpServer) localInventoryMemberAddWithCondition(inv *api.Inventory, p
 
 	i, err := s.localInventoryMember(p, t)
 	if err != nil {
-		inv.Members[t] = api.MemberInventory{
+		i = api.MemberInventory{
 			Error: api.NewError(err),
 		}
-		return
 	}
+	i.Process = s.localInventoryMemberProcess(p, t)
 
 	inv.Members[t] = i
 	return
 }
 
+// localInventoryMemberProcess returns the process status of the server of given type,
+// if that server is managed by this starter.
+func (s *httpServer) localInventoryMemberProcess(p *Peer, t definitions.ServerType) *api.MemberProcess {
+	if p == nil || p.ID != s.idInfo.ID {
+		return nil
+	}
+	w := s.runtimeServerManager.processWrapperByType(t)
+	if w == nil {
+		return nil
+	}
+	status := w.Status()
+	return &api.MemberProcess{
+		Restarts: status.Restarts,
+		Failures: status.Failures,
+		Failed:   status.Failed,
+	}
+}
+
 func (s *httpServer) localInventoryMember(p *Peer, t definitions.ServerType) (api.MemberInventory, error) {
 	c, err := p.CreateClient(s.context, t)
 	if err != nil {
@@ -177,13 +195,14 @@ func (s *httpServer) localInventoryMember(p *Peer, t definitions.ServerType) (ap
 	}
 	i.Version = v
 
-	if features.JWTRotation().Enabled(features.Version{
+	fv := features.Version{
 		Enterprise: v.IsEnterprise(),
 		Version:    v.Version,
-	}) {
-		i.Hashes = &api.MemberHashes{}
+	}
+	ic := client.NewClient(c)
 
-		ic := client.NewClient(c)
+	if features.JWTRotation().Enabled(fv) {
+		i.Hashes = &api.MemberHashes{}
 
 		jwt, err := ic.GetJWT(ctx)
 		if err != nil {
@@ -194,6 +213,32 @@ func (s *httpServer) localInventoryMember(p *Peer, t definitions.ServerType) (ap
 
 	}
 
+	if p.IsSecure && features.TLSRotation().Enabled(fv) {
+		if i.Hashes == nil {
+			i.Hashes = &api.MemberHashes{}
+		}
+
+		tls, err := ic.GetTLS(ctx)
+		if err != nil {
+			return i, err
+		}
+
+		i.Hashes.TLS = &tls.Result
+	}
+
+	if s.context.EncryptionKeyFolder() != "" && features.EncryptionRotation().Enabled(fv) {
+		if i.Hashes == nil {
+			i.Hashes = &api.MemberHashes{}
+		}
+
+		encryption, err := ic.GetEncryption(ctx)
+		if err != nil {
+			return i, err
+		}
+
+		i.Hashes.Encryption = &encryption.Result
+	}
+
 	return i, nil
 }
 
@@ -43,6 +43,17 @@ type JobStatus struct {
 	state  JobState `json:"-"`
 }
 
+// State returns the state of the job.
+func (j JobStatus) State() JobState {
+	return j.state
+}
+
+// jobStatusReporter is implemented by progressors that want to know the status
+// of the job WaitForFinishedJob is waiting for.
+type jobStatusReporter interface {
+	jobStatus(job JobStatus)
+}
+
 var (
 	JobStateToDo     = JobState("ToDo")
 	JobStatePending  = JobState("Pending")
@@ -87,6 +98,9 @@ func WaitForFinishedJob(progress actions.Progressor, ctx context.Context, jobID
 		if err != nil {
 			return err
 		}
+		if r, ok := progress.(jobStatusReporter); ok {
+			r.jobStatus(job)
+		}
 
 		if job.state == JobStateFinished {
 			return nil
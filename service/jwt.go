@@ -152,8 +152,8 @@ func (j jwtManager) tokens() (tokens, error) {
 }
 
 func (s *httpServer) registerJWTFunctions(m *http.ServeMux) {
-	m.HandleFunc("/admin/jwt/activate", s.jwtActivate)
-	m.HandleFunc("/admin/jwt/refresh", s.jwtRefresh)
+	m.HandleFunc("/admin/jwt/activate", s.apiAuth.requireRoleByMethod(s.jwtActivate))
+	m.HandleFunc("/admin/jwt/refresh", s.apiAuth.requireRoleByMethod(s.jwtRefresh))
 }
 
 func (s *httpServer) jwtActivate(w http.ResponseWriter, r *http.Request) {
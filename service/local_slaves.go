@@ -59,6 +59,7 @@ func (s *Service) startLocalSlaves(wg *sync.WaitGroup, config Config, bsCfg Boot
 	}
 	masterAddr = net.JoinHostPort(masterAddr, strconv.Itoa(s.announcePort))
 	for _, p := range peers {
+		p := p
 		if p.ID == s.id {
 			continue
 		}
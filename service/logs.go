@@ -0,0 +1,446 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+const (
+	logsReverseChunkSize = 64 * 1024
+	logsFollowInterval   = time.Millisecond * 500
+	logsMaxHeaderTokens  = 5
+)
+
+var (
+	logTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05"}
+)
+
+// logLevel is a parsed severity of a log line.
+// Lower values are more severe.
+type logLevel int
+
+const (
+	logLevelUnknown logLevel = iota
+	logLevelFatal
+	logLevelError
+	logLevelWarning
+	logLevelInfo
+	logLevelDebug
+	logLevelTrace
+)
+
+// String returns the name of the level as accepted by parseLogLevel.
+func (l logLevel) String() string {
+	switch l {
+	case logLevelFatal:
+		return "fatal"
+	case logLevelError:
+		return "error"
+	case logLevelWarning:
+		return "warning"
+	case logLevelInfo:
+		return "info"
+	case logLevelDebug:
+		return "debug"
+	case logLevelTrace:
+		return "trace"
+	default:
+		return ""
+	}
+}
+
+// parseLogLevel parses the given (arangod or arangosync style) level name.
+func parseLogLevel(s string) (logLevel, bool) {
+	switch strings.ToUpper(s) {
+	case "FATAL", "FTL":
+		return logLevelFatal, true
+	case "ERROR", "ERR":
+		return logLevelError, true
+	case "WARNING", "WARN", "WRN":
+		return logLevelWarning, true
+	case "INFO", "INF":
+		return logLevelInfo, true
+	case "DEBUG", "DBG":
+		return logLevelDebug, true
+	case "TRACE", "TRC":
+		return logLevelTrace, true
+	default:
+		return logLevelUnknown, false
+	}
+}
+
+// logLineHeader holds the attributes parsed from the start of a log line.
+type logLineHeader struct {
+	Time  time.Time
+	Level logLevel
+}
+
+// parseLogLineHeader parses the timestamp & level at the start of a log line.
+// Returns false if the line does not start with a timestamp (e.g. a continuation of a multi line message).
+func parseLogLineHeader(line string) (logLineHeader, bool) {
+	tokens := strings.SplitN(strings.TrimSpace(line), " ", logsMaxHeaderTokens+1)
+	if len(tokens) == 0 {
+		return logLineHeader{}, false
+	}
+	var h logLineHeader
+	parsed := false
+	for _, layout := range logTimeLayouts {
+		if t, err := time.ParseInLocation(layout, tokens[0], time.Local); err == nil {
+			h.Time = t
+			parsed = true
+			break
+		}
+	}
+	if !parsed {
+		return logLineHeader{}, false
+	}
+	for i := 1; i < len(tokens) && i <= logsMaxHeaderTokens; i++ {
+		if level, ok := parseLogLevel(strings.Trim(tokens[i], "[]|")); ok {
+			h.Level = level
+			break
+		}
+	}
+	return h, true
+}
+
+// logsQuery holds the options of a logs request.
+type logsQuery struct {
+	Tail   int       // If > 0, only the last Tail (matching) lines are returned
+	Since  time.Time // If set, only lines logged at or after this time are returned
+	Level  logLevel  // If set, only lines with this level or more severe are returned
+	Follow bool      // If set, keep streaming new lines
+	SSE    bool      // If set, lines are sent as Server-Sent Events
+}
+
+// parseLogsQuery parses the options of a logs request.
+func parseLogsQuery(r *http.Request) (logsQuery, error) {
+	var q logsQuery
+	values := r.URL.Query()
+	if v := values.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, maskAny(client.NewBadRequestError(fmt.Sprintf("Invalid tail '%s'", v)))
+		}
+		q.Tail = n
+	}
+	if v := values.Get("since"); v != "" {
+		since, err := parseLogsSince(v, time.Now())
+		if err != nil {
+			return q, maskAny(client.NewBadRequestError(fmt.Sprintf("Invalid since '%s'", v)))
+		}
+		q.Since = since
+	}
+	if v := values.Get("level"); v != "" {
+		level, ok := parseLogLevel(v)
+		if !ok {
+			return q, maskAny(client.NewBadRequestError(fmt.Sprintf("Invalid level '%s'", v)))
+		}
+		q.Level = level
+	}
+	if v := values.Get("follow"); v != "" {
+		follow, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, maskAny(client.NewBadRequestError(fmt.Sprintf("Invalid follow '%s'", v)))
+		}
+		q.Follow = follow
+	}
+	q.SSE = values.Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	return q, nil
+}
+
+// parseLogsSince parses a since value, which is either an RFC3339 timestamp,
+// a unix timestamp (in seconds) or a duration relative to now.
+func parseLogsSince(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, maskAny(err)
+	}
+	return now.Add(-d), nil
+}
+
+// hasFilter returns true if lines are filtered by their header.
+func (q logsQuery) hasFilter() bool {
+	return q.Level != logLevelUnknown || !q.Since.IsZero()
+}
+
+// matches returns true if a line with given header (if any) must be returned.
+func (q logsQuery) matches(h logLineHeader, hasHeader bool) bool {
+	if !q.hasFilter() {
+		return true
+	}
+	if !hasHeader {
+		return false
+	}
+	if q.Level != logLevelUnknown && (h.Level == logLevelUnknown || h.Level > q.Level) {
+		return false
+	}
+	if !q.Since.IsZero() && h.Time.Before(q.Since) {
+		return false
+	}
+	return true
+}
+
+// findLogsStartOffset scans the given file backwards to find the offset from which lines
+// must be streamed to fulfill the tail & since options of the query.
+// Returns the offset & the number of matching lines to skip after that offset.
+func findLogsStartOffset(f io.ReaderAt, size int64, q logsQuery) (int64, int, error) {
+	if q.Tail <= 0 && q.Since.IsZero() {
+		return 0, 0, nil
+	}
+	matched, pending := 0, 0
+	lastHeaderOffset := size
+	var carry []byte
+	end := size
+	for end > 0 {
+		start := end - logsReverseChunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunk := make([]byte, end-start, int(end-start)+len(carry))
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, 0, maskAny(err)
+		}
+		chunk = append(chunk, carry...)
+		// Process complete lines from the end of the chunk
+		for len(chunk) > 0 {
+			idx := bytes.LastIndexByte(chunk[:len(chunk)-1], '\n')
+			if idx < 0 {
+				break
+			}
+			lineOffset := start + int64(idx) + 1
+			line := string(chunk[idx+1:])
+			chunk = chunk[:idx+1]
+			if offset, skip, done := q.reverseLine(line, lineOffset, &matched, &pending, &lastHeaderOffset); done {
+				return offset, skip, nil
+			}
+		}
+		carry = chunk
+		end = start
+	}
+	// Process the first line of the file
+	if len(carry) > 0 {
+		if offset, skip, done := q.reverseLine(string(carry), 0, &matched, &pending, &lastHeaderOffset); done {
+			return offset, skip, nil
+		}
+	}
+	if !q.Since.IsZero() {
+		return lastHeaderOffset, 0, nil
+	}
+	return 0, 0, nil
+}
+
+// reverseLine processes a single line during a backward scan.
+// Returns the offset to start streaming from, the number of matching lines to skip
+// and true if the scan is complete.
+func (q logsQuery) reverseLine(line string, lineOffset int64, matched, pending *int, lastHeaderOffset *int64) (int64, int, bool) {
+	if !q.hasFilter() {
+		// Every line counts
+		*matched++
+		if *matched >= q.Tail {
+			return lineOffset, 0, true
+		}
+		return 0, 0, false
+	}
+	h, ok := parseLogLineHeader(line)
+	if !ok {
+		// Continuation of the previous line
+		*pending++
+		return 0, 0, false
+	}
+	if !q.Since.IsZero() && h.Time.Before(q.Since) {
+		// All remaining lines are older
+		return *lastHeaderOffset, 0, true
+	}
+	*lastHeaderOffset = lineOffset
+	if q.matches(h, true) {
+		*matched += 1 + *pending
+	}
+	*pending = 0
+	if q.Tail > 0 && *matched >= q.Tail {
+		return lineOffset, *matched - q.Tail, true
+	}
+	return 0, 0, false
+}
+
+// logsStreamer writes log lines to an HTTP response (or any other writer).
+type logsStreamer struct {
+	w         io.Writer
+	flusher   http.Flusher
+	q         logsQuery
+	skip      int
+	header    logLineHeader
+	hasHeader bool
+}
+
+// writeLine writes a single line (without newline) if it matches the query.
+func (s *logsStreamer) writeLine(line string) error {
+	if h, ok := parseLogLineHeader(line); ok {
+		s.header, s.hasHeader = h, true
+	}
+	if !s.q.matches(s.header, s.hasHeader) {
+		return nil
+	}
+	if s.skip > 0 {
+		s.skip--
+		return nil
+	}
+	var err error
+	if s.q.SSE {
+		_, err = fmt.Fprintf(s.w, "data: %s\n\n", line)
+	} else {
+		_, err = io.WriteString(s.w, line+"\n")
+	}
+	return maskAny(err)
+}
+
+// flush sends all buffered data to the client.
+func (s *logsStreamer) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// streamLogFile writes the content of the log file at given path to the response,
+// according to the given query.
+func streamLogFile(ctx context.Context, w http.ResponseWriter, logPath string, q logsQuery) error {
+	f, err := openLogFile(ctx, logPath, q.Follow)
+	if err != nil {
+		return maskAny(err)
+	}
+	if q.SSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	if f == nil {
+		// Log file not there (yet), we allow this
+		return nil
+	}
+
+	streamer := &logsStreamer{w: w, q: q}
+	streamer.flusher, _ = w.(http.Flusher)
+	return maskAny(copyLogFile(ctx, f, logPath, streamer))
+}
+
+// copyLogFile writes the lines of the given (opened) log file to the given streamer,
+// according to the query of the streamer.
+// The file may be replaced by a newly opened one when log rotation is detected,
+// it is closed by this function.
+func copyLogFile(ctx context.Context, f *os.File, logPath string, streamer *logsStreamer) error {
+	defer func() { f.Close() }()
+	q := streamer.q
+
+	info, err := f.Stat()
+	if err != nil {
+		return maskAny(err)
+	}
+	offset, skip, err := findLogsStartOffset(f, info.Size(), q)
+	if err != nil {
+		return maskAny(err)
+	}
+	streamer.skip = skip
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return maskAny(err)
+	}
+
+	rd := bufio.NewReader(f)
+	var partial string
+	for {
+		line, err := rd.ReadString('\n')
+		offset += int64(len(line))
+		if err == nil {
+			if err := streamer.writeLine(strings.TrimSuffix(partial+line, "\n")); err != nil {
+				return maskAny(err)
+			}
+			partial = ""
+			continue
+		} else if err != io.EOF {
+			return maskAny(err)
+		}
+		partial += line
+		if !q.Follow {
+			if partial != "" {
+				return maskAny(streamer.writeLine(partial))
+			}
+			return nil
+		}
+		// Wait for more data
+		streamer.flush()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(logsFollowInterval):
+		}
+		// Detect log rotation
+		if current, err := os.Stat(logPath); err == nil {
+			if fileInfo, err := f.Stat(); err == nil && (!os.SameFile(current, fileInfo) || current.Size() < offset) {
+				f.Close()
+				if f, err = os.Open(logPath); err != nil {
+					return maskAny(err)
+				}
+				offset = 0
+				partial = ""
+				rd.Reset(f)
+			}
+		}
+	}
+}
+
+// openLogFile opens the log file at given path.
+// If the file does not exist, nil is returned, unless follow is set, in which
+// case it waits until the file exists.
+func openLogFile(ctx context.Context, logPath string, follow bool) (*os.File, error) {
+	for {
+		f, err := os.Open(logPath)
+		if err == nil {
+			return f, nil
+		} else if !os.IsNotExist(err) {
+			return nil, maskAny(err)
+		} else if !follow {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(logsFollowInterval):
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testLogContent = `2021-03-01T10:00:00Z [1] INFO [aaaaa] {general} first
+2021-03-01T10:01:00Z [1] WARNING [bbbbb] {general} second
+	continuation of second
+2021-03-01T10:02:00Z [1] INFO [ccccc] {general} third
+2021-03-01T10:03:00Z [1] ERROR [ddddd] {general} fourth
+`
+
+func streamTestLog(t *testing.T, q logsQuery) string {
+	return streamTestLogContent(t, testLogContent, q)
+}
+
+func streamTestLogContent(t *testing.T, content string, q logsQuery) string {
+	dir, err := ioutil.TempDir("", "starter-logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "arangod.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(content), 0644))
+
+	w := httptest.NewRecorder()
+	require.NoError(t, streamLogFile(context.Background(), w, logPath, q))
+	return w.Body.String()
+}
+
+func Test_StreamLogFile(t *testing.T) {
+	t.Run("All", func(t *testing.T) {
+		require.Equal(t, testLogContent, streamTestLog(t, logsQuery{}))
+	})
+
+	t.Run("Tail", func(t *testing.T) {
+		require.Equal(t, "2021-03-01T10:02:00Z [1] INFO [ccccc] {general} third\n"+
+			"2021-03-01T10:03:00Z [1] ERROR [ddddd] {general} fourth\n", streamTestLog(t, logsQuery{Tail: 2}))
+	})
+
+	t.Run("Level", func(t *testing.T) {
+		require.Equal(t, "2021-03-01T10:01:00Z [1] WARNING [bbbbb] {general} second\n"+
+			"\tcontinuation of second\n"+
+			"2021-03-01T10:03:00Z [1] ERROR [ddddd] {general} fourth\n", streamTestLog(t, logsQuery{Level: logLevelWarning}))
+	})
+
+	t.Run("Tail with level", func(t *testing.T) {
+		require.Equal(t, "\tcontinuation of second\n"+
+			"2021-03-01T10:03:00Z [1] ERROR [ddddd] {general} fourth\n", streamTestLog(t, logsQuery{Tail: 2, Level: logLevelWarning}))
+	})
+
+	t.Run("Since", func(t *testing.T) {
+		since, err := time.Parse(time.RFC3339, "2021-03-01T10:02:00Z")
+		require.NoError(t, err)
+		require.Equal(t, "2021-03-01T10:02:00Z [1] INFO [ccccc] {general} third\n"+
+			"2021-03-01T10:03:00Z [1] ERROR [ddddd] {general} fourth\n", streamTestLog(t, logsQuery{Since: since}))
+	})
+
+	t.Run("Tail of large file", func(t *testing.T) {
+		line := "2021-03-01T10:00:00Z [1] INFO [aaaaa] {general} " + strings.Repeat("x", 100) + "\n"
+		content := strings.Repeat(line, 2000)
+		require.Equal(t, strings.Repeat(line, 1500), streamTestLogContent(t, content, logsQuery{Tail: 1500}))
+	})
+}
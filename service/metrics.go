@@ -0,0 +1,254 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	metricsNamespace   = "arangodb_starter"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// upgradeStatusCacheTTL is the time the upgrade status used for metrics is cached,
+	// so frequent scrapes do not cause an agency request each time.
+	upgradeStatusCacheTTL = time.Second * 30
+)
+
+var (
+	metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	allStates           = []State{stateStart, stateBootstrapMaster, stateBootstrapSlave, stateRunningMaster, stateRunningSlave}
+)
+
+// metricSample is a single sample of a metric.
+type metricSample struct {
+	Labels []string // Alternating label names & values
+	Value  float64
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// add writes a metric with given name, help text, type (gauge|counter) and samples.
+func (m *metricsWriter) add(name, help, metricType string, samples ...metricSample) {
+	name = metricsNamespace + "_" + name
+	fmt.Fprintf(&m.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&m.buf, "# TYPE %s %s\n", name, metricType)
+	for _, sample := range samples {
+		m.buf.WriteString(name)
+		if len(sample.Labels) > 0 {
+			pairs := make([]string, 0, len(sample.Labels)/2)
+			for i := 0; i+1 < len(sample.Labels); i += 2 {
+				pairs = append(pairs, fmt.Sprintf(`%s="%s"`, sample.Labels[i], metricsLabelEscaper.Replace(sample.Labels[i+1])))
+			}
+			m.buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		m.buf.WriteString(" " + strconv.FormatFloat(sample.Value, 'g', -1, 64) + "\n")
+	}
+}
+
+// metricBool converts a boolean into a metric value.
+func metricBool(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// metricsHandler returns metrics of the starter & its servers in Prometheus text format.
+func (s *httpServer) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	m := &metricsWriter{}
+	s.collectStarterMetrics(m)
+	s.collectServerMetrics(m)
+	s.collectUpgradeMetrics(r.Context(), m)
+	s.collectLogRotationMetrics(m)
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(m.buf.Bytes())
+}
+
+// collectStarterMetrics adds metrics about the state of the starter itself.
+func (s *httpServer) collectStarterMetrics(m *metricsWriter) {
+	m.add("info", "Information about the starter", "gauge", metricSample{
+		Labels: []string{"id", s.idInfo.ID, "version", s.versionInfo.Version, "build", s.versionInfo.Build},
+		Value:  1,
+	})
+
+	state := s.context.State()
+	stateSamples := make([]metricSample, 0, len(allStates))
+	for _, st := range allStates {
+		stateSamples = append(stateSamples, metricSample{
+			Labels: []string{"state", st.String()},
+			Value:  metricBool(st == state),
+		})
+	}
+	m.add("state", "Current state of the starter", "gauge", stateSamples...)
+
+	isRunningMaster, isRunning, _ := s.context.IsRunningMaster()
+	m.add("running", "Set if the starter is in the running phase", "gauge", metricSample{Value: metricBool(isRunning)})
+	m.add("master", "Set if the starter is the running master, unset if it is a slave", "gauge", metricSample{Value: metricBool(isRunningMaster)})
+}
+
+// collectServerMetrics adds metrics about all servers managed by this starter.
+func (s *httpServer) collectServerMetrics(m *metricsWriter) {
+	wrappers := s.runtimeServerManager.processWrappers()
+	serverTypes := make([]string, 0, len(wrappers))
+	for t := range wrappers {
+		serverTypes = append(serverTypes, string(t))
+	}
+	sort.Strings(serverTypes)
+
+	var running, up, restarts, failures, failed, exitCodes, uptimes, statusCodes []metricSample
+	for _, t := range serverTypes {
+		status := wrappers[definitions.ServerType(t)].Status()
+		labels := []string{"type", t}
+		running = append(running, metricSample{Labels: labels, Value: metricBool(status.Running)})
+		up = append(up, metricSample{Labels: labels, Value: metricBool(status.Up)})
+		restarts = append(restarts, metricSample{Labels: labels, Value: float64(status.Restarts)})
+		failures = append(failures, metricSample{Labels: labels, Value: float64(status.Failures)})
+		failed = append(failed, metricSample{Labels: labels, Value: metricBool(status.Failed)})
+		if status.HasExited {
+			exitCodes = append(exitCodes, metricSample{Labels: labels, Value: float64(status.LastExitCode)})
+		}
+		uptime := 0.0
+		if status.Running {
+			uptime = time.Since(status.StartedAt).Seconds()
+		}
+		uptimes = append(uptimes, metricSample{Labels: labels, Value: uptime})
+		statusCodes = append(statusCodes, metricSample{Labels: labels, Value: float64(status.LastStatusCode)})
+	}
+
+	m.add("server_running", "Set if the server process is running", "gauge", running...)
+	m.add("server_up", "Set if the server is up and has the expected role", "gauge", up...)
+	m.add("server_restarts_total", "Number of times the server has been restarted", "counter", restarts...)
+	m.add("server_failures", "Number of failures of the server within the failure window of its restart policy", "gauge", failures...)
+	m.add("server_failed", "Set if the starter has given up restarting the server", "gauge", failed...)
+	m.add("server_last_exit_code", "Exit code of the last terminated server process", "gauge", exitCodes...)
+	m.add("server_uptime_seconds", "Time since the server process was started", "gauge", uptimes...)
+	m.add("server_status_code", "Last status code returned while testing the server", "gauge", statusCodes...)
+}
+
+// collectUpgradeMetrics adds metrics about the progress of the current upgrade plan (if any).
+func (s *httpServer) collectUpgradeMetrics(ctx context.Context, m *metricsWriter) {
+	_, _, mode := s.context.ClusterConfig()
+	if !mode.HasAgency() {
+		return
+	}
+	status, hasPlan, err := s.cachedUpgradeStatus(ctx)
+	m.add("upgrade_status_error", "Set if the upgrade plan could not be read from the agency", "gauge", metricSample{Value: metricBool(err != nil)})
+	if err != nil {
+		return
+	}
+	m.add("upgrade_plan", "Set if there is an upgrade plan", "gauge", metricSample{Value: metricBool(hasPlan)})
+	if !hasPlan {
+		return
+	}
+	m.add("upgrade_ready", "Set if the upgrade plan has finished", "gauge", metricSample{Value: metricBool(status.Ready)})
+	m.add("upgrade_failed", "Set if the upgrade plan has failed", "gauge", metricSample{Value: metricBool(status.Failed)})
+	m.add("upgrade_servers_upgraded", "Number of servers upgraded by the upgrade plan", "gauge", metricSample{Value: float64(len(status.ServersUpgraded))})
+	m.add("upgrade_servers_remaining", "Number of servers remaining to be upgraded by the upgrade plan", "gauge", metricSample{Value: float64(len(status.ServersRemaining))})
+}
+
+// cachedUpgradeStatus returns the (cached) status of the current upgrade plan
+// and whether there is an upgrade plan.
+func (s *httpServer) cachedUpgradeStatus(ctx context.Context) (client.UpgradeStatus, bool, error) {
+	return s.upgradeStatusCache.get(ctx, time.Now(), func(ctx context.Context) (client.UpgradeStatus, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		return s.context.UpgradeManager().Status(ctx)
+	})
+}
+
+// upgradeStatusCache holds the last fetched upgrade status.
+type upgradeStatusCache struct {
+	mutex     sync.Mutex
+	status    client.UpgradeStatus
+	err       error
+	fetchedAt time.Time
+	fetching  bool
+}
+
+// get returns the cached upgrade status and whether there is an upgrade plan.
+// The status is fetched again when it is older than upgradeStatusCacheTTL.
+// While it is being fetched, concurrent callers get the previous status.
+// An error is returned when the status could not be fetched (for other reasons
+// than not having a plan).
+func (c *upgradeStatusCache) get(ctx context.Context, now time.Time, fetch func(context.Context) (client.UpgradeStatus, error)) (client.UpgradeStatus, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.fetching && (c.fetchedAt.IsZero() || now.Sub(c.fetchedAt) >= upgradeStatusCacheTTL) {
+		// Do not hold the mutex while fetching
+		c.fetching = true
+		c.mutex.Unlock()
+		status, err := fetch(ctx)
+		c.mutex.Lock()
+		c.status, c.err, c.fetchedAt, c.fetching = status, err, now, false
+	}
+	if c.fetchedAt.IsZero() {
+		return client.UpgradeStatus{}, false, maskAny(fmt.Errorf("Upgrade status is being fetched"))
+	} else if client.IsNotFound(c.err) {
+		return client.UpgradeStatus{}, false, nil
+	} else if c.err != nil {
+		return client.UpgradeStatus{}, false, maskAny(c.err)
+	}
+	return c.status, true, nil
+}
+
+// collectLogRotationMetrics adds metrics about log rotation.
+func (s *httpServer) collectLogRotationMetrics(m *metricsWriter) {
+	counters := s.runtimeServerManager.LogRotationCounters()
+	m.add("log_rotation_runs_total", "Number of times log files have been rotated", "counter", metricSample{Value: float64(counters.Runs)})
+
+	toSamples := func(values map[definitions.ServerType]int) []metricSample {
+		serverTypes := make([]string, 0, len(values))
+		for t := range values {
+			serverTypes = append(serverTypes, string(t))
+		}
+		sort.Strings(serverTypes)
+		samples := make([]metricSample, 0, len(serverTypes))
+		for _, t := range serverTypes {
+			samples = append(samples, metricSample{Labels: []string{"type", t}, Value: float64(values[definitions.ServerType(t)])})
+		}
+		return samples
+	}
+	m.add("log_rotations_total", "Number of successful log rotations of a server", "counter", toSamples(counters.Rotations)...)
+	m.add("log_rotation_failures_total", "Number of failed log rotations of a server", "counter", toSamples(counters.Failures)...)
+}
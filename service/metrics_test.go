@@ -0,0 +1,119 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_MetricsWriter(t *testing.T) {
+	m := &metricsWriter{}
+	m.add("running", "Set if running", "gauge", metricSample{Value: metricBool(true)})
+	m.add("server_restarts_total", "Number of restarts", "counter",
+		metricSample{Labels: []string{"type", "agent"}, Value: 3},
+		metricSample{Labels: []string{"type", "dbserver", "id", "a\"b\\c\nd"}, Value: 0.5})
+	m.add("server_last_exit_code", "Exit code", "gauge")
+
+	require.Equal(t, `# HELP arangodb_starter_running Set if running
+# TYPE arangodb_starter_running gauge
+arangodb_starter_running 1
+# HELP arangodb_starter_server_restarts_total Number of restarts
+# TYPE arangodb_starter_server_restarts_total counter
+arangodb_starter_server_restarts_total{type="agent"} 3
+arangodb_starter_server_restarts_total{type="dbserver",id="a\"b\\c\nd"} 0.5
+# HELP arangodb_starter_server_last_exit_code Exit code
+# TYPE arangodb_starter_server_last_exit_code gauge
+`, m.buf.String())
+}
+
+func Test_UpgradeStatusCache(t *testing.T) {
+	var c upgradeStatusCache
+	calls := 0
+	fetch := func(err error) func(context.Context) (client.UpgradeStatus, error) {
+		return func(context.Context) (client.UpgradeStatus, error) {
+			calls++
+			return client.UpgradeStatus{Ready: err == nil}, err
+		}
+	}
+	now := time.Now()
+
+	status, hasPlan, err := c.get(context.Background(), now, fetch(nil))
+	require.NoError(t, err)
+	require.True(t, hasPlan)
+	require.True(t, status.Ready)
+	require.Equal(t, 1, calls)
+
+	// Cached until the TTL has passed
+	_, hasPlan, err = c.get(context.Background(), now.Add(upgradeStatusCacheTTL/2), fetch(client.NewNotFoundError("no plan")))
+	require.NoError(t, err)
+	require.True(t, hasPlan)
+	require.Equal(t, 1, calls)
+
+	// Not found means there is no plan
+	_, hasPlan, err = c.get(context.Background(), now.Add(upgradeStatusCacheTTL), fetch(client.NewNotFoundError("no plan")))
+	require.NoError(t, err)
+	require.False(t, hasPlan)
+	require.Equal(t, 2, calls)
+
+	// Other errors are reported, not taken as having no plan
+	_, hasPlan, err = c.get(context.Background(), now.Add(2*upgradeStatusCacheTTL), fetch(fmt.Errorf("agency timeout")))
+	require.Error(t, err)
+	require.False(t, hasPlan)
+	require.Equal(t, 3, calls)
+}
+
+func Test_UpgradeStatusCacheConcurrentFetch(t *testing.T) {
+	var c upgradeStatusCache
+	now := time.Now()
+	_, _, err := c.get(context.Background(), now, func(context.Context) (client.UpgradeStatus, error) {
+		return client.UpgradeStatus{Ready: true}, nil
+	})
+	require.NoError(t, err)
+
+	// A scrape during a slow fetch gets the previous status without waiting
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.get(context.Background(), now.Add(upgradeStatusCacheTTL), func(context.Context) (client.UpgradeStatus, error) {
+			close(started)
+			<-release
+			return client.UpgradeStatus{}, nil
+		})
+	}()
+	<-started
+	status, hasPlan, err := c.get(context.Background(), now.Add(upgradeStatusCacheTTL), func(context.Context) (client.UpgradeStatus, error) {
+		t.Fatal("Unexpected second fetch")
+		return client.UpgradeStatus{}, nil
+	})
+	require.NoError(t, err)
+	require.True(t, hasPlan)
+	require.True(t, status.Ready)
+	close(release)
+	<-done
+}
@@ -247,6 +247,31 @@ func (c ConfigurationPrefixes) Parse(args ...string) (*Configuration, []Configur
 	return &config, f, nil
 }
 
+// ForbiddenArgs returns the options (without value) in the given arguments
+// that are essential to the starters behavior and cannot be overwritten.
+func (c ConfigurationPrefixes) ForbiddenArgs(args ...string) []string {
+	var result []string
+	for _, arg := range args {
+		arg = strings.SplitN(arg, "=", 2)[0]
+
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+
+		ckey := strings.TrimPrefix(arg, "--")
+
+		for n := range c {
+			p := fmt.Sprintf("%s.", n)
+			if strings.HasPrefix(ckey, p) && forbiddenOptions.IsForbidden(strings.TrimPrefix(ckey, p)) {
+				result = append(result, arg)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
 type ConfigurationPrefix struct {
 	Usage         func(arg, key string) string
 	FieldSelector func(p *Configuration, key string) *[]string
@@ -83,4 +83,11 @@ func Test_Args(t *testing.T) {
 		require.Len(t, *c.All.Envs["zzz"], 1)
 		require.Equal(t, (*c.All.Envs["zzz"])[0], "test")
 	})
+	t.Run("With forbidden args", func(t *testing.T) {
+		_, _, err := prefixes.Parse("--args.all.zzz", "--args.all.server.endpoint=tcp://localhost:8529")
+		require.Error(t, err)
+
+		forbidden := prefixes.ForbiddenArgs("--args.all.zzz", "--args.all.server.endpoint=tcp://localhost:8529", "--envs.all.zzz", "--args.all.agency.size", "3")
+		require.Equal(t, []string{"--args.all.server.endpoint", "--args.all.agency.size"}, forbidden)
+	})
 }
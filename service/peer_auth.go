@@ -0,0 +1,237 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+// PeerAuthMode specifies how starters authenticate with the starter-to-starter API of other starters.
+type PeerAuthMode string
+
+const (
+	PeerAuthModeNone PeerAuthMode = "none" // No authentication
+	PeerAuthModeJWT  PeerAuthMode = "jwt"  // Bearer token derived from the cluster JWT secret
+	PeerAuthModeMTLS PeerAuthMode = "mtls" // Client certificate issued by the auto CA
+
+	callbackTokenParam = "token"
+)
+
+// ParsePeerAuthMode parses the given string into a peer authentication mode.
+func ParsePeerAuthMode(v string) (PeerAuthMode, error) {
+	switch m := PeerAuthMode(strings.ToLower(v)); m {
+	case "":
+		return PeerAuthModeNone, nil
+	case PeerAuthModeNone, PeerAuthModeJWT, PeerAuthModeMTLS:
+		return m, nil
+	default:
+		return "", maskAny(fmt.Errorf("Unknown peer authentication mode '%s'", v))
+	}
+}
+
+// peerAuthenticator authenticates requests to the starter-to-starter API
+// and provides the clients used to contact other starters.
+type peerAuthenticator struct {
+	mode          PeerAuthMode
+	jwtSecret     string
+	autoCA        *autoCAManager
+	httpClient    *http.Client          // Client for requests to other starters
+	clientOptions []client.ClientOption // Options of API clients for other starters
+}
+
+// newPeerAuthenticator creates the authentication used by the starter-to-starter API
+// and by the clients used to contact other starters.
+func newPeerAuthenticator(mode PeerAuthMode, secrets jwtSecretSource, autoCA *autoCAManager) (*peerAuthenticator, error) {
+	jwtSecret := secrets.secret
+	var opts []client.ClientOption
+	switch mode {
+	case PeerAuthModeNone:
+		if jwtSecret != "" {
+			// Let other starters accept our requests when their API requires a token
+			opts = append(opts, client.WithTokenSource(func() (string, error) {
+				active, err := secrets.active()
+				if err != nil {
+					return "", maskAny(err)
+				}
+				return CreateStarterToken(active, "", StarterRoleAdmin, time.Minute)
+			}))
+		}
+	case PeerAuthModeJWT:
+		if jwtSecret == "" {
+			return nil, maskAny(fmt.Errorf("Peer authentication mode '%s' requires a JWT secret", mode))
+		}
+		opts = append(opts, client.WithPeerKey(client.PeerKey([]byte(jwtSecret))))
+	case PeerAuthModeMTLS:
+		if autoCA == nil {
+			return nil, maskAny(fmt.Errorf("Peer authentication mode '%s' requires --ssl.auto-ca", mode))
+		}
+		opts = append(opts, client.WithTLSConfig(autoCA.ClientTLSConfig()))
+	default:
+		return nil, maskAny(fmt.Errorf("Unknown peer authentication mode '%s'", mode))
+	}
+
+	a := &peerAuthenticator{
+		mode:       mode,
+		jwtSecret:  jwtSecret,
+		autoCA:     autoCA,
+		httpClient: client.NewHTTPClient(opts...),
+	}
+	if len(opts) > 0 {
+		a.clientOptions = []client.ClientOption{client.WithHTTPClient(a.httpClient)}
+	}
+	return a, nil
+}
+
+// key returns the key used to sign & verify tokens.
+func (a *peerAuthenticator) key() ([]byte, error) {
+	switch a.mode {
+	case PeerAuthModeJWT:
+		return client.PeerKey([]byte(a.jwtSecret)), nil
+	case PeerAuthModeMTLS:
+		ca, err := a.autoCA.Load()
+		if err != nil {
+			return nil, maskAny(err)
+		} else if ca == nil {
+			return nil, maskAny(errors.Errorf("No certificate authority yet"))
+		}
+		return client.PeerKey([]byte(ca.PrivateKey)), nil
+	default:
+		return nil, nil
+	}
+}
+
+// authenticate returns nil if the given request is allowed to use the starter-to-starter API.
+// Callbacks (from the agency) are authenticated by a token in the callback URL.
+func (a *peerAuthenticator) authenticate(r *http.Request) error {
+	if a.mode == PeerAuthModeNone {
+		return nil
+	}
+
+	if token := r.URL.Query().Get(callbackTokenParam); token != "" {
+		expected, err := a.callbackToken(r.URL.Path)
+		if err != nil {
+			return maskAny(err)
+		}
+		if hmac.Equal([]byte(token), []byte(expected)) {
+			return nil
+		}
+		return maskAny(errors.Errorf("Invalid callback token"))
+	}
+
+	switch a.mode {
+	case PeerAuthModeJWT:
+		header := r.Header.Get(AuthorizationHeader)
+		if !strings.HasPrefix(strings.ToLower(header), BearerPrefix) {
+			return maskAny(errors.Errorf("Missing bearer token"))
+		}
+		key, err := a.key()
+		if err != nil {
+			return maskAny(err)
+		}
+		return maskAny(verifyPeerToken(key, header[len(BearerPrefix):]))
+	case PeerAuthModeMTLS:
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return maskAny(errors.Errorf("Missing client certificate"))
+		}
+		return maskAny(a.autoCA.VerifyCertificate(r.TLS.PeerCertificates, x509.ExtKeyUsageClientAuth))
+	}
+	return nil
+}
+
+// verifyPeerToken returns nil if the given token is a valid bearer token signed with the given key.
+func verifyPeerToken(key []byte, token string) error {
+	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("Unexpected signing method %v", t.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return maskAny(err)
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyIssuer(client.PeerTokenIssuer, true) {
+		return maskAny(errors.Errorf("Invalid token issuer"))
+	}
+	if _, found := claims["exp"]; !found {
+		return maskAny(errors.Errorf("Token without expiration"))
+	}
+	return nil
+}
+
+// callbackToken returns the token that authenticates callbacks to the given path.
+func (a *peerAuthenticator) callbackToken(path string) (string, error) {
+	key, err := a.key()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(path))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// createCallbackURL returns the URL of the callback with given path on the given starter URL,
+// including a token when peer authentication is enabled.
+func (a *peerAuthenticator) createCallbackURL(ownURL, path string) (string, error) {
+	cbURL, err := getURLWithPath(ownURL, path)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if a.mode == PeerAuthModeNone {
+		return cbURL, nil
+	}
+	token, err := a.callbackToken(path)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	u, err := url.Parse(cbURL)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	q := u.Query()
+	q.Set(callbackTokenParam, token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// requirePeerAuthentication wraps the given handler such that it can only be used by other starters.
+func (a *peerAuthenticator) requirePeerAuthentication(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := a.authenticate(r); err != nil {
+			writeError(w, http.StatusUnauthorized, fmt.Sprintf("Peer authentication failed: %v", err))
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,90 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_ParsePeerAuthMode(t *testing.T) {
+	for v, expected := range map[string]PeerAuthMode{
+		"":     PeerAuthModeNone,
+		"none": PeerAuthModeNone,
+		"jwt":  PeerAuthModeJWT,
+		"MTLS": PeerAuthModeMTLS,
+	} {
+		mode, err := ParsePeerAuthMode(v)
+		require.NoError(t, err, v)
+		require.Equal(t, expected, mode, v)
+	}
+	_, err := ParsePeerAuthMode("basic")
+	require.Error(t, err)
+}
+
+func Test_PeerAuthenticatorJWT(t *testing.T) {
+	a := &peerAuthenticator{mode: PeerAuthModeJWT, jwtSecret: "secret"}
+
+	// Requests without token are refused
+	r := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	require.Error(t, a.authenticate(r))
+
+	// Tokens signed with the peer key are accepted
+	token, err := client.CreatePeerToken(client.PeerKey([]byte("secret")))
+	require.NoError(t, err)
+	r.Header.Set(AuthorizationHeader, "bearer "+token)
+	require.NoError(t, a.authenticate(r))
+
+	// Tokens signed with another secret are refused
+	token, err = client.CreatePeerToken(client.PeerKey([]byte("other")))
+	require.NoError(t, err)
+	r.Header.Set(AuthorizationHeader, "bearer "+token)
+	require.Error(t, a.authenticate(r))
+
+	// Server tokens (signed with the secret itself) are refused
+	token, err = CreateJwtToken("secret", "starter", "", nil, 0, nil)
+	require.NoError(t, err)
+	r.Header.Set(AuthorizationHeader, "bearer "+token)
+	require.Error(t, a.authenticate(r))
+}
+
+func Test_PeerAuthenticatorCallbackToken(t *testing.T) {
+	peerAuth := &peerAuthenticator{mode: PeerAuthModeJWT, jwtSecret: "secret"}
+
+	cbURL, err := peerAuth.createCallbackURL("http://localhost:8528", "/cb/masterChanged")
+	require.NoError(t, err)
+	u, err := url.Parse(cbURL)
+	require.NoError(t, err)
+	require.Equal(t, "/cb/masterChanged", u.Path)
+	require.NotEmpty(t, u.Query().Get(callbackTokenParam))
+
+	// The token is only valid for the path it was created for
+	r := httptest.NewRequest(http.MethodPost, cbURL, nil)
+	require.NoError(t, peerAuth.authenticate(r))
+	r = httptest.NewRequest(http.MethodPost, "/cb/upgradePlanChanged?"+u.RawQuery, nil)
+	require.Error(t, peerAuth.authenticate(r))
+}
@@ -0,0 +1,267 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+// peerRemoval keeps track of the removal of a peer from the cluster.
+// It implements actions.Progressor, so it can follow the cleanout job of the dbserver of the peer.
+type peerRemoval struct {
+	log         zerolog.Logger
+	mutex       sync.Mutex
+	status      client.PeerRemovalStatus
+	countShards func() (int, error)
+}
+
+// Started is launched when the action starts.
+func (r *peerRemoval) Started(actionName string) {
+	r.log = r.log.With().Str("name", actionName).Logger()
+}
+
+// Failed is launched when the action fails.
+func (r *peerRemoval) Failed(err error) {
+	r.log.Error().Err(err).Msg("Removal of peer failed")
+}
+
+// Finished is launched when the action finishes.
+func (r *peerRemoval) Finished() {
+	r.log.Info().Msg("Removal of peer finished")
+}
+
+// Progress is launched when the action makes progress.
+func (r *peerRemoval) Progress(message string) error {
+	r.log.Info().Msg(message)
+	r.mutex.Lock()
+	r.status.Progress = message
+	r.mutex.Unlock()
+	return errors.New(message)
+}
+
+// jobStatus records the status of the cleanout job of the dbserver.
+func (r *peerRemoval) jobStatus(job JobStatus) {
+	r.mutex.Lock()
+	r.status.JobID = job.JobID
+	r.status.JobState = string(job.State())
+	countShards := r.countShards
+	r.mutex.Unlock()
+
+	if countShards == nil {
+		return
+	}
+	if remaining, err := countShards(); err != nil {
+		r.log.Debug().Err(err).Msg("Failed to count remaining shards")
+	} else {
+		r.mutex.Lock()
+		r.status.ShardsRemaining = remaining
+		r.mutex.Unlock()
+	}
+}
+
+// trackShards sets the function used to count the shards that remain on the dbserver
+// and records the number of shards before its cleanout starts.
+func (r *peerRemoval) trackShards(countShards func() (int, error)) {
+	total, err := countShards()
+	if err != nil {
+		r.log.Warn().Err(err).Msg("Failed to count shards")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.countShards = countShards
+	r.status.ShardsTotal = total
+	r.status.ShardsRemaining = total
+}
+
+// setState changes the state of the removal.
+func (r *peerRemoval) setState(state client.PeerRemovalState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status.State = state
+}
+
+// finish marks the removal as finished, or failed when the given error is not nil.
+func (r *peerRemoval) finish(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	r.status.FinishedAt = &now
+	if err != nil {
+		r.status.State = client.PeerRemovalStateFailed
+		r.status.Error = err.Error()
+		r.Failed(err)
+	} else {
+		r.status.State = client.PeerRemovalStateFinished
+		r.status.ShardsRemaining = 0
+		r.Finished()
+	}
+}
+
+// getStatus returns a copy of the status of the removal.
+func (r *peerRemoval) getStatus() client.PeerRemovalStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.status
+}
+
+// startPeerRemoval registers the start of the removal of the peer with given ID.
+func (s *Service) startPeerRemoval(id string) (*peerRemoval, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r, found := s.peerRemovals[id]; found && !r.getStatus().IsDone() {
+		return nil, maskAny(errors.Wrapf(client.PreconditionFailedError, "Removal of peer %s is already in progress", id))
+	}
+	r := &peerRemoval{
+		log: s.log.With().Str("peer", id).Logger(),
+		status: client.PeerRemovalStatus{
+			ID:        id,
+			State:     client.PeerRemovalStateShuttingDown,
+			StartedAt: time.Now(),
+		},
+	}
+	r.Started("remove-peer")
+	if s.peerRemovals == nil {
+		s.peerRemovals = make(map[string]*peerRemoval)
+	}
+	s.peerRemovals[id] = r
+	return r, nil
+}
+
+// PeerRemovalStatus returns the status of the last removal of the peer with given ID.
+// Returns false if that peer has not been removed by this starter.
+func (s *Service) PeerRemovalStatus(id string) (client.PeerRemovalStatus, bool) {
+	s.mutex.Lock()
+	r, found := s.peerRemovals[id]
+	s.mutex.Unlock()
+
+	if !found {
+		return client.PeerRemovalStatus{}, false
+	}
+	return r.getStatus(), true
+}
+
+// checkDBServerRemoval checks that all collections can still be stored
+// when the dbserver of the peer with given ID is removed.
+func (s *Service) checkDBServerRemoval(ctx context.Context, config ClusterConfig, id string) error {
+	remaining := 0
+	for _, p := range config.AllPeers {
+		if p.ID != id && p.HasDBServer() {
+			remaining++
+		}
+	}
+	inventories, err := s.databaseInventories(ctx, config)
+	if err != nil {
+		return maskAny(errors.Wrap(err, "Failed to fetch collection inventory"))
+	}
+	return checkReplicationFactors(inventories, remaining)
+}
+
+// countShardsOnServer returns the number of shards that have a replica on the dbserver with given ID.
+func (s *Service) countShardsOnServer(ctx context.Context, sid string) (int, error) {
+	config, _, _ := s.ClusterConfig()
+	inventories, err := s.databaseInventories(ctx, config)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	return countShardsOnServer(inventories, driver.ServerID(sid)), nil
+}
+
+// databaseInventories returns the inventory of all databases in the cluster, by database name.
+func (s *Service) databaseInventories(ctx context.Context, config ClusterConfig) (map[string]driver.DatabaseInventory, error) {
+	endpoints, err := config.GetCoordinatorEndpoints()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	c, err := s.CreateClient(endpoints, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	cluster, err := c.Cluster(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	dbs, err := c.Databases(ctx)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := make(map[string]driver.DatabaseInventory, len(dbs))
+	for _, db := range dbs {
+		inv, err := cluster.DatabaseInventory(ctx, db)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		result[db.Name()] = inv
+	}
+	return result, nil
+}
+
+// checkReplicationFactors returns an error when a collection has a replication factor
+// that is higher than the given number of dbservers.
+// Satellite collections are skipped, since they have a replica on all dbservers.
+func checkReplicationFactors(inventories map[string]driver.DatabaseInventory, dbservers int) error {
+	names := make([]string, 0, len(inventories))
+	for name := range inventories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, col := range inventories[name].Collections {
+			if col.Parameters.IsSatellite() {
+				continue
+			}
+			if col.Parameters.ReplicationFactor > dbservers {
+				return maskAny(errors.Wrapf(client.PreconditionFailedError,
+					"Collection '%s' in database '%s' has a replication factor of %d, but only %d dbservers would remain",
+					col.Parameters.Name, name, col.Parameters.ReplicationFactor, dbservers))
+			}
+		}
+	}
+	return nil
+}
+
+// countShardsOnServer returns the number of shards that have a replica on the dbserver with given ID.
+func countShardsOnServer(inventories map[string]driver.DatabaseInventory, sid driver.ServerID) int {
+	count := 0
+	for _, inv := range inventories {
+		for _, col := range inv.Collections {
+			for _, servers := range col.Parameters.Shards {
+				for _, s := range servers {
+					if s == sid {
+						count++
+						break
+					}
+				}
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,78 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func testInventories() map[string]driver.DatabaseInventory {
+	collection := func(name string, replicationFactor int, shards map[driver.ShardID][]driver.ServerID) driver.InventoryCollection {
+		var col driver.InventoryCollection
+		col.Parameters.Name = name
+		col.Parameters.ReplicationFactor = replicationFactor
+		col.Parameters.Shards = shards
+		return col
+	}
+	return map[string]driver.DatabaseInventory{
+		"_system": {Collections: []driver.InventoryCollection{
+			collection("_users", 2, map[driver.ShardID][]driver.ServerID{
+				"s1": {"PRMR-1", "PRMR-2"},
+			}),
+		}},
+		"db": {Collections: []driver.InventoryCollection{
+			collection("orders", 3, map[driver.ShardID][]driver.ServerID{
+				"s2": {"PRMR-1", "PRMR-2", "PRMR-3"},
+				"s3": {"PRMR-2", "PRMR-3", "PRMR-4"},
+			}),
+			collection("countries", driver.ReplicationFactorSatellite, map[driver.ShardID][]driver.ServerID{
+				"s4": {"PRMR-1", "PRMR-2", "PRMR-3", "PRMR-4"},
+			}),
+		}},
+	}
+}
+
+func Test_CheckReplicationFactors(t *testing.T) {
+	inventories := testInventories()
+
+	require.NoError(t, checkReplicationFactors(inventories, 4))
+	require.NoError(t, checkReplicationFactors(inventories, 3))
+
+	err := checkReplicationFactors(inventories, 2)
+	require.True(t, client.IsPreconditionFailed(err))
+	require.Contains(t, err.Error(), "'orders'")
+
+	require.NoError(t, checkReplicationFactors(nil, 0))
+}
+
+func Test_CountShardsOnServer(t *testing.T) {
+	inventories := testInventories()
+
+	require.Equal(t, 3, countShardsOnServer(inventories, "PRMR-1"))
+	require.Equal(t, 4, countShardsOnServer(inventories, "PRMR-2"))
+	require.Equal(t, 2, countShardsOnServer(inventories, "PRMR-4"))
+	require.Equal(t, 0, countShardsOnServer(inventories, "PRMR-5"))
+}
@@ -0,0 +1,220 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/service/actions"
+)
+
+// withPeerRoles returns a copy of the given peer with the given roles applied.
+// Roles that are nil are left unchanged.
+func withPeerRoles(p Peer, roles client.PeerRoles) Peer {
+	if roles.Agent != nil {
+		p.HasAgentFlag = *roles.Agent
+	}
+	if roles.DBServer != nil {
+		p.HasDBServerFlag = roleFlag(*roles.DBServer)
+	}
+	if roles.Coordinator != nil {
+		p.HasCoordinatorFlag = roleFlag(*roles.Coordinator)
+	}
+	return p
+}
+
+// roleFlag returns the value of a role flag of a Peer that defaults to true.
+func roleFlag(value bool) *bool {
+	if value {
+		return nil
+	}
+	return boolRef(false)
+}
+
+// peerRolesOf returns all roles of the given peer.
+func peerRolesOf(p Peer) client.PeerRoles {
+	return client.PeerRoles{
+		Agent:       boolRef(p.HasAgent()),
+		DBServer:    boolRef(p.HasDBServer()),
+		Coordinator: boolRef(p.HasCoordinator()),
+	}
+}
+
+// samePeerRoles returns true if both peers have the same roles.
+func samePeerRoles(a, b Peer) bool {
+	return a.HasAgent() == b.HasAgent() &&
+		a.HasDBServer() == b.HasDBServer() &&
+		a.HasCoordinator() == b.HasCoordinator()
+}
+
+// checkPeerRoles checks that the cluster remains usable when the given peer
+// is replaced by the updated peer.
+func checkPeerRoles(config ClusterConfig, peer, updated Peer) error {
+	if peer.HasAgent() != updated.HasAgent() {
+		return maskAny(errors.Wrap(client.PreconditionFailedError, "Changing the agent role of a running peer is not supported"))
+	}
+	dbservers, coordinators := 0, 0
+	for _, p := range config.AllPeers {
+		if p.ID == updated.ID {
+			p = updated
+		}
+		if p.HasDBServer() {
+			dbservers++
+		}
+		if p.HasCoordinator() {
+			coordinators++
+		}
+	}
+	if dbservers == 0 {
+		return maskAny(client.NewBadRequestError("At least one peer must have a dbserver"))
+	}
+	if coordinators == 0 {
+		return maskAny(client.NewBadRequestError("At least one peer must have a coordinator"))
+	}
+	return nil
+}
+
+// HandleSetPeerRoles changes the roles of the peer with given id in the cluster configuration
+// and lets that peer start & stop its servers accordingly.
+// A dbserver that is no longer wanted is cleaned out before the configuration is changed.
+func (s *Service) HandleSetPeerRoles(ctx context.Context, id string, roles client.PeerRoles) (peerFound bool, err error) {
+	// Find peer
+	s.mutex.Lock()
+	peer, peerFound := s.myPeers.PeerByID(id)
+	config := s.myPeers
+	state := s.state
+	mode := s.mode
+	s.mutex.Unlock()
+
+	// Check state
+	if state != stateRunningMaster {
+		return false, maskAny(errors.Wrapf(client.PreconditionFailedError, "Invalid state %d", state))
+	}
+	if !mode.IsClusterMode() {
+		return false, maskAny(client.NewPreconditionFailedError("Roles of peers can only be changed in cluster mode"))
+	}
+
+	// Check peer
+	if !peerFound {
+		return false, nil // Peer not found
+	}
+	updated := withPeerRoles(peer, roles)
+	if err := checkPeerRoles(config, peer, updated); err != nil {
+		return true, maskAny(err)
+	}
+	if !samePeerRoles(peer, updated) {
+		// Refuse changes when the upgrade plan cannot be read, since an upgrade may be in progress.
+		if status, err := s.upgradeManager.Status(ctx); client.IsNotFound(err) {
+			// No upgrade plan
+		} else if err != nil {
+			return true, maskAny(errors.Wrapf(client.ServiceUnavailableError, "Cannot check whether a database upgrade is in progress: %v", err))
+		} else if !status.Ready {
+			return true, maskAny(client.NewPreconditionFailedError("Cannot change roles of a peer while a database upgrade is in progress"))
+		}
+
+		// Clean out dbserver (if it is no longer wanted)
+		if peer.HasDBServer() && !updated.HasDBServer() {
+			if err := s.checkDBServerRemoval(ctx, config, id); err != nil {
+				return true, maskAny(err)
+			}
+			s.log.Info().Msgf("Finding server ID of dbserver of peer %s", id)
+			sc, err := peer.CreateDBServerAPI(s)
+			if err != nil {
+				return true, maskAny(err)
+			}
+			sid, err := sc.ServerID(ctx)
+			if err != nil {
+				return true, maskAny(err)
+			}
+			c, err := config.CreateClusterAPI(ctx, s)
+			if err != nil {
+				return true, maskAny(err)
+			}
+			progress := &actions.ProgressLog{LoggerOriginal: s.log}
+			progress.Started("cleanout-dbserver")
+			if err := s.cleanOutDBServer(ctx, c, sid, progress); err != nil {
+				return true, maskAny(err)
+			}
+		}
+
+		// Update cluster configuration
+		s.mutex.Lock()
+		s.log.Info().Msgf("Changing roles of peer %s in cluster configuration", id)
+		s.myPeers.UpdatePeerByID(updated)
+		if err := s.saveSetup(); err != nil {
+			s.log.Error().Err(err).Msg("Failed to save setup")
+		}
+		s.mutex.Unlock()
+	}
+
+	// Let the peer start & stop its servers.
+	// This is also done when the roles have not changed, such that a failed attempt can be retried.
+	if id == s.id {
+		return true, maskAny(s.applyPeerRoles(ctx, updated))
+	}
+	epURL, err := url.Parse(updated.CreateStarterURL("/"))
+	if err != nil {
+		return true, maskAny(err)
+	}
+	c, err := client.NewArangoStarterClient(*epURL, s.peerAuth.clientOptions...)
+	if err != nil {
+		return true, maskAny(err)
+	}
+	if err := c.ApplyPeerRolesLocal(ctx, peerRolesOf(updated)); err != nil {
+		s.log.Warn().Err(err).Msgf("Peer %s failed to apply its new roles", id)
+		return true, maskAny(err)
+	}
+	return true, nil
+}
+
+// ApplyPeerRoles lets this starter take the given roles, starting the servers
+// of the roles it gains and removing the servers of the roles it loses from the cluster.
+func (s *Service) ApplyPeerRoles(ctx context.Context, roles client.PeerRoles) error {
+	s.mutex.Lock()
+	myPeer, found := s.myPeers.PeerByID(s.id)
+	if !found {
+		s.mutex.Unlock()
+		return maskAny(client.NewPreconditionFailedError("Cannot find my own peer in cluster configuration"))
+	}
+	updated := withPeerRoles(myPeer, roles)
+	if !samePeerRoles(myPeer, updated) {
+		s.myPeers.UpdatePeerByID(updated)
+		if err := s.saveSetup(); err != nil {
+			s.log.Error().Err(err).Msg("Failed to save setup")
+		}
+	}
+	s.mutex.Unlock()
+
+	return maskAny(s.applyPeerRoles(ctx, updated))
+}
+
+// applyPeerRoles starts & stops the servers of this starter such that they match
+// the roles of the given peer.
+func (s *Service) applyPeerRoles(ctx context.Context, myPeer Peer) error {
+	if err := s.runtimeServerManager.applyPeerRoles(ctx, s.log, s, myPeer); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_WithPeerRoles(t *testing.T) {
+	p := NewPeer("a", "localhost", 8528, 0, "/data", true, true, true, false, false, false, false)
+
+	// Unset roles are left unchanged
+	require.Equal(t, p, withPeerRoles(p, client.PeerRoles{}))
+
+	updated := withPeerRoles(p, client.PeerRoles{DBServer: boolRef(false)})
+	require.True(t, updated.HasAgent())
+	require.False(t, updated.HasDBServer())
+	require.True(t, updated.HasCoordinator())
+	require.False(t, samePeerRoles(p, updated))
+
+	// Enabled roles are stored like NewPeer does
+	require.Equal(t, p, withPeerRoles(updated, client.PeerRoles{DBServer: boolRef(true)}))
+	require.Equal(t, updated, withPeerRoles(p, peerRolesOf(updated)))
+}
+
+func Test_CheckPeerRoles(t *testing.T) {
+	a := NewPeer("a", "localhost", 8528, 0, "/data/a", true, true, false, false, false, false, false)
+	b := NewPeer("b", "localhost", 8528, 5, "/data/b", false, true, true, false, false, false, false)
+	config := ClusterConfig{AllPeers: []Peer{a, b}}
+
+	require.NoError(t, checkPeerRoles(config, a, withPeerRoles(a, client.PeerRoles{Coordinator: boolRef(true)})))
+	require.NoError(t, checkPeerRoles(config, b, withPeerRoles(b, client.PeerRoles{DBServer: boolRef(false)})))
+
+	// Agents cannot be added or removed
+	err := checkPeerRoles(config, a, withPeerRoles(a, client.PeerRoles{Agent: boolRef(false)}))
+	require.True(t, client.IsPreconditionFailed(err))
+
+	// The last coordinator cannot be removed
+	err = checkPeerRoles(config, b, withPeerRoles(b, client.PeerRoles{Coordinator: boolRef(false)}))
+	require.True(t, client.IsBadRequest(err))
+
+	// The last dbserver cannot be removed
+	config.AllPeers[1] = withPeerRoles(b, client.PeerRoles{DBServer: boolRef(false)})
+	err = checkPeerRoles(config, a, withPeerRoles(a, client.PeerRoles{DBServer: boolRef(false)}))
+	require.True(t, client.IsBadRequest(err))
+}
@@ -0,0 +1,383 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+	docker "github.com/fsouza/go-dockerclient"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	// preflightCheckTimeout is the timeout of a single request made by a pre-flight check.
+	preflightCheckTimeout = time.Second * 5
+)
+
+// CheckResult is the outcome of a single pre-flight check.
+type CheckResult struct {
+	// Name of the check
+	Name string `json:"name"`
+	// Passed is set when the check has passed
+	Passed bool `json:"passed"`
+	// Message describes the outcome of the check
+	Message string `json:"message,omitempty"`
+}
+
+// PassedCheck returns a passed check with given name & message.
+func PassedCheck(name, format string, args ...interface{}) CheckResult {
+	return CheckResult{Name: name, Passed: true, Message: fmt.Sprintf(format, args...)}
+}
+
+// FailedCheck returns a failed check with given name & message.
+func FailedCheck(name, format string, args ...interface{}) CheckResult {
+	return CheckResult{Name: name, Passed: false, Message: fmt.Sprintf(format, args...)}
+}
+
+// Check runs the checks that are done when the service is started, without starting any server.
+// The given config & relaunch flag are the result of reading setup.json (if any).
+func (s *Service) Check(ctx context.Context, bsCfg BootstrapConfig, myPeers ClusterConfig, shouldRelaunch bool) []CheckResult {
+	// Load settings from BootstrapConfig
+	s.id = bsCfg.ID
+	s.mode = bsCfg.Mode
+	s.jwtSecret = bsCfg.JwtSecret
+	s.sslKeyFile = bsCfg.SslKeyFile
+
+	var results []CheckResult
+	add := func(r CheckResult) {
+		results = append(results, r)
+	}
+
+	// Check mode
+	if bsCfg.Mode.IsClusterMode() || bsCfg.Mode.IsActiveFailoverMode() {
+		if bsCfg.AgencySize < 1 {
+			add(FailedCheck("mode", "Agency size must be >= 1"))
+		} else {
+			add(PassedCheck("mode", "Mode %s with an agency of size %d", bsCfg.Mode, bsCfg.AgencySize))
+		}
+	} else if bsCfg.Mode.IsSingleMode() {
+		add(PassedCheck("mode", "Mode %s", bsCfg.Mode))
+	} else {
+		add(FailedCheck("mode", "Unknown mode '%s'", bsCfg.Mode))
+	}
+
+	add(s.checkKeyFiles(bsCfg))
+	add(checkDataDir(bsCfg.DataDir))
+
+	// Check docker & create runner
+	runnerUsable := true
+	if s.cfg.UseDockerRunner() {
+		r := checkDocker(s.cfg)
+		add(r)
+		runnerUsable = r.Passed
+	} else if s.cfg.RunningInDocker {
+		add(FailedCheck("docker", "When running in docker, you must provide a --docker.endpoint=<endpoint> and --docker.image=<image>"))
+		runnerUsable = false
+	}
+	if runnerUsable {
+		s.runner, s.cfg, _ = s.cfg.CreateRunner(s.log)
+		s.restoreArangodBinary(bsCfg)
+		add(s.checkArangodVersion(ctx, bsCfg.ServerStorageEngine))
+		if s.cfg.SyncEnabled {
+			add(checkArangoSyncVersion(ctx, s.runner, s.cfg.ArangoSyncPath))
+		}
+	} else {
+		add(FailedCheck("arangod", "Skipped, no usable runner"))
+	}
+
+	// Check ports of own servers
+	var myPeer Peer
+	if shouldRelaunch {
+		if p, found := myPeers.PeerByID(bsCfg.ID); found {
+			myPeer = p
+		} else {
+			add(FailedCheck("ports", "Cannot find own peer '%s' in %s", bsCfg.ID, setupFileName))
+			shouldRelaunch = false
+		}
+	}
+	if !shouldRelaunch {
+		hasAgent := boolFromRef(bsCfg.StartAgent, !bsCfg.Mode.IsSingleMode())
+		hasDBServer := boolFromRef(bsCfg.StartDBserver, true)
+		hasCoordinator := boolFromRef(bsCfg.StartCoordinator, true)
+		hasResilientSingle := boolFromRef(bsCfg.StartResilientSingle, bsCfg.Mode.IsActiveFailoverMode())
+		hasSyncMaster := boolFromRef(bsCfg.StartSyncMaster, true) && s.cfg.SyncEnabled
+		hasSyncWorker := boolFromRef(bsCfg.StartSyncWorker, true) && s.cfg.SyncEnabled
+		myPeer = NewPeer(bsCfg.ID, s.cfg.OwnAddress, s.cfg.MasterPort, 0, s.cfg.DataDir,
+			hasAgent, hasDBServer, hasCoordinator, hasResilientSingle,
+			hasSyncMaster, hasSyncWorker, s.IsSecure())
+	}
+	for _, r := range s.checkPorts(bsCfg.Mode, myPeer) {
+		add(r)
+	}
+
+	// Check masters
+	if shouldRelaunch {
+		add(PassedCheck("masters", "Skipped, relaunching from %s", setupFileName))
+	} else {
+		for _, r := range s.checkMasterAddresses(ctx) {
+			add(r)
+		}
+	}
+
+	return results
+}
+
+// checkKeyFiles checks that all configured key files & secrets can be read.
+func (s *Service) checkKeyFiles(bsCfg BootstrapConfig) CheckResult {
+	const name = "keyfiles"
+	if !bsCfg.SslAutoCA {
+		if _, err := bsCfg.CreateTLSConfig(); err != nil {
+			return FailedCheck(name, "Cannot load keyfile '%s': %s", bsCfg.SslKeyFile, err)
+		}
+	}
+	files := []string{bsCfg.SslCAFile, bsCfg.RocksDBEncryptionKeyFile}
+	if s.cfg.SyncEnabled {
+		files = append(files, s.cfg.SyncMasterKeyFile, s.cfg.SyncMasterClientCAFile, s.cfg.SyncMasterJWTSecretFile)
+	}
+	for _, folder := range []string{bsCfg.JWTFolderDir(), bsCfg.EncryptionKeyFolderDir()} {
+		entries, err := ioutil.ReadDir(folder)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return FailedCheck(name, "Cannot read folder '%s': %s", folder, err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(folder, e.Name()))
+			}
+		}
+	}
+	count := 0
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return FailedCheck(name, "Cannot read '%s': %s", f, err)
+		}
+		if len(bytes.TrimSpace(content)) == 0 {
+			return FailedCheck(name, "File '%s' is empty", f)
+		}
+		count++
+	}
+	return PassedCheck(name, "%d key files & secrets can be read", count)
+}
+
+// checkDataDir checks that files can be created in the given data directory.
+func checkDataDir(dataDir string) CheckResult {
+	const name = "data-directory"
+	// The data directory is created when the starter starts, check its closest existing parent instead.
+	dir := dataDir
+	for {
+		if _, err := os.Stat(dir); err == nil || !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err := ioutil.TempFile(dir, ".check-")
+	if err != nil {
+		return FailedCheck(name, "Cannot write to data directory '%s': %s", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if dir != dataDir {
+		return PassedCheck(name, "Data directory '%s' can be created in '%s'", dataDir, dir)
+	}
+	return PassedCheck(name, "Data directory '%s' is writable", dataDir)
+}
+
+// checkDocker checks that the docker endpoint can be reached and looks for the docker image(s) to use.
+func checkDocker(config Config) CheckResult {
+	const name = "docker"
+	c, err := docker.NewClient(config.DockerEndpoint)
+	if err != nil {
+		return FailedCheck(name, "Invalid docker endpoint '%s': %s", config.DockerEndpoint, err)
+	}
+	if err := c.Ping(); err != nil {
+		return FailedCheck(name, "Cannot reach docker endpoint '%s': %s", config.DockerEndpoint, err)
+	}
+	images := []string{config.DockerArangodImage}
+	if config.SyncEnabled && config.DockerArangoSyncImage != "" && config.DockerArangoSyncImage != config.DockerArangodImage {
+		images = append(images, config.DockerArangoSyncImage)
+	}
+	var missing []string
+	for _, image := range images {
+		if _, err := c.InspectImage(image); isNoSuchImage(err) {
+			missing = append(missing, image)
+		} else if err != nil {
+			return FailedCheck(name, "Cannot inspect image '%s': %s", image, err)
+		}
+	}
+	if len(missing) > 0 {
+		if config.DockerImagePullPolicy == ImagePullPolicyNever {
+			return FailedCheck(name, "Image(s) %s not found locally and pull policy is %s", strings.Join(missing, ", "), config.DockerImagePullPolicy)
+		}
+		return PassedCheck(name, "Docker endpoint '%s' can be reached, image(s) %s will be pulled", config.DockerEndpoint, strings.Join(missing, ", "))
+	}
+	return PassedCheck(name, "Docker endpoint '%s' can be reached, image(s) %s found", config.DockerEndpoint, strings.Join(images, ", "))
+}
+
+// checkArangodVersion checks that the arangod executable (or docker image) can be started,
+// supports the given storage engine and reports its version.
+func (s *Service) checkArangodVersion(ctx context.Context, storageEngine string) CheckResult {
+	const name = "arangod"
+	v, enterprise, err := s.databaseVersion(ctx)
+	if err != nil {
+		return FailedCheck(name, "Cannot get version of '%s': %s", s.cfg.configuredArangodBinaryOr(s.ArangodBinary()), err)
+	}
+	edition := "community"
+	if enterprise {
+		edition = "enterprise"
+	}
+	if err := s.validateStorageEngine(storageEngine, NewDatabaseFeatures(v, enterprise)); err != nil {
+		return FailedCheck(name, "Version %s (%s): %s", v, edition, err)
+	}
+	return PassedCheck(name, "Version %s (%s)", v, edition)
+}
+
+// checkArangoSyncVersion checks that the arangosync executable (or docker image) can be started and reports its version.
+func checkArangoSyncVersion(ctx context.Context, runner Runner, arangoSyncPath string) CheckResult {
+	const name = "arangosync"
+	output := &bytes.Buffer{}
+	containerName := "arangodb-versioncheck-" + strings.ToLower(uniuri.NewLen(6))
+	p, err := runner.Start(ctx, definitions.ProcessTypeArangoSync, arangoSyncPath, []string{"version"}, nil, nil, nil, containerName, ".", output)
+	if err != nil {
+		return FailedCheck(name, "Cannot start '%s': %s", arangoSyncPath, err)
+	}
+	defer p.Cleanup()
+	if code := p.Wait(); code != 0 {
+		return FailedCheck(name, "Process exited with exit code %d - %s", code, output.String())
+	}
+	version := strings.TrimSpace(strings.SplitN(strings.TrimSpace(output.String()), "\n", 2)[0])
+	return PassedCheck(name, "%s", version)
+}
+
+// checkPorts checks that the ports of the starter and all servers of the given peer are free.
+func (s *Service) checkPorts(mode ServiceMode, p Peer) []CheckResult {
+	var results []CheckResult
+	if IsPortOpen(s.cfg.BindAddress, s.cfg.MasterPort) {
+		results = append(results, PassedCheck("port-starter", "Port %d is free", s.cfg.MasterPort))
+	} else {
+		results = append(results, FailedCheck("port-starter", "Port %d is already in use", s.cfg.MasterPort))
+	}
+	for _, t := range expectedServerTypes(mode, p) {
+		port := p.Port + p.PortOffset + t.PortOffset()
+		name := "port-" + t.String()
+		if IsPortOpen("", port) {
+			results = append(results, PassedCheck(name, "Port %d is free", port))
+		} else {
+			results = append(results, FailedCheck(name, "Port %d is already in use", port))
+		}
+	}
+	return results
+}
+
+// expectedServerTypes returns the types of servers started by the given peer in the given mode.
+func expectedServerTypes(mode ServiceMode, p Peer) []definitions.ServerType {
+	var result []definitions.ServerType
+	switch {
+	case mode.IsClusterMode():
+		if p.HasAgent() {
+			result = append(result, definitions.ServerTypeAgent)
+		}
+		if p.HasDBServer() {
+			result = append(result, definitions.ServerTypeDBServer)
+		}
+		if p.HasCoordinator() {
+			result = append(result, definitions.ServerTypeCoordinator)
+		}
+		if p.HasSyncMaster() {
+			result = append(result, definitions.ServerTypeSyncMaster)
+		}
+		if p.HasSyncWorker() {
+			result = append(result, definitions.ServerTypeSyncWorker)
+		}
+	case mode.IsActiveFailoverMode():
+		if p.HasAgent() {
+			result = append(result, definitions.ServerTypeAgent)
+		}
+		if p.HasResilientSingle() {
+			result = append(result, definitions.ServerTypeResilientSingle)
+		}
+	case mode.IsSingleMode():
+		result = append(result, definitions.ServerTypeSingle)
+	}
+	return result
+}
+
+// checkMasterAddresses checks that all `--starter.join` addresses answer an ID request.
+// The address of this starter (if listed) is skipped, since it is not yet running.
+func (s *Service) checkMasterAddresses(ctx context.Context) []CheckResult {
+	const name = "masters"
+	if len(s.cfg.MasterAddresses) == 0 {
+		return []CheckResult{PassedCheck(name, "No --starter.join addresses, this starter acts as master")}
+	}
+	var results []CheckResult
+	for _, addr := range s.cfg.MasterAddresses {
+		masterURL := s.createBootstrapMasterURL(addr, s.cfg)
+		u, err := url.Parse(masterURL)
+		if err != nil {
+			results = append(results, FailedCheck(name, "Invalid address '%s': %s", addr, err))
+			continue
+		}
+		if isOwnHost(u.Hostname(), s.cfg.OwnAddress) && u.Port() == strconv.Itoa(s.cfg.MasterPort) {
+			results = append(results, PassedCheck(name, "Skipped own address %s", masterURL))
+			continue
+		}
+		c, err := client.NewArangoStarterClient(*u)
+		if err != nil {
+			results = append(results, FailedCheck(name, "Cannot create client for %s: %s", masterURL, err))
+			continue
+		}
+		idCtx, cancel := context.WithTimeout(ctx, preflightCheckTimeout)
+		info, err := c.ID(idCtx)
+		cancel()
+		if err != nil {
+			results = append(results, FailedCheck(name, "Starter at %s does not answer: %s", masterURL, err))
+		} else {
+			results = append(results, PassedCheck(name, "Starter at %s answers with ID '%s'", masterURL, info.ID))
+		}
+	}
+	return results
+}
+
+// isOwnHost returns true if the given host refers to this machine.
+func isOwnHost(host, ownAddress string) bool {
+	if host == ownAddress || host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
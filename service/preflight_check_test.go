@@ -0,0 +1,53 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_ExpectedServerTypes(t *testing.T) {
+	p := NewPeer("a", "localhost", 8528, 0, "/data", true, true, true, false, false, false, false)
+	require.Equal(t, []definitions.ServerType{definitions.ServerTypeAgent, definitions.ServerTypeDBServer, definitions.ServerTypeCoordinator},
+		expectedServerTypes(ServiceModeCluster, p))
+	require.Equal(t, []definitions.ServerType{definitions.ServerTypeAgent},
+		expectedServerTypes(ServiceModeActiveFailover, p))
+	require.Equal(t, []definitions.ServerType{definitions.ServerTypeSingle},
+		expectedServerTypes(ServiceModeSingle, p))
+
+	p = NewPeer("b", "localhost", 8528, 0, "/data", false, false, true, true, true, true, false)
+	require.Equal(t, []definitions.ServerType{definitions.ServerTypeCoordinator, definitions.ServerTypeSyncMaster, definitions.ServerTypeSyncWorker},
+		expectedServerTypes(ServiceModeCluster, p))
+	require.Equal(t, []definitions.ServerType{definitions.ServerTypeResilientSingle},
+		expectedServerTypes(ServiceModeActiveFailover, p))
+}
+
+func Test_IsOwnHost(t *testing.T) {
+	require.True(t, isOwnHost("localhost", ""))
+	require.True(t, isOwnHost("127.0.0.1", ""))
+	require.True(t, isOwnHost("::1", ""))
+	require.True(t, isOwnHost("10.0.0.1", "10.0.0.1"))
+	require.False(t, isOwnHost("10.0.0.2", "10.0.0.1"))
+}
@@ -64,6 +64,21 @@ func NewProcessWrapper(s *runtimeServerManager, ctx context.Context, log zerolog
 type ProcessWrapper interface {
 	Wait(timeout time.Duration) bool
 	Process() Process
+	// Status returns the runtime status of the wrapped server.
+	Status() ProcessWrapperStatus
+}
+
+// ProcessWrapperStatus holds the runtime status of a server managed by a ProcessWrapper.
+type ProcessWrapperStatus struct {
+	Restarts       int       // Number of times the server has been restarted
+	Running        bool      // Set if the server process is running
+	Up             bool      // Set if the server has been detected to be up with the correct role
+	StartedAt      time.Time // Time the server process was last started
+	HasExited      bool      // Set if the server process has terminated at least once
+	LastExitCode   int       // Exit code of the last terminated server process
+	LastStatusCode int       // Last status code returned by TestInstance
+	Failures       int       // Number of unexpected terminations within the failure window of the restart policy
+	Failed         bool      // Set if the starter has given up restarting the server
 }
 
 type processWrapper struct {
@@ -78,8 +93,9 @@ type processWrapper struct {
 	serverType     definitions.ServerType
 	gracePeriod    time.Duration
 
-	lock sync.Mutex
-	proc Process
+	lock   sync.Mutex
+	proc   Process
+	status ProcessWrapperStatus
 
 	closed, stopping chan struct{}
 }
@@ -88,6 +104,21 @@ func (p *processWrapper) Process() Process {
 	return p.proc
 }
 
+func (p *processWrapper) Status() ProcessWrapperStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.status
+}
+
+// updateStatus updates the runtime status of the wrapped server.
+func (p *processWrapper) updateStatus(update func(status *ProcessWrapperStatus)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	update(&p.status)
+}
+
 func (p *processWrapper) Wait(timeout time.Duration) bool {
 	p.stop()
 
@@ -99,6 +130,16 @@ func (p *processWrapper) Wait(timeout time.Duration) bool {
 	}
 }
 
+// isStopping returns true if the wrapper has been asked to stop.
+func (p *processWrapper) isStopping() bool {
+	select {
+	case <-p.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *processWrapper) stop() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -119,6 +160,8 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 	}()
 	restart := 0
 	recentFailures := 0
+	policy := p.config.RestartPolicies.ForServerType(p.serverType)
+	tracker := newRestartTracker(policy)
 
 	close(startedCh)
 
@@ -132,6 +175,9 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 		if err != nil {
 			logProcess.Error().Err(err).Msgf("Error while starting %s", p.serverType)
 			if !portInUse {
+				p.updateStatus(func(status *ProcessWrapperStatus) {
+					status.Failed = true
+				})
 				break
 			}
 		} else {
@@ -139,6 +185,12 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 
 			logProcess.Info().Msg("server started")
 			p.proc = proc
+			p.updateStatus(func(status *ProcessWrapperStatus) {
+				status.Restarts = restart
+				status.Running = true
+				status.Up = false
+				status.StartedAt = startTime
+			})
 			ctx, cancel := context.WithCancel(p.ctx)
 			go func() {
 				port, err := p.runtimeContext.serverPort(p.serverType)
@@ -154,6 +206,9 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 							// Channel closed
 							return
 						}
+						p.updateStatus(func(status *ProcessWrapperStatus) {
+							status.LastStatusCode = statusItem.StatusCode
+						})
 						if statusItem.PrevStatusCode != statusItem.StatusCode {
 							if p.config.DebugCluster {
 								logProcess.Info().Msgf("%s status changed to %d", p.serverType, statusItem.StatusCode)
@@ -168,6 +223,9 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 					}
 				}()
 				if up, correctRole, version, role, mode, isLeader, statusTrail, cancelled := p.runtimeContext.TestInstance(ctx, p.serverType, myHostAddress, port, statusChanged); !cancelled {
+					p.updateStatus(func(status *ProcessWrapperStatus) {
+						status.Up = up && correctRole
+					})
 					if up && correctRole {
 						msgPostfix := ""
 						if p.serverType == definitions.ServerTypeResilientSingle && !isLeader {
@@ -220,7 +278,17 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 				}
 			}()
 
-			procC := proc.WaitCh()
+			procC := make(chan struct{})
+			go func() {
+				defer close(procC)
+				exitCode := proc.Wait()
+				p.updateStatus(func(status *ProcessWrapperStatus) {
+					status.Running = false
+					status.Up = false
+					status.HasExited = true
+					status.LastExitCode = exitCode
+				})
+			}()
 
 			select {
 			case <-procC:
@@ -239,7 +307,12 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 			cancel()
 		}
 		uptime := time.Since(startTime)
-		isTerminationExpected := p.runtimeContext.UpgradeManager().IsServerUpgradeInProgress(p.serverType)
+		isRemoved := p.s.isServerRemovalInProgress(p.serverType)
+		isTerminationRequested := p.runtimeContext.UpgradeManager().IsServerUpgradeInProgress(p.serverType) ||
+			p.runtimeContext.RestartManager().IsServerRestartInProgress(p.serverType)
+		// A server that fails while being upgraded or restarted counts as a failure,
+		// so the starter gives up on it instead of restarting it forever.
+		isTerminationExpected := isRemoved || (isTerminationRequested && p.Status().LastExitCode == 0)
 		if isTerminationExpected {
 			logProcess.Debug().Msgf("%s stopped as expected", p.serverType)
 		} else {
@@ -260,12 +333,6 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 						p.s.showRecentLogs(logProcess, p.runtimeContext, p.serverType)
 					}
 				}
-				if recentFailures >= definitions.MaxRecentFailures {
-					logProcess.Error().Msgf("%s has failed %d times, giving up", p.serverType, recentFailures)
-					p.runtimeContext.Stop()
-					p.s.stopping = true
-					break
-				}
 			} else {
 				logProcess.Info().Msgf("%s has terminated", p.serverType)
 				if p.config.DebugCluster && !p.s.stopping {
@@ -273,12 +340,29 @@ func (p *processWrapper) run(startedCh chan<- struct{}) {
 					p.s.showRecentLogs(logProcess, p.runtimeContext, p.serverType)
 				}
 			}
-			if portInUse {
-				time.Sleep(time.Second)
+
+			if !p.s.stopping {
+				now := time.Now()
+				delay, giveUp := tracker.RecordFailure(now, uptime)
+				failures := tracker.Failures(now)
+				p.updateStatus(func(status *ProcessWrapperStatus) {
+					status.Failures = failures
+					status.Failed = giveUp
+				})
+				if giveUp {
+					logProcess.Error().Msgf("%s has failed %d times within %s, giving up", p.serverType, failures, policy.FailureWindow)
+					break
+				}
+				logProcess.Info().Msgf("Waiting %s before restarting %s (failures: %d)", delay, p.serverType, failures)
+				select {
+				case <-time.After(delay):
+				case <-p.stopping:
+				case <-p.ctx.Done():
+				}
 			}
 		}
 
-		if p.s.stopping {
+		if p.s.stopping || p.isStopping() || isRemoved {
 			break
 		}
 
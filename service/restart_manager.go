@@ -0,0 +1,610 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/arangodb/go-driver/agency"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+	"github.com/arangodb-helper/arangodb/pkg/trigger"
+)
+
+// RestartManager is the API of a service used to control rolling restarts of the servers of a deployment.
+type RestartManager interface {
+	// StartDatabaseRestart is called to start a rolling restart of all servers of the given type
+	// (or all arangod servers for client.RestartTypeAll).
+	StartDatabaseRestart(ctx context.Context, serverType client.ServerType) error
+
+	// RetryDatabaseRestart resets a failure mark in the existing restart plan
+	// such that the starters will retry the restart once more.
+	RetryDatabaseRestart(ctx context.Context) error
+
+	// AbortDatabaseRestart removes the existing restart plan.
+	// Note that Starters working on an entry of the restart
+	// will finish that entry.
+	// If there is no plan, a NotFoundError will be returned.
+	AbortDatabaseRestart(ctx context.Context) error
+
+	// Status returns the status of any restart plan
+	Status(context.Context) (client.RestartStatus, error)
+
+	// IsServerRestartInProgress returns true when the restart manager is busy restarting the server of given type.
+	IsServerRestartInProgress(serverType definitions.ServerType) bool
+
+	// ServerStarted is called when a server of given type has been started.
+	ServerStarted(serverType definitions.ServerType)
+
+	// RunWatchRestartPlan keeps watching the restart plan until the given context is canceled.
+	RunWatchRestartPlan(context.Context)
+
+	// RestartPlanChangedCallback is an agency callback to notify about changes in the restart plan
+	RestartPlanChangedCallback()
+}
+
+// NewRestartManager creates a new restart manager.
+func NewRestartManager(log zerolog.Logger, upgradeManagerContext UpgradeManagerContext) RestartManager {
+	return &restartManager{
+		deploymentChecks: deploymentChecks{
+			log:                   log,
+			upgradeManagerContext: upgradeManagerContext,
+		},
+	}
+}
+
+var (
+	restartPlanKey         = []string{"arangodb-helper", "arangodb", "restart-plan"}
+	restartPlanRevisionKey = append(restartPlanKey, "revision")
+)
+
+// RestartPlan is the JSON structure that describes a plan to restart
+// the servers of a deployment one at a time.
+type RestartPlan struct {
+	Revision        int                `json:"revision"` // Must match with restartPlanRevisionKey
+	CreatedAt       time.Time          `json:"created_at"`
+	LastModifiedAt  time.Time          `json:"last_modified_at"`
+	ServerType      client.ServerType  `json:"server_type"`
+	Entries         []UpgradePlanEntry `json:"entries"`
+	FinishedEntries []UpgradePlanEntry `json:"finished_entries"`
+	Finished        bool               `json:"finished"`
+}
+
+// IsEmpty returns true when the given plan has not been initialized.
+func (p RestartPlan) IsEmpty() bool {
+	return p.CreatedAt.IsZero()
+}
+
+// IsReady returns true when all entries have finished.
+func (p RestartPlan) IsReady() bool {
+	return len(p.Entries) == 0
+}
+
+// IsFailed returns true when one of the entries has failures.
+func (p RestartPlan) IsFailed() bool {
+	for _, e := range p.Entries {
+		if e.Failures > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetFailures resets all Failures field to 0.
+func (p *RestartPlan) ResetFailures() {
+	for i := range p.Entries {
+		p.Entries[i].Failures = 0
+		p.Entries[i].Reason = ""
+	}
+}
+
+// createRestartPlanEntries returns the entries of a plan that restarts all servers of the given type
+// (or all arangod servers for client.RestartTypeAll) in the given deployment.
+// The order of the entries is the same as in an upgrade plan.
+func createRestartPlanEntries(config ClusterConfig, mode ServiceMode, serverType client.ServerType) ([]UpgradePlanEntry, error) {
+	var entries []UpgradePlanEntry
+	add := func(entryType UpgradeEntryType, has func(Peer) bool) {
+		if serverType != client.RestartTypeAll && serverType != client.ServerType(entryType) {
+			return
+		}
+		for _, p := range config.AllPeers {
+			if has(p) {
+				entries = append(entries, UpgradePlanEntry{
+					Type:   entryType,
+					PeerID: p.ID,
+				})
+			}
+		}
+	}
+
+	switch serverType {
+	case client.RestartTypeAll, client.ServerTypeAgent:
+		// Supported in all modes with an agency
+	case client.ServerTypeDBServer, client.ServerTypeCoordinator:
+		if !mode.IsClusterMode() {
+			return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("Server type '%s' is only supported in cluster mode", serverType)))
+		}
+	case client.ServerTypeSingle:
+		if !mode.IsActiveFailoverMode() {
+			return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("Server type '%s' is only supported in active failover mode", serverType)))
+		}
+	default:
+		return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("Unsupported server type '%s'", serverType)))
+	}
+
+	add(UpgradeEntryTypeAgent, Peer.HasAgent)
+	if mode.IsActiveFailoverMode() {
+		add(UpgradeEntryTypeSingle, Peer.HasResilientSingle)
+	}
+	if mode.IsClusterMode() {
+		add(UpgradeEntryTypeDBServer, Peer.HasDBServer)
+		add(UpgradeEntryTypeCoordinator, Peer.HasCoordinator)
+	}
+	if len(entries) == 0 {
+		return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("There are no servers of type '%s'", serverType)))
+	}
+	return entries, nil
+}
+
+// restartManager is a helper used to control rolling restarts of the servers of a deployment.
+type restartManager struct {
+	deploymentChecks
+	mutex             sync.Mutex
+	restartServerType definitions.ServerType
+	restartNeeded     bool
+	cbTrigger         trigger.Trigger
+}
+
+// StartDatabaseRestart is called to start a rolling restart of all servers of the given type
+// (or all arangod servers for client.RestartTypeAll).
+func (m *restartManager) StartDatabaseRestart(ctx context.Context, serverType client.ServerType) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	config, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.HasAgency() {
+		// Without an agency there is no restart plan
+		return maskAny(client.NewBadRequestError("Restart needs an agency"))
+	}
+	entries, err := createRestartPlanEntries(config, mode, serverType)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Check health
+	if mode.IsClusterMode() {
+		if err := m.isClusterHealthy(ctx); err != nil {
+			return maskAny(errors.Wrap(err, "Cannot restart unhealthy cluster"))
+		}
+	} else if err := m.isAgencyHealth(ctx); err != nil {
+		return maskAny(errors.Wrap(err, "Cannot restart with unhealthy agency"))
+	}
+
+	// Create an agency lock, so we know we're the only one to create a plan.
+	// The lock is shared with the upgrade manager, so restarts & upgrades cannot be started at the same time.
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	lock, err := agency.NewLock(m, api, upgradeManagerLockKey, "", upgradeManagerLockTTL)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := lock.Lock(ctx); err != nil {
+		m.log.Debug().Err(err).Msg("Lock failed")
+		return maskAny(err)
+	}
+	defer lock.Unlock(context.Background())
+
+	// Check existing plans
+	plan, err := m.readRestartPlan(ctx)
+	if err != nil && !agency.IsKeyNotFound(err) {
+		return errors.Wrap(err, "Failed to read restart plan")
+	}
+	if !plan.IsReady() {
+		return maskAny(client.NewBadRequestError("Current restart plan has not finished yet"))
+	}
+	upgradePlan, err := m.readUpgradePlan(ctx)
+	if err != nil && !agency.IsKeyNotFound(err) {
+		return errors.Wrap(err, "Failed to read upgrade plan")
+	}
+	if !upgradePlan.IsReady() {
+		return maskAny(client.NewBadRequestError("Current upgrade plan has not finished yet"))
+	}
+
+	// Create & save restart plan
+	plan = RestartPlan{
+		CreatedAt:      time.Now(),
+		LastModifiedAt: time.Now(),
+		ServerType:     serverType,
+		Entries:        entries,
+	}
+	overwrite := true
+	if _, err := m.writeRestartPlan(ctx, plan, overwrite); err != nil {
+		return errors.Wrap(err, "Failed to write restart plan")
+	}
+
+	// Inform user
+	m.log.Info().Msgf("Created plan to restart %d servers (%s)", len(entries), serverType)
+
+	return nil
+}
+
+// RetryDatabaseRestart resets a failure mark in the existing restart plan
+// such that the starters will retry the restart once more.
+func (m *restartManager) RetryDatabaseRestart(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.HasAgency() {
+		// Without an agency there is no restart plan to retry
+		return maskAny(client.NewBadRequestError("Retry needs an agency"))
+	}
+
+	// Like RetryDatabaseUpgrade, we rely on the revision condition instead of an agency lock.
+	plan, err := m.readRestartPlan(ctx)
+	if agency.IsKeyNotFound(err) {
+		return maskAny(client.NewBadRequestError("There is no restart plan"))
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to read restart plan")
+	}
+	if !plan.IsFailed() {
+		return maskAny(client.NewBadRequestError("The restart plan has not failed"))
+	}
+
+	// Reset failures and write plan
+	plan.ResetFailures()
+	overwrite := false
+	if _, err := m.writeRestartPlan(ctx, plan, overwrite); driver.IsPreconditionFailed(err) {
+		return errors.Wrap(err, "Failed to write restart plan because is was outdated or removed")
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to write restart plan")
+	}
+
+	// Inform user
+	m.log.Info().Msg("Reset failures in restart plan so it can be retried")
+
+	return nil
+}
+
+// AbortDatabaseRestart removes the existing restart plan.
+// Note that Starters working on an entry of the restart
+// will finish that entry.
+// If there is no plan, a NotFoundError will be returned.
+func (m *restartManager) AbortDatabaseRestart(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.HasAgency() {
+		// Without an agency there is no restart plan to abort
+		return maskAny(client.NewBadRequestError("Abort needs an agency"))
+	}
+
+	if _, err := m.readRestartPlan(ctx); agency.IsKeyNotFound(err) {
+		return maskAny(client.NewNotFoundError("There is no restart plan"))
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to read restart plan")
+	}
+
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := api.RemoveKey(ctx, restartPlanKey); err != nil {
+		return errors.Wrap(err, "Failed to remove restart plan")
+	}
+
+	// Inform user
+	m.log.Info().Msg("Removed restart plan")
+
+	return nil
+}
+
+// Status returns the current status of the restart process.
+func (m *restartManager) Status(ctx context.Context) (client.RestartStatus, error) {
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.HasAgency() {
+		return client.RestartStatus{}, maskAny(client.NewPreconditionFailedError("Mode does not support agency based restarts"))
+	}
+
+	plan, err := m.readRestartPlan(ctx)
+	if agency.IsKeyNotFound(err) {
+		return client.RestartStatus{}, maskAny(client.NewNotFoundError("There is no restart plan"))
+	} else if err != nil {
+		return client.RestartStatus{}, maskAny(err)
+	}
+	result := client.RestartStatus{
+		Ready:      plan.IsReady(),
+		Failed:     plan.IsFailed(),
+		ServerType: plan.ServerType,
+	}
+	for _, entry := range plan.Entries {
+		if entry.Failures > 0 && result.Reason == "" {
+			result.Reason = entry.Reason
+		}
+		statusServer, err := entry.CreateStatusServer(m.upgradeManagerContext)
+		if err != nil {
+			return client.RestartStatus{}, maskAny(err)
+		}
+		result.ServersRemaining = append(result.ServersRemaining, *statusServer)
+	}
+	for _, entry := range plan.FinishedEntries {
+		statusServer, err := entry.CreateStatusServer(m.upgradeManagerContext)
+		if err != nil {
+			return client.RestartStatus{}, maskAny(err)
+		}
+		result.ServersRestarted = append(result.ServersRestarted, *statusServer)
+	}
+	return result, nil
+}
+
+// IsServerRestartInProgress returns true when the restart manager is busy restarting the server of given type.
+func (m *restartManager) IsServerRestartInProgress(serverType definitions.ServerType) bool {
+	return m.restartServerType == serverType
+}
+
+// ServerStarted is called when a server of given type has been started.
+func (m *restartManager) ServerStarted(serverType definitions.ServerType) {
+	if m.restartServerType == serverType {
+		m.restartNeeded = false
+	}
+}
+
+// readRestartPlan reads the current restart plan from the agency.
+func (m *restartManager) readRestartPlan(ctx context.Context) (RestartPlan, error) {
+	var plan RestartPlan
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return RestartPlan{}, maskAny(err)
+	}
+	if err := api.ReadKey(ctx, restartPlanKey, &plan); err != nil {
+		return RestartPlan{}, maskAny(err)
+	}
+	return plan, nil
+}
+
+// writeRestartPlan writes the given restart plan to the agency.
+// Unless overwrite is true, the revision currently in the agency must match
+// the revision in the given plan. The revision is increased just before writing.
+func (m *restartManager) writeRestartPlan(ctx context.Context, plan RestartPlan, overwrite bool) (RestartPlan, error) {
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return RestartPlan{}, maskAny(err)
+	}
+	oldRevision := plan.Revision
+	plan.Revision++
+	plan.LastModifiedAt = time.Now()
+	var condition agency.WriteCondition
+	if !overwrite {
+		condition = condition.IfEqualTo(restartPlanRevisionKey, oldRevision)
+	}
+	if err := api.WriteKey(ctx, restartPlanKey, plan, 0, condition); err != nil {
+		return RestartPlan{}, maskAny(err)
+	}
+	return plan, nil
+}
+
+// RunWatchRestartPlan keeps watching the restart plan in the agency.
+// Once it detects that this starter has to act, it does.
+func (m *restartManager) RunWatchRestartPlan(ctx context.Context) {
+	_, myPeer, mode := m.upgradeManagerContext.ClusterConfig()
+	ownURL := myPeer.CreateStarterURL("/")
+	if !mode.HasAgency() {
+		// Nothing to do here without an agency
+		return
+	}
+	registeredCallback := false
+	defer func() {
+		if registeredCallback {
+			m.unregisterRestartPlanChangedCallback(ctx, ownURL)
+		}
+	}()
+	for {
+		delay := time.Minute
+		if !registeredCallback {
+			if err := m.registerRestartPlanChangedCallback(ctx, ownURL); err != nil {
+				m.log.Info().Err(err).Msg("Failed to register restart plan changed callback")
+			} else {
+				registeredCallback = true
+			}
+		}
+		plan, err := m.readRestartPlan(ctx)
+		if agency.IsKeyNotFound(err) || plan.IsEmpty() {
+			// Just try later
+		} else if err != nil {
+			m.log.Info().Err(err).Msg("Failed to read restart plan")
+		} else if plan.IsReady() {
+			if !plan.Finished {
+				if err := m.finishRestartPlan(ctx, plan); err != nil {
+					m.log.Error().Err(err).Msg("Failed to finish restart plan")
+				}
+			}
+		} else if plan.IsFailed() {
+			// Plan already failed
+		} else {
+			// Let's inspect the first entry
+			if err := m.processRestartPlan(ctx, plan); err != nil {
+				m.log.Error().Err(err).Msg("Failed to process restart plan entry")
+			}
+			delay = time.Second
+		}
+
+		select {
+		case <-time.After(delay):
+			// Continue
+		case <-m.cbTrigger.Done():
+			// Continue
+		case <-ctx.Done():
+			// Context canceled
+			return
+		}
+	}
+}
+
+// RestartPlanChangedCallback is an agency callback to notify about changes in the restart plan
+func (m *restartManager) RestartPlanChangedCallback() {
+	m.cbTrigger.Trigger()
+}
+
+// registerRestartPlanChangedCallback registers our callback URL with the agency
+func (m *restartManager) registerRestartPlanChangedCallback(ctx context.Context, ownURL string) error {
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	cbURL, err := m.upgradeManagerContext.CreateCallbackURL(ownURL, "/cb/restartPlanChanged")
+	if err != nil {
+		return maskAny(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	if err := api.RegisterChangeCallback(ctx, restartPlanKey, cbURL); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// unregisterRestartPlanChangedCallback removes our callback URL from the agency
+func (m *restartManager) unregisterRestartPlanChangedCallback(ctx context.Context, ownURL string) error {
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	cbURL, err := m.upgradeManagerContext.CreateCallbackURL(ownURL, "/cb/restartPlanChanged")
+	if err != nil {
+		return maskAny(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	if err := api.UnregisterChangeCallback(ctx, restartPlanKey, cbURL); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// processRestartPlan inspects the first entry of the given plan and acts upon
+// it when needed.
+func (m *restartManager) processRestartPlan(ctx context.Context, plan RestartPlan) error {
+	_, myPeer, mode := m.upgradeManagerContext.ClusterConfig()
+	_, isRunning, _ := m.upgradeManagerContext.IsRunningMaster()
+	if !isRunning {
+		return maskAny(fmt.Errorf("Not in running phase"))
+	}
+
+	firstEntry := plan.Entries[0]
+	// We only respond when the peer is ours
+	if firstEntry.PeerID != myPeer.ID {
+		return nil
+	}
+
+	// recordFailure increments the failure count in the first entry and
+	// stored the modified plan.
+	// It then returns the original error.
+	recordFailure := func(err error) error {
+		m.log.Error().Err(err).
+			Str("type", string(firstEntry.Type)).
+			Msg("Restart plan entry failed")
+		plan.Entries[0].Failures++
+		plan.Entries[0].Reason = err.Error()
+		overwrite := false
+		if _, err := m.writeRestartPlan(ctx, plan, overwrite); err != nil {
+			m.log.Error().Err(err).Msg("Failed to write updated plan (recording failure)")
+		}
+		return maskAny(err)
+	}
+
+	serverType, checks, err := m.restartChecks(firstEntry.Type, mode)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Restart the server
+	m.log.Info().Msgf("Restarting %s", serverType)
+	m.restartServerType = serverType
+	m.restartNeeded = true
+	defer func() {
+		m.restartServerType = ""
+		m.restartNeeded = false
+	}()
+	if err := m.upgradeManagerContext.RestartServer(serverType); err != nil {
+		return recordFailure(errors.Wrapf(err, "Failed to restart %s", serverType))
+	}
+
+	// Wait until the server restarted
+	if err := m.waitUntilRestartedServerStarted(ctx); err != nil {
+		return recordFailure(errors.Wrapf(err, "Restart of %s did not succeed", serverType))
+	}
+
+	// Wait until the deployment is healthy again
+	for _, c := range checks {
+		if err := m.waitUntil(ctx, c.predicate, c.errorLogTemplate, nil); err != nil {
+			return recordFailure(errors.Wrap(err, c.failure))
+		}
+	}
+	m.log.Info().Msgf("Finished restarting %s", serverType)
+
+	// Move first entry to finished entries
+	plan.Entries = plan.Entries[1:]
+	plan.FinishedEntries = append(plan.FinishedEntries, firstEntry)
+
+	// Save plan
+	overwrite := false
+	if _, err := m.writeRestartPlan(ctx, plan, overwrite); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// waitUntilRestartedServerStarted waits until the restarted server has been started again.
+func (m *restartManager) waitUntilRestartedServerStarted(ctx context.Context) error {
+	return m.waitUntilServerStarted(ctx, m.restartServerType, func() bool { return !m.restartNeeded })
+}
+
+// finishRestartPlan is called at the end of the restart process.
+func (m *restartManager) finishRestartPlan(ctx context.Context, plan RestartPlan) error {
+	isRunningAsMaster, isRunning, _ := m.upgradeManagerContext.IsRunningMaster()
+	if !isRunning {
+		return maskAny(fmt.Errorf("Not in running phase"))
+	} else if !isRunningAsMaster {
+		return nil
+	}
+
+	// Save plan
+	overwrite := false
+	plan.Finished = true
+	if _, err := m.writeRestartPlan(ctx, plan, overwrite); err != nil {
+		return maskAny(err)
+	}
+
+	// Inform user that we're done
+	m.log.Info().Msg("Restart plan has finished successfully")
+
+	return nil
+}
@@ -0,0 +1,104 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_CreateRestartPlanEntries(t *testing.T) {
+	no := false
+	config := ClusterConfig{
+		AllPeers: []Peer{
+			{ID: "a", HasAgentFlag: true},
+			{ID: "b", HasAgentFlag: true, HasCoordinatorFlag: &no},
+			{ID: "c", HasDBServerFlag: &no},
+		},
+	}
+	entryList := func(entries []UpgradePlanEntry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, string(e.Type)+"/"+e.PeerID)
+		}
+		return result
+	}
+
+	entries, err := createRestartPlanEntries(config, ServiceModeCluster, client.RestartTypeAll)
+	require.NoError(t, err)
+	require.Equal(t, []string{"agent/a", "agent/b", "dbserver/a", "dbserver/b", "coordinator/a", "coordinator/c"}, entryList(entries))
+
+	entries, err = createRestartPlanEntries(config, ServiceModeCluster, client.ServerTypeDBServer)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dbserver/a", "dbserver/b"}, entryList(entries))
+
+	entries, err = createRestartPlanEntries(config, ServiceModeCluster, client.ServerTypeAgent)
+	require.NoError(t, err)
+	require.Equal(t, []string{"agent/a", "agent/b"}, entryList(entries))
+
+	_, err = createRestartPlanEntries(config, ServiceModeCluster, client.ServerTypeSingle)
+	require.Error(t, err)
+	_, err = createRestartPlanEntries(config, ServiceModeCluster, client.ServerTypeSyncMaster)
+	require.Error(t, err)
+	_, err = createRestartPlanEntries(config, ServiceModeActiveFailover, client.ServerTypeCoordinator)
+	require.Error(t, err)
+}
+
+func Test_RestartPlanResetFailures(t *testing.T) {
+	plan := RestartPlan{
+		Entries: []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeAgent, Failures: 2, Reason: "timeout"}},
+	}
+	require.True(t, plan.IsFailed())
+	plan.ResetFailures()
+	require.False(t, plan.IsFailed())
+	require.Empty(t, plan.Entries[0].Reason)
+}
+
+// serverStatusContext is an UpgradeManagerContext that only provides the status of servers.
+type serverStatusContext struct {
+	UpgradeManagerContext
+	status map[definitions.ServerType]ProcessWrapperStatus
+}
+
+func (c serverStatusContext) ServerStatus(serverType definitions.ServerType) (ProcessWrapperStatus, bool) {
+	status, found := c.status[serverType]
+	return status, found
+}
+
+func Test_RestartManagerWaitUntilServerStarted(t *testing.T) {
+	ctx := serverStatusContext{status: map[definitions.ServerType]ProcessWrapperStatus{
+		definitions.ServerTypeDBServer: {Failed: true},
+	}}
+	m := &restartManager{deploymentChecks: deploymentChecks{upgradeManagerContext: ctx}}
+
+	// A server that has been started is done
+	m.restartServerType, m.restartNeeded = definitions.ServerTypeCoordinator, false
+	require.NoError(t, m.waitUntilRestartedServerStarted(context.Background()))
+
+	// A server the starter has given up on fails the wait, instead of blocking
+	m.restartServerType, m.restartNeeded = definitions.ServerTypeDBServer, true
+	require.Error(t, m.waitUntilRestartedServerStarted(context.Background()))
+}
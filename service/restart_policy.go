@@ -0,0 +1,233 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+const (
+	DefaultRestartMaxFailures        = 10
+	DefaultRestartFailureWindow      = time.Minute * 10
+	DefaultRestartInitialBackoff     = time.Second
+	DefaultRestartMaxBackoff         = time.Minute
+	restartBackoffJitter             = 0.5
+	restartBackoffResetAfter         = time.Second * 30 // Uptime after which the backoff is reset
+	restartPolicyServerTypeSeparator = "="
+)
+
+// RestartPolicy specifies how the starter restarts a server that has terminated unexpectedly.
+type RestartPolicy struct {
+	MaxFailures    int           // Maximum number of failures within FailureWindow before the server is marked failed (0 means unlimited)
+	FailureWindow  time.Duration // Time window in which failures are counted
+	InitialBackoff time.Duration // Delay before the first restart after a quick failure
+	MaxBackoff     time.Duration // Maximum delay between restarts
+}
+
+// DefaultRestartPolicy returns the restart policy used when nothing else is specified.
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxFailures:    DefaultRestartMaxFailures,
+		FailureWindow:  DefaultRestartFailureWindow,
+		InitialBackoff: DefaultRestartInitialBackoff,
+		MaxBackoff:     DefaultRestartMaxBackoff,
+	}
+}
+
+// NewBackOff creates an exponential backoff (with jitter) for this policy.
+func (p RestartPolicy) NewBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = p.InitialBackoff
+	b.MaxInterval = p.MaxBackoff
+	b.RandomizationFactor = restartBackoffJitter
+	b.MaxElapsedTime = 0 // Never stop
+	b.Reset()
+	return b
+}
+
+// RestartPolicies holds the restart policy per server type.
+type RestartPolicies map[definitions.ServerType]RestartPolicy
+
+// ForServerType returns the restart policy for the given server type.
+func (p RestartPolicies) ForServerType(serverType definitions.ServerType) RestartPolicy {
+	if policy, found := p[serverType]; found {
+		return policy
+	}
+	if serverType == definitions.ServerTypeResilientSingle {
+		if policy, found := p[definitions.ServerTypeSingle]; found {
+			return policy
+		}
+	}
+	return DefaultRestartPolicy()
+}
+
+// ParseRestartPolicies creates restart policies for all server types from the given option values.
+// Every value is either `<value>` (applies to all server types) or `<server-type>=<value>`
+// (applies only to the given server type).
+func ParseRestartPolicies(maxFailures, failureWindow, initialBackoff, maxBackoff []string) (RestartPolicies, error) {
+	serverTypes := []definitions.ServerType{
+		definitions.ServerTypeAgent,
+		definitions.ServerTypeDBServer,
+		definitions.ServerTypeCoordinator,
+		definitions.ServerTypeSingle,
+		definitions.ServerTypeResilientSingle,
+		definitions.ServerTypeSyncMaster,
+		definitions.ServerTypeSyncWorker,
+	}
+	result := make(RestartPolicies)
+	for _, t := range serverTypes {
+		result[t] = DefaultRestartPolicy()
+	}
+	apply := func(values []string, set func(p *RestartPolicy, value string) error) error {
+		for _, v := range values {
+			targets := serverTypes
+			if idx := strings.Index(v, restartPolicyServerTypeSeparator); idx >= 0 {
+				serverType := definitions.ServerType(strings.TrimSpace(v[:idx]))
+				if !isKnownServerType(serverType, serverTypes) {
+					return maskAny(fmt.Errorf("Unknown server type '%s'", serverType))
+				}
+				targets = []definitions.ServerType{serverType}
+				v = v[idx+1:]
+			}
+			for _, t := range targets {
+				policy := result[t]
+				if err := set(&policy, strings.TrimSpace(v)); err != nil {
+					return maskAny(err)
+				}
+				result[t] = policy
+			}
+		}
+		return nil
+	}
+	parseDuration := func(v string) (time.Duration, error) {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, maskAny(err)
+		}
+		if d < 0 {
+			return 0, maskAny(fmt.Errorf("Duration '%s' must not be negative", v))
+		}
+		return d, nil
+	}
+	if err := apply(maxFailures, func(p *RestartPolicy, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return maskAny(err)
+		}
+		if n < 0 {
+			return maskAny(fmt.Errorf("Maximum number of failures '%s' must not be negative", v))
+		}
+		p.MaxFailures = n
+		return nil
+	}); err != nil {
+		return nil, maskAny(err)
+	}
+	if err := apply(failureWindow, func(p *RestartPolicy, v string) (err error) {
+		p.FailureWindow, err = parseDuration(v)
+		return
+	}); err != nil {
+		return nil, maskAny(err)
+	}
+	if err := apply(initialBackoff, func(p *RestartPolicy, v string) (err error) {
+		p.InitialBackoff, err = parseDuration(v)
+		return
+	}); err != nil {
+		return nil, maskAny(err)
+	}
+	if err := apply(maxBackoff, func(p *RestartPolicy, v string) (err error) {
+		p.MaxBackoff, err = parseDuration(v)
+		return
+	}); err != nil {
+		return nil, maskAny(err)
+	}
+	for t, p := range result {
+		if p.MaxBackoff < p.InitialBackoff {
+			return nil, maskAny(fmt.Errorf("Maximum restart backoff of %s must not be lower than initial restart backoff", t))
+		}
+	}
+	return result, nil
+}
+
+// isKnownServerType returns true if the given server type is part of the given list.
+func isKnownServerType(serverType definitions.ServerType, list []definitions.ServerType) bool {
+	for _, t := range list {
+		if t == serverType {
+			return true
+		}
+	}
+	return false
+}
+
+// restartTracker keeps track of failures of a server according to a restart policy.
+type restartTracker struct {
+	policy   RestartPolicy
+	backoff  backoff.BackOff
+	failures []time.Time
+}
+
+// newRestartTracker creates a restart tracker for the given policy.
+func newRestartTracker(policy RestartPolicy) *restartTracker {
+	return &restartTracker{
+		policy:  policy,
+		backoff: policy.NewBackOff(),
+	}
+}
+
+// RecordFailure records an unexpected termination of the server after running for the given uptime.
+// Returns the delay before the server should be restarted and true if the server should
+// be given up on (marked failed) instead.
+func (t *restartTracker) RecordFailure(now time.Time, uptime time.Duration) (time.Duration, bool) {
+	t.failures = append(t.failures, now)
+	t.pruneFailures(now)
+	if t.policy.MaxFailures > 0 && len(t.failures) > t.policy.MaxFailures {
+		return 0, true
+	}
+	if uptime >= restartBackoffResetAfter {
+		// Server has been running fine for a while, start again with the initial backoff
+		t.backoff.Reset()
+	}
+	return t.backoff.NextBackOff(), false
+}
+
+// Failures returns the number of failures within the failure window.
+func (t *restartTracker) Failures(now time.Time) int {
+	t.pruneFailures(now)
+	return len(t.failures)
+}
+
+// pruneFailures removes all failures outside the failure window.
+func (t *restartTracker) pruneFailures(now time.Time) {
+	if t.policy.FailureWindow <= 0 {
+		return
+	}
+	idx := 0
+	for idx < len(t.failures) && now.Sub(t.failures[idx]) > t.policy.FailureWindow {
+		idx++
+	}
+	t.failures = t.failures[idx:]
+}
@@ -0,0 +1,81 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_ParseRestartPolicies(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		p, err := ParseRestartPolicies(nil, nil, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultRestartPolicy(), p.ForServerType(definitions.ServerTypeDBServer))
+	})
+
+	t.Run("Per server type", func(t *testing.T) {
+		p, err := ParseRestartPolicies([]string{"5", "dbserver=2"}, []string{"agent=1h"}, nil, []string{"30s"})
+		require.NoError(t, err)
+		require.Equal(t, 5, p.ForServerType(definitions.ServerTypeAgent).MaxFailures)
+		require.Equal(t, 2, p.ForServerType(definitions.ServerTypeDBServer).MaxFailures)
+		require.Equal(t, time.Hour, p.ForServerType(definitions.ServerTypeAgent).FailureWindow)
+		require.Equal(t, DefaultRestartFailureWindow, p.ForServerType(definitions.ServerTypeDBServer).FailureWindow)
+		require.Equal(t, 30*time.Second, p.ForServerType(definitions.ServerTypeCoordinator).MaxBackoff)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseRestartPolicies([]string{"foo=1"}, nil, nil, nil)
+		require.Error(t, err)
+		_, err = ParseRestartPolicies([]string{"-1"}, nil, nil, nil)
+		require.Error(t, err)
+		_, err = ParseRestartPolicies(nil, nil, []string{"2m"}, []string{"1m"})
+		require.Error(t, err)
+	})
+}
+
+func Test_RestartTracker(t *testing.T) {
+	tracker := newRestartTracker(RestartPolicy{
+		MaxFailures:    2,
+		FailureWindow:  time.Minute,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 4,
+	})
+	now := time.Now()
+
+	delay, giveUp := tracker.RecordFailure(now, 0)
+	require.False(t, giveUp)
+	require.True(t, delay > 0 && delay <= time.Second*4)
+
+	// Failure outside of the window is forgotten
+	_, giveUp = tracker.RecordFailure(now.Add(time.Minute*2), 0)
+	require.False(t, giveUp)
+	require.Equal(t, 1, tracker.Failures(now.Add(time.Minute*2)))
+
+	_, giveUp = tracker.RecordFailure(now.Add(time.Minute*2+time.Second), 0)
+	require.False(t, giveUp)
+	_, giveUp = tracker.RecordFailure(now.Add(time.Minute*2+time.Second*2), 0)
+	require.True(t, giveUp)
+}
@@ -52,6 +52,10 @@ type Runner interface {
 	// Start a server with given arguments
 	Start(ctx context.Context, processType definitions.ProcessType, command string, args []string, envs map[string]string, volumes []Volume, ports []int, containerName, serverDir string, output io.Writer) (Process, error)
 
+	// WithArangodImage returns a runner that starts arangod servers from the given docker image.
+	// Runners that do not use docker images return themselves.
+	WithArangodImage(image string) Runner
+
 	// Create a command that a user should use to start a slave arangodb instance.
 	CreateStartArangodbCommand(myDataDir string, index int, masterIP, masterPort, starterImageName string, clusterConfig ClusterConfig) string
 
@@ -166,7 +166,11 @@ func (r *dockerRunner) Start(ctx context.Context, processType definitions.Proces
 	default:
 		return nil, maskAny(fmt.Errorf("Unknown process type: %s", processType))
 	}
+	return r.startImage(ctx, image, command, args, envs, volumes, ports, containerName, serverDir, output)
+}
 
+// startImage starts a container from the given image, pulling the image first when needed.
+func (r *dockerRunner) startImage(ctx context.Context, image string, command string, args []string, envs map[string]string, volumes []Volume, ports []int, containerName, serverDir string, output io.Writer) (Process, error) {
 	// Pull docker image
 	switch r.imagePullPolicy {
 	case ImagePullPolicyAlways:
@@ -217,6 +221,30 @@ func (r *dockerRunner) Start(ctx context.Context, processType definitions.Proces
 	return result, nil
 }
 
+// WithArangodImage returns a runner that starts arangod servers from the given docker image.
+func (r *dockerRunner) WithArangodImage(image string) Runner {
+	return &dockerImageRunner{
+		dockerRunner: r,
+		arangodImage: image,
+	}
+}
+
+// dockerImageRunner is a dockerRunner that starts arangod servers from a specific image.
+type dockerImageRunner struct {
+	*dockerRunner
+	arangodImage string
+}
+
+// Start a server with given arguments
+func (r *dockerImageRunner) Start(ctx context.Context, processType definitions.ProcessType, command string, args []string, envs map[string]string, volumes []Volume, ports []int, containerName, serverDir string, output io.Writer) (Process, error) {
+	if processType != definitions.ProcessTypeArangod {
+		return r.dockerRunner.Start(ctx, processType, command, args, envs, volumes, ports, containerName, serverDir, output)
+	}
+	// Start gc (once)
+	r.startGC()
+	return r.startImage(ctx, r.arangodImage, command, args, envs, volumes, ports, containerName, serverDir, output)
+}
+
 // startGC ensures GC is started (only once)
 func (r *dockerRunner) startGC() {
 	// Start gc (once)
@@ -419,7 +447,7 @@ func (r *dockerRunner) Cleanup() error {
 			Force:         true,
 			RemoveVolumes: true,
 		}); err != nil && !isNoSuchContainer(err) {
-			r.log.Warn().Err(err).Msgf("Failed to remove container %s: %#v", id)
+			r.log.Warn().Err(err).Msgf("Failed to remove container %s", id)
 		}
 	}
 	r.containerIDs = make(map[string]time.Time)
@@ -149,6 +149,11 @@ func (r *processRunner) CreateStartArangodbCommand(myDataDir string, index int,
 	return fmt.Sprintf("arangodb --starter.data-dir=%s --starter.join %s", dataDir, addr)
 }
 
+// WithArangodImage returns the runner itself, since processes are not started from images.
+func (r *processRunner) WithArangodImage(image string) Runner {
+	return r
+}
+
 // Cleanup after all processes are dead and have been cleaned themselves
 func (r *processRunner) Cleanup() error {
 	// Nothing here
@@ -28,6 +28,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"reflect"
 	"sync"
 	"time"
@@ -51,6 +52,7 @@ type runtimeClusterManager struct {
 	lastMasterURL    string
 	avoidBeingMaster bool // If set, this peer will not try to become master
 	interruptChan    chan struct{}
+	lastIteration    time.Time // Time the last iteration of the Run loop started
 }
 
 // runtimeClusterManagerContext provides a context for the runtimeClusterManager.
@@ -65,6 +67,13 @@ type runtimeClusterManagerContext interface {
 
 	// UpdateClusterConfig updates the current cluster configuration.
 	UpdateClusterConfig(ClusterConfig)
+
+	// PeerHTTPClient returns the HTTP client used for requests to other starters.
+	PeerHTTPClient() *http.Client
+
+	// CreateCallbackURL returns the URL of the callback with given path on the given starter URL,
+	// which other starters & the agency can use.
+	CreateCallbackURL(ownURL, path string) (string, error)
 }
 
 // Create a client for the agency
@@ -160,7 +169,7 @@ func (s *runtimeClusterManager) updateClusterConfiguration(ctx context.Context,
 		return maskAny(err)
 	}
 	// Perform request
-	r, err := httpClient.Get(helloURL)
+	r, err := s.runtimeContext.PeerHTTPClient().Get(helloURL)
 	if err != nil {
 		return maskAny(err)
 	}
@@ -192,7 +201,7 @@ func (s *runtimeClusterManager) registerMasterChangedCallback(ctx context.Contex
 		return maskAny(err)
 	}
 	// Register callback
-	cbURL, err := getURLWithPath(ownURL, "/cb/masterChanged")
+	cbURL, err := s.runtimeContext.CreateCallbackURL(ownURL, "/cb/masterChanged")
 	if err != nil {
 		return maskAny(err)
 	}
@@ -212,7 +221,7 @@ func (s *runtimeClusterManager) unregisterMasterChangedCallback(ctx context.Cont
 		return maskAny(err)
 	}
 	// Register callback
-	cbURL, err := getURLWithPath(ownURL, "/cb/masterChanged")
+	cbURL, err := s.runtimeContext.CreateCallbackURL(ownURL, "/cb/masterChanged")
 	if err != nil {
 		return maskAny(err)
 	}
@@ -251,6 +260,9 @@ func (s *runtimeClusterManager) Run(ctx context.Context, log zerolog.Logger, run
 			// Stop requested
 			return
 		}
+		s.mutex.Lock()
+		s.lastIteration = time.Now()
+		s.mutex.Unlock()
 
 		// Try to get master URL
 		masterURL, err := s.getMasterURL(ctx)
@@ -369,6 +381,14 @@ func (s *runtimeClusterManager) GetMasterURL() string {
 	return s.lastMasterURL
 }
 
+// LastIteration returns the time the last iteration of the Run loop started.
+// It returns a zero time when the loop has not started (e.g. in single mode).
+func (s *runtimeClusterManager) LastIteration() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastIteration
+}
+
 // AvoidBeingMaster instructs the runtime cluster manager to avoid
 // becoming master and when it is master, to give that up.
 func (s *runtimeClusterManager) AvoidBeingMaster() {
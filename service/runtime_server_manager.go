@@ -35,8 +35,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
+	"github.com/arangodb-helper/arangodb/client"
 	"github.com/arangodb-helper/arangodb/pkg/definitions"
 	"github.com/arangodb-helper/arangodb/pkg/logging"
 	"github.com/arangodb-helper/arangodb/service/actions"
@@ -54,6 +56,104 @@ type runtimeServerManager struct {
 	syncWorkerProc  ProcessWrapper
 
 	stopping bool
+
+	startProcessWrapper func(myPeer Peer, serverType definitions.ServerType) ProcessWrapper // Starts a server of given type (set by Run)
+	rolesMutex          sync.Mutex                                                          // Mutex used to protect dbserverProc, coordinatorProc, stopping & startProcessWrapper
+	applyRolesMutex     sync.Mutex                                                          // Mutex used to serialize applying roles of the peer
+	removingMutex       sync.Mutex                                                          // Mutex used to protect removingServers
+	removingServers     map[definitions.ServerType]bool                                     // Servers that are being removed from the cluster
+
+	logRotationMutex    sync.Mutex                     // Mutex used to protect the log rotation counters
+	logRotationRuns     int                            // Number of times log files have been rotated
+	logRotations        map[definitions.ServerType]int // Number of successful log rotations per server type
+	logRotationFailures map[definitions.ServerType]int // Number of failed log rotations per server type
+}
+
+// LogRotationCounters holds the log rotation counters of a runtimeServerManager.
+type LogRotationCounters struct {
+	Runs      int                            // Number of times log files have been rotated
+	Rotations map[definitions.ServerType]int // Number of successful log rotations per server type
+	Failures  map[definitions.ServerType]int // Number of failed log rotations per server type
+}
+
+// processWrappers returns all started process wrappers, keyed by server type.
+func (s *runtimeServerManager) processWrappers() map[definitions.ServerType]ProcessWrapper {
+	s.rolesMutex.Lock()
+	defer s.rolesMutex.Unlock()
+
+	result := make(map[definitions.ServerType]ProcessWrapper)
+	add := func(serverType definitions.ServerType, w ProcessWrapper) {
+		if w != nil {
+			result[serverType] = w
+		}
+	}
+	add(definitions.ServerTypeAgent, s.agentProc)
+	add(definitions.ServerTypeDBServer, s.dbserverProc)
+	add(definitions.ServerTypeCoordinator, s.coordinatorProc)
+	add(definitions.ServerTypeSingle, s.singleProc)
+	add(definitions.ServerTypeSyncMaster, s.syncMasterProc)
+	add(definitions.ServerTypeSyncWorker, s.syncWorkerProc)
+	return result
+}
+
+// processWrapperByType returns the process wrapper of the server of given type (if any).
+func (s *runtimeServerManager) processWrapperByType(serverType definitions.ServerType) ProcessWrapper {
+	s.rolesMutex.Lock()
+	defer s.rolesMutex.Unlock()
+
+	switch serverType {
+	case definitions.ServerTypeAgent:
+		return s.agentProc
+	case definitions.ServerTypeDBServer:
+		return s.dbserverProc
+	case definitions.ServerTypeCoordinator:
+		return s.coordinatorProc
+	case definitions.ServerTypeSingle, definitions.ServerTypeResilientSingle:
+		return s.singleProc
+	case definitions.ServerTypeSyncMaster:
+		return s.syncMasterProc
+	case definitions.ServerTypeSyncWorker:
+		return s.syncWorkerProc
+	default:
+		return nil
+	}
+}
+
+// LogRotationCounters returns a copy of the log rotation counters.
+func (s *runtimeServerManager) LogRotationCounters() LogRotationCounters {
+	s.logRotationMutex.Lock()
+	defer s.logRotationMutex.Unlock()
+
+	result := LogRotationCounters{
+		Runs:      s.logRotationRuns,
+		Rotations: make(map[definitions.ServerType]int),
+		Failures:  make(map[definitions.ServerType]int),
+	}
+	for k, v := range s.logRotations {
+		result.Rotations[k] = v
+	}
+	for k, v := range s.logRotationFailures {
+		result.Failures[k] = v
+	}
+	return result
+}
+
+// countLogRotation records the outcome of a log rotation of a server of given type.
+func (s *runtimeServerManager) countLogRotation(serverType definitions.ServerType, failed bool) {
+	s.logRotationMutex.Lock()
+	defer s.logRotationMutex.Unlock()
+
+	if failed {
+		if s.logRotationFailures == nil {
+			s.logRotationFailures = make(map[definitions.ServerType]int)
+		}
+		s.logRotationFailures[serverType]++
+	} else {
+		if s.logRotations == nil {
+			s.logRotations = make(map[definitions.ServerType]int)
+		}
+		s.logRotations[serverType]++
+	}
 }
 
 // runtimeServerManagerContext provides a context for the runtimeServerManager.
@@ -82,6 +182,13 @@ type runtimeServerManagerContext interface {
 	// UpgradeManager returns the upgrade manager service.
 	UpgradeManager() UpgradeManager
 
+	// RestartManager returns the restart manager service.
+	RestartManager() RestartManager
+
+	// ArangodBinary returns the arangod executable (or docker image) switched to for an upgrade,
+	// or an empty string when the configured one is used.
+	ArangodBinary() string
+
 	// TestInstance checks the `up` status of an arangod server instance.
 	TestInstance(ctx context.Context, serverType definitions.ServerType, address string, port int,
 		statusChanged chan StatusItem) (up, correctRole bool, version, role, mode string, isLeader bool, statusTrail []int, cancelled bool)
@@ -172,6 +279,16 @@ func startServer(ctx context.Context, log zerolog.Logger, runtimeContext runtime
 		}
 		confVolumes = append(confVolumes, secretFileVolumes...)
 	}
+	var containerEncryptionKeyFolder string
+	if processType == definitions.ProcessTypeArangod {
+		var err error
+		var keyFolderVolumes []Volume
+		keyFolderVolumes, containerEncryptionKeyFolder, err = createRocksDBEncryptionKeyFolder(log, bsCfg, myHostDir, myContainerDir, arangodConfig, features)
+		if err != nil {
+			return nil, false, maskAny(err)
+		}
+		confVolumes = append(confVolumes, keyFolderVolumes...)
+	}
 
 	// Collect volumes
 	v := collectServerConfigVolumes(serverType, arangodConfig)
@@ -181,8 +298,9 @@ func startServer(ctx context.Context, log zerolog.Logger, runtimeContext runtime
 	clusterConfig, myPeer, _ := runtimeContext.ClusterConfig()
 	upgradeManager := runtimeContext.UpgradeManager()
 	databaseAutoUpgrade := upgradeManager.ServerDatabaseAutoUpgrade(serverType)
+	runner, config = withArangodBinary(runner, config, runtimeContext.ArangodBinary())
 	args, err := createServerArgs(log, config, clusterConfig, myContainerDir, myContainerLogFile, myPeer.ID, myHostAddress, strconv.Itoa(myPort), serverType, arangodConfig,
-		containerSecretFileName, bsCfg.RecoveryAgentID, databaseAutoUpgrade, features)
+		containerSecretFileName, containerEncryptionKeyFolder, bsCfg.RecoveryAgentID, databaseAutoUpgrade, features)
 	if err != nil {
 		return nil, false, maskAny(err)
 	}
@@ -203,7 +321,10 @@ func startServer(ctx context.Context, log zerolog.Logger, runtimeContext runtime
 	if databaseAutoUpgrade {
 		// Notify the context that we've succesfully started a server with database.auto-upgrade on.
 		upgradeManager.ServerDatabaseAutoUpgradeStarter(serverType)
+	} else {
+		upgradeManager.ServerStarted(serverType)
 	}
+	runtimeContext.RestartManager().ServerStarted(serverType)
 	return p, false, nil
 }
 
@@ -257,6 +378,7 @@ func (s *runtimeServerManager) rotateLogFile(ctx context.Context, log zerolog.Lo
 	logPath, err := runtimeContext.serverHostLogFile(serverType)
 	if err != nil {
 		log.Debug().Err(err).Msgf("Failed to get host log file for '%s'", serverType)
+		s.countLogRotation(serverType, true)
 		return
 	}
 	log.Debug().Msgf("Rotating %s log file: %s", serverType, logPath)
@@ -273,7 +395,7 @@ func (s *runtimeServerManager) rotateLogFile(ctx context.Context, log zerolog.Lo
 			if i == filesToKeep {
 				// Remove file
 				if err := os.Remove(logPathX); err != nil {
-					log.Error().Err(err).Msgf("Failed to remove %s: %s", logPathX)
+					log.Error().Err(err).Msgf("Failed to remove %s", logPathX)
 				} else {
 					log.Debug().Msgf("Removed old log file: %s", logPathX)
 				}
@@ -292,14 +414,19 @@ func (s *runtimeServerManager) rotateLogFile(ctx context.Context, log zerolog.Lo
 	// Send HUP signal
 	if err := p.Hup(); err != nil {
 		log.Error().Err(err).Msg("Failed to send HUP signal")
+		s.countLogRotation(serverType, true)
+		return
 	}
-	return
+	s.countLogRotation(serverType, false)
 }
 
 // RotateLogFiles rotates the log files of all servers
 func (s *runtimeServerManager) RotateLogFiles(ctx context.Context, log zerolog.Logger, logService logging.Service, runtimeContext runtimeServerManagerContext, config Config) {
 	log.Info().Msg("Rotating log files...")
 	logService.RotateLogFiles()
+	s.logRotationMutex.Lock()
+	s.logRotationRuns++
+	s.logRotationMutex.Unlock()
 	_, myPeer, _ := runtimeContext.ClusterConfig()
 	if myPeer == nil {
 		log.Error().Msg("Cannot find my own peer in cluster configuration")
@@ -319,12 +446,12 @@ func (s *runtimeServerManager) RotateLogFiles(ctx context.Context, log zerolog.L
 				s.rotateLogFile(ctx, log, runtimeContext, *myPeer, definitions.ServerTypeSingle, p, config.LogRotateFilesToKeep)
 			}
 		}
-		if w := s.coordinatorProc; w != nil {
+		if w := s.processWrapperByType(definitions.ServerTypeCoordinator); w != nil {
 			if p := w.Process(); p != nil {
 				s.rotateLogFile(ctx, log, runtimeContext, *myPeer, definitions.ServerTypeCoordinator, p, config.LogRotateFilesToKeep)
 			}
 		}
-		if w := s.dbserverProc; w != nil {
+		if w := s.processWrapperByType(definitions.ServerTypeDBServer); w != nil {
 			if p := w.Process(); p != nil {
 				s.rotateLogFile(ctx, log, runtimeContext, *myPeer, definitions.ServerTypeDBServer, p, config.LogRotateFilesToKeep)
 			}
@@ -358,14 +485,21 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 		}
 
 		// Start DBserver:
-		if bsCfg.StartDBserver == nil || *bsCfg.StartDBserver {
-			s.dbserverProc = NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, *myPeer, definitions.ServerTypeDBServer, time.Minute)
+		// The roles of the peer follow the start options, unless they have been changed at runtime.
+		if myPeer.HasDBServer() {
+			w := NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, *myPeer, definitions.ServerTypeDBServer, time.Minute)
+			s.rolesMutex.Lock()
+			s.dbserverProc = w
+			s.rolesMutex.Unlock()
 			time.Sleep(time.Second)
 		}
 
 		// Start Coordinator:
-		if bsCfg.StartCoordinator == nil || *bsCfg.StartCoordinator {
-			s.coordinatorProc = NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, *myPeer, definitions.ServerTypeCoordinator, time.Minute)
+		if myPeer.HasCoordinator() {
+			w := NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, *myPeer, definitions.ServerTypeCoordinator, time.Minute)
+			s.rolesMutex.Lock()
+			s.coordinatorProc = w
+			s.rolesMutex.Unlock()
 		}
 
 		// Start sync master
@@ -393,9 +527,19 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 		s.singleProc = NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, *myPeer, definitions.ServerTypeSingle, time.Minute)
 	}
 
+	// Roles of the peer can be applied once the initial servers have been started
+	s.rolesMutex.Lock()
+	s.startProcessWrapper = func(myPeer Peer, serverType definitions.ServerType) ProcessWrapper {
+		return NewProcessWrapper(s, ctx, log, runtimeContext, runner, config, bsCfg, myPeer, serverType, time.Minute)
+	}
+	s.rolesMutex.Unlock()
+
 	// Wait until context is cancelled, then we'll stop
 	<-ctx.Done()
+	s.rolesMutex.Lock()
 	s.stopping = true
+	dbserverProc, coordinatorProc := s.dbserverProc, s.coordinatorProc
+	s.rolesMutex.Unlock()
 
 	log.Info().Msg("Shutting down services...")
 	timeout := getTimeoutProcessTermination(definitions.ServerTypeSyncWorker)
@@ -426,7 +570,7 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 	}
 
 	timeout = getTimeoutProcessTermination(definitions.ServerTypeCoordinator)
-	if p := s.coordinatorProc; p != nil {
+	if p := coordinatorProc; p != nil {
 		if !p.Wait(timeout) {
 			log.Warn().Str("timeout", timeout.String()).
 				Str("type", definitions.ServerTypeCoordinator).
@@ -435,7 +579,7 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 	}
 
 	timeout = getTimeoutProcessTermination(definitions.ServerTypeDBServer)
-	if p := s.dbserverProc; p != nil {
+	if p := dbserverProc; p != nil {
 		if !p.Wait(timeout) {
 			log.Warn().Str("timeout", timeout.String()).
 				Str("type", definitions.ServerTypeDBServer).
@@ -475,14 +619,14 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 			}
 		}
 	}
-	if w := s.coordinatorProc; w != nil {
+	if w := coordinatorProc; w != nil {
 		if p := w.Process(); p != nil {
 			if err := p.Cleanup(); err != nil {
 				log.Warn().Err(err).Msg("Failed to cleanup coordinator")
 			}
 		}
 	}
-	if w := s.dbserverProc; w != nil {
+	if w := dbserverProc; w != nil {
 		if p := w.Process(); p != nil {
 			if err := p.Cleanup(); err != nil {
 				log.Warn().Err(err).Msg("Failed to cleanup dbserver")
@@ -504,28 +648,144 @@ func (s *runtimeServerManager) Run(ctx context.Context, log zerolog.Logger, runt
 	}
 }
 
+// applyPeerRoles starts the servers of the roles the given peer has, but are not running yet.
+// Servers of the roles the given peer no longer has are removed from the cluster and stopped.
+// A dbserver must have been cleaned out before.
+func (s *runtimeServerManager) applyPeerRoles(ctx context.Context, log zerolog.Logger, runtimeContext runtimeServerManagerContext, myPeer Peer) error {
+	s.applyRolesMutex.Lock()
+	defer s.applyRolesMutex.Unlock()
+
+	roles := []struct {
+		serverType definitions.ServerType
+		wanted     bool
+		proc       *ProcessWrapper
+	}{
+		{definitions.ServerTypeDBServer, myPeer.HasDBServer(), &s.dbserverProc},
+		{definitions.ServerTypeCoordinator, myPeer.HasCoordinator(), &s.coordinatorProc},
+	}
+	for _, r := range roles {
+		s.rolesMutex.Lock()
+		if s.stopping || s.startProcessWrapper == nil {
+			s.rolesMutex.Unlock()
+			return maskAny(errors.Wrap(client.ServiceUnavailableError, "Servers are not running"))
+		}
+		w := *r.proc
+		if r.wanted && w == nil {
+			log.Info().Msgf("Starting %s for new role of this peer", r.serverType)
+			*r.proc = s.startProcessWrapper(myPeer, r.serverType)
+		}
+		s.rolesMutex.Unlock()
+
+		if !r.wanted && w != nil {
+			// The lock is not held while removing the server, since that can take a while.
+			if err := s.removeServer(ctx, log, runtimeContext, myPeer, r.serverType, w); err != nil {
+				return maskAny(err)
+			}
+			s.rolesMutex.Lock()
+			*r.proc = nil
+			s.rolesMutex.Unlock()
+		}
+	}
+	return nil
+}
+
+// removeServer removes the server of given type from the cluster and stops it.
+// Its data directory is renamed, such that a server started later on for the same role
+// does not re-use the identity of the removed server.
+func (s *runtimeServerManager) removeServer(ctx context.Context, log zerolog.Logger, runtimeContext runtimeServerManagerContext, myPeer Peer,
+	serverType definitions.ServerType, w ProcessWrapper) error {
+	s.setServerRemovalInProgress(serverType, true)
+	defer s.setServerRemovalInProgress(serverType, false)
+
+	timeout := getTimeoutProcessTermination(serverType)
+	if w.Status().Running {
+		log.Info().Msgf("Removing %s from the cluster", serverType)
+		c, err := myPeer.CreateClient(runtimeContext, serverType)
+		if err != nil {
+			return maskAny(err)
+		}
+		if err := c.Shutdown(ctx, true); err != nil {
+			log.Warn().Err(err).Msgf("Shutdown request of %s failed", serverType)
+			return maskAny(err)
+		}
+		// Wait until the server has terminated by itself
+		deadline := time.Now().Add(timeout)
+		for w.Status().Running && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 250)
+		}
+	}
+	if !w.Wait(timeout) {
+		log.Warn().Str("timeout", timeout.String()).
+			Str("type", serverType.String()).
+			Msg("did not terminate in time")
+	}
+	if p := w.Process(); p != nil {
+		if err := p.Cleanup(); err != nil {
+			log.Warn().Err(err).Msgf("Failed to cleanup %s", serverType)
+		}
+	}
+
+	// Keep the data of the removed server
+	if dir, err := runtimeContext.serverHostDir(serverType); err != nil {
+		log.Warn().Err(err).Msgf("Failed to find data directory of %s", serverType)
+	} else if _, err := os.Stat(dir); err == nil {
+		removedDir := fmt.Sprintf("%s.removed-%s", dir, time.Now().Format("20060102-150405"))
+		if err := os.Rename(dir, removedDir); err != nil {
+			log.Warn().Err(err).Msgf("Failed to rename data directory of %s", serverType)
+		} else {
+			log.Info().Msgf("Moved data directory of removed %s to %s", serverType, removedDir)
+		}
+	}
+	log.Info().Msgf("Removed %s", serverType)
+	return nil
+}
+
+// setServerRemovalInProgress marks the server of given type as being removed from the cluster (or not).
+func (s *runtimeServerManager) setServerRemovalInProgress(serverType definitions.ServerType, inProgress bool) {
+	s.removingMutex.Lock()
+	defer s.removingMutex.Unlock()
+
+	if inProgress {
+		if s.removingServers == nil {
+			s.removingServers = make(map[definitions.ServerType]bool)
+		}
+		s.removingServers[serverType] = true
+	} else {
+		delete(s.removingServers, serverType)
+	}
+}
+
+// isServerRemovalInProgress returns true when the server of given type is being removed from the cluster.
+func (s *runtimeServerManager) isServerRemovalInProgress(serverType definitions.ServerType) bool {
+	s.removingMutex.Lock()
+	defer s.removingMutex.Unlock()
+
+	return s.removingServers[serverType]
+}
+
 // RestartServer triggers a restart of the server of the given type.
 func (s *runtimeServerManager) RestartServer(log zerolog.Logger, serverType definitions.ServerType) error {
-	var p Process
+	var w ProcessWrapper
 
 	switch serverType {
 	case definitions.ServerTypeAgent:
-		p = s.agentProc.Process()
-	case definitions.ServerTypeDBServer:
-		p = s.dbserverProc.Process()
-	case definitions.ServerTypeCoordinator:
-		p = s.coordinatorProc.Process()
+		w = s.agentProc
+	case definitions.ServerTypeDBServer, definitions.ServerTypeCoordinator:
+		w = s.processWrapperByType(serverType)
 	case definitions.ServerTypeSingle, definitions.ServerTypeResilientSingle:
-		p = s.singleProc.Process()
+		w = s.singleProc
 	case definitions.ServerTypeSyncMaster:
-		p = s.syncMasterProc.Process()
+		w = s.syncMasterProc
 	case definitions.ServerTypeSyncWorker:
-		p = s.syncWorkerProc.Process()
+		w = s.syncWorkerProc
 	default:
 		return maskAny(fmt.Errorf("Unknown server type '%s'", serverType))
 	}
 
-	if p != nil {
+	if w == nil {
+		return nil
+	}
+	if p := w.Process(); p != nil {
 		terminateProcessWithActions(log, p, serverType, 0, time.Minute, actions.ActionTypeAll)
 	}
 	return nil
@@ -28,14 +28,15 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/arangodb-helper/arangodb/pkg/api"
 	"github.com/arangodb-helper/arangodb/pkg/definitions"
 
 	"github.com/arangodb-helper/arangodb/client"
@@ -43,10 +44,6 @@ import (
 	"github.com/rs/zerolog"
 )
 
-var (
-	httpClient = client.DefaultHTTPClient()
-)
-
 const (
 	contentTypeJSON = "application/json"
 )
@@ -64,6 +61,13 @@ type HelloRequest struct {
 	ResilientSingle *bool  `json:",omitempty"` // If not nil, sets if server gets an resilient single or not. If nil, default handling applies
 	SyncMaster      *bool  `json:",omitempty"` // If not nil, sets if server gets an sync master or not. If nil, default handling applies
 	SyncWorker      *bool  `json:",omitempty"` // If not nil, sets if server gets an sync master or not. If nil, default handling applies
+	AutoCA          bool   `json:",omitempty"` // If set, the slave requests the certificate authority of the cluster (see --ssl.auto-ca)
+}
+
+// HelloResponse is the data structure send of the wire in response to a `/hello` POST request.
+type HelloResponse struct {
+	ClusterConfig
+	AutoCA *AutoCA `json:",omitempty"` // Certificate authority of the cluster, only set when requested
 }
 
 type httpServer struct {
@@ -74,7 +78,12 @@ type httpServer struct {
 	versionInfo          client.VersionInfo
 	idInfo               client.IDInfo
 	runtimeServerManager *runtimeServerManager
+	peerAuth             *peerAuthenticator
+	apiAuth              *apiAuthenticator
 	masterPort           int
+	encryptionLock       sync.Mutex
+	upgradeStatusCache   upgradeStatusCache
+	useDockerRunner      bool
 }
 
 // httpServerContext provides a context for the httpServer.
@@ -87,6 +96,13 @@ type httpServerContext interface {
 	// IsRunningMaster returns if the starter is the running master.
 	IsRunningMaster() (isRunningMaster, isRunning bool, masterURL string)
 
+	// State returns the current state of the service
+	State() State
+
+	// LastClusterManagerIteration returns the time the runtime cluster manager last started
+	// an iteration of its loop, or a zero time when it is not running.
+	LastClusterManagerIteration() time.Time
+
 	// serverHostLogFile returns the path of the logfile (in host namespace) to which the given server will write its logs.
 	serverHostLogFile(serverType definitions.ServerType) (string, error)
 
@@ -98,16 +114,39 @@ type httpServerContext interface {
 	// Stop the peer
 	Stop()
 
+	// LocalSyncHealth checks that the sync master & worker started by this starter (if any) are healthy and,
+	// when that sync master is the leading one, that synchronization is not broken.
+	LocalSyncHealth(ctx context.Context) (api.SyncHealth, error)
+
 	// UpgradeManager returns the database upgrade manager
 	UpgradeManager() UpgradeManager
 
+	// RestartManager returns the rolling restart manager
+	RestartManager() RestartManager
+
 	// Handle a hello request.
 	// If req==nil, this is a GET request, otherwise it is a POST request.
 	HandleHello(ownAddress, remoteAddress string, req *HelloRequest, isUpdateRequest bool) (ClusterConfig, error)
 
 	// HandleGoodbye removes the database servers started by the peer with given id
 	// from the cluster and alters the cluster configuration, removing the peer.
-	HandleGoodbye(id string, force bool) (peerRemoved bool, err error)
+	// When async is set, it returns as soon as the removal has been started.
+	HandleGoodbye(id string, force, async bool) (peerFound bool, err error)
+
+	// PeerRemovalStatus returns the status of the last removal of the peer with given id.
+	PeerRemovalStatus(id string) (client.PeerRemovalStatus, bool)
+
+	// ClusterStatus returns all starters of the cluster with the servers they start
+	// and the health of those servers as known to the agency.
+	ClusterStatus(ctx context.Context) client.ClusterStatus
+
+	// HandleSetPeerRoles changes the roles of the peer with given id in the cluster configuration
+	// and lets that peer start & stop its servers accordingly.
+	HandleSetPeerRoles(ctx context.Context, id string, roles client.PeerRoles) (peerFound bool, err error)
+
+	// ApplyPeerRoles lets this starter take the given roles, starting the servers
+	// of the roles it gains and removing the servers of the roles it loses from the cluster.
+	ApplyPeerRoles(ctx context.Context, roles client.PeerRoles) error
 
 	// Called by an agency callback
 	MasterChangedCallback()
@@ -116,15 +155,31 @@ type httpServerContext interface {
 	// used by this starter.
 	DatabaseVersion(context.Context) (driver.Version, bool, error)
 
+	// SwitchArangodBinary lets this starter use the given arangod executable (or docker image)
+	// for all arangod servers it starts from now on.
+	// When validateOnly is set, the target is only checked.
+	SwitchArangodBinary(ctx context.Context, target client.UpgradeTarget, validateOnly bool) (client.SwitchArangodBinaryResult, error)
+
 	GetLocalFolder() string
 
+	// SslKeyFile returns the path of the keyfile used by the servers (if TLS is enabled).
+	SslKeyFile() string
+
+	// AutoCA returns the certificate authority of the cluster (see --ssl.auto-ca).
+	AutoCA() (AutoCA, error)
+
+	// EncryptionKeyFolder returns the path of the RocksDB encryption key folder of the starter
+	// (empty if encryption keys are not managed in a folder).
+	EncryptionKeyFolder() string
+
 	DatabaseFeatures() DatabaseFeatures
 
 	serverHostDir(serverType definitions.ServerType) (string, error)
 }
 
 // newHTTPServer initializes and an HTTP server.
-func newHTTPServer(log zerolog.Logger, context httpServerContext, runtimeServerManager *runtimeServerManager, config Config, serverID string) *httpServer {
+func newHTTPServer(log zerolog.Logger, context httpServerContext, runtimeServerManager *runtimeServerManager, config Config, serverID string,
+	peerAuth *peerAuthenticator, apiAuth *apiAuthenticator) *httpServer {
 	// Create HTTP server
 	return &httpServer{
 		log:     log,
@@ -138,7 +193,10 @@ func newHTTPServer(log zerolog.Logger, context httpServerContext, runtimeServerM
 			Build:   config.ProjectBuild,
 		},
 		runtimeServerManager: runtimeServerManager,
+		peerAuth:             peerAuth,
+		apiAuth:              apiAuth,
 		masterPort:           config.MasterPort,
+		useDockerRunner:      config.UseDockerRunner(),
 	}
 }
 
@@ -158,32 +216,55 @@ func (s *httpServer) Run(hostAddr, containerAddr string, tlsConfig *tls.Config,
 	mux := http.NewServeMux()
 	if !idOnly {
 		// Starter to starter API
-		mux.HandleFunc("/hello", s.helloHandler)
-		mux.HandleFunc("/goodbye", s.goodbyeHandler)
+		mux.HandleFunc("/hello", s.peerAuth.requirePeerAuthentication(s.helloHandler))
+		mux.HandleFunc("/goodbye", s.apiAuth.requirePeerOrRole(StarterRoleAdmin, s.goodbyeHandler))
+		mux.HandleFunc("/local/upgrade/binary", s.apiAuth.requirePeerOrRole(StarterRoleAdmin, s.localUpgradeBinaryHandler))
+		mux.HandleFunc("/local/peer/roles", s.apiAuth.requirePeerOrRole(StarterRoleAdmin, s.localPeerRolesHandler))
+		mux.HandleFunc("/local/sync/health", s.apiAuth.requirePeerOrRole(StarterRoleReadOnly, s.localSyncHealthHandler))
 	}
 	// External API
 	mux.HandleFunc("/id", s.idHandler)
 	if !idOnly {
-		mux.HandleFunc("/local/inventory", s.localInventory)
-		mux.HandleFunc("/cluster/inventory", s.clusterInventory)
-		mux.HandleFunc("/process", s.processListHandler)
-		mux.HandleFunc("/endpoints", s.endpointsHandler)
-		mux.HandleFunc("/logs/agent", s.agentLogsHandler)
-		mux.HandleFunc("/logs/dbserver", s.dbserverLogsHandler)
-		mux.HandleFunc("/logs/coordinator", s.coordinatorLogsHandler)
-		mux.HandleFunc("/logs/single", s.singleLogsHandler)
-		mux.HandleFunc("/logs/syncmaster", s.syncMasterLogsHandler)
-		mux.HandleFunc("/logs/syncworker", s.syncWorkerLogsHandler)
+		mux.HandleFunc("/local/inventory", s.apiAuth.requireRoleByMethod(s.localInventory))
+		mux.HandleFunc("/cluster/inventory", s.apiAuth.requireRoleByMethod(s.clusterInventory))
+		mux.HandleFunc("/cluster/logs", s.apiAuth.requireRoleByMethod(s.clusterLogsHandler))
+		mux.HandleFunc("/cluster/status", s.apiAuth.requireRoleByMethod(s.clusterStatusHandler))
+		mux.HandleFunc("/process", s.apiAuth.requireRoleByMethod(s.processListHandler))
+		mux.HandleFunc("/endpoints", s.apiAuth.requireRoleByMethod(s.endpointsHandler))
+		mux.HandleFunc("/peer/roles", s.apiAuth.requireRoleByMethod(s.peerRolesHandler))
+		mux.HandleFunc("/peer/removal", s.apiAuth.requireRoleByMethod(s.peerRemovalHandler))
+		mux.HandleFunc("/logs/agent", s.apiAuth.requireRoleByMethod(s.agentLogsHandler))
+		mux.HandleFunc("/logs/dbserver", s.apiAuth.requireRoleByMethod(s.dbserverLogsHandler))
+		mux.HandleFunc("/logs/coordinator", s.apiAuth.requireRoleByMethod(s.coordinatorLogsHandler))
+		mux.HandleFunc("/logs/single", s.apiAuth.requireRoleByMethod(s.singleLogsHandler))
+		mux.HandleFunc("/logs/syncmaster", s.apiAuth.requireRoleByMethod(s.syncMasterLogsHandler))
+		mux.HandleFunc("/logs/syncworker", s.apiAuth.requireRoleByMethod(s.syncWorkerLogsHandler))
 		mux.HandleFunc("/version", s.versionHandler)
-		mux.HandleFunc("/database-version", s.databaseVersionHandler)
-		mux.HandleFunc("/shutdown", s.shutdownHandler)
-		mux.HandleFunc("/database-auto-upgrade", s.databaseAutoUpgradeHandler)
+		mux.HandleFunc("/health/live", s.healthLiveHandler)
+		mux.HandleFunc("/health/ready", s.healthReadyHandler)
+		mux.HandleFunc("/metrics", s.apiAuth.requireRoleByMethod(s.metricsHandler))
+		mux.HandleFunc("/database-version", s.apiAuth.requireRoleByMethod(s.databaseVersionHandler))
+		mux.HandleFunc("/shutdown", s.apiAuth.requireRoleByMethod(s.shutdownHandler))
+		mux.HandleFunc("/database-auto-upgrade", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradeHandler))
+		mux.HandleFunc("/database-auto-upgrade/dry-run", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradeDryRunHandler))
+		mux.HandleFunc("/database-auto-upgrade/rollback", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradeRollbackHandler))
+		mux.HandleFunc("/database-auto-upgrade/history", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradeHistoryHandler))
+		mux.HandleFunc("/database-auto-upgrade/pause", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradePauseHandler))
+		mux.HandleFunc("/database-auto-upgrade/resume", s.apiAuth.requireRoleByMethod(s.databaseAutoUpgradeResumeHandler))
+		mux.HandleFunc("/database-restart", s.apiAuth.requireRoleByMethod(s.databaseRestartHandler))
 		// Agency callback
-		mux.HandleFunc("/cb/masterChanged", s.cbMasterChanged)
-		mux.HandleFunc("/cb/upgradePlanChanged", s.cbUpgradePlanChanged)
+		mux.HandleFunc("/cb/masterChanged", s.peerAuth.requirePeerAuthentication(s.cbMasterChanged))
+		mux.HandleFunc("/cb/upgradePlanChanged", s.peerAuth.requirePeerAuthentication(s.cbUpgradePlanChanged))
+		mux.HandleFunc("/cb/restartPlanChanged", s.peerAuth.requirePeerAuthentication(s.cbRestartPlanChanged))
 
 		// JWT Rotation
 		s.registerJWTFunctions(mux)
+
+		// TLS Rotation
+		s.registerTLSFunctions(mux)
+
+		// Encryption key rotation
+		s.registerEncryptionFunctions(mux)
 	}
 
 	s.server.Addr = containerAddr
@@ -245,12 +326,36 @@ func (s *httpServer) helloHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Fetch certificate authority before the slave is added.
+		// It includes the private key of the CA, so it is only handed out to authenticated starters.
+		var autoCA AutoCA
+		if req.AutoCA {
+			if s.peerAuth.mode == PeerAuthModeNone {
+				handleError(w, maskAny(client.NewPreconditionFailedError("Joining a cluster that uses --ssl.auto-ca requires --starter.peer-auth=jwt or --starter.peer-auth=mtls on all starters")))
+				return
+			}
+			if autoCA, err = s.context.AutoCA(); err != nil {
+				handleError(w, err)
+				return
+			}
+		}
+
 		// Let service handle post request
 		result, err = s.context.HandleHello(ownAddress, r.RemoteAddr, &req, false)
 		if err != nil {
 			handleError(w, err)
 			return
 		}
+
+		if req.AutoCA {
+			b, err := json.Marshal(HelloResponse{ClusterConfig: result, AutoCA: &autoCA})
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+			} else {
+				w.Write(b)
+			}
+			return
+		}
 	} else {
 		// Invalid method
 		writeError(w, http.StatusMethodNotAllowed, "GET or POST required")
@@ -276,6 +381,7 @@ func (s *httpServer) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request
 	force, _ := strconv.ParseBool(r.FormValue("force"))
+	async, _ := strconv.ParseBool(r.FormValue("async"))
 	var req client.GoodbyeRequest
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
@@ -304,11 +410,15 @@ func (s *httpServer) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 		// Redirect to master
 		if masterURL != "" {
 			// Forward the request to the leader.
-			c, err := createMasterClient(masterURL)
+			c, err := createMasterClient(masterURL, s.peerAuth.clientOptions...)
 			if err != nil {
 				handleError(w, err)
 			} else {
-				if err := c.RemovePeer(ctx, req.SlaveID, force); err != nil {
+				removePeer := c.RemovePeer
+				if async {
+					removePeer = c.RemovePeerAsync
+				}
+				if err := removePeer(ctx, req.SlaveID, force); err != nil {
 					s.log.Debug().Err(err).Msg("Forwarding RemovePeer failed")
 					handleError(w, err)
 				} else {
@@ -321,8 +431,8 @@ func (s *httpServer) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Remove the peer
-		s.log.Info().Bool("force", force).Msgf("Goodbye requested for peer %s", req.SlaveID)
-		if removed, err := s.context.HandleGoodbye(req.SlaveID, force); err != nil {
+		s.log.Info().Bool("force", force).Bool("async", async).Msgf("Goodbye requested for peer %s", req.SlaveID)
+		if removed, err := s.context.HandleGoodbye(req.SlaveID, force, async); err != nil {
 			// Failure
 			handleError(w, err)
 		} else if !removed {
@@ -336,6 +446,163 @@ func (s *httpServer) goodbyeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// peerRemovalHandler handles a `/peer/removal` request that returns the status of the removal of a peer.
+func (s *httpServer) peerRemovalHandler(w http.ResponseWriter, r *http.Request) {
+	// Check method
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	// Check request
+	id := r.FormValue("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "id must be set.")
+		return
+	}
+
+	// Check state
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+	if isRunning && !isRunningMaster {
+		// Redirect to master
+		if masterURL != "" {
+			location, err := getURLWithPath(masterURL, "/peer/removal?id="+url.QueryEscape(id))
+			if err != nil {
+				handleError(w, err)
+			} else {
+				handleError(w, RedirectError{Location: location})
+			}
+		} else {
+			writeError(w, http.StatusServiceUnavailable, "No runtime master known")
+		}
+	} else if status, found := s.context.PeerRemovalStatus(id); !found {
+		writeError(w, http.StatusNotFound, "Unknown ID")
+	} else {
+		b, err := json.Marshal(status)
+		if err != nil {
+			handleError(w, err)
+		} else {
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+		}
+	}
+}
+
+// clusterStatusHandler handles a `/cluster/status` request that returns the status of all starters and their servers.
+func (s *httpServer) clusterStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// Check method
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "GET required")
+		return
+	}
+
+	status := s.context.ClusterStatus(r.Context())
+	b, err := json.Marshal(status)
+	if err != nil {
+		handleError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
+
+// peerRolesHandler handles a `/peer/roles` request that changes the roles of a peer.
+func (s *httpServer) peerRolesHandler(w http.ResponseWriter, r *http.Request) {
+	// Check method
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	// Parse request
+	var req client.SetPeerRolesRequest
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+		return
+	}
+
+	// Check request
+	if req.ID == "" {
+		writeError(w, http.StatusBadRequest, "id must be set.")
+		return
+	}
+
+	// Check state
+	ctx := r.Context()
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+	if !isRunning {
+		// Must be running first
+		writeError(w, http.StatusServiceUnavailable, "Starter is not in running phase")
+	} else if !isRunningMaster {
+		// Redirect to master
+		if masterURL != "" {
+			// Forward the request to the leader.
+			c, err := createMasterClient(masterURL, s.peerAuth.clientOptions...)
+			if err != nil {
+				handleError(w, err)
+			} else {
+				if err := c.SetPeerRoles(ctx, req.ID, req.PeerRoles); err != nil {
+					s.log.Debug().Err(err).Msg("Forwarding SetPeerRoles failed")
+					handleError(w, err)
+				} else {
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte("OK"))
+				}
+			}
+		} else {
+			writeError(w, http.StatusServiceUnavailable, "No runtime master known")
+		}
+	} else {
+		// Change the roles of the peer
+		s.log.Info().Msgf("Changing roles of peer %s requested", req.ID)
+		if found, err := s.context.HandleSetPeerRoles(ctx, req.ID, req.PeerRoles); err != nil {
+			// Failure
+			handleError(w, err)
+		} else if !found {
+			// ID not found
+			writeError(w, http.StatusNotFound, "Unknown ID")
+		} else {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		}
+	}
+}
+
+// localPeerRolesHandler lets this starter take the roles given in the request body.
+func (s *httpServer) localPeerRolesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	// Parse request
+	var roles client.PeerRoles
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return
+	}
+	if err := json.Unmarshal(body, &roles); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+		return
+	}
+
+	if err := s.context.ApplyPeerRoles(r.Context(), roles); err != nil {
+		s.log.Debug().Err(err).Msg("Applying roles failed")
+		handleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
 // idHandler returns a JSON object containing the ID of this starter.
 func (s *httpServer) idHandler(w http.ResponseWriter, r *http.Request) {
 	data, err := json.Marshal(s.idInfo)
@@ -376,7 +643,8 @@ func (s *httpServer) processListHandler(w http.ResponseWriter, r *http.Request)
 			expectedServers++
 		}
 
-		createServerProcess := func(serverType definitions.ServerType, p Process) client.ServerProcess {
+		createServerProcess := func(serverType definitions.ServerType, w ProcessWrapper, p Process) client.ServerProcess {
+			status := w.Status()
 			return client.ServerProcess{
 				Type:        client.ServerType(serverType),
 				IP:          ip,
@@ -385,37 +653,42 @@ func (s *httpServer) processListHandler(w http.ResponseWriter, r *http.Request)
 				ContainerID: p.ContainerID(),
 				ContainerIP: p.ContainerIP(),
 				IsSecure:    isSecure,
+				Restarts:    status.Restarts,
+				Failures:    status.Failures,
+				Failed:      status.Failed,
+				Running:     status.Running,
+				Up:          status.Up,
 			}
 		}
 
 		if w := s.runtimeServerManager.agentProc; w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeAgent, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeAgent, w, p))
 			}
 		}
-		if w := s.runtimeServerManager.coordinatorProc; w != nil {
+		if w := s.runtimeServerManager.processWrapperByType(definitions.ServerTypeCoordinator); w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeCoordinator, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeCoordinator, w, p))
 			}
 		}
-		if w := s.runtimeServerManager.dbserverProc; w != nil {
+		if w := s.runtimeServerManager.processWrapperByType(definitions.ServerTypeDBServer); w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeDBServer, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeDBServer, w, p))
 			}
 		}
 		if w := s.runtimeServerManager.singleProc; w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSingle, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSingle, w, p))
 			}
 		}
 		if w := s.runtimeServerManager.syncMasterProc; w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSyncMaster, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSyncMaster, w, p))
 			}
 		}
 		if w := s.runtimeServerManager.syncWorkerProc; w != nil {
 			if p := w.Process(); p != nil {
-				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSyncWorker, p))
+				resp.Servers = append(resp.Servers, createServerProcess(definitions.ServerTypeSyncWorker, w, p))
 			}
 		}
 	}
@@ -554,7 +827,15 @@ func (s *httpServer) syncWorkerLogsHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// logsHandler serves the log of the server of given type.
+// The query parameters `tail`, `since`, `level` & `follow` can be used to
+// limit the returned lines and to keep streaming new lines.
 func (s *httpServer) logsHandler(w http.ResponseWriter, r *http.Request, serverType definitions.ServerType) {
+	q, err := parseLogsQuery(r)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
 	// Find log path
 	logPath, err := s.context.serverHostLogFile(serverType)
 	if err != nil {
@@ -563,19 +844,8 @@ func (s *httpServer) logsHandler(w http.ResponseWriter, r *http.Request, serverT
 		return
 	}
 	s.log.Debug().Msgf("Fetching logs in %s", logPath)
-	rd, err := os.Open(logPath)
-	if os.IsNotExist(err) {
-		// Log file not there (yet), we allow this
-		w.WriteHeader(http.StatusOK)
-	} else if err != nil {
-		s.log.Error().Err(err).Msgf("Failed to open log file '%s'", logPath)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-	} else {
-		// Log open
-		defer rd.Close()
-		w.WriteHeader(http.StatusOK)
-		io.Copy(w, rd)
+	if err := streamLogFile(r.Context(), w, logPath, q); err != nil {
+		s.log.Error().Err(err).Msgf("Failed to stream log file '%s'", logPath)
 	}
 }
 
@@ -656,11 +926,13 @@ func (s *httpServer) databaseAutoUpgradeHandler(w http.ResponseWriter, r *http.R
 	switch r.Method {
 	case "POST":
 		forceMinorUpgrade, _ := strconv.ParseBool(r.URL.Query().Get("forceMinorUpgrade"))
+		target := client.UpgradeTargetFromQuery(r.URL.Query())
+		triggeredBy := s.apiAuth.requester(r, r.URL.Query().Get("triggeredBy"))
 
 		// Start the upgrade process
 		if isRunningMaster || mode.IsSingleMode() {
 			// We're the starter leader, process the request
-			if err := s.context.UpgradeManager().StartDatabaseUpgrade(ctx, forceMinorUpgrade); err != nil {
+			if err := s.context.UpgradeManager().StartDatabaseUpgrade(ctx, forceMinorUpgrade, triggeredBy, target); err != nil {
 				handleError(w, err)
 			} else {
 				w.WriteHeader(http.StatusOK)
@@ -669,11 +941,11 @@ func (s *httpServer) databaseAutoUpgradeHandler(w http.ResponseWriter, r *http.R
 		} else {
 			// We're not the starter leader.
 			// Forward the request to the leader.
-			c, err := createMasterClient(masterURL)
+			c, err := createMasterClient(masterURL, s.apiAuth.requesterClientOptions(triggeredBy)...)
 			if err != nil {
 				handleError(w, err)
 			} else {
-				if err := c.StartDatabaseUpgrade(ctx, forceMinorUpgrade); err != nil {
+				if err := c.StartDatabaseUpgradeTo(ctx, forceMinorUpgrade, target, triggeredBy); err != nil {
 					s.log.Debug().Err(err).Msg("Forwarding StartDatabaseUpgrade failed")
 					handleError(w, err)
 				} else {
@@ -687,7 +959,7 @@ func (s *httpServer) databaseAutoUpgradeHandler(w http.ResponseWriter, r *http.R
 		if !isRunningMaster {
 			// We're not the starter leader.
 			// Forward the request to the leader.
-			c, err := createMasterClient(masterURL)
+			c, err := createMasterClient(masterURL, s.peerAuth.clientOptions...)
 			if err != nil {
 				handleError(w, err)
 			} else {
@@ -713,7 +985,7 @@ func (s *httpServer) databaseAutoUpgradeHandler(w http.ResponseWriter, r *http.R
 		if !isRunningMaster {
 			// We're not the starter leader.
 			// Forward the request to the leader.
-			c, err := createMasterClient(masterURL)
+			c, err := createMasterClient(masterURL, s.peerAuth.clientOptions...)
 			if err != nil {
 				handleError(w, err)
 			} else {
@@ -750,6 +1022,276 @@ func (s *httpServer) databaseAutoUpgradeHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
+// databaseAutoUpgradeDryRunHandler returns the plan an upgrade of the database version would use,
+// without changing anything.
+func (s *httpServer) databaseAutoUpgradeDryRunHandler(w http.ResponseWriter, r *http.Request) {
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+	_, _, mode := s.context.ClusterConfig()
+
+	if !isRunning {
+		// We must have reached the running state before we can handle this kind of request
+		s.log.Debug().Msg("Received /database-auto-upgrade/dry-run request while not in running phase")
+		writeError(w, http.StatusBadRequest, "Must be in running state to do upgrades")
+		return
+	}
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	forceMinorUpgrade, _ := strconv.ParseBool(r.URL.Query().Get("forceMinorUpgrade"))
+	target := client.UpgradeTargetFromQuery(r.URL.Query())
+	var result client.UpgradeDryRun
+	var err error
+	if isRunningMaster || mode.IsSingleMode() {
+		// We're the starter leader, process the request
+		result, err = s.context.UpgradeManager().DryRunDatabaseUpgrade(ctx, forceMinorUpgrade, target)
+	} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+		err = cerr
+	} else {
+		// We're not the starter leader.
+		// Forward the request to the leader.
+		result, err = c.DryRunDatabaseUpgrade(ctx, forceMinorUpgrade, target)
+	}
+	if err != nil {
+		s.log.Debug().Err(err).Msg("Dry-run of database upgrade failed")
+		handleError(w, err)
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// localUpgradeBinaryHandler lets this starter switch to the arangod executable (or docker image)
+// given in the request body.
+func (s *httpServer) localUpgradeBinaryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	// Checking a target runs it, so only accept requests that have been authenticated
+	if !s.apiAuth.requestsAuthenticated() {
+		writeError(w, http.StatusForbidden, "Checking an arangod executable (or image) requires --starter.peer-auth=jwt|mtls or --auth.starter-api")
+		return
+	}
+	// Starters only switch to the target of the upgrade plan themselves (see switchToUpgradeTarget)
+	if validateOnly, _ := strconv.ParseBool(r.URL.Query().Get("validateOnly")); !validateOnly {
+		writeError(w, http.StatusBadRequest, "Only checking an arangod executable (or image) is supported, set validateOnly=true")
+		return
+	}
+
+	// Parse request
+	var target client.UpgradeTarget
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot read request body: %v", err.Error()))
+		return
+	}
+	if err := json.Unmarshal(body, &target); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Cannot parse request body: %v", err.Error()))
+		return
+	}
+
+	result, err := s.context.SwitchArangodBinary(r.Context(), target, true)
+	if err != nil {
+		s.log.Debug().Err(err).Msg("Checking arangod executable failed")
+		handleError(w, err)
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// databaseAutoUpgradeRollbackHandler rolls back an upgrade of the database version.
+func (s *httpServer) databaseAutoUpgradeRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+
+	if !isRunning {
+		// We must have reached the running state before we can handle this kind of request
+		s.log.Debug().Msg("Received /database-auto-upgrade/rollback request while not in running phase")
+		writeError(w, http.StatusBadRequest, "Must be in running state to do rollbacks")
+		return
+	}
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	var err error
+	if isRunningMaster {
+		// We're the starter leader, process the request
+		err = s.context.UpgradeManager().RollbackDatabaseUpgrade(ctx)
+	} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+		err = cerr
+	} else {
+		// We're not the starter leader.
+		// Forward the request to the leader.
+		err = c.RollbackDatabaseUpgrade(ctx)
+	}
+	if err != nil {
+		s.log.Debug().Err(err).Msg("Rollback of database upgrade failed")
+		handleError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// databaseAutoUpgradePauseHandler pauses an upgrade of the database version after the current entry.
+func (s *httpServer) databaseAutoUpgradePauseHandler(w http.ResponseWriter, r *http.Request) {
+	s.setDatabaseAutoUpgradePaused(w, r, true)
+}
+
+// databaseAutoUpgradeResumeHandler resumes a paused upgrade of the database version.
+func (s *httpServer) databaseAutoUpgradeResumeHandler(w http.ResponseWriter, r *http.Request) {
+	s.setDatabaseAutoUpgradePaused(w, r, false)
+}
+
+// setDatabaseAutoUpgradePaused pauses or resumes an upgrade of the database version.
+func (s *httpServer) setDatabaseAutoUpgradePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+
+	if !isRunning {
+		// We must have reached the running state before we can handle this kind of request
+		s.log.Debug().Msgf("Received %s request while not in running phase", r.URL.Path)
+		writeError(w, http.StatusBadRequest, "Must be in running state to pause or resume upgrades")
+		return
+	}
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+	var err error
+	if isRunningMaster {
+		// We're the starter leader, process the request
+		if paused {
+			err = s.context.UpgradeManager().PauseDatabaseUpgrade(ctx)
+		} else {
+			err = s.context.UpgradeManager().ResumeDatabaseUpgrade(ctx)
+		}
+	} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+		err = cerr
+	} else {
+		// We're not the starter leader.
+		// Forward the request to the leader.
+		if paused {
+			err = c.PauseDatabaseUpgrade(ctx)
+		} else {
+			err = c.ResumeDatabaseUpgrade(ctx)
+		}
+	}
+	if err != nil {
+		s.log.Debug().Err(err).Msgf("%s failed", r.URL.Path)
+		handleError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// databaseAutoUpgradeHistoryHandler returns the finished, aborted & rolled back upgrade plans.
+// The history is stored in the agency (if any), so every starter can answer this request.
+func (s *httpServer) databaseAutoUpgradeHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := s.context.UpgradeManager().History(r.Context())
+	if err != nil {
+		s.log.Debug().Err(err).Msg("Reading upgrade history failed")
+		handleError(w, err)
+		return
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+	} else {
+		w.Write(b)
+	}
+}
+
+// databaseRestartHandler initiates a rolling restart of the servers of the deployment.
+func (s *httpServer) databaseRestartHandler(w http.ResponseWriter, r *http.Request) {
+	isRunningMaster, isRunning, masterURL := s.context.IsRunningMaster()
+
+	if !isRunning {
+		// We must have reached the running state before we can handle this kind of request
+		s.log.Debug().Msg("Received /database-restart request while not in running phase")
+		writeError(w, http.StatusBadRequest, "Must be in running state to do restarts")
+		return
+	}
+
+	ctx := r.Context()
+	var err error
+	switch r.Method {
+	case "POST":
+		// Start the restart process
+		serverType := client.ServerType(r.URL.Query().Get("type"))
+		if isRunningMaster {
+			err = s.context.RestartManager().StartDatabaseRestart(ctx, serverType)
+		} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+			err = cerr
+		} else {
+			err = c.StartDatabaseRestart(ctx, serverType)
+		}
+	case "PUT":
+		// Retry the restart process
+		if isRunningMaster {
+			err = s.context.RestartManager().RetryDatabaseRestart(ctx)
+		} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+			err = cerr
+		} else {
+			err = c.RetryDatabaseRestart(ctx)
+		}
+	case "DELETE":
+		// Abort the restart process
+		if isRunningMaster {
+			err = s.context.RestartManager().AbortDatabaseRestart(ctx)
+		} else if c, cerr := createMasterClient(masterURL, s.peerAuth.clientOptions...); cerr != nil {
+			err = cerr
+		} else {
+			err = c.AbortDatabaseRestart(ctx)
+		}
+	case "GET":
+		if status, err := s.context.RestartManager().Status(ctx); err != nil {
+			handleError(w, err)
+		} else {
+			b, err := json.Marshal(status)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+			} else {
+				w.Write(b)
+			}
+		}
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		s.log.Debug().Err(err).Str("method", r.Method).Msg("Database restart request failed")
+		handleError(w, err)
+	} else {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
 // cbMasterChanged is a callback called by the agency when the master URL is modified.
 func (s *httpServer) cbMasterChanged(w http.ResponseWriter, r *http.Request) {
 	s.log.Debug().Msgf("Master changed callback from %s", r.RemoteAddr)
@@ -764,6 +1306,20 @@ func (s *httpServer) cbMasterChanged(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// cbRestartPlanChanged is a callback called by the agency when the restart plan is modified.
+func (s *httpServer) cbRestartPlanChanged(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug().Msgf("Restart plan changed callback from %s", r.RemoteAddr)
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Interrupt restart manager
+	s.context.RestartManager().RestartPlanChangedCallback()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
 // cbUpgradePlanChanged is a callback called by the agency when the upgrade plan is modified.
 func (s *httpServer) cbUpgradePlanChanged(w http.ResponseWriter, r *http.Request) {
 	s.log.Debug().Msgf("Upgrade plan changed callback from %s", r.RemoteAddr)
@@ -807,7 +1363,15 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	w.Write(b)
 }
 
-func createMasterClient(masterURL string) (client.API, error) {
+// createPeerClient creates a client for the starter of the given peer, using the given options
+// (usually the client options of the peerAuthenticator).
+func createPeerClient(p Peer, opts ...client.ClientOption) (client.API, error) {
+	return createMasterClient(p.CreateStarterURL("/"), opts...)
+}
+
+// createMasterClient creates a client for the starter at the given URL, using the given options
+// (usually the client options of the peerAuthenticator).
+func createMasterClient(masterURL string, opts ...client.ClientOption) (client.API, error) {
 	if masterURL == "" {
 		return nil, maskAny(fmt.Errorf("Starter master is not known"))
 	}
@@ -815,7 +1379,7 @@ func createMasterClient(masterURL string) (client.API, error) {
 	if err != nil {
 		return nil, maskAny(err)
 	}
-	c, err := client.NewArangoStarterClient(*ep)
+	c, err := client.NewArangoStarterClient(*ep, opts...)
 	if err != nil {
 		return nil, maskAny(err)
 	}
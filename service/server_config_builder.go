@@ -25,8 +25,10 @@ package service
 //
 // Arangod options are configured in the following places:
 // - arangod.conf:
-//     This holds all settings that are considered static for the lifetime of the cluster.
-//     Using new/different settings on the Starter will not change these settings.
+//     This holds all settings that are considered static for the lifetime of the server.
+//     Using new/different settings on the Starter will update these settings, unless
+//     the file has been modified manually (detected by its content hash) or the
+//     setting is immutable (e.g. storage engine).
 // - arangod commandline:
 //     This holds all settings that can change over the lifetime of the cluster.
 //     These settings mostly involve the cluster layout.
@@ -77,10 +79,10 @@ func collectServerConfigVolumes(serverType definitions.ServerType, config config
 // createServerArgs returns the command line arguments needed to run an arangod/arangosync server of given type.
 func createServerArgs(log zerolog.Logger, config Config, clusterConfig ClusterConfig, myContainerDir, myContainerLogFile string,
 	myPeerID, myAddress, myPort string, serverType definitions.ServerType, arangodConfig configFile,
-	clusterJWTSecretFile string, agentRecoveryID string, databaseAutoUpgrade bool, features DatabaseFeatures) ([]string, error) {
+	clusterJWTSecretFile, encryptionKeyFolder string, agentRecoveryID string, databaseAutoUpgrade bool, features DatabaseFeatures) ([]string, error) {
 	switch serverType.ProcessType() {
 	case definitions.ProcessTypeArangod:
-		return createArangodArgs(log, config, clusterConfig, myContainerDir, myContainerLogFile, myPeerID, myAddress, myPort, serverType, arangodConfig, agentRecoveryID, databaseAutoUpgrade, clusterJWTSecretFile, features), nil
+		return createArangodArgs(log, config, clusterConfig, myContainerDir, myContainerLogFile, myPeerID, myAddress, myPort, serverType, arangodConfig, agentRecoveryID, databaseAutoUpgrade, clusterJWTSecretFile, encryptionKeyFolder, features), nil
 	case definitions.ProcessTypeArangoSync:
 		return createArangoSyncArgs(log, config, clusterConfig, myContainerDir, myContainerLogFile, myPeerID, myAddress, myPort, serverType, clusterJWTSecretFile, features)
 	default:
@@ -55,6 +55,7 @@ import (
 
 	"github.com/arangodb-helper/arangodb/client"
 	"github.com/arangodb-helper/arangodb/pkg/logging"
+	"github.com/arangodb-helper/arangodb/service/actions"
 )
 
 const (
@@ -82,6 +83,9 @@ type Config struct {
 	LogRotateFilesToKeep int
 	LogRotateInterval    time.Duration
 	InstanceUpTimeout    time.Duration
+	RestartPolicies      RestartPolicies // Restart policy per server type
+	PeerAuthMode         PeerAuthMode    // Authentication required for the starter-to-starter API
+	APIAuthentication    bool            // If set, the starter API requires a JWT token (see StarterRole)
 
 	DockerContainerName   string // Name of the container running this process
 	DockerEndpoint        string // Where to reach the docker daemon
@@ -213,25 +217,59 @@ type Service struct {
 		ctx     context.Context    // Context to wait on for the bootstrap state to be completed. Once trigger the cluster config is complete.
 		trigger context.CancelFunc // Triggers the end of the bootstrap state
 	}
-	announcePort          int         // Port I can be reached on from the outside
-	tlsConfig             *tls.Config // Server side TLS config (if any)
-	isNetHost             bool        // Is this process running in a container with `--net=host` or running outside a container?
-	mutex                 sync.Mutex  // Mutex used to protect access to this datastructure
-	allowSameDataDir      bool        // If set, multiple arangdb instances are allowed to have the same dataDir (docker case)
+	announcePort          int                // Port I can be reached on from the outside
+	tlsConfig             *tls.Config        // Server side TLS config (if any)
+	autoCA                *autoCAManager     // Issues the server certificate of this peer (if `--ssl.auto-ca` is set)
+	peerAuth              *peerAuthenticator // Authentication of the starter-to-starter API & of clients for other starters
+	apiAuth               *apiAuthenticator  // Authorization of the public starter API
+	isNetHost             bool               // Is this process running in a container with `--net=host` or running outside a container?
+	mutex                 sync.Mutex         // Mutex used to protect access to this datastructure
+	allowSameDataDir      bool               // If set, multiple arangdb instances are allowed to have the same dataDir (docker case)
 	isLocalSlave          bool
 	learnOwnAddress       bool   // If set, the HTTP server will update my peer with address information gathered from a /hello request.
 	recoveryFile          string // Path of RECOVERY file (if any)
 	runner                Runner
+	arangodBinary         string // arangod executable (or docker image) switched to for an upgrade (if any)
 	runtimeServerManager  runtimeServerManager
 	runtimeClusterManager runtimeClusterManager
 	upgradeManager        UpgradeManager
+	restartManager        RestartManager
 	databaseFeatures      DatabaseFeatures
+	peerRemovals          map[string]*peerRemoval // Removals of peers started by this peer, by peer ID
 }
 
 func (s *Service) GetLocalFolder() string {
 	return s.bsCfg.JWTFolderDir()
 }
 
+// SslKeyFile returns the path of the keyfile used by the servers (if TLS is enabled).
+func (s *Service) SslKeyFile() string {
+	return s.sslKeyFile
+}
+
+// AutoCA returns the certificate authority of the cluster (see --ssl.auto-ca).
+func (s *Service) AutoCA() (AutoCA, error) {
+	if s.autoCA == nil {
+		return AutoCA{}, maskAny(client.NewBadRequestError("Starter is not using --ssl.auto-ca, please use it on all starters or none"))
+	}
+	ca, err := s.autoCA.Load()
+	if err != nil {
+		return AutoCA{}, maskAny(err)
+	} else if ca == nil {
+		return AutoCA{}, maskAny(errors.Wrap(client.ServiceUnavailableError, "No certificate authority yet"))
+	}
+	return *ca, nil
+}
+
+// EncryptionKeyFolder returns the path of the RocksDB encryption key folder of the starter
+// (empty if encryption keys are not managed in a folder).
+func (s *Service) EncryptionKeyFolder() string {
+	if s.bsCfg.RocksDBEncryptionKeyFile == "" || !s.DatabaseFeatures().GetEncryptionFolderOption() {
+		return ""
+	}
+	return s.bsCfg.EncryptionKeyFolderDir()
+}
+
 // NewService creates a new Service instance from the given config.
 func NewService(ctx context.Context, log zerolog.Logger, logService logging.Service, config Config, bsCfg BootstrapConfig, isLocalSlave bool) *Service {
 	// Fix up master addresses
@@ -249,7 +287,10 @@ func NewService(ctx context.Context, log zerolog.Logger, logService logging.Serv
 		state:        stateStart,
 		isLocalSlave: isLocalSlave,
 	}
+	s.peerAuth = &peerAuthenticator{mode: PeerAuthModeNone, httpClient: client.DefaultHTTPClient()}
+	s.apiAuth = &apiAuthenticator{peerAuth: s.peerAuth}
 	s.upgradeManager = NewUpgradeManager(log, s)
+	s.restartManager = NewRestartManager(log, s)
 	s.bootstrapCompleted.ctx, s.bootstrapCompleted.trigger = context.WithCancel(ctx)
 	return s
 }
@@ -304,10 +345,13 @@ func (s *Service) IsRunningMaster() (isRunningMaster, isRunning bool, masterURL
 
 // HandleGoodbye removes the database servers started by the peer with given id
 // from the cluster and alters the cluster configuration, removing the peer.
-func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err error) {
+// When async is set, it returns as soon as the removal has been started.
+// The progress of the removal can be inspected with PeerRemovalStatus.
+func (s *Service) HandleGoodbye(id string, force, async bool) (peerFound bool, err error) {
 	// Find peer
 	s.mutex.Lock()
 	peer, peerFound := s.myPeers.PeerByID(id)
+	config := s.myPeers
 	state := s.state
 	s.mutex.Unlock()
 
@@ -324,11 +368,42 @@ func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err er
 		return false, maskAny(errors.Wrap(client.PreconditionFailedError, "Cannot remove peer with agent"))
 	}
 
-	// Prepare cluster client
+	// Check that the remaining dbservers can hold all shards
 	ctx := context.Background()
+	if peer.HasDBServer() {
+		if err := s.checkDBServerRemoval(ctx, config, id); err != nil {
+			if !force {
+				return true, maskAny(err)
+			}
+			s.log.Warn().Err(err).Msg("Removing dbserver anyway")
+		}
+	}
+
+	removal, err := s.startPeerRemoval(id)
+	if err != nil {
+		return true, maskAny(err)
+	}
+	if async {
+		go s.removePeer(ctx, peer, force, removal)
+		return true, nil
+	}
+	if err := s.removePeer(ctx, peer, force, removal); err != nil {
+		return true, maskAny(err)
+	}
+	return true, nil
+}
+
+// removePeer removes the database servers started by the given peer from the cluster
+// and removes the peer from the cluster configuration, recording its progress in the given removal.
+func (s *Service) removePeer(ctx context.Context, peer Peer, force bool, removal *peerRemoval) (err error) {
+	defer func() {
+		removal.finish(err)
+	}()
+
+	// Prepare cluster client
 	c, err := s.myPeers.CreateClusterAPI(ctx, s)
 	if err != nil {
-		return false, maskAny(err)
+		return maskAny(err)
 	}
 
 	// Remove dbserver from cluster (if any)
@@ -345,24 +420,15 @@ func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err er
 				return maskAny(err)
 			}
 			// Clean out DB server
-			s.log.Info().Msgf("Starting cleanout of dbserver %s", sid)
-			if err := c.CleanOutServer(ctx, sid); err != nil {
-				s.log.Warn().Err(err).Msgf("Cleanout requested of dbserver %s failed", sid)
+			removal.setState(client.PeerRemovalStateCleaningOut)
+			removal.trackShards(func() (int, error) {
+				return s.countShardsOnServer(ctx, sid)
+			})
+			if err := s.cleanOutDBServer(ctx, c, sid, removal); err != nil {
 				return maskAny(err)
 			}
-			// Wait until server is cleaned out
-			s.log.Info().Msgf("Waiting for cleanout of dbserver %s to finish", sid)
-			for {
-				if cleanedOut, err := c.IsCleanedOut(ctx, sid); err != nil {
-					s.log.Warn().Err(err).Msgf("IsCleanedOut request of dbserver %s failed", sid)
-					return maskAny(err)
-				} else if cleanedOut {
-					break
-				}
-				// Wait a bit
-				time.Sleep(time.Millisecond * 250)
-			}
 			// Remove dbserver from cluster
+			removal.setState(client.PeerRemovalStateShuttingDown)
 			s.log.Info().Msgf("Removing dbserver %s from cluster", sid)
 			if err := sc.Shutdown(ctx, true); err != nil {
 				s.log.Warn().Err(err).Msgf("Shutdown request of dbserver %s failed", sid)
@@ -374,12 +440,13 @@ func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err er
 			if force {
 				s.log.Warn().Err(err).Msg("Failed to properly shutdown dbserver, removing peer anyway")
 			} else {
-				return false, maskAny(err)
+				return maskAny(err)
 			}
 		}
 	}
 
 	// Remove coordinator from cluster (if any)
+	removal.setState(client.PeerRemovalStateShuttingDown)
 	if peer.HasCoordinator() {
 		shutdownServer := func() error {
 			// Find id of coordinator
@@ -404,7 +471,7 @@ func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err er
 			if force {
 				s.log.Warn().Err(err).Msg("Failed to properly shutdown coordinator, removing peer anyway")
 			} else {
-				return false, maskAny(err)
+				return maskAny(err)
 			}
 		}
 	}
@@ -412,15 +479,53 @@ func (s *Service) HandleGoodbye(id string, force bool) (peerRemoved bool, err er
 	// Remove peer from cluster configuration
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.log.Info().Msgf("Removing peer %s from cluster configuration", id)
-	s.myPeers.RemovePeerByID(id)
+	s.log.Info().Msgf("Removing peer %s from cluster configuration", peer.ID)
+	s.myPeers.RemovePeerByID(peer.ID)
 
 	// Peer has been removed, update stored config
-	s.log.Info().Msgf("Removed peer %s from cluster configuration, saving setup", id)
+	s.log.Info().Msgf("Removed peer %s from cluster configuration, saving setup", peer.ID)
 	if err := s.saveSetup(); err != nil {
 		s.log.Error().Err(err).Msg("Failed to save setup")
 	}
-	return true, nil
+	return nil
+}
+
+// cleanOutDBServer moves all shards away from the dbserver with given ID
+// and waits until that has finished.
+// The cleanout runs as an agency job, the progress of which is reported to the given progressor.
+func (s *Service) cleanOutDBServer(ctx context.Context, c driver.Cluster, sid string, progress actions.Progressor) error {
+	s.log.Info().Msgf("Starting cleanout of dbserver %s", sid)
+	var jobID string
+	if err := c.CleanOutServer(driver.WithJobIDResponse(ctx, &jobID), sid); err != nil {
+		s.log.Warn().Err(err).Msgf("Cleanout requested of dbserver %s failed", sid)
+		return maskAny(err)
+	}
+	if jobID != "" {
+		// Wait until the cleanout job has finished
+		config, _, _ := s.ClusterConfig()
+		agencyClient, err := config.CreateAgencyAPI(s)
+		if err != nil {
+			return errors.Wrap(err, "failed to create agency API")
+		}
+		s.log.Info().Msgf("Waiting for cleanout job %s of dbserver %s to finish", jobID, sid)
+		progress.Progress(fmt.Sprintf("cleanout of dbserver %s waits for the job ID %s to be finished", sid, jobID))
+		if err := WaitForFinishedJob(progress, ctx, jobID, agencyClient); err != nil {
+			s.log.Warn().Err(err).Msgf("Cleanout job %s of dbserver %s did not finish", jobID, sid)
+			return errors.Wrapf(err, "failed waiting for the cleanout job %s to be finished", jobID)
+		}
+	}
+	// Wait until server is cleaned out
+	s.log.Info().Msgf("Waiting for cleanout of dbserver %s to finish", sid)
+	for {
+		if cleanedOut, err := c.IsCleanedOut(ctx, sid); err != nil {
+			s.log.Warn().Err(err).Msgf("IsCleanedOut request of dbserver %s failed", sid)
+			return maskAny(err)
+		} else if cleanedOut {
+			return nil
+		}
+		// Wait a bit
+		time.Sleep(time.Millisecond * 250)
+	}
 }
 
 // sendMasterLeaveCluster informs the master that we're leaving for good.
@@ -456,7 +561,7 @@ func (s *Service) sendMasterLeaveCluster() error {
 	if err != nil {
 		return maskAny(err)
 	}
-	resp, err := httpClient.Post(u, contentTypeJSON, bytes.NewReader(data))
+	resp, err := s.peerAuth.httpClient.Post(u, contentTypeJSON, bytes.NewReader(data))
 	if err != nil {
 		return maskAny(err)
 	}
@@ -573,6 +678,11 @@ func (s *Service) UpgradeManager() UpgradeManager {
 	return s.upgradeManager
 }
 
+// RestartManager returns the restart manager service.
+func (s *Service) RestartManager() RestartManager {
+	return s.restartManager
+}
+
 // StatusItem contain a single point in time for a status feedback channel.
 type StatusItem struct {
 	PrevStatusCode int
@@ -630,7 +740,7 @@ func (s *Service) TestInstance(ctx context.Context, serverType definitions.Serve
 				return "", -3, maskAny(err)
 			}
 			if resp.StatusCode() != 200 {
-				return "", resp.StatusCode(), maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+				return "", resp.StatusCode(), maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode()))
 			}
 			versionResponse := struct {
 				Version string `json:"version"`
@@ -752,7 +862,7 @@ func (s *Service) TestInstance(ctx context.Context, serverType definitions.Serve
 				return false, nil
 			}
 
-			return false, maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+			return false, maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode()))
 		}
 
 		checkInstanceOnce := func() bool {
@@ -1002,6 +1112,19 @@ func (s *Service) ChangeState(newState State) {
 	s.state = newState
 }
 
+// State returns the current state of the service
+func (s *Service) State() State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// LastClusterManagerIteration returns the time the runtime cluster manager last started
+// an iteration of its loop, or a zero time when it is not running.
+func (s *Service) LastClusterManagerIteration() time.Time {
+	return s.runtimeClusterManager.LastIteration()
+}
+
 // PrepareDatabaseServerRequestFunc returns a function that is used to
 // prepare a request to a database server (including authentication).
 func (s *Service) PrepareDatabaseServerRequestFunc() func(*http.Request) error {
@@ -1116,9 +1239,20 @@ func (s *Service) UpdateClusterConfig(newConfig ClusterConfig) {
 
 	// Only update when changed
 	if !reflect.DeepEqual(s.myPeers, newConfig) {
+		oldPeer, _ := s.myPeers.PeerByID(s.id)
+		newPeer, _ := newConfig.PeerByID(s.id)
 		s.myPeers = newConfig
 		s.saveSetup()
 		s.log.Debug().Msg("Updated cluster config")
+		if !samePeerRoles(oldPeer, newPeer) {
+			// The master has changed our roles, start/stop servers accordingly
+			s.log.Info().Msg("My roles have changed in the cluster config")
+			go func() {
+				if err := s.applyPeerRoles(context.Background(), newPeer); err != nil {
+					s.log.Error().Err(err).Msg("Failed to apply changed roles")
+				}
+			}()
+		}
 	} else {
 		s.log.Debug().Msg("Updating cluster config is not needed")
 	}
@@ -1159,6 +1293,43 @@ func (s *Service) RestartServer(serverType definitions.ServerType) error {
 	return nil
 }
 
+// ServerStatus returns the runtime status of the server of given type started by this starter
+// and whether there is such a server.
+func (s *Service) ServerStatus(serverType definitions.ServerType) (ProcessWrapperStatus, bool) {
+	w := s.runtimeServerManager.processWrapperByType(serverType)
+	if w == nil {
+		return ProcessWrapperStatus{}, false
+	}
+	return w.Status(), true
+}
+
+// PeerClientOptions returns the options of clients for other starters (see --starter.peer-auth).
+func (s *Service) PeerClientOptions() []client.ClientOption {
+	return s.peerAuth.clientOptions
+}
+
+// PeerHTTPClient returns the HTTP client used for requests to other starters.
+func (s *Service) PeerHTTPClient() *http.Client {
+	return s.peerAuth.httpClient
+}
+
+// StarterRequestsAuthenticated returns true when requests to the starter API are authenticated
+// (see --starter.peer-auth & --auth.starter-api).
+func (s *Service) StarterRequestsAuthenticated() bool {
+	return s.apiAuth.requestsAuthenticated()
+}
+
+// CreateCallbackURL returns the URL of the callback with given path on the given starter URL,
+// including a token when peer authentication is enabled.
+func (s *Service) CreateCallbackURL(ownURL, path string) (string, error) {
+	return s.peerAuth.createCallbackURL(ownURL, path)
+}
+
+// UpgradeHistoryFolder returns the folder in which this starter keeps archived upgrade plans.
+func (s *Service) UpgradeHistoryFolder() string {
+	return filepath.Join(s.cfg.DataDir, "upgrade-history")
+}
+
 func (s *Service) getHTTPServerPort() (containerPort, hostPort int, err error) {
 	containerPort = s.cfg.MasterPort
 	hostPort = s.announcePort
@@ -1187,7 +1358,7 @@ func (s *Service) createHTTPServer(config Config) (srv *httpServer, containerPor
 	hostAddr = net.JoinHostPort(config.OwnAddress, strconv.Itoa(hostPort))
 
 	// Create HTTP server
-	return newHTTPServer(s.log, s, &s.runtimeServerManager, config, s.id), containerPort, hostAddr, containerAddr, nil
+	return newHTTPServer(s.log, s, &s.runtimeServerManager, config, s.id, s.peerAuth, s.apiAuth), containerPort, hostAddr, containerAddr, nil
 }
 
 // startHTTPServer initializes and runs the HTTP server.
@@ -1213,6 +1384,11 @@ func (s *Service) startRunning(runner Runner, config Config, bsCfg BootstrapConf
 		s.log.Fatal().Msgf("Cannot find peer information for my ID ('%s')", s.id)
 	}
 
+	// Ensure we do not start servers with outdated encryption keys
+	if err := s.checkEncryptionKeysOfPeers(s.stopPeer.ctx); err != nil {
+		s.log.Fatal().Err(err).Msg("Cannot start servers")
+	}
+
 	// If we're a local slave, do not try to become master (because we have no port mapping in docker)
 	if s.isLocalSlave {
 		s.runtimeClusterManager.AvoidBeingMaster()
@@ -1241,6 +1417,13 @@ func (s *Service) startRunning(runner Runner, config Config, bsCfg BootstrapConf
 		s.upgradeManager.RunWatchUpgradePlan(s.stopPeer.ctx)
 	}()
 
+	// Start the restart manager
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.restartManager.RunWatchRestartPlan(s.stopPeer.ctx)
+	}()
+
 	// Wait until managers have terminated
 	wg.Wait()
 }
@@ -1270,8 +1453,10 @@ func (s *Service) Run(rootCtx context.Context, bsCfg BootstrapConfig, myPeers Cl
 
 	// Load certificates (if needed)
 	var err error
-	if s.tlsConfig, err = bsCfg.CreateTLSConfig(); err != nil {
-		return maskAny(err)
+	if !bsCfg.SslAutoCA {
+		if s.tlsConfig, err = bsCfg.CreateTLSConfig(); err != nil {
+			return maskAny(err)
+		}
 	}
 
 	// Guess own IP address if not specified
@@ -1280,10 +1465,32 @@ func (s *Service) Run(rootCtx context.Context, bsCfg BootstrapConfig, myPeers Cl
 	// Find the port mapping if running in a docker container
 	s.cfg, s.announcePort, s.isNetHost = s.cfg.GetNetworkEnvironment(s.log)
 
+	// Prepare the server certificate issued by the auto CA (if needed)
+	if bsCfg.SslAutoCA {
+		s.autoCA = newAutoCAManager(s.log, bsCfg.DataDir, bsCfg.SslAutoOrganization)
+		if err := s.autoCA.Prepare(s.autoCAHosts()); err != nil {
+			return errors.Wrap(err, "Failed to prepare server certificate")
+		}
+		s.tlsConfig = s.autoCA.TLSConfig(s.cfg.PeerAuthMode == PeerAuthModeMTLS)
+	}
+
+	// Configure authentication of the starter API
+	secrets := jwtSecretSource{secret: s.jwtSecret, folder: bsCfg.JWTFolderDir()}
+	if s.peerAuth, err = newPeerAuthenticator(s.cfg.PeerAuthMode, secrets, s.autoCA); err != nil {
+		return maskAny(err)
+	}
+	if s.apiAuth, err = newAPIAuthenticator(s.cfg.APIAuthentication, secrets, s.peerAuth); err != nil {
+		return maskAny(err)
+	}
+	if s.autoCA != nil {
+		go s.runAutoCARenewal(s.stopPeer.ctx)
+	}
+
 	// Create a runner
 	var runner Runner
 	runner, s.cfg, s.allowSameDataDir = s.cfg.CreateRunner(s.log)
 	s.runner = runner
+	s.restoreArangodBinary(bsCfg)
 
 	// Detect database version
 	ctx := context.Background()
@@ -1307,6 +1514,12 @@ func (s *Service) Run(rootCtx context.Context, bsCfg BootstrapConfig, myPeers Cl
 		}
 	}
 
+	if bsCfg.RocksDBEncryptionKeyFile != "" && s.DatabaseFeatures().GetEncryptionFolderOption() {
+		if err := initEncryptionKeyFolder(bsCfg.EncryptionKeyFolderDir(), bsCfg.RocksDBEncryptionKeyFile); err != nil {
+			return errors.Wrap(err, "Failed to initialize encryption key folder")
+		}
+	}
+
 	// Check storage engine
 	if err := s.validateStorageEngine(bsCfg.ServerStorageEngine, s.DatabaseFeatures()); err != nil {
 		return maskAny(err)
@@ -200,7 +200,7 @@ func (s *Service) getRecoveryClusterConfig(ctx context.Context, masterAddresses
 			return ClusterConfig{}, maskAny(err)
 		}
 		// Perform request
-		r, err := httpClient.Get(helloURL)
+		r, err := s.peerAuth.httpClient.Get(helloURL)
 		if err != nil {
 			return ClusterConfig{}, maskAny(err)
 		}
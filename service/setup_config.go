@@ -35,7 +35,7 @@ import (
 var (
 	// SetupConfigVersion is the semantic version of the process that created this.
 	// If the structure of SetupConfigFile (or any underlying fields) or its semantics change, you must increase this version.
-	setupConfigVersion    = *semver.New("0.2.2") // Current version
+	setupConfigVersion    = *semver.New("0.2.3") // Current version
 	minSetupConfigVersion = *semver.New("0.2.1") // Minimum version that we can support
 )
 
@@ -52,6 +52,10 @@ type SetupConfigFile struct {
 	Mode             ServiceMode   `json:"mode,omitempty"` // Starter mode (cluster|single)
 	SslKeyFile       string        `json:"ssl-keyfile,omitempty"`
 	JwtSecret        string        `json:"jwt-secret,omitempty"`
+	// ArangodBinary is the arangod executable (or docker image) switched to for an upgrade
+	ArangodBinary string `json:"arangod-binary,omitempty"`
+	// ConfiguredArangodBinary is the arangod executable (or docker image) configured when switching
+	ConfiguredArangodBinary string `json:"configured-arangod-binary,omitempty"`
 }
 
 // saveSetup saves the current peer configuration to disk.
@@ -65,6 +69,10 @@ func (s *Service) saveSetup() error {
 		SslKeyFile:       s.sslKeyFile,
 		JwtSecret:        s.jwtSecret,
 	}
+	if s.arangodBinary != "" {
+		cfg.ArangodBinary = s.arangodBinary
+		cfg.ConfiguredArangodBinary = s.cfg.configuredArangodBinary()
+	}
 	b, err := json.Marshal(cfg)
 	if err != nil {
 		s.log.Error().Err(err).Msg("Cannot serialize config")
@@ -117,6 +125,8 @@ func ReadSetupConfig(log zerolog.Logger, dataDir string, bsCfg BootstrapConfig)
 		bsCfg.JwtSecret = cfg.JwtSecret
 	}
 	bsCfg.AgencySize = cfg.Peers.AgencySize
+	bsCfg.ArangodBinary = cfg.ArangodBinary
+	bsCfg.ConfiguredArangodBinary = cfg.ConfiguredArangodBinary
 
 	return bsCfg, cfg.Peers, true, nil
 }
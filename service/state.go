@@ -33,6 +33,24 @@ const (
 	stateRunningSlave                 // running phase, acting as slave
 )
 
+// String returns a human readable name of the state.
+func (s State) String() string {
+	switch s {
+	case stateStart:
+		return "start"
+	case stateBootstrapMaster:
+		return "bootstrap-master"
+	case stateBootstrapSlave:
+		return "bootstrap-slave"
+	case stateRunningMaster:
+		return "running-master"
+	case stateRunningSlave:
+		return "running-slave"
+	default:
+		return "unknown"
+	}
+}
+
 // IsBootstrap returns true if given state is bootstrap master/slave
 func (s State) IsBootstrap() bool {
 	return s == stateBootstrapMaster || s == stateBootstrapSlave
@@ -0,0 +1,241 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/pkg/api"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+	"github.com/arangodb-helper/arangodb/pkg/features"
+	rotateClient "github.com/arangodb-helper/arangodb/service/clients"
+)
+
+const (
+	tlsRefreshTimeout = time.Minute
+)
+
+func (s *httpServer) registerTLSFunctions(m *http.ServeMux) {
+	m.HandleFunc("/admin/tls/refresh", s.apiAuth.requireRoleByMethod(s.tlsRefresh))
+	m.HandleFunc("/local/tls/refresh", s.apiAuth.requirePeerOrRole(StarterRoleAdmin, s.localTLSRefresh))
+}
+
+// tlsRefresh replaces the keyfile (if given in the request body) on all peers
+// and lets all members reload it.
+func (s *httpServer) tlsRefresh(w http.ResponseWriter, r *http.Request) {
+	code, err := s.tlsRefreshE(r, false)
+	writeEmptyResponse(w, code, err)
+}
+
+// localTLSRefresh replaces the keyfile (if given in the request body) of this peer
+// and lets all members of this peer reload it.
+func (s *httpServer) localTLSRefresh(w http.ResponseWriter, r *http.Request) {
+	code, err := s.tlsRefreshE(r, true)
+	writeEmptyResponse(w, code, err)
+}
+
+func (s *httpServer) tlsRefreshE(r *http.Request, local bool) (int, error) {
+	if r.Method != http.MethodPost {
+		return http.StatusMethodNotAllowed, errors.Errorf("Method not allowed")
+	}
+
+	keyFile, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(bytes.TrimSpace(keyFile)) == 0 {
+		keyFile = nil
+	} else if _, err := tls.X509KeyPair(keyFile, keyFile); err != nil {
+		return http.StatusBadRequest, errors.Errorf("Invalid keyfile: %s", err)
+	}
+
+	s.log.Info().Bool("local", local).Bool("new-keyfile", keyFile != nil).Msgf("Received TLS Refresh call")
+	if local {
+		code, err := s.refreshTLSOnMembers(keyFile)
+		if err != nil {
+			s.log.Warn().Err(err).Msgf("TLS Refresh call failed")
+			return code, err
+		}
+	} else {
+		code, err := s.synchronizeTLSOnPeers(keyFile)
+		if err != nil {
+			s.log.Warn().Err(err).Msgf("TLS Refresh call failed")
+			return code, err
+		}
+	}
+	s.log.Info().Msgf("TLS Refresh call done")
+
+	return 0, nil
+}
+
+// synchronizeTLSOnPeers refreshes the TLS keyfile on all peers, one peer at a time.
+func (s *httpServer) synchronizeTLSOnPeers(keyFile []byte) (int, error) {
+	peers, _, _ := s.context.ClusterConfig()
+
+	for _, p := range peers.AllPeers {
+		if p.ID == s.idInfo.ID {
+			if code, err := s.refreshTLSOnMembers(keyFile); err != nil {
+				return code, errors.Wrapf(err, "Failed to refresh TLS on peer %s", p.ID)
+			}
+			continue
+		}
+
+		c, err := createPeerClient(p, s.peerAuth.clientOptions...)
+		if err != nil {
+			return 0, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), tlsRefreshTimeout)
+		_, err = c.AdminTLSRefreshLocal(ctx, keyFile)
+		cancel()
+		if err != nil {
+			return 0, errors.Wrapf(err, "Failed to refresh TLS on peer %s", p.ID)
+		}
+	}
+
+	return 0, nil
+}
+
+// refreshTLSOnMembers replaces the keyfile of this peer (if given) and lets
+// all members of this peer reload it.
+func (s *httpServer) refreshTLSOnMembers(keyFile []byte) (int, error) {
+	_, p, mode := s.context.ClusterConfig()
+
+	keyFilePath := s.context.SslKeyFile()
+	if keyFilePath == "" || p == nil || !p.IsSecure {
+		return http.StatusPreconditionFailed, errors.Errorf("TLS is not enabled")
+	}
+
+	if keyFile != nil {
+		if s.useDockerRunner {
+			// The keyfile is mounted as a single file, so a replaced file is not seen by the containers
+			return http.StatusPreconditionFailed, errors.Errorf("Replacing the keyfile is not supported with the docker runner, update %s and refresh without a keyfile instead", keyFilePath)
+		}
+		if err := replaceKeyFile(keyFilePath, keyFile); err != nil {
+			return 0, err
+		}
+	}
+
+	content, err := ioutil.ReadFile(keyFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, s.forEachServerType(mode, p, func(m ServiceMode, p *Peer, t definitions.ServerType) error {
+		client, err := p.CreateClient(s.context, t)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), tlsRefreshTimeout)
+		defer cancel()
+
+		v, err := client.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if !features.TLSRotation().Enabled(features.Version{
+			Enterprise: v.IsEnterprise(),
+			Version:    v.Version,
+		}) {
+			return errors.Errorf("TLS rotation is not supported by %s version %s", t, v.Version)
+		}
+
+		details, err := rotateClient.NewClient(client).RefreshTLS(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !keyFileChecksumMatches(details.Result.KeyFile.GetSHA().Checksum(), content) {
+			return errors.Errorf("Keyfile checksum of %s does not match the local keyfile", t)
+		}
+
+		return nil
+	})
+}
+
+// replaceKeyFile replaces the content of the keyfile at the given path.
+// The content is written to a temporary file that is renamed to the keyfile,
+// so a server never reads a half-written keyfile.
+func replaceKeyFile(keyFilePath string, content []byte) error {
+	info, err := os.Stat(keyFilePath)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(keyFilePath), filepath.Base(keyFilePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), keyFilePath)
+}
+
+// keyFileChecksumMatches returns true if the given checksum (as reported by a server)
+// is the SHA256 of the raw content of the given keyfile.
+func keyFileChecksumMatches(checksum string, content []byte) bool {
+	return checksum == Sha256sumRaw(content)
+}
+
+// writeEmptyResponse writes an api.Empty response holding the given error (if any).
+func writeEmptyResponse(w http.ResponseWriter, code int, err error) {
+	e := api.Empty{
+		Error: api.NewError(err),
+	}
+
+	if err != nil {
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+	} else {
+		code = http.StatusOK
+	}
+
+	w.WriteHeader(code)
+
+	m, err := json.Marshal(e)
+	if err == nil {
+		w.Write(m)
+	}
+}
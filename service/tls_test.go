@@ -0,0 +1,66 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReplaceKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starter-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyFilePath := filepath.Join(dir, "tls.keyfile")
+	require.NoError(t, ioutil.WriteFile(keyFilePath, []byte("a much longer old keyfile content"), 0600))
+	before, err := os.Stat(keyFilePath)
+	require.NoError(t, err)
+
+	require.NoError(t, replaceKeyFile(keyFilePath, []byte("new keyfile")))
+
+	content, err := ioutil.ReadFile(keyFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "new keyfile", string(content))
+	after, err := os.Stat(keyFilePath)
+	require.NoError(t, err)
+	require.Equal(t, before.Mode(), after.Mode())
+
+	// No temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// A missing keyfile is not created
+	require.Error(t, replaceKeyFile(filepath.Join(dir, "missing"), []byte("new keyfile")))
+}
+
+func Test_KeyFileChecksumMatches(t *testing.T) {
+	content := []byte("keyfile\n")
+	require.True(t, keyFileChecksumMatches(fmt.Sprintf("%0x", sha256.Sum256(content)), content))
+	require.False(t, keyFileChecksumMatches(Sha256sum(content), content), "checksum of trimmed content must not match")
+	require.False(t, keyFileChecksumMatches(Sha256sumRaw([]byte("other")), content))
+}
@@ -0,0 +1,214 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arangodb/go-driver/agency"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+var (
+	upgradeHistoryKey = []string{"arangodb-helper", "arangodb", "upgrade-history"}
+)
+
+const (
+	// upgradeHistoryLimit is the maximum number of plans kept in the upgrade history in the agency.
+	upgradeHistoryLimit = 50
+)
+
+// ArchivedUpgradePlan is the JSON structure of an upgrade plan in the upgrade history.
+type ArchivedUpgradePlan struct {
+	UpgradePlan
+	Outcome    client.UpgradeOutcome `json:"outcome"`
+	ArchivedAt time.Time             `json:"archived_at"`
+}
+
+// upgradeHistoryPlanKey returns the agency key of the archived plan with given ID.
+func upgradeHistoryPlanKey(id string) []string {
+	return append(append([]string{}, upgradeHistoryKey...), id)
+}
+
+// archiveUpgradePlan stores the given plan in the upgrade history in the agency (if any)
+// and in the upgrade history folder of this starter.
+func (m *upgradeManager) archiveUpgradePlan(ctx context.Context, plan UpgradePlan, outcome client.UpgradeOutcome) error {
+	archived := ArchivedUpgradePlan{
+		UpgradePlan: plan,
+		Outcome:     outcome,
+		ArchivedAt:  time.Now(),
+	}
+
+	// Store on disk
+	folder := m.upgradeManagerContext.UpgradeHistoryFolder()
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return maskAny(err)
+	}
+	content, err := json.MarshalIndent(archived, "", "  ")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(folder, plan.ID()+".json"), content, 0644); err != nil {
+		return maskAny(err)
+	}
+
+	// Store in agency
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.HasAgency() {
+		return nil
+	}
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := api.WriteKey(ctx, upgradeHistoryPlanKey(plan.ID()), archived, 0); err != nil {
+		return maskAny(err)
+	}
+
+	// Remove the oldest plans
+	history, err := m.readUpgradeHistory(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	for i := 0; i < len(history)-upgradeHistoryLimit; i++ {
+		if err := api.RemoveKey(ctx, upgradeHistoryPlanKey(history[i].ID())); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	m.log.Info().Str("id", plan.ID()).Msgf("Archived upgrade plan as %s", outcome)
+	return nil
+}
+
+// readUpgradeHistory reads all archived plans, oldest first.
+// With an agency, the plans are read from the agency, otherwise they are read
+// from the upgrade history folder of this starter.
+func (m *upgradeManager) readUpgradeHistory(ctx context.Context) ([]ArchivedUpgradePlan, error) {
+	var history []ArchivedUpgradePlan
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if mode.HasAgency() {
+		api, err := m.createAgencyAPI()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		plans := make(map[string]ArchivedUpgradePlan)
+		if err := api.ReadKey(ctx, upgradeHistoryKey, &plans); err != nil && !agency.IsKeyNotFound(err) {
+			return nil, maskAny(err)
+		}
+		for _, p := range plans {
+			history = append(history, p)
+		}
+	} else {
+		folder := m.upgradeManagerContext.UpgradeHistoryFolder()
+		files, err := ioutil.ReadDir(folder)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, maskAny(err)
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+				continue
+			}
+			content, err := ioutil.ReadFile(filepath.Join(folder, f.Name()))
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			var p ArchivedUpgradePlan
+			if err := json.Unmarshal(content, &p); err != nil {
+				m.log.Warn().Err(err).Str("file", f.Name()).Msg("Ignoring invalid archived upgrade plan")
+				continue
+			}
+			history = append(history, p)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].CreatedAt.Before(history[j].CreatedAt)
+	})
+	return history, nil
+}
+
+// History returns the finished, aborted & rolled back upgrade plans, oldest first.
+func (m *upgradeManager) History(ctx context.Context) (client.UpgradeHistory, error) {
+	history, err := m.readUpgradeHistory(ctx)
+	if err != nil {
+		return client.UpgradeHistory{}, maskAny(err)
+	}
+	result := client.UpgradeHistory{
+		Plans: make([]client.UpgradeHistoryPlan, 0, len(history)),
+	}
+	for _, p := range history {
+		result.Plans = append(result.Plans, p.createHistoryPlan(m.upgradeManagerContext))
+	}
+	return result, nil
+}
+
+// createHistoryPlan converts the given archived plan into its client representation.
+func (p ArchivedUpgradePlan) createHistoryPlan(upgradeManagerContext UpgradeManagerContext) client.UpgradeHistoryPlan {
+	result := client.UpgradeHistoryPlan{
+		ID:           p.ID(),
+		Outcome:      p.Outcome,
+		TriggeredBy:  p.TriggeredBy,
+		CreatedAt:    p.CreatedAt,
+		ArchivedAt:   p.ArchivedAt,
+		FromVersions: p.FromVersions,
+		ToVersion:    p.ToVersion,
+	}
+	rolledBack := make(map[string]bool)
+	for _, e := range p.FinishedRollbackEntries {
+		rolledBack[e.PeerID+"/"+string(e.Type)] = true
+	}
+	add := func(e UpgradePlanEntry, upgraded bool) {
+		entry := client.UpgradeHistoryEntry{
+			PeerID:     e.PeerID,
+			Upgraded:   upgraded,
+			RolledBack: rolledBack[e.PeerID+"/"+string(e.Type)],
+			Failures:   append([]string{}, e.FailureHistory...),
+		}
+		if e.Failures > 0 {
+			entry.Failures = append(entry.Failures, e.Reason)
+		}
+		if len(entry.Failures) == 0 {
+			entry.Failures = nil
+		}
+		// Peers may have been removed since the upgrade
+		if statusServer, err := e.CreateStatusServer(upgradeManagerContext); err == nil && statusServer != nil {
+			entry.UpgradeStatusServer = *statusServer
+		} else {
+			entry.Type = client.ServerType(e.Type)
+			e.setStatusProgress(&entry.UpgradeStatusServer)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	for _, e := range p.FinishedEntries {
+		add(e, true)
+	}
+	for _, e := range p.Entries {
+		add(e, false)
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+// historyTestContext implements the parts of UpgradeManagerContext used by the upgrade history.
+type historyTestContext struct {
+	UpgradeManagerContext
+	config ClusterConfig
+	folder string
+}
+
+func (c historyTestContext) ClusterConfig() (ClusterConfig, *Peer, ServiceMode) {
+	return c.config, nil, ServiceModeSingle
+}
+
+func (c historyTestContext) UpgradeHistoryFolder() string {
+	return c.folder
+}
+
+func Test_UpgradePlanResetFailuresHistory(t *testing.T) {
+	plan := UpgradePlan{
+		Entries: []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeSingle, Failures: 2, Reason: "timeout"}},
+	}
+	plan.ResetFailures()
+	require.Equal(t, 0, plan.Entries[0].Failures)
+	require.Equal(t, []string{"timeout"}, plan.Entries[0].FailureHistory)
+
+	// Entries without failures do not add to the history
+	plan.ResetFailures()
+	require.Equal(t, []string{"timeout"}, plan.Entries[0].FailureHistory)
+}
+
+func Test_UpgradeHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "starter-upgrade-history")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := &upgradeManager{
+		deploymentChecks: deploymentChecks{
+			log: zerolog.Nop(),
+			upgradeManagerContext: historyTestContext{
+				config: ClusterConfig{AllPeers: []Peer{{ID: "a", Address: "10.0.0.1", Port: 8528, PortOffset: 0}}},
+				folder: dir,
+			},
+		},
+	}
+	ctx := context.Background()
+	createdAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	failed := UpgradePlan{
+		CreatedAt:   createdAt.Add(time.Hour),
+		ToVersion:   "3.7.11",
+		TriggeredBy: "admin@host",
+		Entries: []UpgradePlanEntry{
+			{PeerID: "a", Type: UpgradeEntryTypeSingle, Failures: 1, Reason: "timeout", FailureHistory: []string{"crashed"}},
+		},
+	}
+	finished := UpgradePlan{
+		CreatedAt:       createdAt,
+		ToVersion:       "3.7.10",
+		FinishedEntries: []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeSingle}},
+	}
+	require.NoError(t, m.archiveUpgradePlan(ctx, failed, client.UpgradeOutcomeFailed))
+	require.NoError(t, m.archiveUpgradePlan(ctx, finished, client.UpgradeOutcomeFinished))
+
+	history, err := m.History(ctx)
+	require.NoError(t, err)
+	require.Len(t, history.Plans, 2)
+
+	// Oldest plan first
+	p := history.Plans[0]
+	require.Equal(t, "20210301-100000", p.ID)
+	require.Equal(t, client.UpgradeOutcomeFinished, p.Outcome)
+	require.Len(t, p.Entries, 1)
+	require.True(t, p.Entries[0].Upgraded)
+	require.Equal(t, "10.0.0.1", p.Entries[0].Address)
+	require.Nil(t, p.Entries[0].Failures)
+
+	p = history.Plans[1]
+	require.Equal(t, client.UpgradeOutcomeFailed, p.Outcome)
+	require.Equal(t, "admin@host", p.TriggeredBy)
+	require.Len(t, p.Entries, 1)
+	require.False(t, p.Entries[0].Upgraded)
+	require.Equal(t, []string{"crashed", "timeout"}, p.Entries[0].Failures)
+}
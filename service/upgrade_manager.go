@@ -31,6 +31,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/arangodb-helper/arangodb/pkg/api"
 	"github.com/arangodb-helper/arangodb/pkg/definitions"
 
 	driver "github.com/arangodb/go-driver"
@@ -42,12 +43,20 @@ import (
 
 	"github.com/arangodb-helper/arangodb/client"
 	"github.com/arangodb-helper/arangodb/pkg/trigger"
+	"github.com/arangodb-helper/arangodb/service/actions"
 )
 
 // UpgradeManager is the API of a service used to control the upgrade process from 1 database version to the next.
 type UpgradeManager interface {
-	// StartDatabaseUpgrade is called to start the upgrade process
-	StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool) error
+	// StartDatabaseUpgrade is called to start the upgrade process.
+	// TriggeredBy describes who started the upgrade, it is kept in the upgrade history.
+	// When target is not empty, all starters switch to the arangod executable (or docker image)
+	// of the target before upgrading their servers.
+	StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, triggeredBy string, target client.UpgradeTarget) error
+
+	// DryRunDatabaseUpgrade runs all checks of StartDatabaseUpgrade and returns the
+	// plan it would create, without changing anything.
+	DryRunDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, target client.UpgradeTarget) (client.UpgradeDryRun, error)
 
 	// RetryDatabaseUpgrade resets a failure mark in the existing upgrade plan
 	// such that the starters will retry the upgrade once more.
@@ -59,9 +68,28 @@ type UpgradeManager interface {
 	// If there is no plan, a NotFoundError will be returned.
 	AbortDatabaseUpgrade(ctx context.Context) error
 
+	// RollbackDatabaseUpgrade turns the existing upgrade plan into a plan
+	// that restarts all servers that have already been upgraded with the
+	// binary (or docker image) they used before the upgrade.
+	// If there is no plan, a NotFoundError will be returned.
+	RollbackDatabaseUpgrade(ctx context.Context) error
+
+	// PauseDatabaseUpgrade marks the existing upgrade plan as paused.
+	// Note that Starters working on an entry of the upgrade
+	// will finish that entry.
+	// If there is no plan, a NotFoundError will be returned.
+	PauseDatabaseUpgrade(ctx context.Context) error
+
+	// ResumeDatabaseUpgrade removes the paused mark from the existing upgrade plan.
+	// If there is no plan, a NotFoundError will be returned.
+	ResumeDatabaseUpgrade(ctx context.Context) error
+
 	// Status returns the status of any upgrade plan
 	Status(context.Context) (client.UpgradeStatus, error)
 
+	// History returns the finished, aborted & rolled back upgrade plans, oldest first.
+	History(context.Context) (client.UpgradeHistory, error)
+
 	// IsServerUpgradeInProgress returns true when the upgrade manager is busy upgrading the server of given type.
 	IsServerUpgradeInProgress(serverType definitions.ServerType) bool
 
@@ -71,6 +99,10 @@ type UpgradeManager interface {
 	// ServerDatabaseAutoUpgradeStarter is called when a server of given type has been be started with --database.auto-upgrade
 	ServerDatabaseAutoUpgradeStarter(serverType definitions.ServerType)
 
+	// ServerStarted is called when a server of given type has been started
+	// without --database.auto-upgrade.
+	ServerStarted(serverType definitions.ServerType)
+
 	// RunWatchUpgradePlan keeps watching the upgrade plan until the given context is canceled.
 	RunWatchUpgradePlan(context.Context)
 
@@ -85,8 +117,31 @@ type UpgradeManagerContext interface {
 	ClusterConfig() (ClusterConfig, *Peer, ServiceMode)
 	// RestartServer triggers a restart of the server of the given type.
 	RestartServer(serverType definitions.ServerType) error
+	// ArangodTarget returns the arangod executable (or docker image) this starter uses
+	// to start arangod servers.
+	ArangodTarget() client.UpgradeTarget
+	// UpgradeHistoryFolder returns the path of the folder in which finished upgrade plans are archived.
+	UpgradeHistoryFolder() string
+	// LocalSyncHealth checks that the sync master & worker started by this starter (if any) are healthy and,
+	// when that sync master is the leading one, that synchronization is not broken.
+	LocalSyncHealth(ctx context.Context) (api.SyncHealth, error)
+	// SwitchArangodBinary lets this starter use the given arangod executable (or docker image)
+	// for all arangod servers it starts from now on.
+	// When validateOnly is set, the target is only checked.
+	SwitchArangodBinary(ctx context.Context, target client.UpgradeTarget, validateOnly bool) (client.SwitchArangodBinaryResult, error)
 	// IsRunningMaster returns if the starter is the running master.
 	IsRunningMaster() (isRunningMaster, isRunning bool, masterURL string)
+	// ServerStatus returns the runtime status of the server of given type started by this starter
+	// and whether there is such a server.
+	ServerStatus(serverType definitions.ServerType) (ProcessWrapperStatus, bool)
+	// PeerClientOptions returns the options of clients for other starters (see --starter.peer-auth).
+	PeerClientOptions() []client.ClientOption
+	// StarterRequestsAuthenticated returns true when requests to the starter API are authenticated
+	// (see --starter.peer-auth & --auth.starter-api).
+	StarterRequestsAuthenticated() bool
+	// CreateCallbackURL returns the URL of the callback with given path on the given starter URL,
+	// which other starters & the agency can use.
+	CreateCallbackURL(ownURL, path string) (string, error)
 	// TestInstance checks the `up` status of an arangod server instance.
 	TestInstance(ctx context.Context, serverType definitions.ServerType, address string, port int,
 		statusChanged chan StatusItem) (up, correctRole bool, version, role, mode string, isLeader bool, statusTrail []int, cancelled bool)
@@ -95,8 +150,10 @@ type UpgradeManagerContext interface {
 // NewUpgradeManager creates a new upgrade manager.
 func NewUpgradeManager(log zerolog.Logger, upgradeManagerContext UpgradeManagerContext) UpgradeManager {
 	return &upgradeManager{
-		log:                   log,
-		upgradeManagerContext: upgradeManagerContext,
+		deploymentChecks: deploymentChecks{
+			log:                   log,
+			upgradeManagerContext: upgradeManagerContext,
+		},
 	}
 }
 
@@ -126,6 +183,17 @@ type UpgradePlan struct {
 	Finished        bool               `json:"finished"`
 	FromVersions    []driver.Version   `json:"from_versions"`
 	ToVersion       driver.Version     `json:"to_version"`
+	TriggeredBy     string             `json:"triggered_by,omitempty"`
+	// Target is the arangod executable (or docker image) all starters switch to before
+	// upgrading their servers. It is empty when the starters keep their current one.
+	Target client.UpgradeTarget `json:"target"`
+	// Paused is set when no further entries must be processed until the plan is resumed.
+	Paused bool `json:"paused,omitempty"`
+	// Rollback is set when the upgrade is being rolled back.
+	// From then on only the rollback entries are processed.
+	Rollback                bool               `json:"rollback,omitempty"`
+	RollbackEntries         []UpgradePlanEntry `json:"rollback_entries,omitempty"`
+	FinishedRollbackEntries []UpgradePlanEntry `json:"finished_rollback_entries,omitempty"`
 }
 
 // IsEmpty returns true when the given plan has not been initialized.
@@ -135,12 +203,12 @@ func (p UpgradePlan) IsEmpty() bool {
 
 // IsReady returns true when all entries have finished.
 func (p UpgradePlan) IsReady() bool {
-	return len(p.Entries) == 0
+	return len(p.activeEntries()) == 0
 }
 
 // IsFailed returns true when one of the entries has failures.
 func (p UpgradePlan) IsFailed() bool {
-	for _, e := range p.Entries {
+	for _, e := range p.activeEntries() {
 		if e.Failures > 0 {
 			return true
 		}
@@ -149,13 +217,33 @@ func (p UpgradePlan) IsFailed() bool {
 }
 
 // ResetFailures resets all Failures field to 0.
+// The reasons of the failures are kept in the FailureHistory field.
 func (p *UpgradePlan) ResetFailures() {
-	for _, e := range p.Entries {
-		e.Failures = 0
-		e.Reason = ""
+	for _, entries := range [][]UpgradePlanEntry{p.Entries, p.RollbackEntries} {
+		for i := range entries {
+			if entries[i].Failures > 0 {
+				entries[i].FailureHistory = append(entries[i].FailureHistory, entries[i].Reason)
+			}
+			entries[i].Failures = 0
+			entries[i].Reason = ""
+		}
 	}
 }
 
+// ID returns the identifier of the plan in the upgrade history.
+func (p UpgradePlan) ID() string {
+	return p.CreatedAt.UTC().Format("20060102-150405")
+}
+
+// activeEntries returns the entries that are being processed,
+// which are the rollback entries once the plan is being rolled back.
+func (p UpgradePlan) activeEntries() []UpgradePlanEntry {
+	if p.Rollback {
+		return p.RollbackEntries
+	}
+	return p.Entries
+}
+
 // UpgradeEntryType is a strongly typed upgrade plan item
 type UpgradeEntryType string
 
@@ -168,6 +256,22 @@ const (
 	UpgradeEntryTypeSyncWorker  = "syncworker"
 )
 
+// IsArangod returns true when entries of the given type involve an arangod server.
+func (t UpgradeEntryType) IsArangod() bool {
+	switch t {
+	case UpgradeEntryTypeAgent, UpgradeEntryTypeDBServer, UpgradeEntryTypeCoordinator, UpgradeEntryTypeSingle:
+		return true
+	default:
+		return false
+	}
+}
+
+// SupportsRollback returns true when entries of the given type involve a
+// server that can be rolled back to its previous binary.
+func (t UpgradeEntryType) SupportsRollback() bool {
+	return t.IsArangod()
+}
+
 // UpgradePlanEntry is the JSON structure that describes a single entry
 // in an upgrade plan.
 type UpgradePlanEntry struct {
@@ -175,99 +279,101 @@ type UpgradePlanEntry struct {
 	Type     UpgradeEntryType `json:"type"`
 	Failures int              `json:"failures,omitempty"`
 	Reason   string           `json:"reason,omitempty"`
+	// FromVersion & FromTarget are recorded just before the server is upgraded,
+	// so the upgrade can be rolled back.
+	FromVersion driver.Version       `json:"from_version,omitempty"`
+	FromTarget  client.UpgradeTarget `json:"from_target"`
+	// FailureHistory contains the reasons of failures that have been reset to retry the entry.
+	FailureHistory []string  `json:"failure_history,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	// Phase & Progress describe what is happening with the server of the entry
+	// while it is being processed.
+	Phase    client.UpgradePhase `json:"phase,omitempty"`
+	Progress string              `json:"progress,omitempty"`
 }
 
-// CreateStatusServer creates a UpgradeStatusServer for the given entry.
-// When the entry does not involve a specific server, nil is returned.
-func (e UpgradePlanEntry) CreateStatusServer(upgradeManagerContext UpgradeManagerContext) (*client.UpgradeStatusServer, error) {
-	config, _, mode := upgradeManagerContext.ClusterConfig()
-	var serverType definitions.ServerType
+// ServerType returns the type of server involved in the given entry.
+func (e UpgradePlanEntry) ServerType(mode ServiceMode) (definitions.ServerType, error) {
 	switch e.Type {
 	case UpgradeEntryTypeAgent:
-		serverType = definitions.ServerTypeAgent
+		return definitions.ServerTypeAgent, nil
 	case UpgradeEntryTypeDBServer:
-		serverType = definitions.ServerTypeDBServer
+		return definitions.ServerTypeDBServer, nil
 	case UpgradeEntryTypeCoordinator:
-		serverType = definitions.ServerTypeCoordinator
+		return definitions.ServerTypeCoordinator, nil
 	case UpgradeEntryTypeSingle:
 		if mode.IsActiveFailoverMode() {
-			serverType = definitions.ServerTypeResilientSingle
-		} else {
-			serverType = definitions.ServerTypeSingle
+			return definitions.ServerTypeResilientSingle, nil
 		}
+		return definitions.ServerTypeSingle, nil
 	case UpgradeEntryTypeSyncMaster:
-		serverType = definitions.ServerTypeSyncMaster
+		return definitions.ServerTypeSyncMaster, nil
 	case UpgradeEntryTypeSyncWorker:
-		serverType = definitions.ServerTypeSyncWorker
+		return definitions.ServerTypeSyncWorker, nil
 	default:
-		return nil, maskAny(fmt.Errorf("Unknown entry type '%s'", e.Type))
+		return "", maskAny(fmt.Errorf("Unknown entry type '%s'", e.Type))
+	}
+}
+
+// CreateStatusServer creates a UpgradeStatusServer for the given entry.
+// When the entry does not involve a specific server, nil is returned.
+func (e UpgradePlanEntry) CreateStatusServer(upgradeManagerContext UpgradeManagerContext) (*client.UpgradeStatusServer, error) {
+	config, _, mode := upgradeManagerContext.ClusterConfig()
+	serverType, err := e.ServerType(mode)
+	if err != nil {
+		return nil, maskAny(err)
 	}
 	peer, found := config.PeerByID(e.PeerID)
 	if !found {
 		return nil, maskAny(fmt.Errorf("Unknown entry peer ID '%s'", e.PeerID))
 	}
 	port := peer.Port + peer.PortOffset + definitions.ServerType(serverType).PortOffset()
-	return &client.UpgradeStatusServer{
+	result := &client.UpgradeStatusServer{
 		Type:    client.ServerType(serverType),
 		Address: peer.Address,
 		Port:    port,
-	}, nil
+	}
+	e.setStatusProgress(result)
+	return result, nil
+}
+
+// setStatusProgress copies the timestamps, phase & progress of the entry into the given status.
+func (e UpgradePlanEntry) setStatusProgress(s *client.UpgradeStatusServer) {
+	if !e.StartedAt.IsZero() {
+		startedAt := e.StartedAt
+		s.StartedAt = &startedAt
+	}
+	if !e.FinishedAt.IsZero() {
+		finishedAt := e.FinishedAt
+		s.FinishedAt = &finishedAt
+	}
+	s.Phase = e.Phase
+	s.Progress = e.Progress
 }
 
 // upgradeManager is a helper used to control the upgrade process from 1 database version to the next.
 type upgradeManager struct {
-	mutex                 sync.Mutex
-	log                   zerolog.Logger
-	upgradeManagerContext UpgradeManagerContext
-	upgradeServerType     definitions.ServerType
-	updateNeeded          bool
-	cbTrigger             trigger.Trigger
+	deploymentChecks
+	mutex             sync.Mutex
+	upgradeServerType definitions.ServerType
+	updateNeeded      bool
+	rollbackNeeded    bool
+	serverRestarted   bool
+	progress          *upgradeProgress
+	cbTrigger         trigger.Trigger
 }
 
 // StartDatabaseUpgrade is called to start the upgrade process
-func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool) error {
+func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, triggeredBy string, target client.UpgradeTarget) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	// Check the versions of all starters
-	if err := m.checkStarterVersions(ctx); err != nil {
-		return maskAny(err)
-	}
-
-	// Fetch (binary) database versions of all starters
-	binaryDBVersions, err := m.fetchBinaryDatabaseVersions(ctx)
+	prepared, err := m.prepareDatabaseUpgrade(ctx, forceMinorUpgrade, target)
 	if err != nil {
 		return maskAny(err)
 	}
-	if len(binaryDBVersions) > 1 {
-		return maskAny(client.NewBadRequestError(fmt.Sprintf("Found multiple database versions (%v). Make sure all machines have the same version", binaryDBVersions)))
-	}
-	if len(binaryDBVersions) == 0 {
-		return maskAny(client.NewBadRequestError("Found no database versions. This is likely a bug"))
-	}
-	toVersion := binaryDBVersions[0]
-
-	// Fetch (running) database versions of all starters
-	runningDBVersions, err := m.fetchRunningDatabaseVersions(ctx)
-	if err != nil {
-		return maskAny(err)
-	}
-
-	// Check if we can upgrade from running to binary versions
-	specialUpgradeFrom346 := false
-	rules := upgraderules.CheckUpgradeRules
-	if forceMinorUpgrade {
-		rules = upgraderules.CheckSoftUpgradeRules
-	}
-
-	for _, from := range runningDBVersions {
-		if err := rules(from, toVersion); err != nil {
-			return maskAny(errors.Wrap(err, "Found incompatible upgrade versions"))
-		}
-		if from.CompareTo("3.4.6") == 0 {
-			specialUpgradeFrom346 = true
-		}
-	}
+	runningDBVersions, toVersion := prepared.fromVersions, prepared.toVersion
 
 	// Fetch mode
 	config, myPeer, mode := m.upgradeManagerContext.ClusterConfig()
@@ -276,18 +382,22 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		// Run upgrade without agency (i.e., SingleServer)
 
 		// Create a new context to be independent of ctx
-		timeoutContext, _ := context.WithTimeout(context.Background(), time.Minute*5)
-		go m.runSingleServerUpgradeProcess(timeoutContext, myPeer, mode)
+		timeoutContext, cancel := context.WithTimeout(context.Background(), time.Minute*5)
+		plan := UpgradePlan{
+			CreatedAt:      time.Now(),
+			LastModifiedAt: time.Now(),
+			FromVersions:   runningDBVersions,
+			ToVersion:      toVersion,
+			TriggeredBy:    triggeredBy,
+			Target:         target,
+		}
+		go func() {
+			defer cancel()
+			m.runSingleServerUpgradeProcess(timeoutContext, myPeer, mode, plan)
+		}()
 		return nil
 	}
 
-	// Check cluster health
-	if mode.IsClusterMode() {
-		if err := m.isClusterHealthy(ctx); err != nil {
-			return maskAny(errors.Wrap(err, "Cannot upgrade unhealthy cluster"))
-		}
-	}
-
 	// Run upgrade with agency.
 	// Create an agency lock, so we know we're the only one to create a plan.
 	m.log.Debug().Msg("Creating agency API")
@@ -314,24 +424,13 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		lock.Unlock(context.Background())
 	}()
 
-	m.log.Debug().Msg("Reading upgrade plan...")
-	// Check existing plan
-	plan, err := m.readUpgradePlan(ctx)
-	if err != nil && !agency.IsKeyNotFound(err) {
-		// Failed to read upgrade plan
-		m.log.Error().Msg("Failed to read upgrade plan")
-		return errors.Wrap(err, "Failed to read upgrade plan")
-	}
-
-	m.log.Debug().Msg("Checking if plan is ready...")
-	// Check plan status
-	if !plan.IsReady() {
-		m.log.Debug().Msg("Current upgrade plan has not finished yet.")
-		return maskAny(client.NewBadRequestError("Current upgrade plan has not finished yet"))
+	// Check existing plans
+	if err := m.checkNoUnfinishedPlans(ctx, api); err != nil {
+		return maskAny(err)
 	}
 
 	// Special measure for upgrades from 3.4.6:
-	if specialUpgradeFrom346 {
+	if prepared.specialUpgradeFrom346 {
 		// Write 1000 dummy values into agency to advance the log:
 		for i := 0; i < 1000; i++ {
 			err := api.WriteKey(nil, []string{"/arangodb-helper/dummy"}, 17, 0)
@@ -371,16 +470,203 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 
 	// Create upgrade plan
 	m.log.Debug().Msg("Creating upgrade plan")
-	plan = UpgradePlan{
+	plan := UpgradePlan{
 		CreatedAt:      time.Now(),
 		LastModifiedAt: time.Now(),
 		FromVersions:   runningDBVersions,
 		ToVersion:      toVersion,
+		TriggeredBy:    triggeredBy,
+		Target:         target,
+		Entries:        createUpgradePlanEntries(config, mode),
+	}
+
+	// Save plan
+	m.log.Debug().Msg("Writing upgrade plan")
+	overwrite := true
+	if _, err := m.writeUpgradePlan(ctx, plan, overwrite); driver.IsPreconditionFailed(err) {
+		m.log.Error().Msg("Failed to write upgrade plan because it was outdated or removed.")
+		return errors.Wrap(err, "Failed to write upgrade plan because is was outdated or removed")
+	} else if err != nil {
+		m.log.Error().Msgf("Failed to write upgrade plan %v.", err)
+		return errors.Wrap(err, "Failed to write upgrade plan")
+	}
+
+	// Inform user
+	m.log.Info().Msgf("Created plan to upgrade from %v to %v", runningDBVersions, toVersion)
+
+	// We're done
+	return nil
+}
+
+// DryRunDatabaseUpgrade runs all checks of StartDatabaseUpgrade and returns the
+// plan it would create, without changing anything.
+func (m *upgradeManager) DryRunDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, target client.UpgradeTarget) (client.UpgradeDryRun, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	prepared, err := m.prepareDatabaseUpgrade(ctx, forceMinorUpgrade, target)
+	if err != nil {
+		return client.UpgradeDryRun{}, maskAny(err)
+	}
+	result := client.UpgradeDryRun{
+		FromVersions: prepared.fromVersions,
+		ToVersion:    prepared.toVersion,
+	}
+
+	// Fetch mode
+	config, myPeer, mode := m.upgradeManagerContext.ClusterConfig()
+
+	var entries []UpgradePlanEntry
+	if mode.IsSingleMode() {
+		// Only the single server of this starter is upgraded
+		entries = []UpgradePlanEntry{{Type: UpgradeEntryTypeSingle, PeerID: myPeer.ID}}
+	} else {
+		api, err := m.createAgencyAPI()
+		if err != nil {
+			return client.UpgradeDryRun{}, maskAny(err)
+		}
+		if err := m.checkNoUnfinishedPlans(ctx, api); err != nil {
+			return client.UpgradeDryRun{}, maskAny(err)
+		}
+		supported, err := m.isSuperVisionMaintenanceSupported(ctx)
+		if err != nil {
+			return client.UpgradeDryRun{}, maskAny(err)
+		}
+		result.SupervisionMaintenance = supported
+		entries = createUpgradePlanEntries(config, mode)
+	}
+
+	for _, entry := range entries {
+		statusServer, err := entry.CreateStatusServer(m.upgradeManagerContext)
+		if err != nil {
+			return client.UpgradeDryRun{}, maskAny(err)
+		}
+		// Arangod servers are started once with --database.auto-upgrade and once normally.
+		restarts := 1
+		if entry.Type.IsArangod() {
+			restarts = 2
+		}
+		result.Entries = append(result.Entries, client.UpgradeDryRunEntry{
+			UpgradeStatusServer: *statusServer,
+			PeerID:              entry.PeerID,
+			Restarts:            restarts,
+		})
+		result.Restarts += restarts
+	}
+	return result, nil
+}
+
+// preparedUpgrade holds the outcome of the checks done before an upgrade is started.
+type preparedUpgrade struct {
+	fromVersions          []driver.Version
+	toVersion             driver.Version
+	specialUpgradeFrom346 bool
+}
+
+// prepareDatabaseUpgrade runs all checks needed before an upgrade to the given target can be started.
+// It does not change anything.
+func (m *upgradeManager) prepareDatabaseUpgrade(ctx context.Context, forceMinorUpgrade bool, target client.UpgradeTarget) (preparedUpgrade, error) {
+	// Check the versions of all starters
+	if err := m.checkStarterVersions(ctx); err != nil {
+		return preparedUpgrade{}, maskAny(err)
+	}
+
+	// Fetch (binary) database versions of all starters
+	if !target.IsEmpty() && !m.upgradeManagerContext.StarterRequestsAuthenticated() {
+		// Starters only check an executable (or image) given by another starter that authenticated itself
+		return preparedUpgrade{}, maskAny(client.NewBadRequestError("Upgrading to another arangod executable (or image) requires --starter.peer-auth=jwt|mtls or --auth.starter-api"))
+	}
+	binaryDBVersions, err := m.fetchBinaryDatabaseVersions(ctx, target)
+	if err != nil {
+		return preparedUpgrade{}, maskAny(err)
 	}
+	if len(binaryDBVersions) > 1 {
+		return preparedUpgrade{}, maskAny(client.NewBadRequestError(fmt.Sprintf("Found multiple database versions (%v). Make sure all machines have the same version", binaryDBVersions)))
+	}
+	if len(binaryDBVersions) == 0 {
+		return preparedUpgrade{}, maskAny(client.NewBadRequestError("Found no database versions. This is likely a bug"))
+	}
+	toVersion := binaryDBVersions[0]
+
+	// Fetch (running) database versions of all starters
+	runningDBVersions, err := m.fetchRunningDatabaseVersions(ctx)
+	if err != nil {
+		return preparedUpgrade{}, maskAny(err)
+	}
+
+	// Check if we can upgrade from running to binary versions
+	specialUpgradeFrom346 := false
+	rules := upgraderules.CheckUpgradeRules
+	if forceMinorUpgrade {
+		rules = upgraderules.CheckSoftUpgradeRules
+	}
+
+	for _, from := range runningDBVersions {
+		if err := rules(from, toVersion); err != nil {
+			return preparedUpgrade{}, maskAny(errors.Wrap(err, "Found incompatible upgrade versions"))
+		}
+		if from.CompareTo("3.4.6") == 0 {
+			specialUpgradeFrom346 = true
+		}
+	}
+
+	// Check cluster health
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if mode.IsClusterMode() {
+		if err := m.isClusterHealthy(ctx); err != nil {
+			return preparedUpgrade{}, maskAny(errors.Wrap(err, "Cannot upgrade unhealthy cluster"))
+		}
+	}
+
+	// Check ArangoSync health & synchronization state
+	if m.usesArangoSync() {
+		if err := m.isSyncHealthy(ctx); err != nil {
+			return preparedUpgrade{}, maskAny(errors.Wrap(err, "Cannot upgrade while ArangoSync is unhealthy or synchronization is broken"))
+		}
+	}
+
+	return preparedUpgrade{
+		fromVersions:          runningDBVersions,
+		toVersion:             toVersion,
+		specialUpgradeFrom346: specialUpgradeFrom346,
+	}, nil
+}
+
+// checkNoUnfinishedPlans returns a BadRequestError when the upgrade or restart plan
+// in the agency has not finished yet.
+func (m *upgradeManager) checkNoUnfinishedPlans(ctx context.Context, api agency.Agency) error {
+	m.log.Debug().Msg("Reading upgrade plan...")
+	var plan UpgradePlan
+	if err := api.ReadKey(ctx, upgradePlanKey, &plan); err != nil && !agency.IsKeyNotFound(err) {
+		// Failed to read upgrade plan
+		m.log.Error().Msg("Failed to read upgrade plan")
+		return errors.Wrap(err, "Failed to read upgrade plan")
+	}
+
+	m.log.Debug().Msg("Checking if plan is ready...")
+	if !plan.IsReady() {
+		m.log.Debug().Msg("Current upgrade plan has not finished yet.")
+		return maskAny(client.NewBadRequestError("Current upgrade plan has not finished yet"))
+	}
+
+	var restartPlan RestartPlan
+	if err := api.ReadKey(ctx, restartPlanKey, &restartPlan); err != nil && !agency.IsKeyNotFound(err) {
+		return errors.Wrap(err, "Failed to read restart plan")
+	}
+	if !restartPlan.IsReady() {
+		return maskAny(client.NewBadRequestError("Current restart plan has not finished yet"))
+	}
+	return nil
+}
+
+// createUpgradePlanEntries returns the entries of a plan that upgrades all servers
+// in the given deployment, in the order they must be upgraded.
+func createUpgradePlanEntries(config ClusterConfig, mode ServiceMode) []UpgradePlanEntry {
+	var entries []UpgradePlanEntry
 	// First add all agents
 	for _, p := range config.AllPeers {
 		if p.HasAgent() {
-			plan.Entries = append(plan.Entries, UpgradePlanEntry{
+			entries = append(entries, UpgradePlanEntry{
 				Type:   UpgradeEntryTypeAgent,
 				PeerID: p.ID,
 			})
@@ -390,7 +676,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 	if mode.IsActiveFailoverMode() {
 		for _, p := range config.AllPeers {
 			if p.HasResilientSingle() {
-				plan.Entries = append(plan.Entries, UpgradePlanEntry{
+				entries = append(entries, UpgradePlanEntry{
 					Type:   UpgradeEntryTypeSingle,
 					PeerID: p.ID,
 				})
@@ -402,7 +688,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		// Add all dbservers
 		for _, p := range config.AllPeers {
 			if p.HasDBServer() {
-				plan.Entries = append(plan.Entries, UpgradePlanEntry{
+				entries = append(entries, UpgradePlanEntry{
 					Type:   UpgradeEntryTypeDBServer,
 					PeerID: p.ID,
 				})
@@ -411,7 +697,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		// Add all coordinators
 		for _, p := range config.AllPeers {
 			if p.HasCoordinator() {
-				plan.Entries = append(plan.Entries, UpgradePlanEntry{
+				entries = append(entries, UpgradePlanEntry{
 					Type:   UpgradeEntryTypeCoordinator,
 					PeerID: p.ID,
 				})
@@ -423,7 +709,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		// Add all syncmasters
 		for _, p := range config.AllPeers {
 			if p.HasSyncMaster() {
-				plan.Entries = append(plan.Entries, UpgradePlanEntry{
+				entries = append(entries, UpgradePlanEntry{
 					Type:   UpgradeEntryTypeSyncMaster,
 					PeerID: p.ID,
 				})
@@ -432,7 +718,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		// Add all syncworkers
 		for _, p := range config.AllPeers {
 			if p.HasSyncWorker() {
-				plan.Entries = append(plan.Entries, UpgradePlanEntry{
+				entries = append(entries, UpgradePlanEntry{
 					Type:   UpgradeEntryTypeSyncWorker,
 					PeerID: p.ID,
 				})
@@ -440,22 +726,7 @@ func (m *upgradeManager) StartDatabaseUpgrade(ctx context.Context, forceMinorUpg
 		}
 	}
 
-	// Save plan
-	m.log.Debug().Msg("Writing upgrade plan")
-	overwrite := true
-	if _, err := m.writeUpgradePlan(ctx, plan, overwrite); driver.IsPreconditionFailed(err) {
-		m.log.Error().Msg("Failed to write upgrade plan because it was outdated or removed.")
-		return errors.Wrap(err, "Failed to write upgrade plan because is was outdated or removed")
-	} else if err != nil {
-		m.log.Error().Msgf("Failed to write upgrade plan %v.", err)
-		return errors.Wrap(err, "Failed to write upgrade plan")
-	}
-
-	// Inform user
-	m.log.Info().Msgf("Created plan to upgrade from %v to %v", runningDBVersions, binaryDBVersions)
-
-	// We're done
-	return nil
+	return entries
 }
 
 // RetryDatabaseUpgrade resets a failure mark in the existing upgrade plan
@@ -557,10 +828,17 @@ func (m *upgradeManager) AbortDatabaseUpgrade(ctx context.Context) error {
 	}()
 
 	// Check plan
-	if _, err := m.readUpgradePlan(ctx); agency.IsKeyNotFound(err) {
+	plan, err := m.readUpgradePlan(ctx)
+	if agency.IsKeyNotFound(err) {
 		// There is no plan
 		return maskAny(client.NewNotFoundError("There is no upgrade plan"))
 	}
+	if err == nil && !plan.Finished {
+		// Finished plans have been archived already
+		if err := m.archiveUpgradePlan(ctx, plan, client.UpgradeOutcomeAborted); err != nil {
+			m.log.Warn().Err(err).Msg("Failed to archive aborted upgrade plan")
+		}
+	}
 
 	// Remove plan
 	m.log.Debug().Msg("Removing upgrade plan")
@@ -594,8 +872,10 @@ func (m *upgradeManager) Status(ctx context.Context) (client.UpgradeStatus, erro
 		Failed:       plan.IsFailed(),
 		FromVersions: plan.FromVersions,
 		ToVersion:    plan.ToVersion,
+		Paused:       plan.Paused,
+		Rollback:     plan.Rollback,
 	}
-	for _, entry := range plan.Entries {
+	for _, entry := range plan.activeEntries() {
 		if entry.Failures > 0 && result.Reason == "" {
 			result.Reason = entry.Reason
 		}
@@ -616,6 +896,15 @@ func (m *upgradeManager) Status(ctx context.Context) (client.UpgradeStatus, erro
 			result.ServersUpgraded = append(result.ServersUpgraded, *statusServer)
 		}
 	}
+	for _, entry := range plan.FinishedRollbackEntries {
+		statusServer, err := entry.CreateStatusServer(m.upgradeManagerContext)
+		if err != nil {
+			return client.UpgradeStatus{}, maskAny(err)
+		}
+		if statusServer != nil {
+			result.ServersRolledBack = append(result.ServersRolledBack, *statusServer)
+		}
+	}
 	return result, nil
 }
 
@@ -633,7 +922,7 @@ func (m *upgradeManager) checkStarterVersions(ctx context.Context) error {
 		if err != nil {
 			return maskAny(err)
 		}
-		c, err := client.NewArangoStarterClient(*epURL)
+		c, err := client.NewArangoStarterClient(*epURL, m.upgradeManagerContext.PeerClientOptions()...)
 		if err != nil {
 			return maskAny(err)
 		}
@@ -655,8 +944,9 @@ func (m *upgradeManager) checkStarterVersions(ctx context.Context) error {
 }
 
 // fetchBinaryDatabaseVersions asks all starters for the version of the arangod binary.
+// When the given target is not empty, all starters check the version of the target instead.
 // It returns all distinct versions.
-func (m *upgradeManager) fetchBinaryDatabaseVersions(ctx context.Context) ([]driver.Version, error) {
+func (m *upgradeManager) fetchBinaryDatabaseVersions(ctx context.Context, target client.UpgradeTarget) ([]driver.Version, error) {
 	config, _, _ := m.upgradeManagerContext.ClusterConfig()
 	endpoints, err := config.GetPeerEndpoints()
 	if err != nil {
@@ -670,11 +960,18 @@ func (m *upgradeManager) fetchBinaryDatabaseVersions(ctx context.Context) ([]dri
 		if err != nil {
 			return nil, maskAny(err)
 		}
-		c, err := client.NewArangoStarterClient(*epURL)
+		c, err := client.NewArangoStarterClient(*epURL, m.upgradeManagerContext.PeerClientOptions()...)
 		if err != nil {
 			return nil, maskAny(err)
 		}
-		version, err := c.DatabaseVersion(ctx)
+		var version driver.Version
+		if target.IsEmpty() {
+			version, err = c.DatabaseVersion(ctx)
+		} else {
+			var result client.SwitchArangodBinaryResult
+			result, err = c.CheckArangodBinaryLocal(ctx, target)
+			version = result.Version
+		}
 		if err != nil {
 			return nil, maskAny(err)
 		}
@@ -738,11 +1035,6 @@ func (m *upgradeManager) fetchRunningDatabaseVersions(ctx context.Context) ([]dr
 	return versionList, nil
 }
 
-// Errorf is a wrapper for log.Error()... used by the agency lock
-func (m *upgradeManager) Errorf(msg string, args ...interface{}) {
-	m.log.Error().Msgf(msg, args...)
-}
-
 // IsServerUpgradeInProgress returns true when the upgrade manager is busy upgrading the server of given type.
 func (m *upgradeManager) IsServerUpgradeInProgress(serverType definitions.ServerType) bool {
 	return m.upgradeServerType == serverType
@@ -760,31 +1052,6 @@ func (m *upgradeManager) ServerDatabaseAutoUpgradeStarter(serverType definitions
 	}
 }
 
-// Create a client for the agency
-func (m *upgradeManager) createAgencyAPI() (agency.Agency, error) {
-	// Get cluster config
-	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
-	// Create client
-	a, err := clusterConfig.CreateAgencyAPI(m.upgradeManagerContext)
-	if err != nil {
-		return nil, maskAny(err)
-	}
-	return a, nil
-}
-
-// readUpgradePlan reads the current upgrade plan from the agency.
-func (m *upgradeManager) readUpgradePlan(ctx context.Context) (UpgradePlan, error) {
-	var plan UpgradePlan
-	api, err := m.createAgencyAPI()
-	if err != nil {
-		return UpgradePlan{}, maskAny(err)
-	}
-	if err := api.ReadKey(ctx, upgradePlanKey, &plan); err != nil {
-		return UpgradePlan{}, maskAny(err)
-	}
-	return plan, nil
-}
-
 // writeUpgradePlan writes the given upgrade plan to the agency.
 // Unless overwrite is true, the revision currently in the agency must match
 // the revision in the given plan. The revision is increased just before writing.
@@ -859,6 +1126,14 @@ func (m *upgradeManager) RunWatchUpgradePlan(ctx context.Context) {
 			}
 		} else if plan.IsFailed() {
 			// Plan already failed
+		} else if plan.Paused {
+			// Plan is paused, wait until it is resumed
+		} else if plan.Rollback {
+			// Let's inspect the first rollback entry
+			if err := m.processRollbackPlan(ctx, plan); err != nil {
+				m.log.Error().Err(err).Msg("Failed to process upgrade rollback entry")
+			}
+			delay = time.Second
 		} else if len(plan.Entries) > 0 {
 			// Let's inspect the first entry
 			if err := m.processUpgradePlan(ctx, plan); err != nil {
@@ -892,7 +1167,7 @@ func (m *upgradeManager) registerUpgradePlanChangedCallback(ctx context.Context,
 		return maskAny(err)
 	}
 	// Register callback
-	cbURL, err := getURLWithPath(ownURL, "/cb/upgradePlanChanged")
+	cbURL, err := m.upgradeManagerContext.CreateCallbackURL(ownURL, "/cb/upgradePlanChanged")
 	if err != nil {
 		return maskAny(err)
 	}
@@ -912,7 +1187,7 @@ func (m *upgradeManager) unregisterUpgradePlanChangedCallback(ctx context.Contex
 		return maskAny(err)
 	}
 	// Register callback
-	cbURL, err := getURLWithPath(ownURL, "/cb/upgradePlanChanged")
+	cbURL, err := m.upgradeManagerContext.CreateCallbackURL(ownURL, "/cb/upgradePlanChanged")
 	if err != nil {
 		return maskAny(err)
 	}
@@ -942,8 +1217,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 			Msg("Upgrade plan entry failed")
 		plan.Entries[0].Failures++
 		plan.Entries[0].Reason = err.Error()
-		overwrite := false
-		if _, err := m.writeUpgradePlan(ctx, plan, overwrite); err != nil {
+		if _, err := m.updateUpgradePlan(ctx, plan); err != nil {
 			m.log.Error().Err(err).Msg("Failed to write updated plan (recording failure)")
 		}
 		return maskAny(err)
@@ -958,14 +1232,50 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 	defer func() {
 		m.upgradeServerType = ""
 		m.updateNeeded = false
+		m.progress = nil
 	}()
 
+	// Record when the entry started and for arangod servers the executable (or image) & version
+	// used before the upgrade, so it can be rolled back
+	serverType, err := firstEntry.ServerType(mode)
+	if err != nil {
+		return maskAny(err)
+	}
+	if firstEntry.StartedAt.IsZero() {
+		plan.Entries[0].StartedAt = time.Now()
+		if firstEntry.Type.SupportsRollback() {
+			plan.Entries[0].FromTarget = plan.fromTargetOf(myPeer.ID)
+			if plan.Entries[0].FromTarget.IsEmpty() {
+				plan.Entries[0].FromTarget = m.upgradeManagerContext.ArangodTarget()
+			}
+			plan.Entries[0].FromVersion = m.runningServerVersion(ctx, myPeer, serverType)
+			if plan.Entries[0].FromVersion == "" && len(plan.FromVersions) == 1 {
+				plan.Entries[0].FromVersion = plan.FromVersions[0]
+			}
+		}
+		if plan, err = m.updateUpgradePlan(ctx, plan); err != nil {
+			return maskAny(err)
+		}
+		firstEntry = plan.Entries[0]
+	}
+	if firstEntry.Type.IsArangod() && !plan.Target.IsEmpty() {
+		// Switch to the target of the plan
+		if err := m.switchToUpgradeTarget(ctx, plan); err != nil {
+			return recordFailure(err)
+		}
+	}
+	// Record the phase & progress of the entry in the plan
+	progress := m.newUpgradeProgress(ctx, &plan)
+	m.progress = progress
+	m.serverRestarted = false
+
 	switch firstEntry.Type {
 	case UpgradeEntryTypeAgent:
 		// Restart the agency in auto-upgrade mode
 		m.log.Info().Msg("Upgrading agent")
 		m.upgradeServerType = definitions.ServerTypeAgent
 		m.updateNeeded = true
+		progress.SetPhase(client.UpgradePhaseStopping)
 		if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeAgent); err != nil {
 			return recordFailure(errors.Wrap(err, "Failed to restart agent"))
 		}
@@ -975,13 +1285,21 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 			return recordFailure(errors.Wrap(err, "Agent restart in upgrade mode did not succeed"))
 		}
 
+		// Wait until agent finished its upgrade
+		progress.SetPhase(client.UpgradePhaseAutoUpgrade)
+		if err := m.waitUntilUpgradeServerRestarted(ctx); err != nil {
+			return recordFailure(errors.Wrap(err, "Agent restart after upgrade did not succeed"))
+		}
+
 		// Wait until agency happy again
+		progress.SetPhase(client.UpgradePhaseRestarting)
 		if err := m.waitUntil(ctx, m.isAgencyHealth, "Agency is not yet healthy: %v"); err != nil {
 			return recordFailure(errors.Wrap(err, "Agency is not healthy in time"))
 		}
 
 		// Wait until cluster healthy
 		if mode.IsClusterMode() {
+			progress.SetPhase(client.UpgradePhaseWaitingForHealth)
 			if err := m.waitUntil(ctx, m.isClusterHealthy, "Cluster is not yet healthy: %v"); err != nil {
 				return recordFailure(errors.Wrap(err, "Cluster is not healthy in time"))
 			}
@@ -993,6 +1311,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		m.upgradeServerType = definitions.ServerTypeDBServer
 		m.updateNeeded = true
 		upgrade := func() error {
+			progress.SetPhase(client.UpgradePhaseStopping)
 			if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeDBServer); err != nil {
 				return recordFailure(errors.Wrap(err, "Failed to restart dbserver"))
 			}
@@ -1015,12 +1334,20 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 				return recordFailure(errors.Wrap(err, "DBServer restart in upgrade mode did not succeed"))
 			}
 
+			// Wait until dbserver finished its upgrade
+			progress.SetPhase(client.UpgradePhaseAutoUpgrade)
+			if err := m.waitUntilUpgradeServerRestarted(ctx); err != nil {
+				return recordFailure(errors.Wrap(err, "DBServer restart after upgrade did not succeed"))
+			}
+
 			// Wait until all dbservers respond
+			progress.SetPhase(client.UpgradePhaseRestarting)
 			if err := m.waitUntil(ctx, m.areDBServersResponding, "DBServers are not yet all responding: %v"); err != nil {
 				return recordFailure(errors.Wrap(err, "Not all DBServers are responding in time"))
 			}
 
 			// Wait until cluster healthy
+			progress.SetPhase(client.UpgradePhaseWaitingForHealth)
 			if err := m.waitUntil(ctx, m.isClusterHealthy, "Cluster is not yet healthy: %v"); err != nil {
 				return recordFailure(errors.Wrap(err, "Cluster is not healthy in time"))
 			}
@@ -1036,6 +1363,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		m.log.Info().Msg("Upgrading coordinator")
 		m.upgradeServerType = definitions.ServerTypeCoordinator
 		m.updateNeeded = true
+		progress.SetPhase(client.UpgradePhaseStopping)
 		if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeCoordinator); err != nil {
 			return recordFailure(errors.Wrap(err, "Failed to restart coordinator"))
 		}
@@ -1045,12 +1373,20 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 			return recordFailure(errors.Wrap(err, "Coordinator restart in upgrade mode did not succeed"))
 		}
 
+		// Wait until coordinator finished its upgrade
+		progress.SetPhase(client.UpgradePhaseAutoUpgrade)
+		if err := m.waitUntilUpgradeServerRestarted(ctx); err != nil {
+			return recordFailure(errors.Wrap(err, "Coordinator restart after upgrade did not succeed"))
+		}
+
 		// Wait until all coordinators respond
+		progress.SetPhase(client.UpgradePhaseRestarting)
 		if err := m.waitUntil(ctx, m.areCoordinatorsResponding, "Coordinator are not yet all responding: %v"); err != nil {
 			return recordFailure(errors.Wrap(err, "Not all Coordinators are responding in time"))
 		}
 
 		// Wait until cluster healthy
+		progress.SetPhase(client.UpgradePhaseWaitingForHealth)
 		if err := m.waitUntil(ctx, m.isClusterHealthy, "Cluster is not yet healthy: %v"); err != nil {
 			return recordFailure(errors.Wrap(err, "Cluster is not healthy in time"))
 		}
@@ -1061,6 +1397,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		m.upgradeServerType = definitions.ServerTypeResilientSingle
 		m.updateNeeded = true
 		upgrade := func() error {
+			progress.SetPhase(client.UpgradePhaseStopping)
 			if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeResilientSingle); err != nil {
 				return recordFailure(errors.Wrap(err, "Failed to restart single server"))
 			}
@@ -1083,7 +1420,14 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 				return recordFailure(errors.Wrap(err, "Single server restart in upgrade mode did not succeed"))
 			}
 
+			// Wait until single server finished its upgrade
+			progress.SetPhase(client.UpgradePhaseAutoUpgrade)
+			if err := m.waitUntilUpgradeServerRestarted(ctx); err != nil {
+				return recordFailure(errors.Wrap(err, "Single server restart after upgrade did not succeed"))
+			}
+
 			// Wait until all single servers respond
+			progress.SetPhase(client.UpgradePhaseRestarting)
 			if err := m.waitUntil(ctx, m.areSingleServersResponding, "Active failover single server is not yet responding: %v"); err != nil {
 				return recordFailure(errors.Wrap(err, "Not all single servers are responding in time"))
 			}
@@ -1098,6 +1442,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		m.log.Info().Msg("Restarting syncmaster")
 		m.upgradeServerType = ""
 		m.updateNeeded = false
+		progress.SetPhase(client.UpgradePhaseStopping)
 		if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeSyncMaster); err != nil {
 			return recordFailure(errors.Wrap(err, "Failed to restart syncmaster"))
 		}
@@ -1108,6 +1453,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		}
 
 		// Wait until syncmaster 'up'
+		progress.SetPhase(client.UpgradePhaseRestarting)
 		address := myPeer.Address
 		port := myPeer.Port + myPeer.PortOffset + definitions.ServerType(definitions.ServerTypeSyncMaster).PortOffset()
 		if up, _, _, _, _, _, _, _ := m.upgradeManagerContext.TestInstance(ctx, definitions.ServerTypeSyncMaster, address, port, nil); !up {
@@ -1119,6 +1465,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		m.log.Info().Msg("Restarting syncworker")
 		m.upgradeServerType = ""
 		m.updateNeeded = false
+		progress.SetPhase(client.UpgradePhaseStopping)
 		if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeSyncWorker); err != nil {
 			return recordFailure(errors.Wrap(err, "Failed to restart syncworker"))
 		}
@@ -1129,6 +1476,7 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		}
 
 		// Wait until syncworker 'up'
+		progress.SetPhase(client.UpgradePhaseRestarting)
 		address := myPeer.Address
 		port := myPeer.Port + myPeer.PortOffset + definitions.ServerType(definitions.ServerTypeSyncWorker).PortOffset()
 		if up, _, _, _, _, _, _, _ := m.upgradeManagerContext.TestInstance(ctx, definitions.ServerTypeSyncWorker, address, port, nil); !up {
@@ -1139,13 +1487,25 @@ func (m *upgradeManager) processUpgradePlan(ctx context.Context, plan UpgradePla
 		return maskAny(fmt.Errorf("Unsupported upgrade plan entry type '%s'", firstEntry.Type))
 	}
 
+	// Wait until ArangoSync is healthy before continuing with the next entry
+	if m.usesArangoSync() {
+		progress.SetPhase(client.UpgradePhaseWaitingForHealth)
+		waitCtx, cancel := context.WithTimeout(ctx, maxSyncHealthyWaitTime)
+		err := m.waitUntil(waitCtx, m.isSyncHealthy, "ArangoSync is not yet healthy: %v")
+		cancel()
+		if err != nil {
+			return recordFailure(errors.Wrap(err, "ArangoSync is not healthy in time"))
+		}
+	}
+
 	// Move first entry to finished entries
+	firstEntry.FinishedAt = time.Now()
+	firstEntry.Phase, firstEntry.Progress = "", ""
 	plan.Entries = plan.Entries[1:]
 	plan.FinishedEntries = append(plan.FinishedEntries, firstEntry)
 
 	// Save plan
-	overwrite := false
-	if _, err := m.writeUpgradePlan(ctx, plan, overwrite); err != nil {
+	if _, err := m.updateUpgradePlan(ctx, plan); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -1172,41 +1532,83 @@ func (m *upgradeManager) finishUpgradePlan(ctx context.Context, plan UpgradePlan
 	}
 
 	// Inform user that we're done
-	m.log.Info().Msg("Upgrade plan has finished successfully")
+	outcome := client.UpgradeOutcomeFinished
+	if plan.Rollback {
+		outcome = client.UpgradeOutcomeRolledBack
+		m.log.Info().Msg("Rollback of upgrade plan has finished successfully")
+	} else {
+		m.log.Info().Msg("Upgrade plan has finished successfully")
+	}
+
+	// Keep the plan in the upgrade history
+	if err := m.archiveUpgradePlan(ctx, plan, outcome); err != nil {
+		m.log.Warn().Err(err).Msg("Failed to archive upgrade plan")
+	}
 
 	return nil
 }
 
 // runSingleServerUpgradeProcess runs the entire upgrade process of a single server until it is finished.
-func (m *upgradeManager) runSingleServerUpgradeProcess(ctx context.Context, myPeer *Peer, mode ServiceMode) {
+// The given plan is archived in the upgrade history when done.
+func (m *upgradeManager) runSingleServerUpgradeProcess(ctx context.Context, myPeer *Peer, mode ServiceMode, plan UpgradePlan) {
+	entry := UpgradePlanEntry{
+		PeerID:    myPeer.ID,
+		Type:      UpgradeEntryTypeSingle,
+		StartedAt: time.Now(),
+	}
+	outcome := client.UpgradeOutcomeFailed
 	// Cleanup when we're done
 	defer func() {
 		m.upgradeServerType = ""
 		m.updateNeeded = false
+
+		if outcome == client.UpgradeOutcomeFinished {
+			entry.FinishedAt = time.Now()
+			plan.FinishedEntries = []UpgradePlanEntry{entry}
+		} else {
+			plan.Entries = []UpgradePlanEntry{entry}
+		}
+		plan.Finished = true
+		if err := m.archiveUpgradePlan(context.Background(), plan, outcome); err != nil {
+			m.log.Warn().Err(err).Msg("Failed to archive upgrade plan")
+		}
 	}()
 
 	if !mode.IsSingleMode() {
 		m.log.Info().Msg("Not in Single Server Mode, aborting.")
+		entry.Failures, entry.Reason = 1, "Not in Single Server Mode"
 		return
 	}
+	if !plan.Target.IsEmpty() {
+		// Switch to the target of the plan
+		if _, err := m.upgradeManagerContext.SwitchArangodBinary(ctx, plan.Target, false); err != nil {
+			m.log.Error().Err(err).Msg("Failed to switch arangod executable")
+			entry.Failures, entry.Reason = 1, err.Error()
+			return
+		}
+	}
 	// Restart the single server in auto-upgrade mode
 	m.log.Info().Msg("Upgrading single server")
 	m.upgradeServerType = definitions.ServerTypeSingle
 	m.updateNeeded = true
 	if err := m.upgradeManagerContext.RestartServer(definitions.ServerTypeSingle); err != nil {
 		m.log.Error().Err(err).Msg("Failed to restart single server")
+		entry.Failures, entry.Reason = 1, err.Error()
 		return
 	}
 
 	// Wait until single server restarted
 	if err := m.waitUntilUpgradeServerStarted(ctx); err != nil {
+		entry.Failures, entry.Reason = 1, err.Error()
 		return
 	}
 
 	// Wait until all single servers respond
 	if err := m.waitUntil(ctx, m.areSingleServersResponding, "Single server is not yet responding: %v"); err != nil {
+		entry.Failures, entry.Reason = 1, err.Error()
 		return
 	}
+	outcome = client.UpgradeOutcomeFinished
 
 	// We're done
 	allSameVersion, err := m.ShowArangodServerVersions(ctx)
@@ -1221,165 +1623,23 @@ func (m *upgradeManager) runSingleServerUpgradeProcess(ctx context.Context, myPe
 
 // waitUntilUpgradeServerStarted waits until the updateNeeded is false.
 func (m *upgradeManager) waitUntilUpgradeServerStarted(ctx context.Context) error {
-	for {
-		if !m.updateNeeded {
-			return nil
-		}
-		select {
-		case <-ctx.Done():
-			return maskAny(ctx.Err())
-		case <-time.After(time.Millisecond * 100):
-			// Try again
-		}
-	}
+	return m.waitUntilServerStarted(ctx, m.upgradeServerType, func() bool { return !m.updateNeeded })
 }
 
-// waitUntil loops until the the given predicate returns nil or the given context is
-// canceled.
-// Returns nil when agency is completely healthy, an error otherwise.
-func (m *upgradeManager) waitUntil(ctx context.Context, predicate func(ctx context.Context) error, errorLogTemplate string) error {
-	for {
-		err := predicate(ctx)
-		if err == nil {
-			return nil
-		}
-		m.log.Info().Msgf(errorLogTemplate, err)
-		select {
-		case <-ctx.Done():
-			return maskAny(ctx.Err())
-		case <-time.After(time.Second * 5):
-			// Try again
-		}
-	}
-}
-
-// isAgencyHealth performs a check if the agency is healthy.
-// Returns nil when agency is completely healthy, an error
-// when the agency is not healthy or its health state could not
-// be determined.
-func (m *upgradeManager) isAgencyHealth(ctx context.Context) error {
-	// Get cluster config
-	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
-	// Build endpoint list
-	endpoints, err := clusterConfig.GetAgentEndpoints()
-	if err != nil {
-		return maskAny(err)
-	}
-	// Build agency clients
-	clients := make([]driver.Connection, 0, len(endpoints))
-	for _, ep := range endpoints {
-		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeAgency, definitions.ServerTypeUnknown)
-		if err != nil {
-			return maskAny(err)
-		}
-		clients = append(clients, c.Connection())
-	}
-	// Check health
-	if err := agency.AreAgentsHealthy(ctx, clients); err != nil {
-		return maskAny(err)
-	}
-	return nil
+// waitUntilUpgradeServerRestarted waits until the server that is being upgraded
+// has been started again without --database.auto-upgrade.
+func (m *upgradeManager) waitUntilUpgradeServerRestarted(ctx context.Context) error {
+	return m.waitUntilServerStarted(ctx, m.upgradeServerType, func() bool { return m.serverRestarted })
 }
 
-// isClusterHealthy performs a check on the cluster health status.
-// If any of the servers is reported as not GOOD, an error is returned.
-func (m *upgradeManager) isClusterHealthy(ctx context.Context) error {
-	// Get cluster config
-	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
-	// Build endpoint list
-	endpoints, err := clusterConfig.GetCoordinatorEndpoints()
-	if err != nil {
-		return maskAny(err)
-	}
-	// Build client
-	c, err := m.upgradeManagerContext.CreateClient(endpoints, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
-	if err != nil {
-		return maskAny(err)
-	}
-	// Check health
-	cl, err := c.Cluster(ctx)
-	if err != nil {
-		return maskAny(err)
-	}
-	h, err := cl.Health(ctx)
-	if err != nil {
-		return maskAny(err)
-	}
-	for id, sh := range h.Health {
-		if sh.Role == driver.ServerRoleAgent && sh.Status == "" {
-			continue
-		}
-		if sh.Status != driver.ServerStatusGood {
-			return maskAny(fmt.Errorf("Server '%s' has a '%s' status", id, sh.Status))
-		}
-	}
-	return nil
-}
-
-// areDBServersResponding performs a check if all dbservers are responding.
-func (m *upgradeManager) areDBServersResponding(ctx context.Context) error {
-	// Get cluster config
-	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
-	// Build endpoint list
-	endpoints, err := clusterConfig.GetDBServerEndpoints()
-	if err != nil {
-		return maskAny(err)
-	}
-	// Check all
-	for _, ep := range endpoints {
-		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
-		if err != nil {
-			return maskAny(err)
-		}
-		if _, err := c.ServerID(ctx); err != nil {
-			return maskAny(err)
-		}
-	}
-	return nil
-}
-
-// areCoordinatorsResponding performs a check if all coordinators are responding.
-func (m *upgradeManager) areCoordinatorsResponding(ctx context.Context) error {
-	// Get cluster config
-	clusterConfig, _, _ := m.upgradeManagerContext.ClusterConfig()
-	// Build endpoint list
-	endpoints, err := clusterConfig.GetCoordinatorEndpoints()
-	if err != nil {
-		return maskAny(err)
-	}
-	// Check all
-	for _, ep := range endpoints {
-		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
-		if err != nil {
-			return maskAny(err)
-		}
-		if _, err := c.ServerID(ctx); err != nil {
-			return maskAny(err)
-		}
-	}
-	return nil
-}
-
-// areSingleServersResponding performs a check if all single servers are responding.
-func (m *upgradeManager) areSingleServersResponding(ctx context.Context) error {
-	// Get cluster config
-	clusterConfig, _, mode := m.upgradeManagerContext.ClusterConfig()
-	// Build endpoint list
-	endpoints, err := clusterConfig.GetSingleEndpoints(mode.IsSingleMode())
-	if err != nil {
-		return maskAny(err)
-	}
-	// Check all
-	for _, ep := range endpoints {
-		c, err := m.upgradeManagerContext.CreateClient([]string{ep}, ConnectionTypeDatabase, definitions.ServerTypeUnknown)
-		if err != nil {
-			return maskAny(err)
-		}
-		if _, err := c.Version(ctx); err != nil {
-			return maskAny(err)
-		}
+// waitUntil loops until the the given predicate returns nil or the given context is
+// canceled, reporting failures of the predicate to the progress of the current entry.
+func (m *upgradeManager) waitUntil(ctx context.Context, predicate func(ctx context.Context) error, errorLogTemplate string) error {
+	var progress actions.Progressor
+	if m.progress != nil {
+		progress = m.progress
 	}
-	return nil
+	return m.deploymentChecks.waitUntil(ctx, predicate, errorLogTemplate, progress)
 }
 
 // isSuperVisionMaintenanceSupported checks all agents for their version number.
@@ -0,0 +1,77 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_CreateUpgradePlanEntries(t *testing.T) {
+	no := false
+	config := ClusterConfig{
+		AllPeers: []Peer{
+			{ID: "a", HasAgentFlag: true},
+			{ID: "b", HasAgentFlag: true, HasCoordinatorFlag: &no},
+			{ID: "c", HasDBServerFlag: &no},
+		},
+	}
+	entryList := func(entries []UpgradePlanEntry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, string(e.Type)+"/"+e.PeerID)
+		}
+		return result
+	}
+
+	entries := createUpgradePlanEntries(config, ServiceModeCluster)
+	require.Equal(t, []string{"agent/a", "agent/b", "dbserver/a", "dbserver/b", "coordinator/a", "coordinator/c"}, entryList(entries))
+
+	config.AllPeers[0].HasResilientSingleFlag = true
+	config.AllPeers[2].HasResilientSingleFlag = true
+	entries = createUpgradePlanEntries(config, ServiceModeActiveFailover)
+	require.Equal(t, []string{"agent/a", "agent/b", "single/a", "single/c"}, entryList(entries))
+}
+
+func Test_CreateStatusServerProgress(t *testing.T) {
+	ctx := historyTestContext{
+		config: ClusterConfig{AllPeers: []Peer{{ID: "a", Address: "10.0.0.1", Port: 8528}}},
+	}
+	startedAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	entry := UpgradePlanEntry{
+		PeerID:    "a",
+		Type:      UpgradeEntryTypeSingle,
+		StartedAt: startedAt,
+		Phase:     client.UpgradePhaseAutoUpgrade,
+		Progress:  "Single server is not yet responding",
+	}
+	s, err := entry.CreateStatusServer(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 8529, s.Port)
+	require.Equal(t, &startedAt, s.StartedAt)
+	require.Nil(t, s.FinishedAt)
+	require.Equal(t, client.UpgradePhaseAutoUpgrade, s.Phase)
+	require.Equal(t, "Single server is not yet responding", s.Progress)
+}
@@ -0,0 +1,194 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/arangodb/go-driver/agency"
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+// PauseDatabaseUpgrade marks the existing upgrade plan as paused.
+// Note that Starters working on an entry of the upgrade
+// will finish that entry.
+// If there is no plan, a NotFoundError will be returned.
+func (m *upgradeManager) PauseDatabaseUpgrade(ctx context.Context) error {
+	if err := m.setUpgradePlanPaused(ctx, true); err != nil {
+		return maskAny(err)
+	}
+	m.log.Info().Msg("Paused upgrade plan, it will stop after the current entry")
+	return nil
+}
+
+// ResumeDatabaseUpgrade removes the paused mark from the existing upgrade plan.
+// If there is no plan, a NotFoundError will be returned.
+func (m *upgradeManager) ResumeDatabaseUpgrade(ctx context.Context) error {
+	if err := m.setUpgradePlanPaused(ctx, false); err != nil {
+		return maskAny(err)
+	}
+	m.log.Info().Msg("Resumed upgrade plan")
+	return nil
+}
+
+// setUpgradePlanPaused sets the paused mark of the existing upgrade plan.
+func (m *upgradeManager) setUpgradePlanPaused(ctx context.Context, paused bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Fetch mode
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+
+	if !mode.HasAgency() {
+		// Without an agency there is not upgrade plan to pause
+		return maskAny(client.NewBadRequestError("Pause & resume need an agency"))
+	}
+
+	// Note that like RetryDatabaseUpgrade we do not use an agency lock
+	// here, but the revision condition.
+	plan, err := m.readUpgradePlan(ctx)
+	if agency.IsKeyNotFound(err) {
+		// There is no plan
+		return maskAny(client.NewNotFoundError("There is no upgrade plan"))
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to read upgrade plan")
+	}
+	if err := plan.setPaused(paused); err != nil {
+		return maskAny(err)
+	}
+
+	// Save plan
+	overwrite := false
+	if _, err := m.writeUpgradePlan(ctx, plan, overwrite); driver.IsPreconditionFailed(err) {
+		return errors.Wrap(err, "Failed to write upgrade plan because is was outdated or removed")
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to write upgrade plan")
+	}
+	return nil
+}
+
+// setPaused sets the paused mark of the plan.
+// It fails when the plan has already finished or when the mark is already set that way.
+func (p *UpgradePlan) setPaused(paused bool) error {
+	if p.IsReady() {
+		return maskAny(client.NewBadRequestError("The upgrade plan has already finished"))
+	}
+	if p.Paused == paused {
+		if paused {
+			return maskAny(client.NewBadRequestError("The upgrade plan is already paused"))
+		}
+		return maskAny(client.NewBadRequestError("The upgrade plan is not paused"))
+	}
+	p.Paused = paused
+	return nil
+}
+
+// updateUpgradePlan writes the given plan, of which this starter is processing the first active entry.
+// While the entry is processed, other starters can change the plan, e.g. pause, resume or retry it.
+// When that happened, the changes this starter made to the entry are merged into the plan
+// in the agency (see mergeUpgradePlan) and the write is retried.
+func (m *upgradeManager) updateUpgradePlan(ctx context.Context, plan UpgradePlan) (UpgradePlan, error) {
+	overwrite := false
+	result, err := m.writeUpgradePlan(ctx, plan, overwrite)
+	if err == nil {
+		return result, nil
+	} else if !driver.IsPreconditionFailed(err) {
+		return UpgradePlan{}, maskAny(err)
+	}
+	current, rerr := m.readUpgradePlan(ctx)
+	if rerr != nil {
+		return UpgradePlan{}, maskAny(err)
+	}
+	merged, ok := mergeUpgradePlan(current, plan)
+	if !ok {
+		// The plan has been removed, replaced or rolled back, or its entry has been processed already
+		return UpgradePlan{}, maskAny(err)
+	}
+	result, err = m.writeUpgradePlan(ctx, merged, overwrite)
+	if err != nil {
+		return UpgradePlan{}, maskAny(err)
+	}
+	return result, nil
+}
+
+// entryLists returns the active entries of the plan and the list they are moved to once finished.
+func (p *UpgradePlan) entryLists() (active, finished *[]UpgradePlanEntry) {
+	if p.Rollback {
+		return &p.RollbackEntries, &p.FinishedRollbackEntries
+	}
+	return &p.Entries, &p.FinishedEntries
+}
+
+// mergeUpgradePlan returns the current plan (as found in the agency) with the changes that this
+// starter made in the given plan to the first active entry of the current plan:
+// - the timestamps, phase, progress & the executable (or image) & version recorded for a rollback,
+// - a failure recorded by this starter,
+// - moving the entry to the finished entries.
+// All other fields, e.g. the paused mark & failures reset by a retry, are taken from the current plan.
+// Returns false when the current plan is another plan or its first active entry is not the one
+// the given plan has changed.
+func mergeUpgradePlan(current, plan UpgradePlan) (UpgradePlan, bool) {
+	if !current.CreatedAt.Equal(plan.CreatedAt) || current.Rollback != plan.Rollback {
+		return UpgradePlan{}, false
+	}
+	curActive, curFinished := current.entryLists()
+	ownActive, ownFinished := plan.entryLists()
+	if len(*curActive) == 0 {
+		return UpgradePlan{}, false
+	}
+	cur := (*curActive)[0]
+	merged := current
+	mergedActive, mergedFinished := merged.entryLists()
+	switch len(*ownFinished) - len(*curFinished) {
+	case 0:
+		// The entry is still active
+		if len(*ownActive) == 0 {
+			return UpgradePlan{}, false
+		}
+		own := (*ownActive)[0]
+		if own.PeerID != cur.PeerID || own.Type != cur.Type {
+			return UpgradePlan{}, false
+		}
+		e := cur
+		e.StartedAt, e.FinishedAt = own.StartedAt, own.FinishedAt
+		e.Phase, e.Progress = own.Phase, own.Progress
+		e.FromVersion, e.FromTarget = own.FromVersion, own.FromTarget
+		if own.Failures > cur.Failures && len(own.FailureHistory) >= len(cur.FailureHistory) {
+			// This starter recorded a failure after the last retry
+			e.Failures, e.Reason = own.Failures, own.Reason
+		}
+		*mergedActive = append([]UpgradePlanEntry{e}, (*curActive)[1:]...)
+	case 1:
+		// The entry has been finished
+		own := (*ownFinished)[len(*ownFinished)-1]
+		if own.PeerID != cur.PeerID || own.Type != cur.Type {
+			return UpgradePlan{}, false
+		}
+		*mergedActive = append([]UpgradePlanEntry{}, (*curActive)[1:]...)
+		*mergedFinished = append(append([]UpgradePlanEntry{}, (*curFinished)...), own)
+	default:
+		return UpgradePlan{}, false
+	}
+	return merged, true
+}
@@ -0,0 +1,139 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_UpgradePlanSetPaused(t *testing.T) {
+	active := UpgradePlan{Entries: []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeAgent}}}
+	paused := active
+	paused.Paused = true
+	for name, tc := range map[string]struct {
+		plan     UpgradePlan
+		paused   bool
+		expected bool
+		failed   bool
+	}{
+		"pause":              {plan: active, paused: true, expected: true},
+		"resume":             {plan: paused, paused: false, expected: false},
+		"pause paused plan":  {plan: paused, paused: true, failed: true},
+		"resume active plan": {plan: active, paused: false, failed: true},
+		"pause finished":     {plan: UpgradePlan{}, paused: true, failed: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			plan := tc.plan
+			err := plan.setPaused(tc.paused)
+			if tc.failed {
+				require.True(t, client.IsBadRequest(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, plan.Paused)
+		})
+	}
+}
+
+func Test_MergeUpgradePlan(t *testing.T) {
+	createdAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(time.Minute)
+	agentA := UpgradePlanEntry{PeerID: "a", Type: UpgradeEntryTypeAgent}
+	agentB := UpgradePlanEntry{PeerID: "b", Type: UpgradeEntryTypeAgent}
+	newPlan := func(entries ...UpgradePlanEntry) UpgradePlan {
+		return UpgradePlan{CreatedAt: createdAt, Revision: 3, Entries: entries}
+	}
+	started := agentA
+	started.StartedAt = startedAt
+	started.Phase = client.UpgradePhaseWaitingForHealth
+	failed := started
+	failed.Failures, failed.Reason = 1, "timeout"
+	retried := agentA
+	retried.FailureHistory = []string{"timeout"}
+	finished := started
+	finished.FinishedAt = startedAt.Add(time.Minute)
+	finished.Phase = ""
+
+	for name, tc := range map[string]struct {
+		current  UpgradePlan
+		own      UpgradePlan
+		expected UpgradePlan
+		failed   bool
+	}{
+		"progress after pause": {
+			current:  UpgradePlan{CreatedAt: createdAt, Revision: 4, Paused: true, Entries: []UpgradePlanEntry{agentA, agentB}},
+			own:      newPlan(started, agentB),
+			expected: UpgradePlan{CreatedAt: createdAt, Revision: 4, Paused: true, Entries: []UpgradePlanEntry{started, agentB}},
+		},
+		"failure after resume": {
+			current:  UpgradePlan{CreatedAt: createdAt, Revision: 5, Entries: []UpgradePlanEntry{started, agentB}},
+			own:      UpgradePlan{CreatedAt: createdAt, Revision: 3, Paused: true, Entries: []UpgradePlanEntry{failed, agentB}},
+			expected: UpgradePlan{CreatedAt: createdAt, Revision: 5, Entries: []UpgradePlanEntry{failed, agentB}},
+		},
+		"retry keeps reset failures": {
+			current: UpgradePlan{CreatedAt: createdAt, Revision: 5, Entries: []UpgradePlanEntry{retried, agentB}},
+			own:     newPlan(failed, agentB),
+			expected: UpgradePlan{CreatedAt: createdAt, Revision: 5, Entries: []UpgradePlanEntry{{
+				PeerID: "a", Type: UpgradeEntryTypeAgent, StartedAt: startedAt, Phase: client.UpgradePhaseWaitingForHealth, FailureHistory: []string{"timeout"},
+			}, agentB}},
+		},
+		"finish after pause": {
+			current:  UpgradePlan{CreatedAt: createdAt, Revision: 4, Paused: true, Entries: []UpgradePlanEntry{started, agentB}},
+			own:      UpgradePlan{CreatedAt: createdAt, Revision: 3, Entries: []UpgradePlanEntry{agentB}, FinishedEntries: []UpgradePlanEntry{finished}},
+			expected: UpgradePlan{CreatedAt: createdAt, Revision: 4, Paused: true, Entries: []UpgradePlanEntry{agentB}, FinishedEntries: []UpgradePlanEntry{finished}},
+		},
+		"finish rollback entry": {
+			current:  UpgradePlan{CreatedAt: createdAt, Revision: 4, Rollback: true, Entries: []UpgradePlanEntry{agentB}, RollbackEntries: []UpgradePlanEntry{started}},
+			own:      UpgradePlan{CreatedAt: createdAt, Revision: 3, Rollback: true, Entries: []UpgradePlanEntry{agentB}, FinishedRollbackEntries: []UpgradePlanEntry{finished}},
+			expected: UpgradePlan{CreatedAt: createdAt, Revision: 4, Rollback: true, Entries: []UpgradePlanEntry{agentB}, RollbackEntries: []UpgradePlanEntry{}, FinishedRollbackEntries: []UpgradePlanEntry{finished}},
+		},
+		"replaced plan": {
+			current: UpgradePlan{CreatedAt: createdAt.Add(time.Hour), Entries: []UpgradePlanEntry{agentA}},
+			own:     newPlan(started),
+			failed:  true,
+		},
+		"rolled back plan": {
+			current: UpgradePlan{CreatedAt: createdAt, Rollback: true, Entries: []UpgradePlanEntry{agentA}, RollbackEntries: []UpgradePlanEntry{agentA}},
+			own:     newPlan(started),
+			failed:  true,
+		},
+		"other entry": {
+			current: newPlan(agentB),
+			own:     newPlan(started, agentB),
+			failed:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			merged, ok := mergeUpgradePlan(tc.current, tc.own)
+			if tc.failed {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tc.expected, merged)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/service/actions"
+)
+
+// upgradeProgress records the phase & the last progress message of the
+// upgrade plan entry that is processed by this starter, such that they
+// show up in the upgrade status.
+// It implements actions.Progressor.
+type upgradeProgress struct {
+	ctx        context.Context
+	m          *upgradeManager
+	plan       *UpgradePlan
+	actionName string
+}
+
+var _ actions.Progressor = &upgradeProgress{}
+
+// newUpgradeProgress creates a progress recorder for the first active entry of the given plan.
+// The given plan is updated every time the progress is written.
+func (m *upgradeManager) newUpgradeProgress(ctx context.Context, plan *UpgradePlan) *upgradeProgress {
+	return &upgradeProgress{
+		ctx:  ctx,
+		m:    m,
+		plan: plan,
+	}
+}
+
+// SetPhase stores the given phase in the first active entry of the plan.
+func (p *upgradeProgress) SetPhase(phase client.UpgradePhase) {
+	p.update(func(e *UpgradePlanEntry) bool {
+		if e.Phase == phase {
+			return false
+		}
+		e.Phase = phase
+		e.Progress = ""
+		return true
+	})
+}
+
+// Started is launched when the action starts.
+func (p *upgradeProgress) Started(actionName string) {
+	p.actionName = actionName
+	p.Progress(fmt.Sprintf("%s started", actionName))
+}
+
+// Failed is launched when the action fails.
+func (p *upgradeProgress) Failed(err error) {
+	p.Progress(fmt.Sprintf("%s failed: %v", p.actionName, err))
+}
+
+// Finished is launched when the action finishes.
+func (p *upgradeProgress) Finished() {
+	p.Progress(fmt.Sprintf("%s finished", p.actionName))
+}
+
+// Progress stores the given message in the first active entry of the plan.
+func (p *upgradeProgress) Progress(message string) error {
+	p.update(func(e *UpgradePlanEntry) bool {
+		if e.Progress == message {
+			return false
+		}
+		e.Progress = message
+		return true
+	})
+	return errors.New(message)
+}
+
+// update applies the given change to the first active entry of the plan and
+// writes the plan when the change returns true.
+// Progress is informational, so failures to write the plan are only logged.
+func (p *upgradeProgress) update(change func(e *UpgradePlanEntry) bool) {
+	entries := p.plan.activeEntries()
+	if len(entries) == 0 || !change(&entries[0]) {
+		return
+	}
+	if plan, err := p.m.updateUpgradePlan(p.ctx, *p.plan); err != nil {
+		p.m.log.Debug().Err(err).Msg("Failed to write upgrade plan (recording progress)")
+	} else {
+		*p.plan = plan
+	}
+}
@@ -0,0 +1,251 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/arangodb/go-driver/agency"
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+// RollbackDatabaseUpgrade turns the existing upgrade plan into a plan
+// that restarts all servers that have already been upgraded with the
+// binary (or docker image) they used before the upgrade.
+// If there is no plan, a NotFoundError will be returned.
+func (m *upgradeManager) RollbackDatabaseUpgrade(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Fetch mode
+	_, _, mode := m.upgradeManagerContext.ClusterConfig()
+
+	if !mode.HasAgency() {
+		// Without an agency there is not upgrade plan to rollback
+		return maskAny(client.NewBadRequestError("Rollback needs an agency"))
+	}
+
+	// Create an agency lock, so we know we're the only one to modify the plan.
+	api, err := m.createAgencyAPI()
+	if err != nil {
+		return maskAny(err)
+	}
+	lock, err := agency.NewLock(m, api, upgradeManagerLockKey, "", upgradeManagerLockTTL)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := lock.Lock(ctx); err != nil {
+		m.log.Debug().Err(err).Msg("Lock failed")
+		return maskAny(err)
+	}
+	defer func() {
+		lock.Unlock(context.Background())
+	}()
+
+	// Check plan
+	plan, err := m.readUpgradePlan(ctx)
+	if agency.IsKeyNotFound(err) {
+		// There is no plan
+		return maskAny(client.NewNotFoundError("There is no upgrade plan"))
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to read upgrade plan")
+	}
+	if plan.Rollback {
+		return maskAny(client.NewBadRequestError("The upgrade plan is already being rolled back"))
+	}
+
+	// Create rollback entries
+	entries, err := createRollbackEntries(plan)
+	if err != nil {
+		return maskAny(err)
+	}
+	if len(entries) == 0 {
+		return maskAny(client.NewBadRequestError("No servers have been upgraded yet, abort the upgrade instead"))
+	}
+	plan.Rollback = true
+	plan.RollbackEntries = entries
+	// Rolling back a paused plan resumes it
+	plan.Paused = false
+
+	// Save plan
+	overwrite := false
+	if _, err := m.writeUpgradePlan(ctx, plan, overwrite); driver.IsPreconditionFailed(err) {
+		return errors.Wrap(err, "Failed to write upgrade plan because is was outdated or removed")
+	} else if err != nil {
+		return errors.Wrap(err, "Failed to write upgrade plan")
+	}
+
+	// Inform user
+	m.log.Info().Msgf("Rolling back upgrade of %d servers", len(entries))
+
+	return nil
+}
+
+// createRollbackEntries returns the entries needed to roll back all servers that have been
+// upgraded by the given plan, in reverse upgrade order.
+// The first remaining entry of the plan is included when its upgrade has started.
+// Only rollbacks to an earlier patch release of the version upgraded to are supported.
+func createRollbackEntries(plan UpgradePlan) ([]UpgradePlanEntry, error) {
+	upgraded := append([]UpgradePlanEntry{}, plan.FinishedEntries...)
+	if len(plan.Entries) > 0 && !plan.Entries[0].FromTarget.IsEmpty() {
+		upgraded = append(upgraded, plan.Entries[0])
+	}
+	var entries []UpgradePlanEntry
+	for i := len(upgraded) - 1; i >= 0; i-- {
+		e := upgraded[i]
+		if !e.Type.SupportsRollback() {
+			continue
+		}
+		if e.FromTarget.IsEmpty() {
+			return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("The arangod executable (or image) used by the %s of peer '%s' before the upgrade is unknown", e.Type, e.PeerID)))
+		}
+		if e.FromVersion == "" {
+			return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("The version of the %s of peer '%s' before the upgrade is unknown", e.Type, e.PeerID)))
+		}
+		if e.FromVersion.Major() != plan.ToVersion.Major() || e.FromVersion.Minor() != plan.ToVersion.Minor() {
+			return nil, maskAny(client.NewBadRequestError(fmt.Sprintf("Cannot roll back from %s to %s, only patch releases can be rolled back", plan.ToVersion, e.FromVersion)))
+		}
+		entries = append(entries, UpgradePlanEntry{
+			PeerID:      e.PeerID,
+			Type:        e.Type,
+			FromVersion: e.FromVersion,
+			FromTarget:  e.FromTarget,
+		})
+	}
+	return entries, nil
+}
+
+// processRollbackPlan inspects the first rollback entry of the given plan and acts upon
+// it when needed.
+func (m *upgradeManager) processRollbackPlan(ctx context.Context, plan UpgradePlan) error {
+	_, myPeer, mode := m.upgradeManagerContext.ClusterConfig()
+	_, isRunning, _ := m.upgradeManagerContext.IsRunningMaster()
+	if !isRunning {
+		return maskAny(fmt.Errorf("Not in running phase"))
+	}
+
+	firstEntry := plan.RollbackEntries[0]
+	// We only respond when the peer is ours
+	if firstEntry.PeerID != myPeer.ID {
+		return nil
+	}
+
+	// recordFailure increments the failure count in the first rollback entry and
+	// stored the modified plan.
+	// It then returns the original error.
+	recordFailure := func(err error) error {
+		m.log.Error().Err(err).
+			Str("type", string(firstEntry.Type)).
+			Msg("Upgrade rollback entry failed")
+		plan.RollbackEntries[0].Failures++
+		plan.RollbackEntries[0].Reason = err.Error()
+		if _, err := m.updateUpgradePlan(ctx, plan); err != nil {
+			m.log.Error().Err(err).Msg("Failed to write updated plan (recording failure)")
+		}
+		return maskAny(err)
+	}
+
+	serverType, checks, err := m.restartChecks(firstEntry.Type, mode)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	// Switch back to the previous binary, which is kept when this starter is restarted
+	if err := m.switchToRollbackTarget(ctx, firstEntry); err != nil {
+		return recordFailure(err)
+	}
+
+	// Restart the server with its previous binary
+	firstEntry.StartedAt = time.Now()
+	plan.RollbackEntries[0].StartedAt = firstEntry.StartedAt
+	m.log.Info().Msgf("Rolling back %s to version %s", serverType, firstEntry.FromVersion)
+	m.upgradeServerType = serverType
+	m.rollbackNeeded = true
+	m.progress = m.newUpgradeProgress(ctx, &plan)
+	defer func() {
+		m.upgradeServerType = ""
+		m.rollbackNeeded = false
+		m.progress = nil
+	}()
+	m.progress.SetPhase(client.UpgradePhaseStopping)
+	if err := m.upgradeManagerContext.RestartServer(serverType); err != nil {
+		return recordFailure(errors.Wrapf(err, "Failed to restart %s", serverType))
+	}
+
+	// Wait until the server restarted
+	if err := m.waitUntilRollbackServerStarted(ctx); err != nil {
+		return recordFailure(errors.Wrapf(err, "Restart of %s with its previous binary did not succeed", serverType))
+	}
+
+	// Wait until the deployment is healthy again
+	m.progress.SetPhase(client.UpgradePhaseWaitingForHealth)
+	for _, c := range checks {
+		if err := m.waitUntil(ctx, c.predicate, c.errorLogTemplate); err != nil {
+			return recordFailure(errors.Wrap(err, c.failure))
+		}
+	}
+	m.log.Info().Msgf("Finished rolling back %s", serverType)
+
+	// Move first entry to finished rollback entries
+	firstEntry.FinishedAt = time.Now()
+	firstEntry.Phase, firstEntry.Progress = "", ""
+	plan.RollbackEntries = plan.RollbackEntries[1:]
+	plan.FinishedRollbackEntries = append(plan.FinishedRollbackEntries, firstEntry)
+
+	// Save plan
+	if _, err := m.updateUpgradePlan(ctx, plan); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// ServerStarted is called when a server of given type has been started
+// without --database.auto-upgrade.
+func (m *upgradeManager) ServerStarted(serverType definitions.ServerType) {
+	if m.upgradeServerType == serverType {
+		m.rollbackNeeded = false
+		m.serverRestarted = true
+	}
+}
+
+// waitUntilRollbackServerStarted waits until the rollbackNeeded is false.
+func (m *upgradeManager) waitUntilRollbackServerStarted(ctx context.Context) error {
+	return m.waitUntilServerStarted(ctx, m.upgradeServerType, func() bool { return !m.rollbackNeeded })
+}
+
+// runningServerVersion returns the version of the running server of given type of the given peer.
+// Returns an empty version when that is unknown.
+func (m *upgradeManager) runningServerVersion(ctx context.Context, peer *Peer, serverType definitions.ServerType) driver.Version {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	port := peer.Port + peer.PortOffset + serverType.PortOffset()
+	up, _, version, _, _, _, _, _ := m.upgradeManagerContext.TestInstance(ctx, serverType, peer.Address, port, nil)
+	if !up {
+		return ""
+	}
+	return driver.Version(version)
+}
@@ -0,0 +1,105 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+func Test_CreateRollbackEntries(t *testing.T) {
+	plan := UpgradePlan{
+		ToVersion: "3.7.11",
+		FinishedEntries: []UpgradePlanEntry{
+			{PeerID: "a", Type: UpgradeEntryTypeAgent, FromVersion: "3.7.10", FromTarget: client.UpgradeTarget{Image: "arangodb:3.7.10"}},
+			{PeerID: "b", Type: UpgradeEntryTypeAgent, FromVersion: "3.7.10", FromTarget: client.UpgradeTarget{Image: "arangodb:3.7.10"}},
+		},
+		Entries: []UpgradePlanEntry{
+			{PeerID: "a", Type: UpgradeEntryTypeDBServer, FromVersion: "3.7.10", FromTarget: client.UpgradeTarget{Image: "arangodb:3.7.10"}, Failures: 1},
+			{PeerID: "b", Type: UpgradeEntryTypeDBServer},
+		},
+	}
+	entries, err := createRollbackEntries(plan)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, UpgradeEntryType(UpgradeEntryTypeDBServer), entries[0].Type)
+	require.Equal(t, "a", entries[0].PeerID)
+	require.Equal(t, 0, entries[0].Failures)
+	require.Equal(t, "b", entries[1].PeerID)
+	require.Equal(t, "a", entries[2].PeerID)
+
+	// Sync entries are skipped
+	plan.FinishedEntries = append(plan.FinishedEntries, UpgradePlanEntry{PeerID: "a", Type: UpgradeEntryTypeSyncMaster})
+	entries, err = createRollbackEntries(plan)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// Unknown binary
+	plan.FinishedEntries[1].FromTarget = client.UpgradeTarget{}
+	_, err = createRollbackEntries(plan)
+	require.True(t, client.IsBadRequest(err))
+
+	// Minor version downgrade
+	plan.FinishedEntries[1].FromTarget = client.UpgradeTarget{Image: "arangodb:3.6.12"}
+	plan.FinishedEntries[1].FromVersion = "3.6.12"
+	_, err = createRollbackEntries(plan)
+	require.True(t, client.IsBadRequest(err))
+}
+
+func Test_UpgradePlanFromTargetOf(t *testing.T) {
+	previous := client.UpgradeTarget{ArangodPath: "/usr/sbin/arangod"}
+	plan := UpgradePlan{
+		Target: client.UpgradeTarget{ArangodPath: "/opt/arangodb3.7.11/usr/sbin/arangod"},
+		FinishedEntries: []UpgradePlanEntry{
+			{PeerID: "a", Type: UpgradeEntryTypeAgent, FromTarget: previous},
+		},
+		Entries: []UpgradePlanEntry{
+			{PeerID: "b", Type: UpgradeEntryTypeAgent},
+			{PeerID: "a", Type: UpgradeEntryTypeDBServer},
+		},
+	}
+	require.Equal(t, previous, plan.fromTargetOf("a"))
+	require.True(t, plan.fromTargetOf("b").IsEmpty())
+}
+
+func Test_UpgradePlanRollbackStatus(t *testing.T) {
+	plan := UpgradePlan{
+		Entries: []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeDBServer, Failures: 1}},
+	}
+	require.False(t, plan.IsReady())
+	require.True(t, plan.IsFailed())
+
+	plan.Rollback = true
+	plan.RollbackEntries = []UpgradePlanEntry{{PeerID: "a", Type: UpgradeEntryTypeAgent, Failures: 1, Reason: "timeout"}}
+	require.False(t, plan.IsReady())
+	require.True(t, plan.IsFailed())
+
+	plan.ResetFailures()
+	require.False(t, plan.IsFailed())
+	require.Empty(t, plan.RollbackEntries[0].Reason)
+	require.Equal(t, 0, plan.Entries[0].Failures)
+
+	plan.RollbackEntries = nil
+	require.True(t, plan.IsReady())
+}
@@ -0,0 +1,266 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/api"
+)
+
+var (
+	// arangoSyncClient is the HTTP client used for all requests to arangosync servers,
+	// such that connections are reused.
+	arangoSyncClient = &http.Client{
+		Timeout: time.Second * 10,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+)
+
+const (
+	// maxSyncHealthyWaitTime is the maximum time to wait for ArangoSync to become healthy
+	// after an entry of the upgrade plan.
+	maxSyncHealthyWaitTime = time.Minute * 15
+
+	// Synchronization states of arangosync that indicate that synchronization is broken
+	syncStatusCancelling = "cancelling"
+	syncStatusFailed     = "failed"
+)
+
+// syncShardInfo is the synchronization state of a single shard, as reported by a sync master.
+type syncShardInfo struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	ShardIndex int    `json:"shard_index"`
+	Status     string `json:"status"`
+}
+
+// syncOutgoingInfo is the synchronization state towards a single other datacenter, as reported by a sync master.
+type syncOutgoingInfo struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Shards []syncShardInfo `json:"shards"`
+}
+
+// syncInfo is the response of `GET /_api/sync` of a sync master.
+type syncInfo struct {
+	Status   string             `json:"status"`
+	Shards   []syncShardInfo    `json:"shards"`
+	Outgoing []syncOutgoingInfo `json:"outgoing"`
+}
+
+// checkSyncInfo returns an error when the given synchronization state
+// indicates that synchronization (incoming or outgoing) is broken.
+func checkSyncInfo(info syncInfo) error {
+	isBroken := func(status string) bool {
+		return status == syncStatusCancelling || status == syncStatusFailed
+	}
+	checkShards := func(shards []syncShardInfo) error {
+		for _, s := range shards {
+			if isBroken(s.Status) {
+				return maskAny(fmt.Errorf("Synchronization of shard %d of '%s/%s' has a '%s' status", s.ShardIndex, s.Database, s.Collection, s.Status))
+			}
+		}
+		return nil
+	}
+	if isBroken(info.Status) {
+		return maskAny(fmt.Errorf("Incoming synchronization has a '%s' status", info.Status))
+	}
+	if err := checkShards(info.Shards); err != nil {
+		return maskAny(err)
+	}
+	for _, o := range info.Outgoing {
+		if isBroken(o.Status) {
+			return maskAny(fmt.Errorf("Outgoing synchronization to '%s' has a '%s' status", o.ID, o.Status))
+		}
+		if err := checkShards(o.Shards); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// usesArangoSync returns true when the deployment contains sync masters or sync workers.
+func (m *upgradeManager) usesArangoSync() bool {
+	config, _, mode := m.upgradeManagerContext.ClusterConfig()
+	if !mode.SupportsArangoSync() {
+		return false
+	}
+	for _, p := range config.AllPeers {
+		if p.HasSyncMaster() || p.HasSyncWorker() {
+			return true
+		}
+	}
+	return false
+}
+
+// isSyncHealthy performs a check if all sync masters & workers are healthy
+// and synchronization is not broken.
+// Every starter checks the arangosync servers it started, since only it knows their monitoring token.
+func (m *upgradeManager) isSyncHealthy(ctx context.Context) error {
+	config, myPeer, _ := m.upgradeManagerContext.ClusterConfig()
+	hasSyncMaster, reported := false, false
+	for _, p := range config.AllPeers {
+		if !p.HasSyncMaster() && !p.HasSyncWorker() {
+			continue
+		}
+		hasSyncMaster = hasSyncMaster || p.HasSyncMaster()
+		var health api.SyncHealth
+		var err error
+		if myPeer != nil && p.ID == myPeer.ID {
+			health, err = m.upgradeManagerContext.LocalSyncHealth(ctx)
+		} else {
+			var c client.API
+			if c, err = createPeerClient(p, m.upgradeManagerContext.PeerClientOptions()...); err == nil {
+				health, err = c.SyncHealthLocal(ctx)
+			}
+		}
+		if err != nil {
+			return maskAny(fmt.Errorf("ArangoSync of starter %s: %v", p.ID, err))
+		}
+		reported = reported || health.StateReported
+	}
+	if hasSyncMaster && !reported {
+		return maskAny(fmt.Errorf("No sync master reported the synchronization state"))
+	}
+	return nil
+}
+
+// checkSyncHealth checks that the sync master & worker of the given peer (if any) are healthy and,
+// when that sync master is the leading one, that synchronization is not broken.
+// Requests are authenticated with the given monitoring token.
+func checkSyncHealth(ctx context.Context, p Peer, monitoringToken string) (api.SyncHealth, error) {
+	config := ClusterConfig{AllPeers: []Peer{p}}
+	masters, err := config.GetSyncMasterEndpoints()
+	if err != nil {
+		return api.SyncHealth{}, maskAny(err)
+	}
+	workers, err := config.GetSyncWorkerEndpoints()
+	if err != nil {
+		return api.SyncHealth{}, maskAny(err)
+	}
+	// Check health of all servers
+	for _, ep := range append(masters, workers...) {
+		if _, err := arangoSyncRequest(ctx, monitoringToken, ep, "/_api/health", nil); err != nil {
+			return api.SyncHealth{}, maskAny(fmt.Errorf("ArangoSync server at %s is not healthy: %v", ep, err))
+		}
+	}
+	// Check synchronization state
+	var result api.SyncHealth
+	for _, ep := range masters {
+		var info syncInfo
+		if code, err := arangoSyncRequest(ctx, monitoringToken, ep, "/_api/sync", &info); code == http.StatusServiceUnavailable {
+			// Not the leading sync master
+			continue
+		} else if err != nil {
+			return api.SyncHealth{}, maskAny(fmt.Errorf("Failed to get synchronization state from %s: %v", ep, err))
+		}
+		if err := checkSyncInfo(info); err != nil {
+			return api.SyncHealth{}, maskAny(err)
+		}
+		result.StateReported = true
+	}
+	return result, nil
+}
+
+// LocalSyncHealth checks that the sync master & worker started by this starter (if any) are healthy and,
+// when that sync master is the leading one, that synchronization is not broken.
+func (s *Service) LocalSyncHealth(ctx context.Context) (api.SyncHealth, error) {
+	_, myPeer, _ := s.ClusterConfig()
+	if myPeer == nil {
+		return api.SyncHealth{}, maskAny(errors.Wrap(client.ServiceUnavailableError, "Starter is not yet part of the cluster"))
+	}
+	return checkSyncHealth(ctx, *myPeer, s.cfg.SyncMonitoringToken)
+}
+
+// localSyncHealthHandler checks the arangosync servers started by this starter.
+func (s *httpServer) localSyncHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	health, err := s.context.LocalSyncHealth(r.Context())
+	if client.IsServiceUnavailable(err) {
+		handleError(w, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	b, err := json.Marshal(health)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// arangoSyncRequest performs a GET request, authenticated with the given monitoring token,
+// to the given path of an arangosync server and decodes the response into the given result (if any).
+// It returns the status code of the response.
+func arangoSyncRequest(ctx context.Context, monitoringToken, endpoint, path string, result interface{}) (int, error) {
+	req, err := http.NewRequest("GET", endpoint+path, nil)
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	if err := addBearerTokenHeader(req, monitoringToken); err != nil {
+		return 0, maskAny(err)
+	}
+	resp, err := arangoSyncClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, maskAny(err)
+	}
+	defer func() {
+		// Read the remaining body, so the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// Continue
+	case http.StatusUnauthorized:
+		return resp.StatusCode, maskAny(fmt.Errorf("Unauthorized, check --sync.monitoring.token"))
+	default:
+		return resp.StatusCode, maskAny(fmt.Errorf("Invalid status %d", resp.StatusCode))
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return resp.StatusCode, maskAny(fmt.Errorf("Unexpected response: %v", err))
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,105 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+func Test_CheckSyncInfo(t *testing.T) {
+	parse := func(s string) syncInfo {
+		var info syncInfo
+		require.NoError(t, json.Unmarshal([]byte(s), &info))
+		return info
+	}
+
+	// Synchronization running or not configured
+	require.NoError(t, checkSyncInfo(parse(`{"status":"running","shards":[{"database":"db","collection":"c","shard_index":0,"status":"running"}]}`)))
+	require.NoError(t, checkSyncInfo(parse(`{"status":"inactive","outgoing":[{"id":"dc2","status":"running"}]}`)))
+
+	// Broken incoming synchronization
+	require.Error(t, checkSyncInfo(parse(`{"status":"failed"}`)))
+	require.Error(t, checkSyncInfo(parse(`{"status":"running","shards":[{"database":"db","collection":"c","shard_index":1,"status":"cancelling"}]}`)))
+
+	// Broken outgoing synchronization
+	require.Error(t, checkSyncInfo(parse(`{"status":"inactive","outgoing":[{"id":"dc2","status":"failed"}]}`)))
+	require.Error(t, checkSyncInfo(parse(`{"status":"inactive","outgoing":[{"id":"dc2","status":"running","shards":[{"status":"failed"}]}]}`)))
+}
+
+func Test_CheckSyncHealth(t *testing.T) {
+	leading, syncStatus := true, "running"
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(AuthorizationHeader) != BearerPrefix+"token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/_api/health":
+			w.Write([]byte(`{}`))
+		case "/_api/sync":
+			if !leading {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(syncInfo{Status: syncStatus})
+		}
+	}))
+	defer srv.Close()
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	p := Peer{
+		Address:           host,
+		Port:              port - definitions.ServerType(definitions.ServerTypeSyncMaster).PortOffset(),
+		HasSyncMasterFlag: true,
+	}
+	ctx := context.Background()
+
+	health, err := checkSyncHealth(ctx, p, "token")
+	require.NoError(t, err)
+	require.True(t, health.StateReported)
+
+	// Another monitoring token is refused
+	_, err = checkSyncHealth(ctx, p, "other")
+	require.Error(t, err)
+
+	// A sync master that is not leading does not report the state
+	leading = false
+	health, err = checkSyncHealth(ctx, p, "token")
+	require.NoError(t, err)
+	require.False(t, health.StateReported)
+
+	// Broken synchronization
+	leading, syncStatus = true, syncStatusFailed
+	_, err = checkSyncHealth(ctx, p, "token")
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/arangodb-helper/arangodb/client"
+)
+
+// switchToUpgradeTarget lets this starter switch to the target of the given plan.
+// The target used before is recorded in the entries of the plan (see fromTargetOf),
+// so the upgrade can be rolled back.
+func (m *upgradeManager) switchToUpgradeTarget(ctx context.Context, plan UpgradePlan) error {
+	if _, err := m.upgradeManagerContext.SwitchArangodBinary(ctx, plan.Target, false); err != nil {
+		return errors.Wrap(err, "Failed to switch to arangod executable (or image) to upgrade to")
+	}
+	return nil
+}
+
+// fromTargetOf returns the arangod executable (or docker image) the given peer used
+// before it switched to the target of the plan, as recorded in an entry of that peer
+// that has already been started.
+// Returns an empty target when there is no such entry.
+func (p UpgradePlan) fromTargetOf(peerID string) client.UpgradeTarget {
+	entries := append(append([]UpgradePlanEntry{}, p.FinishedEntries...), p.Entries...)
+	for _, e := range entries {
+		if e.PeerID == peerID && !e.FromTarget.IsEmpty() {
+			return e.FromTarget
+		}
+	}
+	return client.UpgradeTarget{}
+}
+
+// switchToRollbackTarget lets this starter switch back to the arangod executable (or docker image)
+// used before the upgrade by the server of the given rollback entry.
+// The executable (or image) must still have the version used before the upgrade.
+// It is not the case when e.g. the executable has been replaced by a package upgrade.
+func (m *upgradeManager) switchToRollbackTarget(ctx context.Context, entry UpgradePlanEntry) error {
+	checked, err := m.upgradeManagerContext.SwitchArangodBinary(ctx, entry.FromTarget, true)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check arangod executable (or image) used before the upgrade")
+	}
+	if checked.Version.CompareTo(entry.FromVersion) != 0 {
+		return maskAny(fmt.Errorf("The arangod executable (or image) used before the upgrade has version %s instead of %s", checked.Version, entry.FromVersion))
+	}
+	if _, err := m.upgradeManagerContext.SwitchArangodBinary(ctx, entry.FromTarget, false); err != nil {
+		return errors.Wrap(err, "Failed to switch back to arangod executable (or image) used before the upgrade")
+	}
+	return nil
+}
@@ -54,10 +54,17 @@ func (s *Service) DatabaseVersion(ctx context.Context) (driver.Version, bool, er
 }
 
 func (s *Service) databaseVersion(ctx context.Context) (driver.Version, bool, error) {
+	runner, config := withArangodBinary(s.runner, s.cfg, s.ArangodBinary())
+	return databaseVersionOf(ctx, runner, config.ArangodPath)
+}
+
+// databaseVersionOf returns the version of the `arangod` binary at the given path,
+// started with the given runner.
+func databaseVersionOf(ctx context.Context, runner Runner, arangodPath string) (driver.Version, bool, error) {
 	// Start process to print version info
 	output := &bytes.Buffer{}
 	containerName := "arangodb-versioncheck-" + strings.ToLower(uniuri.NewLen(6))
-	p, err := s.runner.Start(ctx, definitions.ProcessTypeArangod, s.cfg.ArangodPath, []string{"--version"}, nil, nil, nil, containerName, ".", output)
+	p, err := runner.Start(ctx, definitions.ProcessTypeArangod, arangodPath, []string{"--version"}, nil, nil, nil, containerName, ".", output)
 	if err != nil {
 		return "", false, maskAny(err)
 	}
@@ -63,7 +63,7 @@ func cmdStartRun(cmd *cobra.Command, args []string) {
 	log.Info().Msgf("Starting %s version %s, build %s in the background", projectName, projectVersion, projectBuild)
 
 	// Build service
-	service, bsCfg := mustPrepareService(true)
+	service, bsCfg := mustPrepareService(false)
 
 	// Find executable
 	exePath, err := os.Executable()
@@ -103,7 +103,7 @@ func cmdStartRun(cmd *cobra.Command, args []string) {
 			}
 		}
 	})
-	if bsCfg.SslKeyFile != "" {
+	if bsCfg.SslKeyFile != "" && !bsCfg.SslAutoCA {
 		childArgs = append(childArgs, "--ssl.keyfile="+bsCfg.SslKeyFile)
 	}
 
@@ -125,7 +125,7 @@ func cmdStartRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create starter URL")
 	}
-	client, err := client.NewArangoStarterClient(*starterURL)
+	client, err := client.NewArangoStarterClient(*starterURL, mustCreateStarterClientOptions(jwtSecretFile, "")...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create starter client")
 	}
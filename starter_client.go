@@ -0,0 +1,87 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/service"
+)
+
+var (
+	starterAuthOptions struct {
+		jwtSecretFile string
+		token         string
+	}
+)
+
+// mustCreateStarterClient creates a client for a starter at the given endpoint.
+// Any errors cause the process to exit.
+func mustCreateStarterClient(endpoint string) client.API {
+	// Check options
+	if endpoint == "" {
+		log.Fatal().Msg("--starter.endpoint must be set")
+	}
+	ep, err := url.Parse(endpoint)
+	if err != nil {
+		log.Fatal().Err(err).Msg("--starter.endpoint is invalid")
+	}
+
+	// Create starter client
+	c, err := client.NewArangoStarterClient(*ep, mustCreateStarterClientOptions(starterAuthOptions.jwtSecretFile, starterAuthOptions.token)...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create Starter client")
+	}
+	return c
+}
+
+// addStarterAuthFlags adds the flags used to authenticate with the starter API to the given flag set.
+func addStarterAuthFlags(f *pflag.FlagSet) {
+	f.StringVar(&starterAuthOptions.jwtSecretFile, "auth.jwt-secret", "", "name of a plain text file containing the JWT secret used to create a token for the starter API")
+	f.StringVar(&starterAuthOptions.token, "auth.token", "", "JWT token used to authenticate with the starter API")
+}
+
+// mustCreateStarterClientOptions returns the options for a starter client that authenticates
+// using the given token or using tokens created with the JWT secret in the given file.
+// Any errors cause the process to exit.
+func mustCreateStarterClientOptions(jwtSecretFile, token string) []client.ClientOption {
+	if token != "" {
+		return []client.ClientOption{client.WithBearerToken(token)}
+	}
+	if jwtSecretFile == "" {
+		return nil
+	}
+	jwtSecretFile = mustExpand(jwtSecretFile)
+	content, err := ioutil.ReadFile(jwtSecretFile)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed to read JWT secret file '%s'", jwtSecretFile)
+	}
+	jwtSecret := strings.TrimSpace(string(content))
+	return []client.ClientOption{client.WithTokenSource(func() (string, error) {
+		return service.CreateStarterToken(jwtSecret, "", service.StarterRoleAdmin, time.Minute)
+	})}
+}
@@ -0,0 +1,215 @@
+//
+// DISCLAIMER
+//
+// Copyright 2021 ArangoDB GmbH, Cologne, Germany
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Copyright holder is ArangoDB GmbH, Cologne, Germany
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/arangodb-helper/arangodb/client"
+	"github.com/arangodb-helper/arangodb/pkg/definitions"
+)
+
+var (
+	cmdStatus = &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of all starters and servers of the cluster",
+		Long: "Show the status of all starters and servers of the cluster.\n" +
+			"It shows the version, process, health and upgrade state of every server.",
+		Run: cmdStatusRun,
+	}
+	statusOptions struct {
+		starterEndpoint string
+		output          string
+	}
+)
+
+func init() {
+	f := cmdStatus.Flags()
+	f.StringVar(&statusOptions.starterEndpoint, "starter.endpoint", "http://localhost:8528", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	f.StringVar(&statusOptions.output, "output", "text", "Output format (text|json)")
+	addStarterAuthFlags(f)
+
+	cmdMain.AddCommand(cmdStatus)
+}
+
+func cmdStatusRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	if statusOptions.output != "text" && statusOptions.output != "json" {
+		log.Fatal().Msgf("Unsupported output format '%s', expected text or json", statusOptions.output)
+	}
+
+	// Create starter client
+	c := mustCreateStarterClient(statusOptions.starterEndpoint)
+	ctx := context.Background()
+	status, err := c.ClusterStatus(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to fetch cluster status")
+	}
+
+	// Add versions, processes & upgrade state
+	inventory, err := c.ClusterInventory(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch cluster inventory, versions are not shown")
+	}
+	upgradeStatus, err := c.UpgradeStatus(ctx)
+	if err != nil && !client.IsNotFound(err) {
+		log.Debug().Err(err).Msg("Failed to fetch upgrade status")
+	}
+	for i, p := range status.Peers {
+		peerStatus := &status.Peers[i]
+		processes, err := fetchPeerProcesses(ctx, p.Endpoint)
+		if err != nil {
+			peerStatus.Error = err.Error()
+		}
+		for j, srv := range p.Servers {
+			srvStatus := &peerStatus.Servers[j]
+			if member, found := inventory.Peers[p.ID].Members[definitions.ServerType(srv.Type)]; found {
+				srvStatus.Version = member.Version.Version
+			}
+			if sp, found := processes.ServerByType(srv.Type); found {
+				srvStatus.Up = sp.Running && sp.Up
+				srvStatus.ProcessID = sp.ProcessID
+				srvStatus.ContainerID = sp.ContainerID
+				srvStatus.Restarts = sp.Restarts
+				srvStatus.Failed = sp.Failed
+			}
+			srvStatus.Upgrade = serverUpgradeState(upgradeStatus, srv)
+		}
+	}
+
+	if statusOptions.output == "json" {
+		b, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to encode cluster status")
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	log.Info().Msgf("Mode: %s", status.Mode)
+	if status.HealthError != "" {
+		log.Warn().Msgf("Health of servers is unknown: %s", status.HealthError)
+	}
+	rows := []string{"Peer | Type | Address | Version | PID/Container | Up | Health | Leader | Restarts | Upgrade"}
+	for _, p := range status.Peers {
+		peerID := p.ID
+		if p.IsMaster {
+			peerID += " (master)"
+		}
+		if p.Error != "" {
+			rows = append(rows, fmt.Sprintf("%s | - | %s | - | - | - | - | - | - | %s", peerID, p.Endpoint, p.Error))
+		}
+		for _, srv := range p.Servers {
+			process := "-"
+			if srv.ContainerID != "" {
+				process = shortContainerID(srv.ContainerID)
+			} else if srv.ProcessID != 0 {
+				process = strconv.Itoa(srv.ProcessID)
+			}
+			rows = append(rows, fmt.Sprintf("%s | %s | %s:%d | %s | %s | %s | %s | %s | %d | %s", peerID, srv.Type,
+				srv.Address, srv.Port, valueOrDash(string(srv.Version)), process, yesNo(srv.Up),
+				valueOrDash(srv.Health), yesNo(srv.Leader), srv.Restarts, valueOrDash(srv.Upgrade)))
+		}
+	}
+	for _, r := range strings.Split(columnize.SimpleFormat(rows), "\n") {
+		log.Info().Msg(r)
+	}
+}
+
+// fetchPeerProcesses returns the servers started by the starter at given endpoint.
+func fetchPeerProcesses(ctx context.Context, endpoint string) (client.ProcessList, error) {
+	ep, err := url.Parse(endpoint)
+	if err != nil {
+		return client.ProcessList{}, maskAny(err)
+	}
+	c, err := client.NewArangoStarterClient(*ep, mustCreateStarterClientOptions(starterAuthOptions.jwtSecretFile, starterAuthOptions.token)...)
+	if err != nil {
+		return client.ProcessList{}, maskAny(err)
+	}
+	processes, err := c.Processes(ctx)
+	if err != nil {
+		return client.ProcessList{}, maskAny(err)
+	}
+	return processes, nil
+}
+
+// serverUpgradeState returns the state of the given server in the given upgrade status,
+// or an empty string when the server is not part of an upgrade.
+func serverUpgradeState(status client.UpgradeStatus, srv client.ServerStatus) string {
+	matches := func(s client.UpgradeStatusServer) bool {
+		return s.Type == srv.Type && s.Address == srv.Address && s.Port == srv.Port
+	}
+	if status.Ready {
+		return ""
+	}
+	for _, s := range status.ServersRolledBack {
+		if matches(s) {
+			return "rolled-back"
+		}
+	}
+	for _, s := range status.ServersUpgraded {
+		if matches(s) {
+			return "upgraded"
+		}
+	}
+	for _, s := range status.ServersRemaining {
+		if matches(s) {
+			if s.Phase != "" {
+				return fmt.Sprintf("upgrading (%s)", s.Phase)
+			}
+			return "remaining"
+		}
+	}
+	return ""
+}
+
+// shortContainerID returns the first 12 characters of the given docker container ID.
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func valueOrDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
@@ -24,12 +24,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"net/url"
+	"os"
+	"os/user"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 
 	"github.com/arangodb-helper/arangodb/client"
@@ -41,6 +44,21 @@ var (
 		Short: "Upgrade an ArangoDB deployment to a new version",
 		Run:   cmdUpgradeRun,
 	}
+	cmdUpgradeHistory = &cobra.Command{
+		Use:   "history",
+		Short: "Show the finished, aborted & rolled back upgrades of an ArangoDB deployment",
+		Run:   cmdUpgradeHistoryRun,
+	}
+	cmdUpgradePause = &cobra.Command{
+		Use:   "pause",
+		Short: "Pause an upgrade of an ArangoDB deployment after the server that is currently being upgraded",
+		Run:   cmdUpgradePauseRun,
+	}
+	cmdUpgradeResume = &cobra.Command{
+		Use:   "resume",
+		Short: "Resume a paused upgrade of an ArangoDB deployment",
+		Run:   cmdUpgradeResumeRun,
+	}
 	cmdRetry = &cobra.Command{
 		Use:   "retry",
 		Short: "Retry an operation",
@@ -61,9 +79,29 @@ var (
 		Short: "Abort (or remove) an upgrade of an ArangoDB deployment to a new version",
 		Run:   cmdAbortUpgradeRun,
 	}
+	cmdRollback = &cobra.Command{
+		Use:   "rollback",
+		Short: "Rollback an operation",
+		Run:   cmdShowUsage,
+	}
+	cmdRollbackUpgrade = &cobra.Command{
+		Use:   "upgrade",
+		Short: "Rollback the servers upgraded by a failed upgrade to the version they had before",
+		Run:   cmdRollbackUpgradeRun,
+	}
 	upgradeOptions struct {
 		starterEndpoint   string
 		forceMinorUpgrade bool
+		dryRun            bool
+		arangodPath       string
+		image             string
+	}
+	upgradeHistoryOptions struct {
+		starterEndpoint string
+		output          string
+	}
+	pauseUpgradeOptions struct {
+		starterEndpoint string
 	}
 	retryUpgradeOptions struct {
 		starterEndpoint string
@@ -71,12 +109,28 @@ var (
 	abortUpgradeOptions struct {
 		starterEndpoint string
 	}
+	rollbackUpgradeOptions struct {
+		starterEndpoint string
+	}
 )
 
 func init() {
 	f := cmdUpgrade.Flags()
 	f.StringVar(&upgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
 	f.BoolVar(&upgradeOptions.forceMinorUpgrade, "starter.force-minor-upgrade", false, "Ignore minor version check")
+	f.BoolVar(&upgradeOptions.dryRun, "dry-run", false, "Run all checks and show the upgrade plan without starting the upgrade")
+	f.StringVar(&upgradeOptions.arangodPath, "arangod", "", "Path of the arangod executable to upgrade to (process runner only). All starters switch to it before upgrading")
+	f.StringVar(&upgradeOptions.image, "image", "", "Docker image to upgrade to (docker runner only). All starters switch to it before upgrading")
+
+	f = cmdUpgradeHistory.Flags()
+	f.StringVar(&upgradeHistoryOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+	f.StringVar(&upgradeHistoryOptions.output, "output", "text", "Output format (text|json)")
+
+	f = cmdUpgradePause.Flags()
+	f.StringVar(&pauseUpgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+
+	f = cmdUpgradeResume.Flags()
+	f.StringVar(&pauseUpgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
 
 	f = cmdRetryUpgrade.Flags()
 	f.StringVar(&retryUpgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
@@ -84,19 +138,130 @@ func init() {
 	f = cmdAbortUpgrade.Flags()
 	f.StringVar(&abortUpgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
 
+	f = cmdRollbackUpgrade.Flags()
+	f.StringVar(&rollbackUpgradeOptions.starterEndpoint, "starter.endpoint", "", "The endpoint of the starter to connect to. E.g. http://localhost:8528")
+
+	addStarterAuthFlags(cmdUpgrade.Flags())
+	addStarterAuthFlags(cmdUpgradeHistory.Flags())
+	addStarterAuthFlags(cmdUpgradePause.Flags())
+	addStarterAuthFlags(cmdUpgradeResume.Flags())
+	addStarterAuthFlags(cmdRetryUpgrade.Flags())
+	addStarterAuthFlags(cmdAbortUpgrade.Flags())
+	addStarterAuthFlags(cmdRollbackUpgrade.Flags())
+
 	cmdMain.AddCommand(cmdUpgrade)
+	cmdUpgrade.AddCommand(cmdUpgradeHistory)
+	cmdUpgrade.AddCommand(cmdUpgradePause)
+	cmdUpgrade.AddCommand(cmdUpgradeResume)
 	cmdMain.AddCommand(cmdRetry)
 	cmdRetry.AddCommand(cmdRetryUpgrade)
 	cmdMain.AddCommand(cmdAbort)
 	cmdAbort.AddCommand(cmdAbortUpgrade)
+	cmdMain.AddCommand(cmdRollback)
+	cmdRollback.AddCommand(cmdRollbackUpgrade)
 }
 
 func cmdUpgradeRun(cmd *cobra.Command, args []string) {
-	runUpgrade(upgradeOptions.starterEndpoint, upgradeOptions.forceMinorUpgrade, false)
+	target := client.UpgradeTarget{
+		ArangodPath: upgradeOptions.arangodPath,
+		Image:       upgradeOptions.image,
+	}
+	if upgradeOptions.dryRun {
+		runUpgradeDryRun(upgradeOptions.starterEndpoint, upgradeOptions.forceMinorUpgrade, target)
+		return
+	}
+	runUpgrade(upgradeOptions.starterEndpoint, upgradeOptions.forceMinorUpgrade, false, target)
+}
+
+func cmdUpgradeHistoryRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	if upgradeHistoryOptions.output != "text" && upgradeHistoryOptions.output != "json" {
+		log.Fatal().Msgf("Unsupported output format '%s', expected text or json", upgradeHistoryOptions.output)
+	}
+
+	// Create starter client
+	c := mustCreateStarterClient(upgradeHistoryOptions.starterEndpoint)
+	ctx := context.Background()
+	history, err := c.UpgradeHistory(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to fetch database automatic upgrade history")
+	}
+
+	if upgradeHistoryOptions.output == "json" {
+		b, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to encode database automatic upgrade history")
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	if len(history.Plans) == 0 {
+		log.Info().Msg("No database automatic upgrades have been archived")
+		return
+	}
+	rows := []string{"ID | Outcome | From | To | Triggered by | Created | Archived | Servers | Failures"}
+	for _, p := range history.Plans {
+		fromVersions := make([]string, 0, len(p.FromVersions))
+		for _, v := range p.FromVersions {
+			fromVersions = append(fromVersions, string(v))
+		}
+		upgraded, failures := 0, 0
+		for _, e := range p.Entries {
+			if e.Upgraded {
+				upgraded++
+			}
+			failures += len(e.Failures)
+		}
+		rows = append(rows, fmt.Sprintf("%s | %s | %s | %s | %s | %s | %s | %d/%d | %d", p.ID, p.Outcome,
+			strings.Join(fromVersions, ", "), p.ToVersion, p.TriggeredBy,
+			p.CreatedAt.Local().Format(time.RFC3339), p.ArchivedAt.Local().Format(time.RFC3339),
+			upgraded, len(p.Entries), failures))
+	}
+	for _, r := range strings.Split(columnize.SimpleFormat(rows), "\n") {
+		log.Info().Msg(r)
+	}
+}
+
+func cmdUpgradePauseRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(pauseUpgradeOptions.starterEndpoint)
+	ctx := context.Background()
+	if err := c.PauseDatabaseUpgrade(ctx); client.IsNotFound(err) {
+		log.Fatal().Msg("Database automatic upgrade plan does not exist")
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Failed to pause database automatic upgrade")
+	}
+	log.Info().Msg("Database automatic upgrade has been paused, it stops after the server that is currently being upgraded")
+	log.Info().Msg("Use `arangodb upgrade resume` to continue the upgrade")
+}
+
+func cmdUpgradeResumeRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(pauseUpgradeOptions.starterEndpoint)
+	ctx := context.Background()
+	if err := c.ResumeDatabaseUpgrade(ctx); client.IsNotFound(err) {
+		log.Fatal().Msg("Database automatic upgrade plan does not exist")
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Failed to resume database automatic upgrade")
+	}
+	log.Info().Msg("Database automatic upgrade has been resumed")
+	waitForUpgrade(ctx, c)
 }
 
 func cmdRetryUpgradeRun(cmd *cobra.Command, args []string) {
-	runUpgrade(retryUpgradeOptions.starterEndpoint, false, true)
+	runUpgrade(retryUpgradeOptions.starterEndpoint, false, true, client.UpgradeTarget{})
 }
 
 func cmdAbortUpgradeRun(cmd *cobra.Command, args []string) {
@@ -116,7 +281,61 @@ func cmdAbortUpgradeRun(cmd *cobra.Command, args []string) {
 	}
 }
 
-func runUpgrade(starterEndpoint string, forceMinorUpgrade, retry bool) {
+func cmdRollbackUpgradeRun(cmd *cobra.Command, args []string) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(rollbackUpgradeOptions.starterEndpoint)
+	ctx := context.Background()
+	if err := c.RollbackDatabaseUpgrade(ctx); client.IsNotFound(err) {
+		log.Fatal().Msg("Database automatic upgrade plan does not exist")
+	} else if err != nil {
+		log.Fatal().Err(err).Msg("Failed to rollback database automatic upgrade")
+	}
+	log.Info().Msg("Database automatic upgrade is being rolled back")
+	waitForUpgrade(ctx, c)
+}
+
+// runUpgradeDryRun shows the plan an upgrade would use, without starting the upgrade.
+func runUpgradeDryRun(starterEndpoint string, forceMinorUpgrade bool, target client.UpgradeTarget) {
+	// Setup logging
+	consoleOnly := true
+	configureLogging(consoleOnly)
+
+	// Create starter client
+	c := mustCreateStarterClient(starterEndpoint)
+	ctx := context.Background()
+	plan, err := c.DryRunDatabaseUpgrade(ctx, forceMinorUpgrade, target)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Database automatic upgrade checks failed")
+	}
+
+	fromVersions := make([]string, 0, len(plan.FromVersions))
+	for _, v := range plan.FromVersions {
+		fromVersions = append(fromVersions, string(v))
+	}
+	log.Info().Msgf("Database automatic upgrade from %s to version %s passed all checks", strings.Join(fromVersions, ", "), plan.ToVersion)
+	if binary := upgradeTargetDescription(target); binary != "" {
+		log.Info().Msgf("All starters will switch to %s before upgrading", binary)
+	}
+	rows := []string{"# | Type | Address | Port | Peer | Restarts"}
+	for i, e := range plan.Entries {
+		rows = append(rows, fmt.Sprintf("%d | %s | %s | %d | %s | %d", i+1, e.Type, e.Address, e.Port, e.PeerID, e.Restarts))
+	}
+	log.Info().Msg("Upgrade plan:")
+	for _, r := range strings.Split(columnize.SimpleFormat(rows), "\n") {
+		log.Info().Msg(r)
+	}
+	if plan.SupervisionMaintenance {
+		log.Info().Msg("Agency supervision will be put into maintenance mode while upgrading dbservers & single servers")
+	}
+	log.Info().Msgf("Estimated number of server restarts: %d", plan.Restarts)
+	log.Info().Msg("This was a dry-run, nothing has been changed")
+}
+
+func runUpgrade(starterEndpoint string, forceMinorUpgrade, retry bool, target client.UpgradeTarget) {
 	// Setup logging
 	consoleOnly := true
 	configureLogging(consoleOnly)
@@ -131,7 +350,7 @@ func runUpgrade(starterEndpoint string, forceMinorUpgrade, retry bool) {
 		}
 		action = "restarted"
 	} else {
-		if err := c.StartDatabaseUpgrade(ctx, forceMinorUpgrade); err != nil {
+		if err := c.StartDatabaseUpgradeTo(ctx, forceMinorUpgrade, target, upgradeTriggeredBy()); err != nil {
 			log.Fatal().Err(err).Msg("Failed to start database automatic upgrade")
 		}
 		action = "started"
@@ -155,9 +374,42 @@ func runUpgrade(starterEndpoint string, forceMinorUpgrade, retry bool) {
 		log.Info().Msgf("Database automatic upgrade from %s %s to version %s has been %s", fromVersionPrefix, strings.Join(fromVersions, ", "), status.ToVersion, action)
 	}
 
-	// Wait for the upgrade to finish
+	waitForUpgrade(ctx, c)
+}
+
+// upgradeTargetDescription returns a description of the given target,
+// or an empty string when the target is empty.
+func upgradeTargetDescription(target client.UpgradeTarget) string {
+	switch {
+	case target.Image != "":
+		return fmt.Sprintf("image '%s'", target.Image)
+	case target.ArangodPath != "":
+		return fmt.Sprintf("arangod executable '%s'", target.ArangodPath)
+	}
+	return ""
+}
+
+// upgradeTriggeredBy returns a description of the user starting an upgrade,
+// as it is stored in the upgrade history.
+func upgradeTriggeredBy() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		name = name + "@" + hostname
+	}
+	return name
+}
+
+// waitForUpgrade waits until the upgrade (or its rollback) has finished or failed.
+// When it has finished, the upgrade plan is removed.
+func waitForUpgrade(ctx context.Context, c client.API) {
 	remaining := ""
 	finished := ""
+	progress := ""
+	var progressLoggedAt time.Time
+	paused := false
 	for {
 		status, err := c.UpgradeStatus(ctx)
 		if client.IsNotFound(err) {
@@ -168,27 +420,81 @@ func runUpgrade(starterEndpoint string, forceMinorUpgrade, retry bool) {
 			log.Error().Err(err).Msg("Failed to fetch upgrade status")
 		} else {
 			if status.Failed {
-				log.Error().Str("reason", status.Reason).Msg("Database upgrade has failed")
+				if status.Rollback {
+					log.Error().Str("reason", status.Reason).Msg("Database upgrade rollback has failed")
+				} else {
+					log.Error().Str("reason", status.Reason).Msg("Database upgrade has failed")
+				}
 				return
 			}
 			if status.Ready {
-				log.Info().Msg("Database upgrade has finished")
+				if status.Rollback {
+					log.Info().Msg("Database upgrade rollback has finished")
+				} else {
+					log.Info().Msg("Database upgrade has finished")
+				}
 				// Let's remove the plan now
 				if err := c.AbortDatabaseUpgrade(ctx); err != nil {
 					log.Warn().Err(err).Msg("Failed to remove upgrade plan")
 				}
 				return
 			}
-			r, f := formatServerStatusList(status.ServersRemaining), formatServerStatusList(status.ServersUpgraded)
-			if remaining != r || finished != f {
-				remaining, finished = r, f
-				log.Info().Msgf("Servers upgraded: %s, remaining servers: %s", finished, remaining)
+			if status.Paused != paused {
+				paused = status.Paused
+				if paused {
+					log.Info().Msg("Database upgrade has been paused, use `arangodb upgrade resume` to continue")
+				} else {
+					log.Info().Msg("Database upgrade has been resumed")
+				}
+			}
+			if status.Rollback {
+				r, f := formatServerStatusList(status.ServersRemaining), formatServerStatusList(status.ServersRolledBack)
+				if remaining != r || finished != f {
+					remaining, finished = r, f
+					log.Info().Msgf("Servers rolled back: %s, remaining servers: %s", finished, remaining)
+				}
+			} else {
+				r, f := formatServerStatusList(status.ServersRemaining), formatServerStatusList(status.ServersUpgraded)
+				if remaining != r || finished != f {
+					remaining, finished = r, f
+					log.Info().Msgf("Servers upgraded: %s, remaining servers: %s", finished, remaining)
+				}
+			}
+			// Show what is happening with the server that is being processed.
+			// Repeat it once in a while, so users know the upgrade is still alive.
+			if p, msg := formatUpgradeProgress(status.ServersRemaining); p != "" {
+				if p != progress || time.Since(progressLoggedAt) > time.Second*30 {
+					progress, progressLoggedAt = p, time.Now()
+					log.Info().Msg(msg)
+				}
 			}
 		}
 		time.Sleep(time.Second)
 	}
 }
 
+// formatUpgradeProgress formats the phase & progress of the server that is being upgraded
+// in a human readable format.
+// It returns a key that changes when the phase or progress changes and the message to show.
+// When no server is being upgraded, empty strings are returned.
+func formatUpgradeProgress(list []client.UpgradeStatusServer) (string, string) {
+	for _, s := range list {
+		if s.Phase == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s %s:%d %s %s", s.Type, s.Address, s.Port, s.Phase, s.Progress)
+		msg := fmt.Sprintf("%s on %s:%d is in phase %s", s.Type, s.Address, s.Port, s.Phase)
+		if s.StartedAt != nil {
+			msg = fmt.Sprintf("%s (started %s ago)", msg, time.Since(*s.StartedAt).Round(time.Second))
+		}
+		if s.Progress != "" {
+			msg = fmt.Sprintf("%s: %s", msg, s.Progress)
+		}
+		return key, msg
+	}
+	return "", ""
+}
+
 // formatServerStatusList formats the given server status list in a human readable format.
 func formatServerStatusList(list []client.UpgradeStatusServer) string {
 	counts := make(map[client.ServerType]int)
@@ -214,23 +520,3 @@ func formatServerStatusList(list []client.UpgradeStatusServer) string {
 	})
 	return strings.Join(strList, ", ")
 }
-
-// mustCreateStarterClient creates a client for a starter at the given endpoint.
-// Any errors cause the process to exit.
-func mustCreateStarterClient(endpoint string) client.API {
-	// Check options
-	if endpoint == "" {
-		log.Fatal().Msg("--starter.endpoint must be set")
-	}
-	ep, err := url.Parse(endpoint)
-	if err != nil {
-		log.Fatal().Err(err).Msg("--starter.endpoint is invalid")
-	}
-
-	// Create starter client
-	c, err := client.NewArangoStarterClient(*ep)
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create Starter client")
-	}
-	return c
-}